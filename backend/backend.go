@@ -0,0 +1,269 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+// package backend defines the interfaces that let tt inspect and act on the
+// temporary things that live at the addresses of Things, for each ThingsType.
+
+package backend
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"os/user"
+	"slices"
+	"strings"
+
+	"github.com/wtsi-hgi/tt/database"
+)
+
+const (
+	ErrNotOwner     = database.Error("You do not own, and can not write to, that address")
+	ErrAddressInUse = database.Error("Something now exists at the thing's address, so it can't be restored")
+)
+
+// Backend knows how to deal with Things of a particular ThingsType, and is
+// registered as part of its database.ThingsTypeInfo. Optional capabilities are
+// provided by also implementing the other interfaces in this package.
+type Backend = database.Backend
+
+// Prober is a Backend that can measure what exists at a thing's address.
+type Prober interface {
+	Backend
+
+	// Probe returns the size, number of files and latest modification time of
+	// what exists at the thing's address. If nothing exists there, returns a
+	// result with Exists false, and no error.
+	Probe(ctx context.Context, thing *database.Thing) (*database.ProbeResult, error)
+}
+
+// Fingerprinter is a Backend that can snapshot what exists at a thing's
+// address, so that tt can refuse to remove it if it has materially changed
+// since the thing was registered.
+type Fingerprinter interface {
+	Backend
+
+	// Fingerprint returns a Fingerprint of what exists at the thing's address,
+	// or nil if nothing exists there.
+	Fingerprint(ctx context.Context, thing *database.Thing) (*database.Fingerprint, error)
+}
+
+// RootChecker is a Backend that can tell if the location a thing's address is
+// in (eg. its parent directory, and so the filesystem mounted there) is
+// available, so that its things aren't mistaken for having been removed when it
+// isn't.
+type RootChecker interface {
+	Backend
+
+	// RootExists returns true if the location the thing's address is in
+	// exists.
+	RootExists(ctx context.Context, thing *database.Thing) (bool, error)
+}
+
+// Remover is a Backend that can remove what exists at a thing's address.
+type Remover interface {
+	Backend
+
+	// Remove deletes what exists at the thing's address. It is not an error if
+	// nothing exists there. Progress can be reported to ProgressFrom(ctx).
+	Remove(ctx context.Context, thing *database.Thing) error
+}
+
+// Registrar is a Backend that needs to act on what exists at a thing's address
+// when the thing is registered with tt, eg. to label it with the thing's ID and
+// removal date.
+type Registrar interface {
+	Backend
+
+	// Register is called after the thing has been created in the database, and
+	// again whenever its removal date is changed, so must be idempotent.
+	Register(ctx context.Context, thing *database.Thing) error
+}
+
+// Quarantiner is a Backend that, instead of removing what exists at a thing's
+// address straight away, can first move it out of the way to a quarantine
+// location, from where it can either be restored or permanently removed.
+type Quarantiner interface {
+	Backend
+
+	// Quarantine moves what exists at the thing's address to a quarantine
+	// location, and returns that location.
+	Quarantine(ctx context.Context, thing *database.Thing) (string, error)
+
+	// Restore moves the thing's data from its Quarantine location back to its
+	// address.
+	Restore(ctx context.Context, thing *database.Thing) error
+
+	// Purge permanently removes the thing's data from its Quarantine location.
+	// It is not an error if nothing exists there. Progress can be reported to
+	// ProgressFrom(ctx).
+	Purge(ctx context.Context, thing *database.Thing) error
+}
+
+// Archiver is a Backend that can pack what exists at a thing's address in to an
+// archive, so that it can be recovered after the thing is removed.
+type Archiver interface {
+	Backend
+
+	// Archive packs what exists at the thing's address in to an archive, then
+	// removes it from the address. Returns the path of the archive, even if
+	// removal then fails. Must not replace an existing archive. Progress
+	// removing it can be reported to ProgressFrom(ctx).
+	Archive(ctx context.Context, thing *database.Thing) (string, error)
+}
+
+// ArchiveChecker is an Archiver that can only archive things once it has been
+// configured to, eg. with somewhere to put the archives.
+type ArchiveChecker interface {
+	Archiver
+
+	// CanArchive returns true if Archive() has been configured, so could
+	// succeed.
+	CanArchive() bool
+}
+
+// Identity describes a Unix user.
+type Identity struct {
+	Username string
+	UID      string
+	GIDs     []string
+}
+
+// LookupIdentity returns the Identity of the Unix user with the given username.
+func LookupIdentity(username string) (*Identity, error) {
+	u, err := user.Lookup(username)
+	if err != nil {
+		return nil, err
+	}
+
+	gids, err := u.GroupIds()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Identity{Username: username, UID: u.Uid, GIDs: gids}, nil
+}
+
+// GroupMembers returns the names of the users listed as members of the Unix
+// group with the given name by `getent group`, which doesn't include users
+// whose primary group it is unless they're also listed.
+func GroupMembers(group string) ([]string, error) {
+	out, err := exec.Command("getent", "group", group).Output()
+	if err != nil {
+		return nil, fmt.Errorf("getent group %s failed: %w", group, err)
+	}
+
+	fields := strings.Split(strings.TrimSpace(string(out)), ":")
+	if len(fields) < 4 || fields[3] == "" {
+		return nil, nil
+	}
+
+	return strings.Split(fields[3], ","), nil
+}
+
+// InGroup returns true if the Identity is a member of the given group ID.
+func (i *Identity) InGroup(gid string) bool {
+	return slices.Contains(i.GIDs, gid)
+}
+
+// OwnerVerifier is a Backend that can check if a user is entitled to register
+// the thing at an address, and so have it removed.
+type OwnerVerifier interface {
+	Backend
+
+	// VerifyOwner returns nil if the given user (or one of their groups) owns
+	// or can write to the thing's address. Otherwise returns an error that Is()
+	// ErrNotOwner.
+	VerifyOwner(ctx context.Context, thing *database.Thing, identity *Identity) error
+}
+
+// ProberFor returns the Backend registered for the given ThingsType if it is a
+// Prober.
+func ProberFor(thingsType database.ThingsType) (Prober, bool) {
+	p, ok := thingsType.Backend().(Prober)
+
+	return p, ok
+}
+
+// FingerprinterFor returns the Backend registered for the given ThingsType if
+// it is a Fingerprinter.
+func FingerprinterFor(thingsType database.ThingsType) (Fingerprinter, bool) {
+	f, ok := thingsType.Backend().(Fingerprinter)
+
+	return f, ok
+}
+
+// OwnerVerifierFor returns the Backend registered for the given ThingsType if
+// it is an OwnerVerifier.
+func OwnerVerifierFor(thingsType database.ThingsType) (OwnerVerifier, bool) {
+	v, ok := thingsType.Backend().(OwnerVerifier)
+
+	return v, ok
+}
+
+// RootCheckerFor returns the Backend registered for the given ThingsType if it
+// is a RootChecker.
+func RootCheckerFor(thingsType database.ThingsType) (RootChecker, bool) {
+	r, ok := thingsType.Backend().(RootChecker)
+
+	return r, ok
+}
+
+// RemoverFor returns the Backend registered for the given ThingsType if it is a
+// Remover.
+func RemoverFor(thingsType database.ThingsType) (Remover, bool) {
+	r, ok := thingsType.Backend().(Remover)
+
+	return r, ok
+}
+
+// QuarantinerFor returns the Backend registered for the given ThingsType if it
+// is a Quarantiner.
+func QuarantinerFor(thingsType database.ThingsType) (Quarantiner, bool) {
+	q, ok := thingsType.Backend().(Quarantiner)
+
+	return q, ok
+}
+
+// ArchiverFor returns the Backend registered for the given ThingsType if it is
+// an Archiver, and isn't an ArchiveChecker that hasn't been configured to
+// archive.
+func ArchiverFor(thingsType database.ThingsType) (Archiver, bool) {
+	a, ok := thingsType.Backend().(Archiver)
+
+	if c, isChecker := a.(ArchiveChecker); isChecker && !c.CanArchive() {
+		return nil, false
+	}
+
+	return a, ok
+}
+
+// RegistrarFor returns the Backend registered for the given ThingsType if it is
+// a Registrar.
+func RegistrarFor(thingsType database.ThingsType) (Registrar, bool) {
+	r, ok := thingsType.Backend().(Registrar)
+
+	return r, ok
+}
@@ -0,0 +1,197 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package backend
+
+import (
+	"context"
+	"os"
+	"os/user"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/wtsi-hgi/tt/database"
+)
+
+type existsBackend struct{}
+
+func (existsBackend) Exists(context.Context, *database.Thing) (bool, error) { return true, nil }
+
+type proberBackend struct{ existsBackend }
+
+func (proberBackend) Probe(context.Context, *database.Thing) (*database.ProbeResult, error) {
+	return &database.ProbeResult{Exists: true}, nil
+}
+
+type removerBackend struct{ existsBackend }
+
+func (removerBackend) Remove(context.Context, *database.Thing) error { return nil }
+
+func (removerBackend) Register(context.Context, *database.Thing) error { return nil }
+
+type archiverBackend struct {
+	existsBackend
+	configured bool
+}
+
+func (archiverBackend) Archive(context.Context, *database.Thing) (string, error) { return "", nil }
+
+func (a archiverBackend) CanArchive() bool { return a.configured }
+
+func TestBackendFor(t *testing.T) {
+	Convey("You can get the capabilities of the backend registered for a ThingsType", t, func() {
+		prober := database.ThingsType("backend-test-prober")
+		remover := database.ThingsType("backend-test-remover")
+		note := database.ThingsType("backend-test-note")
+		archiver := database.ThingsType("backend-test-archiver")
+		configured := database.ThingsType("backend-test-configured")
+
+		for name, backend := range map[database.ThingsType]Backend{
+			prober:     proberBackend{},
+			remover:    removerBackend{},
+			note:       nil,
+			archiver:   archiverBackend{},
+			configured: archiverBackend{configured: true},
+		} {
+			err := database.RegisterThingsType(database.ThingsTypeInfo{Name: name, Backend: backend})
+			So(err, ShouldBeNil)
+		}
+
+		_, ok := ProberFor(prober)
+		So(ok, ShouldBeTrue)
+
+		_, ok = ProberFor(remover)
+		So(ok, ShouldBeFalse)
+
+		_, ok = ProberFor(note)
+		So(ok, ShouldBeFalse)
+
+		_, ok = ProberFor("backend-test-unregistered")
+		So(ok, ShouldBeFalse)
+
+		_, ok = FingerprinterFor(prober)
+		So(ok, ShouldBeFalse)
+
+		_, ok = OwnerVerifierFor(prober)
+		So(ok, ShouldBeFalse)
+
+		_, ok = RemoverFor(remover)
+		So(ok, ShouldBeTrue)
+
+		_, ok = RegistrarFor(remover)
+		So(ok, ShouldBeTrue)
+
+		_, ok = RemoverFor(prober)
+		So(ok, ShouldBeFalse)
+
+		_, ok = QuarantinerFor(remover)
+		So(ok, ShouldBeFalse)
+
+		_, ok = ArchiverFor(prober)
+		So(ok, ShouldBeFalse)
+
+		_, ok = ArchiverFor(archiver)
+		So(ok, ShouldBeFalse)
+
+		_, ok = ArchiverFor(configured)
+		So(ok, ShouldBeTrue)
+
+		_, ok = RootCheckerFor(prober)
+		So(ok, ShouldBeFalse)
+
+		Convey("and change it once it has been configured", func() {
+			previous, err := database.SetBackend(note, removerBackend{})
+			So(err, ShouldBeNil)
+			So(previous, ShouldBeNil)
+
+			_, ok = RemoverFor(note)
+			So(ok, ShouldBeTrue)
+
+			_, err = database.SetBackend("backend-test-unregistered", removerBackend{})
+			So(err, ShouldEqual, database.ErrBadType)
+		})
+	})
+}
+
+func TestIdentity(t *testing.T) {
+	Convey("You can look up the Identity of a user", t, func() {
+		u, err := user.Current()
+		So(err, ShouldBeNil)
+
+		identity, err := LookupIdentity(u.Username)
+		So(err, ShouldBeNil)
+		So(identity.Username, ShouldEqual, u.Username)
+		So(identity.UID, ShouldEqual, u.Uid)
+		So(identity.InGroup(u.Gid), ShouldBeTrue)
+		So(identity.InGroup("-1"), ShouldBeFalse)
+
+		_, err = LookupIdentity("tt-no-such-user")
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("You can get the listed members of a group", t, func() {
+		dir := t.TempDir()
+		script := `#!/bin/sh
+case "$*" in
+"group approvers") echo "approvers:x:100:alice,bob" ;;
+"group empty") echo "empty:x:101:" ;;
+*) exit 2 ;;
+esac
+`
+		So(os.WriteFile(filepath.Join(dir, "getent"), []byte(script), 0o700), ShouldBeNil)
+		t.Setenv("PATH", dir)
+
+		members, err := GroupMembers("approvers")
+		So(err, ShouldBeNil)
+		So(members, ShouldResemble, []string{"alice", "bob"})
+
+		members, err = GroupMembers("empty")
+		So(err, ShouldBeNil)
+		So(members, ShouldBeEmpty)
+
+		_, err = GroupMembers("missing")
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestProgress(t *testing.T) {
+	Convey("Backends can report progress to a Progress carried by a context", t, func() {
+		progress := &Progress{}
+		ctx := WithProgress(context.Background(), progress)
+
+		ProgressFrom(ctx).Removed(2, 100)
+		ProgressFrom(ctx).Removed(1, 10)
+		ProgressFrom(ctx).Failed()
+
+		So(progress.Snapshot(), ShouldResemble, database.RemovalProgress{Files: 3, Bytes: 110, Errors: 1})
+
+		Convey("or to nowhere, if it doesn't carry one", func() {
+			ProgressFrom(context.Background()).Removed(1, 1)
+
+			So(progress.Snapshot().Files, ShouldEqual, 3)
+		})
+	})
+}
@@ -0,0 +1,252 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package fs
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/wtsi-hgi/tt/database"
+)
+
+const (
+	ErrNoArchiveDir  = database.Error("No archive directory has been configured")
+	ErrArchiveExists = database.Error("An archive or manifest for that thing already exists")
+
+	ArchiveSuffix  = ".tar.gz"
+	ManifestSuffix = ".sha256"
+)
+
+// SetArchiveDir sets the directory that Archive() creates archives in.
+func (f *FS) SetArchiveDir(dir string) {
+	f.archiveDir = dir
+}
+
+// CanArchive returns true if SetArchiveDir() has been called with a directory,
+// so that the FS is a backend.ArchiveChecker that only archives once
+// configured.
+func (f *FS) CanArchive() bool {
+	return f.archiveDir != ""
+}
+
+// Archive packs the thing's address in to a gzipped tarball named
+// <thing ID>-<basename>.tar.gz in the configured archive directory, alongside a
+// manifest of the sha256 checksums of the files within, named
+// <thing ID>-<basename>.sha256, which can be checked with `sha256sum -c` after
+// extraction. Once the archive is complete, the address is removed, with
+// progress reported to the backend.Progress in ctx.
+//
+// Returns the path of the tarball (even if removing the address then fails),
+// ErrNoArchiveDir if SetArchiveDir() hasn't been called, or ErrArchiveExists if
+// the tarball or manifest already exist, which are never overwritten.
+func (f *FS) Archive(ctx context.Context, thing *database.Thing) (string, error) {
+	if f.archiveDir == "" {
+		return "", ErrNoArchiveDir
+	}
+
+	name := fmt.Sprintf("%d-%s", thing.ID, filepath.Base(thing.Address))
+	tarPath := filepath.Join(f.archiveDir, name+ArchiveSuffix)
+	manifestPath := filepath.Join(f.archiveDir, name+ManifestSuffix)
+
+	if _, err := os.Lstat(manifestPath); !errors.Is(err, fs.ErrNotExist) {
+		return "", archiveExistsError(err)
+	}
+
+	manifest, err := writeArchive(ctx, thing.Address, tarPath)
+	if err != nil {
+		return "", err
+	}
+
+	if err = writeNewFile(manifestPath, manifest); err != nil {
+		os.Remove(tarPath)
+
+		return "", archiveExistsError(err)
+	}
+
+	return tarPath, removeAll(ctx, thing.Address)
+}
+
+// archiveExistsError returns ErrArchiveExists if err is nil or due to a file
+// existing, otherwise err.
+func archiveExistsError(err error) error {
+	if err == nil || errors.Is(err, fs.ErrExist) {
+		return ErrArchiveExists
+	}
+
+	return err
+}
+
+// writeNewFile writes content to a new file at path, failing if something
+// already exists there.
+func writeNewFile(path, content string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+
+	_, err = file.WriteString(content)
+
+	if errc := file.Close(); err == nil {
+		err = errc
+	}
+
+	return err
+}
+
+// writeArchive creates a gzipped tarball at tarPath containing root, returning
+// its manifest. The tarball is written to a temporary file first, so that it
+// only appears at tarPath if it is complete, and is then hard linked there, so
+// that it doesn't replace anything that already exists at tarPath.
+func writeArchive(ctx context.Context, root, tarPath string) (string, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(tarPath), ".tt-archive-*")
+	if err != nil {
+		return "", err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	gz := gzip.NewWriter(tmp)
+	tw := tar.NewWriter(gz)
+
+	manifest, err := addToArchive(ctx, tw, root)
+	if err == nil {
+		err = tw.Close()
+	}
+
+	if err == nil {
+		err = gz.Close()
+	}
+
+	if errc := tmp.Close(); err == nil {
+		err = errc
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	if err = os.Link(tmp.Name(), tarPath); err != nil {
+		return "", archiveExistsError(err)
+	}
+
+	return manifest, nil
+}
+
+// addToArchive walks root, adding everything in it to tw under the basename of
+// root, and returns a manifest of the sha256 checksums of the regular files.
+func addToArchive(ctx context.Context, tw *tar.Writer, root string) (string, error) {
+	var manifest strings.Builder
+
+	parent := filepath.Dir(root)
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+
+		name, err := filepath.Rel(parent, path)
+		if err != nil {
+			return err
+		}
+
+		sum, err := addEntry(tw, path, filepath.ToSlash(name), d)
+		if err != nil || sum == "" {
+			return err
+		}
+
+		fmt.Fprintf(&manifest, "%s  %s\n", sum, filepath.ToSlash(name))
+
+		return nil
+	})
+
+	return manifest.String(), err
+}
+
+// addEntry adds the given file, dir or symlink to tw with the given name,
+// returning the hex sha256 checksum of its content if it's a regular file.
+func addEntry(tw *tar.Writer, path, name string, d fs.DirEntry) (string, error) {
+	info, err := d.Info()
+	if err != nil {
+		return "", err
+	}
+
+	var link string
+
+	if info.Mode()&fs.ModeSymlink != 0 {
+		if link, err = os.Readlink(path); err != nil {
+			return "", err
+		}
+	}
+
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return "", err
+	}
+
+	header.Name = name
+	if info.IsDir() {
+		header.Name += "/"
+	}
+
+	if err = tw.WriteHeader(header); err != nil || !info.Mode().IsRegular() {
+		return "", err
+	}
+
+	return copyFile(tw, path)
+}
+
+// copyFile copies the content of the file at path to w, returning its hex
+// sha256 checksum.
+func copyFile(w io.Writer, path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+
+	defer file.Close()
+
+	hash := sha256.New()
+
+	if _, err = io.Copy(io.MultiWriter(w, hash), file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,181 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package fs
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/wtsi-hgi/tt/database"
+)
+
+func TestArchive(t *testing.T) {
+	Convey("Given an FS and a directory tree", t, func() {
+		dir := t.TempDir()
+		archiveDir := filepath.Join(dir, "archive")
+		address := filepath.Join(dir, "data")
+
+		So(os.MkdirAll(archiveDir, 0o755), ShouldBeNil)
+		So(os.MkdirAll(filepath.Join(address, "sub"), 0o755), ShouldBeNil)
+		So(os.WriteFile(filepath.Join(address, "a"), []byte("a"), 0o600), ShouldBeNil)
+		So(os.WriteFile(filepath.Join(address, "sub", "b"), []byte("bb"), 0o600), ShouldBeNil)
+		So(os.Symlink("a", filepath.Join(address, "link")), ShouldBeNil)
+
+		f := New(1)
+		ctx := context.Background()
+		thing := &database.Thing{ID: 7, Address: address, Type: database.ThingsTypeDir}
+
+		Convey("You can't archive without an archive dir", func() {
+			So(f.CanArchive(), ShouldBeFalse)
+
+			_, err := f.Archive(ctx, thing)
+			So(err, ShouldEqual, ErrNoArchiveDir)
+		})
+
+		Convey("With an archive dir set, you can archive it", func() {
+			f.SetArchiveDir(archiveDir)
+			So(f.CanArchive(), ShouldBeTrue)
+
+			path, err := f.Archive(ctx, thing)
+			So(err, ShouldBeNil)
+			So(path, ShouldEqual, filepath.Join(archiveDir, "7-data.tar.gz"))
+
+			_, err = os.Stat(address)
+			So(errors.Is(err, os.ErrNotExist), ShouldBeTrue)
+
+			contents := readArchive(path)
+			So(contents, ShouldResemble, map[string]string{
+				"data/":      "",
+				"data/a":     "a",
+				"data/link":  "-> a",
+				"data/sub/":  "",
+				"data/sub/b": "bb",
+			})
+
+			manifest, err := os.ReadFile(filepath.Join(archiveDir, "7-data.sha256"))
+			So(err, ShouldBeNil)
+			So(string(manifest), ShouldEqual, sha256Hex("a")+"  data/a\n"+sha256Hex("bb")+"  data/sub/b\n")
+
+			entries, err := os.ReadDir(archiveDir)
+			So(err, ShouldBeNil)
+			So(len(entries), ShouldEqual, 2)
+		})
+
+		Convey("You can archive single files", func() {
+			f.SetArchiveDir(archiveDir)
+			thing.Address = filepath.Join(address, "sub", "b")
+
+			path, err := f.Archive(ctx, thing)
+			So(err, ShouldBeNil)
+			So(readArchive(path), ShouldResemble, map[string]string{"b": "bb"})
+		})
+
+		Convey("Existing archives and manifests are not overwritten", func() {
+			f.SetArchiveDir(archiveDir)
+
+			for _, suffix := range []string{ArchiveSuffix, ManifestSuffix} {
+				existing := filepath.Join(archiveDir, "7-data"+suffix)
+				So(os.WriteFile(existing, []byte("old"), 0o600), ShouldBeNil)
+
+				_, err := f.Archive(ctx, thing)
+				So(err, ShouldEqual, ErrArchiveExists)
+
+				content, err := os.ReadFile(existing)
+				So(err, ShouldBeNil)
+				So(string(content), ShouldEqual, "old")
+
+				entries, err := os.ReadDir(archiveDir)
+				So(err, ShouldBeNil)
+				So(len(entries), ShouldEqual, 1)
+
+				_, err = os.Stat(address)
+				So(err, ShouldBeNil)
+
+				So(os.Remove(existing), ShouldBeNil)
+			}
+		})
+
+		Convey("Failure to archive leaves the address alone", func() {
+			f.SetArchiveDir(filepath.Join(dir, "missing"))
+
+			_, err := f.Archive(ctx, thing)
+			So(err, ShouldNotBeNil)
+
+			_, err = os.Stat(address)
+			So(err, ShouldBeNil)
+		})
+	})
+}
+
+// readArchive returns the names of the entries in the given gzipped tarball,
+// mapped to their content, or "-> target" for symlinks.
+func readArchive(path string) map[string]string {
+	file, err := os.Open(path)
+	So(err, ShouldBeNil)
+
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	So(err, ShouldBeNil)
+
+	tr := tar.NewReader(gz)
+	contents := make(map[string]string)
+
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		So(err, ShouldBeNil)
+
+		content, err := io.ReadAll(tr)
+		So(err, ShouldBeNil)
+
+		if header.Typeflag == tar.TypeSymlink {
+			content = []byte("-> " + header.Linkname)
+		}
+
+		contents[header.Name] = string(content)
+	}
+
+	return contents
+}
+
+func sha256Hex(content string) string {
+	sum := sha256.Sum256([]byte(content))
+
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,119 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package fs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/wtsi-hgi/tt/database"
+)
+
+// SetListingChecksum sets whether Fingerprint() includes a checksum of the
+// top-level listing of directories (or the name and size of files). It's off by
+// default.
+func (f *FS) SetListingChecksum(on bool) {
+	f.listingChecksum = on
+}
+
+// Fingerprint probes the thing's address, returning its size, number of files
+// and latest modification time as a Fingerprint, along with a checksum of its
+// top-level listing if SetListingChecksum(true) was called. Returns nil if
+// nothing exists there.
+func (f *FS) Fingerprint(ctx context.Context, thing *database.Thing) (*database.Fingerprint, error) {
+	result, err := f.Probe(ctx, thing)
+	if err != nil || !result.Exists {
+		return nil, err
+	}
+
+	fingerprint := &database.Fingerprint{
+		Size:     result.Size,
+		Files:    result.Files,
+		Modified: result.Modified,
+	}
+
+	if f.listingChecksum {
+		fingerprint.Checksum, err = listingChecksum(thing.Address)
+	}
+
+	return fingerprint, err
+}
+
+// listingChecksum returns the hex encoded sha256 checksum of the names, types
+// and (for non-directories) sizes of the entries in the given directory, or of
+// the given path itself if it isn't a directory.
+func listingChecksum(path string) (string, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return "", err
+	}
+
+	infos := []fs.FileInfo{info}
+
+	if info.IsDir() {
+		if infos, err = readDirInfos(path); err != nil {
+			return "", err
+		}
+	}
+
+	h := sha256.New()
+
+	for _, info := range infos {
+		size := info.Size()
+		if info.IsDir() {
+			size = 0
+		}
+
+		fmt.Fprintf(h, "%s\t%s\t%d\n", info.Name(), info.Mode().Type(), size)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// readDirInfos returns the FileInfo of each entry in dir, sorted by name.
+func readDirInfos(dir string) ([]fs.FileInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]fs.FileInfo, 0, len(entries))
+
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+
+		infos = append(infos, info)
+	}
+
+	return infos, nil
+}
@@ -0,0 +1,97 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package fs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/wtsi-hgi/tt/database"
+)
+
+func TestFingerprint(t *testing.T) {
+	Convey("Given an FS and a directory tree", t, func() {
+		dir := t.TempDir()
+		address := filepath.Join(dir, "data")
+		old := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+
+		So(os.MkdirAll(filepath.Join(address, "sub"), 0o755), ShouldBeNil)
+		So(os.WriteFile(filepath.Join(address, "a"), []byte("a"), 0o600), ShouldBeNil)
+		So(os.WriteFile(filepath.Join(address, "sub", "b"), []byte("bb"), 0o600), ShouldBeNil)
+
+		for _, path := range []string{filepath.Join(address, "sub", "b"), filepath.Join(address, "sub"),
+			filepath.Join(address, "a"), address} {
+			So(os.Chtimes(path, old, old), ShouldBeNil)
+		}
+
+		f := New(1)
+		ctx := context.Background()
+		thing := &database.Thing{Address: address, Type: database.ThingsTypeDir}
+
+		Convey("You can fingerprint it without a checksum", func() {
+			fingerprint, err := f.Fingerprint(ctx, thing)
+			So(err, ShouldBeNil)
+			So(fingerprint, ShouldResemble, &database.Fingerprint{Size: 3, Files: 2, Modified: old})
+		})
+
+		Convey("With listing checksums on, the checksum changes with the top-level listing", func() {
+			f.SetListingChecksum(true)
+
+			fingerprint, err := f.Fingerprint(ctx, thing)
+			So(err, ShouldBeNil)
+			So(len(fingerprint.Checksum), ShouldEqual, 64)
+
+			So(os.WriteFile(filepath.Join(address, "sub", "c"), []byte("c"), 0o600), ShouldBeNil)
+
+			deeper, err := f.Fingerprint(ctx, thing)
+			So(err, ShouldBeNil)
+			So(deeper.Checksum, ShouldEqual, fingerprint.Checksum)
+			So(deeper.Files, ShouldEqual, 3)
+			So(fingerprint.Changes(deeper), ShouldHaveLength, 3)
+
+			So(os.Rename(filepath.Join(address, "a"), filepath.Join(address, "z")), ShouldBeNil)
+
+			renamed, err := f.Fingerprint(ctx, thing)
+			So(err, ShouldBeNil)
+			So(renamed.Checksum, ShouldNotEqual, fingerprint.Checksum)
+
+			file, err := f.Fingerprint(ctx, &database.Thing{Address: filepath.Join(address, "z")})
+			So(err, ShouldBeNil)
+			So(file.Files, ShouldEqual, 1)
+			So(file.Checksum, ShouldNotBeBlank)
+		})
+
+		Convey("Fingerprinting something that doesn't exist returns nil", func() {
+			fingerprint, err := f.Fingerprint(ctx, &database.Thing{Address: filepath.Join(dir, "missing")})
+			So(err, ShouldBeNil)
+			So(fingerprint, ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,294 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+// package fs is a backend for Things of ThingsTypeDir and ThingsTypeFile, which
+// have local filesystem paths as their addresses.
+
+package fs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/wtsi-hgi/tt/backend"
+	"github.com/wtsi-hgi/tt/database"
+)
+
+const DefaultConcurrency = 8
+
+const (
+	groupWritable = 0o020
+	otherWritable = 0o002
+)
+
+// FS is a backend.Prober, backend.Fingerprinter, backend.OwnerVerifier,
+// backend.RootChecker and backend.Archiver for dir and file Things.
+type FS struct {
+	concurrency     int
+	archiveDir      string
+	listingChecksum bool
+}
+
+// New returns an FS that will use up to the given number of goroutines to walk
+// each directory it probes. concurrency < 1 means DefaultConcurrency.
+func New(concurrency int) *FS {
+	if concurrency < 1 {
+		concurrency = DefaultConcurrency
+	}
+
+	return &FS{concurrency: concurrency}
+}
+
+// Exists returns true if the thing's address exists on the filesystem. Symlinks
+// are not followed.
+func (f *FS) Exists(_ context.Context, thing *database.Thing) (bool, error) {
+	_, err := os.Lstat(thing.Address)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// Probe stats the thing's address. If it is a directory, walks it to total up
+// the sizes of all files within, count them, and find the latest modification
+// time (which includes directories) and access time (which doesn't, since
+// walking them updates it). Symlinks are counted as files, but not followed.
+func (f *FS) Probe(ctx context.Context, thing *database.Thing) (*database.ProbeResult, error) {
+	info, err := os.Lstat(thing.Address)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &database.ProbeResult{}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return &database.ProbeResult{
+			Exists:   true,
+			Size:     info.Size(),
+			Files:    1,
+			Modified: info.ModTime(),
+			Accessed: accessTime(info),
+		}, nil
+	}
+
+	w := &walker{
+		ctx:      ctx,
+		sem:      make(chan struct{}, f.concurrency),
+		modified: info.ModTime(),
+	}
+
+	w.walk(thing.Address)
+	w.wg.Wait()
+
+	if w.err != nil {
+		return nil, w.err
+	}
+
+	return &database.ProbeResult{
+		Exists:   true,
+		Size:     w.size,
+		Files:    w.files,
+		Modified: w.modified,
+		Accessed: w.accessed,
+	}, nil
+}
+
+// RootExists returns true if the parent directory of the thing's address
+// exists, which won't be the case if the filesystem it's on isn't mounted.
+func (f *FS) RootExists(_ context.Context, thing *database.Thing) (bool, error) {
+	info, err := os.Stat(filepath.Dir(thing.Address))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return info.IsDir(), nil
+}
+
+// VerifyOwner returns nil if the given user owns the thing's address, or can
+// write to it via group permissions. Addresses that anyone can write to are
+// only accepted for their owner. If the address doesn't exist yet, the same
+// test is applied to its nearest existing parent directory instead.
+func (f *FS) VerifyOwner(_ context.Context, thing *database.Thing, identity *backend.Identity) error {
+	path := thing.Address
+
+	for {
+		info, err := os.Lstat(path)
+		if err == nil {
+			return verifyOwner(path, info, identity)
+		}
+
+		parent := filepath.Dir(path)
+		if !errors.Is(err, fs.ErrNotExist) || parent == path {
+			return err
+		}
+
+		path = parent
+	}
+}
+
+func verifyOwner(path string, info fs.FileInfo, identity *backend.Identity) error {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fmt.Errorf("%w: can't determine ownership of %s", backend.ErrNotOwner, path)
+	}
+
+	perm := info.Mode().Perm()
+
+	switch {
+	case strconv.FormatUint(uint64(stat.Uid), 10) == identity.UID:
+		return nil
+	case perm&otherWritable != 0:
+		return fmt.Errorf("%w: %s is writable by anyone", backend.ErrNotOwner, path)
+	case perm&groupWritable != 0 && identity.InGroup(strconv.FormatUint(uint64(stat.Gid), 10)):
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s", backend.ErrNotOwner, path)
+}
+
+// walker totals up the contents of a directory tree, walking sub directories
+// in parallel with a limited number of goroutines.
+type walker struct {
+	ctx context.Context
+	sem chan struct{}
+	wg  sync.WaitGroup
+
+	mu       sync.Mutex
+	size     int64
+	files    int64
+	modified time.Time
+	accessed time.Time
+	err      error
+}
+
+// walk reads the entries of dir, recording them and walking sub directories in
+// new goroutines if the concurrency limit allows, or in this one otherwise.
+// Entries that disappear while walking are ignored.
+func (w *walker) walk(dir string) {
+	if w.failed() {
+		return
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		w.fail(err)
+
+		return
+	}
+
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+
+		info, err := entry.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+
+		if err != nil {
+			w.fail(err)
+
+			return
+		}
+
+		if entry.IsDir() {
+			w.record(0, 0, info.ModTime(), time.Time{})
+			w.walkSubDir(path)
+
+			continue
+		}
+
+		w.record(info.Size(), 1, info.ModTime(), accessTime(info))
+	}
+}
+
+func (w *walker) walkSubDir(path string) {
+	select {
+	case w.sem <- struct{}{}:
+		w.wg.Add(1)
+
+		go func() {
+			defer func() {
+				<-w.sem
+				w.wg.Done()
+			}()
+
+			w.walk(path)
+		}()
+	default:
+		w.walk(path)
+	}
+}
+
+func (w *walker) record(size, files int64, modified, accessed time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.size += size
+	w.files += files
+
+	if modified.After(w.modified) {
+		w.modified = modified
+	}
+
+	if accessed.After(w.accessed) {
+		w.accessed = accessed
+	}
+}
+
+func (w *walker) fail(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.err == nil && !errors.Is(err, fs.ErrNotExist) {
+		w.err = err
+	}
+}
+
+// failed returns true if we've had an error, or our context is done.
+func (w *walker) failed() bool {
+	if err := w.ctx.Err(); err != nil {
+		w.fail(err)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.err != nil
+}
@@ -0,0 +1,174 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package fs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/wtsi-hgi/tt/backend"
+	"github.com/wtsi-hgi/tt/database"
+)
+
+func TestFS(t *testing.T) {
+	Convey("Given a directory tree and an FS", t, func() {
+		dir := t.TempDir()
+		old := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+		latest := time.Now().Add(time.Hour).Truncate(time.Second)
+
+		files := map[string]int{
+			"a":         1,
+			"b/c":       10,
+			"b/d/e":     100,
+			"b/d/f/g":   1000,
+			"h/i/j/k/l": 10000,
+		}
+
+		for path, size := range files {
+			path = filepath.Join(dir, path)
+			So(os.MkdirAll(filepath.Dir(path), 0755), ShouldBeNil)
+			So(os.WriteFile(path, make([]byte, size), 0600), ShouldBeNil)
+			So(os.Chtimes(path, old, old), ShouldBeNil)
+		}
+
+		So(os.Chtimes(filepath.Join(dir, "b/d/e"), latest, latest), ShouldBeNil)
+		So(os.Symlink(filepath.Join(dir, "b"), filepath.Join(dir, "link")), ShouldBeNil)
+
+		err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+			if d.IsDir() {
+				return os.Chtimes(path, old, old)
+			}
+
+			return err
+		})
+		So(err, ShouldBeNil)
+
+		f := New(2)
+		ctx := context.Background()
+
+		Convey("You can tell if things exist", func() {
+			exists, err := f.Exists(ctx, &database.Thing{Address: dir})
+			So(err, ShouldBeNil)
+			So(exists, ShouldBeTrue)
+
+			exists, err = f.Exists(ctx, &database.Thing{Address: filepath.Join(dir, "a")})
+			So(err, ShouldBeNil)
+			So(exists, ShouldBeTrue)
+
+			exists, err = f.Exists(ctx, &database.Thing{Address: filepath.Join(dir, "missing")})
+			So(err, ShouldBeNil)
+			So(exists, ShouldBeFalse)
+		})
+
+		Convey("You can probe a directory", func() {
+			result, err := f.Probe(ctx, &database.Thing{Address: dir})
+			So(err, ShouldBeNil)
+			So(result.Exists, ShouldBeTrue)
+			So(result.Size, ShouldEqual, 11111+len(filepath.Join(dir, "b")))
+			So(result.Files, ShouldEqual, 6)
+			So(result.Modified, ShouldEqual, latest)
+			So(result.Accessed, ShouldEqual, latest)
+
+			result, err = New(0).Probe(ctx, &database.Thing{Address: filepath.Join(dir, "b", "d")})
+			So(err, ShouldBeNil)
+			So(result.Size, ShouldEqual, 1100)
+			So(result.Files, ShouldEqual, 2)
+		})
+
+		Convey("You can probe a file", func() {
+			result, err := f.Probe(ctx, &database.Thing{Address: filepath.Join(dir, "b", "c")})
+			So(err, ShouldBeNil)
+			So(result, ShouldResemble, &database.ProbeResult{
+				Exists:   true,
+				Size:     10,
+				Files:    1,
+				Modified: old,
+				Accessed: old,
+			})
+		})
+
+		Convey("You can check if the directory things are in exists", func() {
+			exists, err := f.RootExists(ctx, &database.Thing{Address: filepath.Join(dir, "missing")})
+			So(err, ShouldBeNil)
+			So(exists, ShouldBeTrue)
+
+			exists, err = f.RootExists(ctx, &database.Thing{Address: filepath.Join(dir, "missing", "a")})
+			So(err, ShouldBeNil)
+			So(exists, ShouldBeFalse)
+		})
+
+		Convey("Probing something that doesn't exist is not an error", func() {
+			result, err := f.Probe(ctx, &database.Thing{Address: filepath.Join(dir, "missing")})
+			So(err, ShouldBeNil)
+			So(result.Exists, ShouldBeFalse)
+		})
+
+		Convey("You can verify who owns or can write to things, but not via other permissions", func() {
+			owner := &backend.Identity{UID: strconv.Itoa(os.Getuid())}
+			stranger := &backend.Identity{UID: "999999"}
+			groupMember := &backend.Identity{UID: "999999", GIDs: []string{strconv.Itoa(os.Getgid())}}
+
+			sub := filepath.Join(dir, "b")
+			So(os.Chmod(sub, 0755), ShouldBeNil)
+
+			for _, address := range []string{sub, filepath.Join(sub, "not", "yet", "created")} {
+				thing := &database.Thing{Address: address}
+
+				So(f.VerifyOwner(ctx, thing, owner), ShouldBeNil)
+
+				err := f.VerifyOwner(ctx, thing, stranger)
+				So(errors.Is(err, backend.ErrNotOwner), ShouldBeTrue)
+
+				err = f.VerifyOwner(ctx, thing, groupMember)
+				So(errors.Is(err, backend.ErrNotOwner), ShouldBeTrue)
+			}
+
+			So(os.Chmod(sub, 0775), ShouldBeNil)
+			So(f.VerifyOwner(ctx, &database.Thing{Address: sub}, groupMember), ShouldBeNil)
+			So(errors.Is(f.VerifyOwner(ctx, &database.Thing{Address: sub}, stranger), backend.ErrNotOwner), ShouldBeTrue)
+
+			So(os.Chmod(sub, 0777), ShouldBeNil)
+			So(f.VerifyOwner(ctx, &database.Thing{Address: sub}, owner), ShouldBeNil)
+			So(errors.Is(f.VerifyOwner(ctx, &database.Thing{Address: sub}, stranger), backend.ErrNotOwner), ShouldBeTrue)
+			So(errors.Is(f.VerifyOwner(ctx, &database.Thing{Address: sub}, groupMember), backend.ErrNotOwner),
+				ShouldBeTrue)
+		})
+
+		Convey("Probing fails if the context is cancelled", func() {
+			cctx, cancel := context.WithCancel(ctx)
+			cancel()
+
+			_, err := f.Probe(cctx, &database.Thing{Address: dir})
+			So(err, ShouldEqual, context.Canceled)
+		})
+	})
+}
@@ -0,0 +1,142 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package fs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+
+	"github.com/wtsi-hgi/tt/backend"
+	"github.com/wtsi-hgi/tt/database"
+)
+
+const (
+	quarantineDirPerms = 0o700
+	inPlacePrefix      = ".tt-quarantine."
+)
+
+// Quarantining is an FS that is also a backend.Quarantiner, moving the data of
+// expired dir and file things to a quarantine area before they are permanently
+// removed.
+type Quarantining struct {
+	*FS
+	root string
+}
+
+// NewQuarantining returns a Quarantining version of the given FS, that moves
+// quarantined data into sub directories of the given root directory.
+func NewQuarantining(f *FS, root string) *Quarantining {
+	return &Quarantining{FS: f, root: root}
+}
+
+// Quarantine moves what exists at the thing's address into <root>/<thing ID>/,
+// and returns its new path. If the root is on a different filesystem to the
+// address, instead renames it in place to a hidden name,
+// .tt-quarantine.<thing ID>.<basename>.
+func (q *Quarantining) Quarantine(_ context.Context, thing *database.Thing) (string, error) {
+	id := strconv.FormatUint(uint64(thing.ID), 10)
+	dir := filepath.Join(q.root, id)
+
+	if err := os.MkdirAll(dir, quarantineDirPerms); err != nil {
+		return "", err
+	}
+
+	location := filepath.Join(dir, filepath.Base(thing.Address))
+
+	err := os.Rename(thing.Address, location)
+	if errors.Is(err, syscall.EXDEV) {
+		os.Remove(dir)
+
+		location = filepath.Join(filepath.Dir(thing.Address), inPlacePrefix+id+"."+filepath.Base(thing.Address))
+		err = os.Rename(thing.Address, location)
+	}
+
+	if err != nil {
+		os.Remove(dir)
+
+		return "", err
+	}
+
+	return location, nil
+}
+
+// Restore moves the thing's quarantined data back to its address. Returns
+// backend.ErrAddressInUse if something else has been created at the address in
+// the mean time.
+func (q *Quarantining) Restore(_ context.Context, thing *database.Thing) error {
+	if !thing.Quarantine.Valid {
+		return database.ErrNotQuarantined
+	}
+
+	_, err := os.Lstat(thing.Address)
+	if err == nil {
+		return fmt.Errorf("%w: %s", backend.ErrAddressInUse, thing.Address)
+	}
+
+	if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	if err = os.Rename(thing.Quarantine.String, thing.Address); err != nil {
+		return err
+	}
+
+	q.removeQuarantineDir(thing.Quarantine.String)
+
+	return nil
+}
+
+// Purge permanently deletes the thing's quarantined data, reporting its
+// progress to the backend.Progress in ctx.
+func (q *Quarantining) Purge(ctx context.Context, thing *database.Thing) error {
+	if !thing.Quarantine.Valid {
+		return database.ErrNotQuarantined
+	}
+
+	if err := removeAll(ctx, thing.Quarantine.String); err != nil {
+		return err
+	}
+
+	q.removeQuarantineDir(thing.Quarantine.String)
+
+	return nil
+}
+
+// removeQuarantineDir removes the <root>/<thing ID> directory that the given
+// location is in, if it is in one.
+func (q *Quarantining) removeQuarantineDir(location string) {
+	dir := filepath.Dir(location)
+
+	if filepath.Dir(dir) == filepath.Clean(q.root) {
+		os.Remove(dir)
+	}
+}
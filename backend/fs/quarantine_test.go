@@ -0,0 +1,117 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package fs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	null "github.com/guregu/null/v5"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/wtsi-hgi/tt/backend"
+	"github.com/wtsi-hgi/tt/database"
+)
+
+func TestQuarantining(t *testing.T) {
+	Convey("Given a Quarantining FS and a thing", t, func() {
+		dir := t.TempDir()
+		root := filepath.Join(dir, "quarantine")
+		address := filepath.Join(dir, "data")
+
+		So(os.MkdirAll(filepath.Join(address, "sub"), 0o755), ShouldBeNil)
+		So(os.WriteFile(filepath.Join(address, "sub", "file"), []byte("data"), 0o600), ShouldBeNil)
+
+		q := NewQuarantining(New(2), root)
+		ctx := context.Background()
+		thing := &database.Thing{ID: 3, Address: address, Type: database.ThingsTypeDir}
+
+		Convey("You can't restore or purge a thing that isn't quarantined", func() {
+			So(q.Restore(ctx, thing), ShouldEqual, database.ErrNotQuarantined)
+			So(q.Purge(ctx, thing), ShouldEqual, database.ErrNotQuarantined)
+		})
+
+		Convey("You can quarantine it", func() {
+			location, err := q.Quarantine(ctx, thing)
+			So(err, ShouldBeNil)
+			So(location, ShouldEqual, filepath.Join(root, "3", "data"))
+
+			_, err = os.Stat(address)
+			So(errors.Is(err, os.ErrNotExist), ShouldBeTrue)
+
+			content, err := os.ReadFile(filepath.Join(location, "sub", "file"))
+			So(err, ShouldBeNil)
+			So(string(content), ShouldEqual, "data")
+
+			thing.Quarantine = null.StringFrom(location)
+
+			Convey("Then restore it", func() {
+				So(q.Restore(ctx, thing), ShouldBeNil)
+
+				content, err := os.ReadFile(filepath.Join(address, "sub", "file"))
+				So(err, ShouldBeNil)
+				So(string(content), ShouldEqual, "data")
+
+				_, err = os.Stat(filepath.Join(root, "3"))
+				So(errors.Is(err, os.ErrNotExist), ShouldBeTrue)
+			})
+
+			Convey("But not if something new is at its address", func() {
+				So(os.WriteFile(address, []byte("new"), 0o600), ShouldBeNil)
+
+				err := q.Restore(ctx, thing)
+				So(errors.Is(err, backend.ErrAddressInUse), ShouldBeTrue)
+
+				_, err = os.Stat(location)
+				So(err, ShouldBeNil)
+			})
+
+			Convey("Then purge it, reporting progress", func() {
+				progress := &backend.Progress{}
+
+				So(q.Purge(backend.WithProgress(ctx, progress), thing), ShouldBeNil)
+				So(progress.Snapshot(), ShouldResemble, database.RemovalProgress{Files: 1, Bytes: 4})
+
+				_, err := os.Stat(filepath.Join(root, "3"))
+				So(errors.Is(err, os.ErrNotExist), ShouldBeTrue)
+
+				So(q.Purge(ctx, thing), ShouldBeNil)
+			})
+		})
+
+		Convey("Quarantining a missing thing fails without leaving anything behind", func() {
+			thing.Address = filepath.Join(dir, "missing")
+
+			_, err := q.Quarantine(ctx, thing)
+			So(err, ShouldNotBeNil)
+
+			_, err = os.Stat(filepath.Join(root, "3"))
+			So(errors.Is(err, os.ErrNotExist), ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,111 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+package fs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/wtsi-hgi/tt/backend"
+)
+
+// removeAll removes path and everything under it, like os.RemoveAll(), but
+// reports each file it removes to the backend.Progress in ctx, and carries on
+// past files it can't remove, returning an error about them at the end. It is
+// not an error if path doesn't exist.
+func removeAll(ctx context.Context, path string) error {
+	progress := backend.ProgressFrom(ctx)
+
+	var (
+		dirs     []string
+		failed   int
+		firstErr error
+	)
+
+	fail := func(err error) {
+		progress.Failed()
+
+		failed++
+
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				fail(err)
+			}
+
+			return nil
+		}
+
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			dirs = append(dirs, p)
+
+			return nil
+		}
+
+		var size int64
+
+		if info, erri := d.Info(); erri == nil {
+			size = info.Size()
+		}
+
+		if err = os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			fail(err)
+
+			return nil
+		}
+
+		progress.Removed(1, size)
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err = os.Remove(dirs[i]); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			fail(err)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d files couldn't be removed, eg. %w", failed, firstErr)
+	}
+
+	return nil
+}
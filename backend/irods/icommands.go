@@ -0,0 +1,157 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package irods
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	notExistMsg = "does not exist"
+	aclPrefix   = "ACL - "
+)
+
+// writeACL matches the entries in an ils -A ACL line that let a user (or
+// group) change what they apply to, capturing the user.
+var writeACL = regexp.MustCompile(`([^\s#]+)#[^\s:]+:(?:own|modify[ _]object|write)`)
+
+// ICommands is a Client that runs the iRODS icommands ils, imeta and irm,
+// which must be in your PATH and already authenticated (eg. with iinit).
+type ICommands struct{}
+
+// Stat uses ils to find out if the given path exists, and if it's a
+// collection.
+func (ICommands) Stat(ctx context.Context, path string) (bool, bool, error) {
+	stdout, err := runICommand(ctx, "ils", path)
+	if err != nil {
+		if strings.Contains(err.Error(), notExistMsg) {
+			return false, false, nil
+		}
+
+		return false, false, err
+	}
+
+	firstLine, _, _ := strings.Cut(stdout, "\n")
+
+	return true, strings.TrimSpace(firstLine) == path+":", nil
+}
+
+// Writers uses `ils -A` to find the users (and groups) that own or can modify
+// the given path.
+func (ICommands) Writers(ctx context.Context, path string) ([]string, error) {
+	stdout, err := runICommand(ctx, "ils", "-A", path)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, line := range strings.Split(stdout, "\n") {
+		_, acl, found := strings.Cut(line, aclPrefix)
+		if !found {
+			continue
+		}
+
+		var writers []string
+
+		for _, match := range writeACL.FindAllStringSubmatch(acl, -1) {
+			writers = append(writers, match[1])
+		}
+
+		return writers, nil
+	}
+
+	return nil, nil
+}
+
+// SetMeta uses `imeta set` to set each AVU.
+func (ICommands) SetMeta(ctx context.Context, path string, isCollection bool, avus map[string]string) error {
+	attrs := make([]string, 0, len(avus))
+
+	for attr := range avus {
+		attrs = append(attrs, attr)
+	}
+
+	sort.Strings(attrs)
+
+	for _, attr := range attrs {
+		if _, err := runICommand(ctx, "imeta", "set", typeFlag(isCollection), path, attr, avus[attr]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func typeFlag(isCollection bool) string {
+	if isCollection {
+		return "-C"
+	}
+
+	return "-d"
+}
+
+// Remove uses irm to remove the given path, recursively if it's a collection,
+// and bypassing the trash if force is true.
+func (ICommands) Remove(ctx context.Context, path string, isCollection, force bool) error {
+	args := []string{}
+
+	if isCollection {
+		args = append(args, "-r")
+	}
+
+	if force {
+		args = append(args, "-f")
+	}
+
+	_, err := runICommand(ctx, "irm", append(args, path)...)
+
+	return err
+}
+
+// runICommand runs the given icommand and returns its stdout. If it fails, the
+// error includes the first line of its stderr.
+func runICommand(ctx context.Context, name string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		scanner := bufio.NewScanner(&stderr)
+		scanner.Scan()
+
+		return "", fmt.Errorf("%s failed: %w: %s", name, err, scanner.Text())
+	}
+
+	return stdout.String(), nil
+}
@@ -0,0 +1,133 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package irods
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const fakeILS = `#!/bin/sh
+case "$*" in
+"-A /zone/coll") printf '/zone/coll:\n        ACL - user#zone:own   grp#zone:read object   other#zone:modify object\n        Inheritance - Disabled\n  obj\n        ACL - third#zone:own\n' ;;
+"-A /zone/obj") printf '  /zone/obj\n        ACL - user#zone:read object\n' ;;
+/zone/coll) printf '/zone/coll:\n  obj\n' ;;
+/zone/obj) printf '  /zone/obj\n' ;;
+/zone/broken) echo "ERROR: connection refused" >&2; exit 1 ;;
+*) echo "ERROR: lsUtil: srcPath $1 does not exist or user lacks access permission" >&2; exit 4 ;;
+esac
+`
+
+const fakeLogger = `#!/bin/sh
+echo "$(basename "$0") $*" >> "$TT_TEST_ICOMMANDS_LOG"
+`
+
+func TestICommands(t *testing.T) {
+	Convey("Given fake icommands in your PATH", t, func() {
+		dir := t.TempDir()
+		logPath := filepath.Join(dir, "log")
+
+		for name, script := range map[string]string{"ils": fakeILS, "imeta": fakeLogger, "irm": fakeLogger} {
+			err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0o700)
+			So(err, ShouldBeNil)
+		}
+
+		t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+		t.Setenv("TT_TEST_ICOMMANDS_LOG", logPath)
+
+		ctx := context.Background()
+		client := ICommands{}
+
+		readLog := func() []string {
+			b, err := os.ReadFile(logPath)
+			So(err, ShouldBeNil)
+
+			return strings.Split(strings.TrimSpace(string(b)), "\n")
+		}
+
+		Convey("You can stat paths", func() {
+			exists, isCollection, err := client.Stat(ctx, "/zone/coll")
+			So(err, ShouldBeNil)
+			So(exists, ShouldBeTrue)
+			So(isCollection, ShouldBeTrue)
+
+			exists, isCollection, err = client.Stat(ctx, "/zone/obj")
+			So(err, ShouldBeNil)
+			So(exists, ShouldBeTrue)
+			So(isCollection, ShouldBeFalse)
+
+			exists, _, err = client.Stat(ctx, "/zone/missing")
+			So(err, ShouldBeNil)
+			So(exists, ShouldBeFalse)
+
+			_, _, err = client.Stat(ctx, "/zone/broken")
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "connection refused")
+		})
+
+		Convey("You can find who can write to paths", func() {
+			writers, err := client.Writers(ctx, "/zone/coll")
+			So(err, ShouldBeNil)
+			So(writers, ShouldResemble, []string{"user", "other"})
+
+			writers, err = client.Writers(ctx, "/zone/obj")
+			So(err, ShouldBeNil)
+			So(writers, ShouldBeEmpty)
+
+			_, err = client.Writers(ctx, "/zone/missing")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("You can set metadata", func() {
+			err := client.SetMeta(ctx, "/zone/coll", true, map[string]string{AVUID: "1", AVURemove: "2030-01-02"})
+			So(err, ShouldBeNil)
+
+			err = client.SetMeta(ctx, "/zone/obj", false, map[string]string{AVUCreator: "user"})
+			So(err, ShouldBeNil)
+
+			So(readLog(), ShouldResemble, []string{
+				"imeta set -C /zone/coll tt_id 1",
+				"imeta set -C /zone/coll tt_remove 2030-01-02",
+				"imeta set -d /zone/obj tt_creator user",
+			})
+		})
+
+		Convey("You can remove things", func() {
+			So(client.Remove(ctx, "/zone/coll", true, false), ShouldBeNil)
+			So(client.Remove(ctx, "/zone/obj", false, true), ShouldBeNil)
+
+			So(readLog(), ShouldResemble, []string{
+				"irm -r /zone/coll",
+				"irm -f /zone/obj",
+			})
+		})
+	})
+}
@@ -0,0 +1,185 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+// package irods provides a backend for irods Things: collections and data
+// objects in iRODS, accessed via a Client.
+
+package irods
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/wtsi-hgi/tt/backend"
+	"github.com/wtsi-hgi/tt/database"
+)
+
+const (
+	// AVUID is the attribute of the AVU holding the tt thing ID.
+	AVUID = "tt_id"
+
+	// AVURemove is the attribute of the AVU holding the removal date, in
+	// YYYY-MM-DD format.
+	AVURemove = "tt_remove"
+
+	// AVUCreator is the attribute of the AVU holding the name of the user who
+	// registered the thing with tt.
+	AVUCreator = "tt_creator"
+
+	ErrBadAddress = database.Error("iRODS addresses must be absolute paths")
+)
+
+// Client is the interface to iRODS used by Irods.
+type Client interface {
+	// Stat returns whether the given path exists, and if so, whether it is a
+	// collection (as opposed to a data object).
+	Stat(ctx context.Context, path string) (exists, isCollection bool, err error)
+
+	// Writers returns the names of the users and groups that own or can
+	// modify the collection or data object at the given path.
+	Writers(ctx context.Context, path string) ([]string, error)
+
+	// SetMeta sets the given attribute/value pairs as AVUs on the collection or
+	// data object at the given path, replacing any existing AVUs with the same
+	// attributes.
+	SetMeta(ctx context.Context, path string, isCollection bool, avus map[string]string) error
+
+	// Remove removes the data object at the given path, or the collection and
+	// everything in it. Unless force is true, what's removed is moved to the
+	// trash.
+	Remove(ctx context.Context, path string, isCollection, force bool) error
+}
+
+// Irods is a backend.Registrar, backend.OwnerVerifier and backend.Remover for
+// irods Things. On registration (and extension), it adds AVUs to the collection
+// or data object at the thing's address with the thing's ID, removal date and
+// creator.
+type Irods struct {
+	client Client
+	trash  bool
+}
+
+// New returns an Irods that uses the given client. If trash is true, expired
+// things will be moved to the trash instead of being removed permanently.
+func New(client Client, trash bool) *Irods {
+	return &Irods{client: client, trash: trash}
+}
+
+// Exists returns true if there's a collection or data object at the thing's
+// address.
+func (i *Irods) Exists(ctx context.Context, thing *database.Thing) (bool, error) {
+	if !path.IsAbs(thing.Address) {
+		return false, ErrBadAddress
+	}
+
+	exists, _, err := i.client.Stat(ctx, thing.Address)
+
+	return exists, err
+}
+
+// VerifyOwner returns nil if the given user owns or can modify the collection
+// or data object at the thing's address, according to its ACL. If nothing
+// exists there yet, the same test is applied to its nearest existing parent
+// collection instead.
+func (i *Irods) VerifyOwner(ctx context.Context, thing *database.Thing, identity *backend.Identity) error {
+	if !path.IsAbs(thing.Address) {
+		return ErrBadAddress
+	}
+
+	p := thing.Address
+
+	for {
+		exists, _, err := i.client.Stat(ctx, p)
+		if err != nil {
+			return err
+		}
+
+		if exists {
+			break
+		}
+
+		if p == "/" {
+			return fmt.Errorf("%w: %s", backend.ErrNotOwner, thing.Address)
+		}
+
+		p = path.Dir(p)
+	}
+
+	writers, err := i.client.Writers(ctx, p)
+	if err != nil {
+		return err
+	}
+
+	if !slices.Contains(writers, identity.Username) {
+		return fmt.Errorf("%w: %s", backend.ErrNotOwner, p)
+	}
+
+	return nil
+}
+
+// Register sets the tt_id, tt_remove and tt_creator AVUs on the collection or
+// data object at the thing's address. It is not an error if nothing exists
+// there yet.
+func (i *Irods) Register(ctx context.Context, thing *database.Thing) error {
+	if !path.IsAbs(thing.Address) {
+		return ErrBadAddress
+	}
+
+	exists, isCollection, err := i.client.Stat(ctx, thing.Address)
+	if err != nil || !exists {
+		return err
+	}
+
+	avus := map[string]string{
+		AVUID:     strconv.FormatUint(uint64(thing.ID), 10),
+		AVURemove: thing.Remove.Format(time.DateOnly),
+	}
+
+	if thing.Creator != "" {
+		avus[AVUCreator] = thing.Creator
+	}
+
+	return i.client.SetMeta(ctx, thing.Address, isCollection, avus)
+}
+
+// Remove removes the collection or data object at the thing's address,
+// moving it to the trash if this Irods was made with trash enabled. It is not
+// an error if nothing exists there.
+func (i *Irods) Remove(ctx context.Context, thing *database.Thing) error {
+	if !path.IsAbs(thing.Address) {
+		return ErrBadAddress
+	}
+
+	exists, isCollection, err := i.client.Stat(ctx, thing.Address)
+	if err != nil || !exists {
+		return err
+	}
+
+	return i.client.Remove(ctx, thing.Address, isCollection, !i.trash)
+}
@@ -0,0 +1,219 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package irods
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/wtsi-hgi/tt/backend"
+	"github.com/wtsi-hgi/tt/database"
+)
+
+// fakeClient is an in-memory Client.
+type fakeClient struct {
+	isCollection map[string]bool
+	avus         map[string]map[string]string
+	writers      map[string][]string
+	removed      map[string]bool
+	trashed      map[string]bool
+	err          error
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{
+		isCollection: map[string]bool{"/zone/coll": true, "/zone/obj": false},
+		avus:         map[string]map[string]string{"/zone/obj": {"other": "avu", AVURemove: "old"}},
+		writers:      map[string][]string{"/zone/coll": {"user"}, "/zone/obj": {"other"}},
+		removed:      make(map[string]bool),
+		trashed:      make(map[string]bool),
+	}
+}
+
+func (f *fakeClient) Stat(_ context.Context, path string) (bool, bool, error) {
+	if f.err != nil {
+		return false, false, f.err
+	}
+
+	isCollection, ok := f.isCollection[path]
+
+	return ok, isCollection, nil
+}
+
+func (f *fakeClient) Writers(_ context.Context, path string) ([]string, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	return f.writers[path], nil
+}
+
+func (f *fakeClient) SetMeta(_ context.Context, path string, isCollection bool, avus map[string]string) error {
+	if f.isCollection[path] != isCollection {
+		return errors.New("wrong type")
+	}
+
+	if f.avus[path] == nil {
+		f.avus[path] = make(map[string]string)
+	}
+
+	for attr, value := range avus {
+		f.avus[path][attr] = value
+	}
+
+	return nil
+}
+
+func (f *fakeClient) Remove(_ context.Context, path string, isCollection, force bool) error {
+	if f.isCollection[path] != isCollection {
+		return errors.New("wrong type")
+	}
+
+	delete(f.isCollection, path)
+
+	if force {
+		f.removed[path] = true
+	} else {
+		f.trashed[path] = true
+	}
+
+	return nil
+}
+
+func TestIrods(t *testing.T) {
+	Convey("Given an Irods with a fake client", t, func() {
+		client := newFakeClient()
+		i := New(client, true)
+		ctx := context.Background()
+		remove := time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC)
+		thing := func(address string) *database.Thing {
+			return &database.Thing{
+				ID:      7,
+				Address: address,
+				Type:    database.ThingsTypeIrods,
+				Remove:  remove,
+				Creator: "user",
+			}
+		}
+
+		Convey("You can tell if collections and data objects exist", func() {
+			for address, expected := range map[string]bool{
+				"/zone/coll":    true,
+				"/zone/obj":     true,
+				"/zone/missing": false,
+			} {
+				exists, err := i.Exists(ctx, thing(address))
+				So(err, ShouldBeNil)
+				So(exists, ShouldEqual, expected)
+			}
+		})
+
+		Convey("Only users that can write to things are their owners", func() {
+			user := &backend.Identity{Username: "user"}
+			other := &backend.Identity{Username: "other"}
+
+			So(i.VerifyOwner(ctx, thing("/zone/coll"), user), ShouldBeNil)
+			So(i.VerifyOwner(ctx, thing("/zone/coll/new"), user), ShouldBeNil)
+			So(i.VerifyOwner(ctx, thing("/zone/obj"), other), ShouldBeNil)
+
+			err := i.VerifyOwner(ctx, thing("/zone/obj"), user)
+			So(errors.Is(err, backend.ErrNotOwner), ShouldBeTrue)
+
+			err = i.VerifyOwner(ctx, thing("/zone/coll"), other)
+			So(errors.Is(err, backend.ErrNotOwner), ShouldBeTrue)
+
+			err = i.VerifyOwner(ctx, thing("/elsewhere/obj"), user)
+			So(errors.Is(err, backend.ErrNotOwner), ShouldBeTrue)
+
+			So(i.VerifyOwner(ctx, thing("zone/obj"), user), ShouldEqual, ErrBadAddress)
+		})
+
+		Convey("You can add expiry AVUs to collections and data objects", func() {
+			So(i.Register(ctx, thing("/zone/coll")), ShouldBeNil)
+			So(client.avus["/zone/coll"], ShouldResemble, map[string]string{
+				AVUID:      "7",
+				AVURemove:  "2030-01-02",
+				AVUCreator: "user",
+			})
+
+			So(i.Register(ctx, thing("/zone/obj")), ShouldBeNil)
+			So(client.avus["/zone/obj"], ShouldResemble, map[string]string{
+				"other":    "avu",
+				AVUID:      "7",
+				AVURemove:  "2030-01-02",
+				AVUCreator: "user",
+			})
+
+			Convey("and update them when the thing is extended", func() {
+				extended := thing("/zone/obj")
+				extended.Remove = remove.AddDate(0, 1, 0)
+
+				So(i.Register(ctx, extended), ShouldBeNil)
+				So(client.avus["/zone/obj"][AVURemove], ShouldEqual, "2030-02-02")
+			})
+		})
+
+		Convey("Registering things that don't exist does nothing", func() {
+			So(i.Register(ctx, thing("/zone/missing")), ShouldBeNil)
+			So(client.avus["/zone/missing"], ShouldBeNil)
+		})
+
+		Convey("You can move expired things to the trash", func() {
+			So(i.Remove(ctx, thing("/zone/coll")), ShouldBeNil)
+			So(i.Remove(ctx, thing("/zone/obj")), ShouldBeNil)
+			So(client.trashed, ShouldResemble, map[string]bool{"/zone/coll": true, "/zone/obj": true})
+			So(client.removed, ShouldBeEmpty)
+
+			So(i.Remove(ctx, thing("/zone/coll")), ShouldBeNil)
+		})
+
+		Convey("You can permanently remove expired things", func() {
+			i = New(client, false)
+
+			So(i.Remove(ctx, thing("/zone/coll")), ShouldBeNil)
+			So(client.removed, ShouldResemble, map[string]bool{"/zone/coll": true})
+			So(client.trashed, ShouldBeEmpty)
+		})
+
+		Convey("Client errors and bad addresses are returned", func() {
+			client.err = errors.New("irods down")
+
+			_, err := i.Exists(ctx, thing("/zone/obj"))
+			So(err, ShouldEqual, client.err)
+			So(i.Register(ctx, thing("/zone/obj")), ShouldEqual, client.err)
+			So(i.Remove(ctx, thing("/zone/obj")), ShouldEqual, client.err)
+			So(i.VerifyOwner(ctx, thing("/zone/obj"), &backend.Identity{}), ShouldEqual, client.err)
+
+			_, err = i.Exists(ctx, thing("zone/obj"))
+			So(err, ShouldEqual, ErrBadAddress)
+			So(i.Register(ctx, thing("zone/obj")), ShouldEqual, ErrBadAddress)
+			So(i.Remove(ctx, thing("zone/obj")), ShouldEqual, ErrBadAddress)
+		})
+	})
+}
@@ -0,0 +1,410 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package openstack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultDomain is the domain used for the user and project if none is
+	// configured.
+	DefaultDomain = "Default"
+
+	tokenHeader      = "X-Auth-Token"
+	subjectHeader    = "X-Subject-Token"
+	tokenExpiryGrace = time.Minute
+	publicInterface  = "public"
+	maxErrBodyLength = 256
+
+	// kindUser is used to look up keystone users, at the configured AuthURL.
+	kindUser Kind = "user"
+)
+
+// serviceTypes are the catalog service types that can provide each kind of
+// resource, in order of preference, and paths are the path under the service's
+// endpoint where resources of that kind are found.
+var (
+	serviceTypes = map[Kind][]string{
+		KindInstance:   {"compute"},
+		KindVolume:     {"block-storage", "volumev3", "volumev2", "volume"},
+		KindImage:      {"image"},
+		KindFloatingIP: {"network"},
+	}
+
+	paths = map[Kind]string{
+		KindInstance:   "/servers/",
+		KindVolume:     "/volumes/",
+		KindImage:      "/v2/images/",
+		KindFloatingIP: "/v2.0/floatingips/",
+		kindUser:       "/users/",
+	}
+
+	// creatorKeys are the keys of the JSON objects describing the kinds of
+	// resource that record the ID of the user that created them.
+	creatorKeys = map[Kind]string{
+		KindInstance: "server",
+		KindVolume:   "volume",
+	}
+)
+
+// Config holds the details needed to authenticate with an OpenStack Keystone v3
+// identity service using a password, with the same meaning as the standard
+// OS_* environment variables.
+type Config struct {
+	AuthURL           string
+	Username          string
+	Password          string
+	UserDomainName    string // defaults to DefaultDomain
+	ProjectName       string
+	ProjectDomainName string // defaults to DefaultDomain
+	RegionName        string // optional; only use endpoints in this region
+}
+
+// Client is an API that talks to the OpenStack service REST APIs, finding them
+// using the service catalog returned when authenticating.
+type Client struct {
+	config Config
+	http   *http.Client
+
+	mu        sync.Mutex
+	token     string
+	expires   time.Time
+	endpoints map[string]string
+}
+
+// NewClient returns a Client that will authenticate using the given config
+// when first needed.
+func NewClient(config Config) *Client {
+	if config.UserDomainName == "" {
+		config.UserDomainName = DefaultDomain
+	}
+
+	if config.ProjectDomainName == "" {
+		config.ProjectDomainName = DefaultDomain
+	}
+
+	return &Client{config: config, http: &http.Client{}}
+}
+
+// Exists implements API.
+func (c *Client) Exists(ctx context.Context, kind Kind, ref string) (bool, error) {
+	id, found, err := c.resolve(ctx, kind, ref)
+	if err != nil || !found {
+		return false, err
+	}
+
+	resp, err := c.do(ctx, http.MethodGet, kind, paths[kind]+url.PathEscape(id))
+	if err != nil {
+		return false, err
+	}
+
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, responseError(resp)
+	}
+}
+
+// Delete implements API.
+func (c *Client) Delete(ctx context.Context, kind Kind, ref string) error {
+	id, found, err := c.resolve(ctx, kind, ref)
+	if err != nil || !found {
+		return err
+	}
+
+	resp, err := c.do(ctx, http.MethodDelete, kind, paths[kind]+url.PathEscape(id))
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode/100 == 2 {
+		return nil
+	}
+
+	return responseError(resp)
+}
+
+// Creator implements API, using the user_id recorded by the compute and
+// block-storage services and looking up that user's name in keystone.
+func (c *Client) Creator(ctx context.Context, kind Kind, ref string) (string, error) {
+	key, ok := creatorKeys[kind]
+	if !ok {
+		return "", nil
+	}
+
+	id, found, err := c.resolve(ctx, kind, ref)
+	if err != nil || !found {
+		return "", err
+	}
+
+	var resource map[string]struct {
+		UserID string `json:"user_id"`
+	}
+
+	found, err = c.getJSON(ctx, kind, paths[kind]+url.PathEscape(id), &resource)
+	if err != nil || !found || resource[key].UserID == "" {
+		return "", err
+	}
+
+	var user struct {
+		User struct {
+			Name string `json:"name"`
+		} `json:"user"`
+	}
+
+	found, err = c.getJSON(ctx, kindUser, paths[kindUser]+url.PathEscape(resource[key].UserID), &user)
+	if err != nil || !found {
+		return "", err
+	}
+
+	return user.User.Name, nil
+}
+
+// getJSON decodes the body of a GET of the given path in to v, returning false
+// if there was nothing at that path.
+func (c *Client) getJSON(ctx context.Context, kind Kind, path string, v any) (bool, error) {
+	resp, err := c.do(ctx, http.MethodGet, kind, path)
+	if err != nil {
+		return false, err
+	}
+
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, json.NewDecoder(resp.Body).Decode(v)
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, responseError(resp)
+	}
+}
+
+// resolve returns the ID of the resource with the given reference. References
+// are IDs, except for floating IPs which can also be IP addresses, which are
+// looked up. found is false if an IP address isn't allocated.
+func (c *Client) resolve(ctx context.Context, kind Kind, ref string) (string, bool, error) {
+	if kind != KindFloatingIP || net.ParseIP(ref) == nil {
+		return ref, true, nil
+	}
+
+	resp, err := c.do(ctx, http.MethodGet, kind, "/v2.0/floatingips?floating_ip_address="+url.QueryEscape(ref))
+	if err != nil {
+		return "", false, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", false, responseError(resp)
+	}
+
+	var list struct {
+		FloatingIPs []struct {
+			ID string `json:"id"`
+		} `json:"floatingips"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return "", false, err
+	}
+
+	if len(list.FloatingIPs) == 0 {
+		return "", false, nil
+	}
+
+	return list.FloatingIPs[0].ID, true, nil
+}
+
+// do makes an authenticated request to the given path under the endpoint of
+// the service for the given kind of resource.
+func (c *Client) do(ctx context.Context, method string, kind Kind, path string) (*http.Response, error) {
+	token, endpoint, err := c.authenticate(ctx, kind)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint+path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set(tokenHeader, token)
+	req.Header.Set("Accept", "application/json")
+
+	return c.http.Do(req)
+}
+
+// authenticate returns a valid token and the endpoint of the service for the
+// given kind of resource, getting a new token if we don't have one or it's
+// about to expire.
+func (c *Client) authenticate(ctx context.Context, kind Kind) (string, string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token == "" || time.Now().Add(tokenExpiryGrace).After(c.expires) {
+		if err := c.getToken(ctx); err != nil {
+			return "", "", err
+		}
+	}
+
+	if kind == kindUser {
+		return c.token, strings.TrimSuffix(c.config.AuthURL, "/"), nil
+	}
+
+	for _, serviceType := range serviceTypes[kind] {
+		if endpoint, ok := c.endpoints[serviceType]; ok {
+			return c.token, endpoint, nil
+		}
+	}
+
+	return "", "", fmt.Errorf("no %s service found in the openstack catalog", kind)
+}
+
+type authName struct {
+	Name string `json:"name"`
+}
+
+type authRequest struct {
+	Auth struct {
+		Identity struct {
+			Methods  []string `json:"methods"`
+			Password struct {
+				User struct {
+					Name     string   `json:"name"`
+					Domain   authName `json:"domain"`
+					Password string   `json:"password"`
+				} `json:"user"`
+			} `json:"password"`
+		} `json:"identity"`
+		Scope struct {
+			Project struct {
+				Name   string   `json:"name"`
+				Domain authName `json:"domain"`
+			} `json:"project"`
+		} `json:"scope"`
+	} `json:"auth"`
+}
+
+type authResponse struct {
+	Token struct {
+		ExpiresAt time.Time `json:"expires_at"`
+		Catalog   []struct {
+			Type      string `json:"type"`
+			Endpoints []struct {
+				Interface string `json:"interface"`
+				Region    string `json:"region"`
+				URL       string `json:"url"`
+			} `json:"endpoints"`
+		} `json:"catalog"`
+	} `json:"token"`
+}
+
+// getToken gets a project-scoped token from keystone, and records the public
+// endpoints of the services in its catalog. Must be called with c.mu held.
+func (c *Client) getToken(ctx context.Context) error {
+	var areq authRequest
+
+	areq.Auth.Identity.Methods = []string{"password"}
+	areq.Auth.Identity.Password.User.Name = c.config.Username
+	areq.Auth.Identity.Password.User.Domain.Name = c.config.UserDomainName
+	areq.Auth.Identity.Password.User.Password = c.config.Password
+	areq.Auth.Scope.Project.Name = c.config.ProjectName
+	areq.Auth.Scope.Project.Domain.Name = c.config.ProjectDomainName
+
+	body, err := json.Marshal(areq)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		strings.TrimSuffix(c.config.AuthURL, "/")+"/auth/tokens", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("openstack authentication failed: %w", responseError(resp))
+	}
+
+	var aresp authResponse
+
+	if err := json.NewDecoder(resp.Body).Decode(&aresp); err != nil {
+		return err
+	}
+
+	c.token = resp.Header.Get(subjectHeader)
+	c.expires = aresp.Token.ExpiresAt
+	c.endpoints = make(map[string]string)
+
+	for _, service := range aresp.Token.Catalog {
+		for _, endpoint := range service.Endpoints {
+			if endpoint.Interface != publicInterface ||
+				(c.config.RegionName != "" && endpoint.Region != c.config.RegionName) {
+				continue
+			}
+
+			c.endpoints[service.Type] = strings.TrimSuffix(endpoint.URL, "/")
+		}
+	}
+
+	return nil
+}
+
+// responseError returns an error describing an unexpected response.
+func responseError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrBodyLength))
+
+	return fmt.Errorf("%s %s: %s: %s", resp.Request.Method, resp.Request.URL.Path,
+		resp.Status, strings.TrimSpace(string(body)))
+}
@@ -0,0 +1,202 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package openstack
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+)
+
+const (
+	fakePassword = "pass"
+	fakeToken    = "token"
+	fakeInUse    = "/volume/v3/project/volumes/inuse"
+)
+
+// fakeOpenStack is a local HTTP stand-in for keystone and the compute,
+// block-storage, image and network services, implementing just enough for
+// Client to be tested against it.
+type fakeOpenStack struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	resources   map[string]bool
+	bodies      map[string]string
+	floatingIPs map[string]string
+	auths       int
+}
+
+func newFakeOpenStack() *fakeOpenStack {
+	f := &fakeOpenStack{
+		resources: map[string]bool{
+			"/compute/v2.1/servers/i1":        true,
+			"/volume/v3/project/volumes/v1":   true,
+			fakeInUse:                         true,
+			"/image/v2/images/im1":            true,
+			"/network/v2.0/floatingips/fip-1": true,
+			"/identity/v3/users/u1":           true,
+			"/identity/v3/users/u2":           true,
+		},
+		bodies: map[string]string{
+			"/compute/v2.1/servers/i1":      `{"server": {"id": "i1", "user_id": "u1"}}`,
+			"/volume/v3/project/volumes/v1": `{"volume": {"id": "v1", "user_id": "u2"}}`,
+			"/identity/v3/users/u1":         `{"user": {"id": "u1", "name": "user"}}`,
+			"/identity/v3/users/u2":         `{"user": {"id": "u2", "name": "other"}}`,
+		},
+		floatingIPs: map[string]string{"10.0.0.1": "fip-1"},
+	}
+
+	f.Server = httptest.NewServer(f)
+
+	return f
+}
+
+func (f *fakeOpenStack) config() Config {
+	return Config{
+		AuthURL:     f.URL + "/identity/v3",
+		Username:    "user",
+		Password:    fakePassword,
+		ProjectName: "project",
+		RegionName:  "RegionOne",
+	}
+}
+
+func (f *fakeOpenStack) exists(path string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.resources[path]
+}
+
+func (f *fakeOpenStack) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.URL.Path == "/identity/v3/auth/tokens" && r.Method == http.MethodPost {
+		f.serveAuth(w, r)
+
+		return
+	}
+
+	if r.Header.Get(tokenHeader) != fakeToken {
+		http.Error(w, "bad token", http.StatusUnauthorized)
+
+		return
+	}
+
+	if r.URL.Path == "/network/v2.0/floatingips" && r.Method == http.MethodGet {
+		f.serveFloatingIPs(w, r)
+
+		return
+	}
+
+	if !f.resources[r.URL.Path] {
+		http.Error(w, "not found", http.StatusNotFound)
+
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if body, ok := f.bodies[r.URL.Path]; ok {
+			fmt.Fprint(w, body)
+		} else {
+			fmt.Fprint(w, "{}")
+		}
+	case http.MethodDelete:
+		if r.URL.Path == fakeInUse {
+			http.Error(w, "volume is attached", http.StatusBadRequest)
+
+			return
+		}
+
+		delete(f.resources, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "bad method", http.StatusMethodNotAllowed)
+	}
+}
+
+func (f *fakeOpenStack) serveAuth(w http.ResponseWriter, r *http.Request) {
+	var areq authRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&areq); err != nil ||
+		areq.Auth.Identity.Password.User.Password != fakePassword ||
+		areq.Auth.Identity.Password.User.Domain.Name != DefaultDomain {
+		http.Error(w, "bad credentials", http.StatusUnauthorized)
+
+		return
+	}
+
+	f.auths++
+
+	endpoint := func(region, iface, path string) map[string]string {
+		return map[string]string{"region": region, "interface": iface, "url": f.URL + path}
+	}
+
+	w.Header().Set(subjectHeader, fakeToken)
+	w.WriteHeader(http.StatusCreated)
+
+	json.NewEncoder(w).Encode(map[string]any{ //nolint:errcheck
+		"token": map[string]any{
+			"expires_at": time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+			"catalog": []map[string]any{
+				{"type": "compute", "endpoints": []map[string]string{
+					endpoint("RegionOne", "internal", "/internal"),
+					endpoint("RegionOne", "public", "/compute/v2.1"),
+					endpoint("RegionTwo", "public", "/other"),
+				}},
+				{"type": "volumev3", "endpoints": []map[string]string{
+					endpoint("RegionOne", "public", "/volume/v3/project/"),
+				}},
+				{"type": "image", "endpoints": []map[string]string{
+					endpoint("RegionOne", "public", "/image"),
+				}},
+				{"type": "network", "endpoints": []map[string]string{
+					endpoint("RegionOne", "public", "/network"),
+				}},
+			},
+		},
+	})
+}
+
+func (f *fakeOpenStack) serveFloatingIPs(w http.ResponseWriter, r *http.Request) {
+	var list struct {
+		FloatingIPs []map[string]string `json:"floatingips"`
+	}
+
+	list.FloatingIPs = []map[string]string{}
+
+	if id, ok := f.floatingIPs[r.URL.Query().Get("floating_ip_address")]; ok && f.resources["/network/v2.0/floatingips/"+id] {
+		list.FloatingIPs = append(list.FloatingIPs, map[string]string{"id": id})
+	}
+
+	json.NewEncoder(w).Encode(list) //nolint:errcheck
+}
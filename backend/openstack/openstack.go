@@ -0,0 +1,179 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+// package openstack provides a backend for openstack Things: instances,
+// volumes, images and floating IPs, accessed via an API.
+
+package openstack
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/wtsi-hgi/tt/backend"
+	"github.com/wtsi-hgi/tt/database"
+)
+
+// Kind is a kind of OpenStack resource.
+type Kind string
+
+const (
+	KindInstance   Kind = "instance"
+	KindVolume     Kind = "volume"
+	KindImage      Kind = "image"
+	KindFloatingIP Kind = "floatingip"
+
+	ErrBadAddress = database.Error("OpenStack addresses must be of the form instance/<id>, volume/<id>, " +
+		"image/<id> or floatingip/<id or ip address>")
+)
+
+func init() {
+	if err := database.RegisterThingsType(database.ThingsTypeInfo{
+		Name:         database.ThingsTypeOpenstack,
+		Label:        "OpenStack",
+		Canonicalise: canonicaliseAddress,
+		Validate:     validateAddress,
+	}); err != nil {
+		panic(err)
+	}
+}
+
+// Kinds returns all the kinds of resource that can be openstack Things.
+func Kinds() []Kind {
+	return []Kind{KindInstance, KindVolume, KindImage, KindFloatingIP}
+}
+
+// API is the interface to the OpenStack compute, block-storage, image and
+// network services used by OpenStack.
+type API interface {
+	// Exists returns true if the resource of the given kind with the given
+	// reference (an ID, or for floating IPs, an ID or IP address) exists.
+	Exists(ctx context.Context, kind Kind, ref string) (bool, error)
+
+	// Creator returns the name of the user that created the resource of the
+	// given kind with the given reference, or an empty string if it doesn't
+	// exist or that kind of resource doesn't record who created it.
+	Creator(ctx context.Context, kind Kind, ref string) (string, error)
+
+	// Delete deletes the resource of the given kind with the given reference.
+	// It is not an error if the resource doesn't exist.
+	Delete(ctx context.Context, kind Kind, ref string) error
+}
+
+// OpenStack is a backend.Remover and backend.OwnerVerifier for openstack
+// Things, whose addresses are of the form kind/ref, eg. instance/<server id> or
+// floatingip/10.0.0.1.
+type OpenStack struct {
+	api API
+}
+
+// New returns an OpenStack that uses the given API.
+func New(api API) *OpenStack {
+	return &OpenStack{api: api}
+}
+
+// ParseAddress splits an openstack thing's address into the kind of resource
+// and the reference to it, returning ErrBadAddress if the address is not valid.
+func ParseAddress(address string) (Kind, string, error) {
+	kindStr, ref, ok := strings.Cut(address, "/")
+	if !ok || ref == "" || strings.Contains(ref, "/") {
+		return "", "", ErrBadAddress
+	}
+
+	kind := Kind(strings.ToLower(kindStr))
+
+	for _, k := range Kinds() {
+		if kind == k {
+			return kind, ref, nil
+		}
+	}
+
+	return "", "", ErrBadAddress
+}
+
+// canonicaliseAddress lower-cases the kind part of the given address.
+func canonicaliseAddress(address string) string {
+	kind, ref, ok := strings.Cut(address, "/")
+	if !ok {
+		return address
+	}
+
+	return strings.ToLower(kind) + "/" + ref
+}
+
+// validateAddress returns ErrBadAddress if the given address can't be parsed.
+func validateAddress(address string) error {
+	_, _, err := ParseAddress(address)
+
+	return err
+}
+
+// Exists returns true if the resource at the thing's address exists.
+func (o *OpenStack) Exists(ctx context.Context, thing *database.Thing) (bool, error) {
+	kind, ref, err := ParseAddress(thing.Address)
+	if err != nil {
+		return false, err
+	}
+
+	return o.api.Exists(ctx, kind, ref)
+}
+
+// VerifyOwner returns nil if the resource at the thing's address was created by
+// the OpenStack user with the same name as the given user. Since images and
+// floating IPs don't record who created them, they can't be verified, so are
+// refused.
+func (o *OpenStack) VerifyOwner(ctx context.Context, thing *database.Thing, identity *backend.Identity) error {
+	kind, ref, err := ParseAddress(thing.Address)
+	if err != nil {
+		return err
+	}
+
+	creator, err := o.api.Creator(ctx, kind, ref)
+	if err != nil {
+		return err
+	}
+
+	if creator == "" {
+		return fmt.Errorf("%w: %s has no known creator", backend.ErrNotOwner, thing.Address)
+	}
+
+	if creator != identity.Username {
+		return fmt.Errorf("%w: %s", backend.ErrNotOwner, thing.Address)
+	}
+
+	return nil
+}
+
+// Remove deletes the resource at the thing's address. It is not an error if it
+// doesn't exist.
+func (o *OpenStack) Remove(ctx context.Context, thing *database.Thing) error {
+	kind, ref, err := ParseAddress(thing.Address)
+	if err != nil {
+		return err
+	}
+
+	return o.api.Delete(ctx, kind, ref)
+}
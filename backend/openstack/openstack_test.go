@@ -0,0 +1,166 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package openstack
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/wtsi-hgi/tt/backend"
+	"github.com/wtsi-hgi/tt/database"
+)
+
+func TestParseAddress(t *testing.T) {
+	Convey("You can parse openstack addresses", t, func() {
+		kind, ref, err := ParseAddress("instance/abc")
+		So(err, ShouldBeNil)
+		So(kind, ShouldEqual, KindInstance)
+		So(ref, ShouldEqual, "abc")
+
+		kind, ref, err = ParseAddress("FloatingIP/10.0.0.1")
+		So(err, ShouldBeNil)
+		So(kind, ShouldEqual, KindFloatingIP)
+		So(ref, ShouldEqual, "10.0.0.1")
+
+		for _, bad := range []string{"", "abc", "instance/", "instance/a/b", "network/abc"} {
+			_, _, err = ParseAddress(bad)
+			So(err, ShouldEqual, ErrBadAddress)
+		}
+	})
+
+	Convey("The openstack ThingsType is registered with an address validator", t, func() {
+		So(database.ThingsTypeOpenstack.Label(), ShouldEqual, "OpenStack")
+		So(database.ThingsTypeOpenstack.CanonicalAddress("Instance/abc"), ShouldEqual, "instance/abc")
+		So(database.ThingsTypeOpenstack.ValidateAddress("Instance/abc"), ShouldBeNil)
+		So(database.ThingsTypeOpenstack.ValidateAddress("floatingip/10.0.0.1"), ShouldBeNil)
+		So(database.ThingsTypeOpenstack.ValidateAddress("network/abc"), ShouldEqual, ErrBadAddress)
+		So(database.ThingsTypeOpenstack.ValidateAddress("volume/"), ShouldEqual, ErrBadAddress)
+	})
+}
+
+func TestOpenStack(t *testing.T) {
+	Convey("Given an OpenStack using a Client talking to a local stand-in", t, func() {
+		fake := newFakeOpenStack()
+		defer fake.Close()
+
+		o := New(NewClient(fake.config()))
+		ctx := context.Background()
+		thing := func(address string) *database.Thing {
+			return &database.Thing{Address: address, Type: database.ThingsTypeOpenstack}
+		}
+
+		Convey("You can tell if resources of each kind exist", func() {
+			for address, expected := range map[string]bool{
+				"instance/i1":         true,
+				"instance/missing":    false,
+				"volume/v1":           true,
+				"volume/missing":      false,
+				"image/im1":           true,
+				"image/missing":       false,
+				"floatingip/fip-1":    true,
+				"floatingip/10.0.0.1": true,
+				"floatingip/10.0.0.2": false,
+			} {
+				exists, err := o.Exists(ctx, thing(address))
+				So(err, ShouldBeNil)
+				So(exists, ShouldEqual, expected)
+			}
+
+			So(fake.auths, ShouldEqual, 1)
+		})
+
+		Convey("You can delete resources of each kind", func() {
+			for _, address := range []string{"instance/i1", "volume/v1", "image/im1", "floatingip/10.0.0.1"} {
+				So(o.Remove(ctx, thing(address)), ShouldBeNil)
+
+				exists, err := o.Exists(ctx, thing(address))
+				So(err, ShouldBeNil)
+				So(exists, ShouldBeFalse)
+			}
+
+			So(fake.exists("/network/v2.0/floatingips/fip-1"), ShouldBeFalse)
+
+			Convey("and deleting them again is not an error", func() {
+				So(o.Remove(ctx, thing("instance/i1")), ShouldBeNil)
+				So(o.Remove(ctx, thing("floatingip/10.0.0.1")), ShouldBeNil)
+			})
+		})
+
+		Convey("Only the creators of instances and volumes can register them", func() {
+			user := &backend.Identity{Username: "user"}
+
+			other := &backend.Identity{Username: "other"}
+
+			So(o.VerifyOwner(ctx, thing("instance/i1"), user), ShouldBeNil)
+			So(o.VerifyOwner(ctx, thing("volume/v1"), other), ShouldBeNil)
+
+			err := o.VerifyOwner(ctx, thing("instance/i1"), other)
+			So(errors.Is(err, backend.ErrNotOwner), ShouldBeTrue)
+
+			for _, address := range []string{"volume/v1", "image/im1", "floatingip/10.0.0.1", "instance/missing"} {
+				err = o.VerifyOwner(ctx, thing(address), user)
+				So(errors.Is(err, backend.ErrNotOwner), ShouldBeTrue)
+			}
+
+			So(o.VerifyOwner(ctx, thing("bad"), user), ShouldEqual, ErrBadAddress)
+		})
+
+		Convey("Failures to delete are returned", func() {
+			err := o.Remove(ctx, thing("volume/inuse"))
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "volume is attached")
+			So(fake.exists(fakeInUse), ShouldBeTrue)
+		})
+
+		Convey("Bad addresses are errors", func() {
+			_, err := o.Exists(ctx, thing("bad"))
+			So(err, ShouldEqual, ErrBadAddress)
+			So(o.Remove(ctx, thing("bad")), ShouldEqual, ErrBadAddress)
+		})
+
+		Convey("Bad credentials are errors", func() {
+			config := fake.config()
+			config.Password = "wrong"
+			o = New(NewClient(config))
+
+			_, err := o.Exists(ctx, thing("instance/i1"))
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "authentication failed")
+		})
+
+		Convey("Missing services are errors", func() {
+			config := fake.config()
+			config.RegionName = "RegionTwo"
+			o = New(NewClient(config))
+
+			_, err := o.Exists(ctx, thing("volume/v1"))
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "no volume service")
+		})
+	})
+}
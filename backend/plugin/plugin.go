@@ -0,0 +1,350 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+// package plugin is a backend for site-specific ThingsTypes whose things are
+// dealt with by external executables.
+//
+// A plugin executable is run once per request, with a JSON Request on its
+// stdin, and must write a JSON Response to its stdout and exit 0. Anything it
+// writes to stderr is recorded in the history of the thing the request was
+// about. Every plugin must handle all 4 Actions:
+//
+//	validate: set "error" if the address is not valid for the type.
+//	exists:   set "exists" to whether something exists at the address.
+//	size:     set "exists", and if true, "size" in bytes, "files" and
+//	          "modified" (RFC 3339) if known.
+//	remove:   remove what exists at the address; not an error if nothing does.
+//
+// Setting "error" or exiting non-zero means the request failed.
+
+package plugin
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/wtsi-hgi/tt/database"
+)
+
+const (
+	ErrTimeout    = database.Error("plugin timed out")
+	ErrBadConfig  = database.Error("plugin config must have a type and exec")
+	ErrBadAddress = database.Error("That address is not valid for that type")
+
+	DefaultTimeout = 1 * time.Minute
+
+	// maxStderr is the most stderr output recorded in a thing's history per
+	// request.
+	maxStderr = 4096
+
+	// waitDelay is how long we wait for a timed-out plugin's output to close,
+	// in case it started processes that outlive it.
+	waitDelay = time.Second
+)
+
+// Action is the kind of request being made of a plugin.
+type Action string
+
+const (
+	ActionValidate Action = "validate"
+	ActionExists   Action = "exists"
+	ActionSize     Action = "size"
+	ActionRemove   Action = "remove"
+)
+
+// Request is what a plugin receives on its stdin. For ActionValidate, only
+// Action, Type and Address are set.
+type Request struct {
+	Action      Action `json:"action"`
+	ID          uint32 `json:"id,omitempty"`
+	Type        string `json:"type"`
+	Address     string `json:"address"`
+	Description string `json:"description,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+	Remove      string `json:"remove,omitempty"` // YYYY-MM-DD
+	Creator     string `json:"creator,omitempty"`
+}
+
+// Response is what a plugin writes to its stdout.
+type Response struct {
+	Error    string    `json:"error,omitempty"`
+	Exists   bool      `json:"exists,omitempty"`
+	Size     int64     `json:"size,omitempty"`
+	Files    int64     `json:"files,omitempty"`
+	Modified time.Time `json:"modified,omitempty"`
+}
+
+// Auditor records events in the history of things. database.Queries is an
+// Auditor.
+type Auditor interface {
+	AddAuditEvent(event database.AuditEvent) error
+}
+
+// Plugin is a backend.Prober and backend.Remover that passes requests to an
+// external executable.
+type Plugin struct {
+	thingsType database.ThingsType
+	exe        string
+	timeout    time.Duration
+	auditor    Auditor
+}
+
+// New returns a Plugin for the given ThingsType that runs the given executable,
+// killing it if it takes longer than timeout (< 1 means DefaultTimeout). Its
+// stderr output is recorded using the given auditor, which can be nil to
+// discard it.
+func New(thingsType database.ThingsType, exe string, timeout time.Duration, auditor Auditor) *Plugin {
+	if timeout < 1 {
+		timeout = DefaultTimeout
+	}
+
+	return &Plugin{
+		thingsType: thingsType,
+		exe:        exe,
+		timeout:    timeout,
+		auditor:    auditor,
+	}
+}
+
+// Validate asks the plugin if the given address is valid for its type. If not,
+// returns an error that Is() ErrBadAddress, which includes the plugin's reason.
+func (p *Plugin) Validate(address string) error {
+	resp, err := p.run(context.Background(), Request{
+		Action:  ActionValidate,
+		Type:    string(p.thingsType),
+		Address: address,
+	})
+	if err != nil {
+		return err
+	}
+
+	if resp.Error != "" {
+		return fmt.Errorf("%w: %s", ErrBadAddress, resp.Error)
+	}
+
+	return nil
+}
+
+// Exists asks the plugin if something exists at the thing's address.
+func (p *Plugin) Exists(ctx context.Context, thing *database.Thing) (bool, error) {
+	resp, err := p.request(ctx, ActionExists, thing)
+	if err != nil {
+		return false, err
+	}
+
+	return resp.Exists, nil
+}
+
+// Probe asks the plugin for the size of what exists at the thing's address.
+func (p *Plugin) Probe(ctx context.Context, thing *database.Thing) (*database.ProbeResult, error) {
+	resp, err := p.request(ctx, ActionSize, thing)
+	if err != nil {
+		return nil, err
+	}
+
+	if !resp.Exists {
+		return &database.ProbeResult{}, nil
+	}
+
+	return &database.ProbeResult{
+		Exists:   true,
+		Size:     resp.Size,
+		Files:    resp.Files,
+		Modified: resp.Modified,
+	}, nil
+}
+
+// Remove asks the plugin to remove what exists at the thing's address.
+func (p *Plugin) Remove(ctx context.Context, thing *database.Thing) error {
+	_, err := p.request(ctx, ActionRemove, thing)
+
+	return err
+}
+
+// request runs the plugin with a Request for the given action on the given
+// thing, returning an error if the plugin's Response has one.
+func (p *Plugin) request(ctx context.Context, action Action, thing *database.Thing) (*Response, error) {
+	resp, err := p.run(ctx, Request{
+		Action:      action,
+		ID:          thing.ID,
+		Type:        string(thing.Type),
+		Address:     thing.Address,
+		Description: thing.Description,
+		Reason:      thing.Reason,
+		Remove:      thing.Remove.Format(time.DateOnly),
+		Creator:     thing.Creator,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Error != "" {
+		return nil, fmt.Errorf("plugin %s %s failed: %s", p.exe, action, resp.Error)
+	}
+
+	return resp, nil
+}
+
+// run runs the plugin with the given request on its stdin, and decodes its
+// stdout. Any stderr output is audited against the request's thing.
+func (p *Plugin) run(ctx context.Context, req Request) (*Response, error) {
+	input, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, p.exe)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.WaitDelay = waitDelay
+
+	err = cmd.Run()
+
+	if auditErr := p.audit(req, stderr.String()); auditErr != nil {
+		return nil, fmt.Errorf("recording output of plugin %s %s failed: %w", p.exe, req.Action, auditErr)
+	}
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("%w: %s %s took longer than %s", ErrTimeout, p.exe, req.Action, p.timeout)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s %s failed: %w: %s", p.exe, req.Action, err, firstLine(&stderr))
+	}
+
+	resp := &Response{}
+
+	if err = json.Unmarshal(stdout.Bytes(), resp); err != nil {
+		return nil, fmt.Errorf("plugin %s %s gave invalid output: %w", p.exe, req.Action, err)
+	}
+
+	return resp, nil
+}
+
+// audit records the given stderr output of a plugin in the history of the
+// request's thing, if it has any and the request was about an existing thing.
+func (p *Plugin) audit(req Request, stderr string) error {
+	stderr = strings.TrimSpace(stderr)
+	if p.auditor == nil || req.ID == 0 || stderr == "" {
+		return nil
+	}
+
+	if len(stderr) > maxStderr {
+		stderr = stderr[:maxStderr] + "..."
+	}
+
+	return p.auditor.AddAuditEvent(database.AuditEvent{
+		ThingID: req.ID,
+		Action:  database.AuditPluginOutput,
+		Detail:  fmt.Sprintf("%s: %s", req.Action, stderr),
+	})
+}
+
+func firstLine(buf *bytes.Buffer) string {
+	scanner := bufio.NewScanner(buf)
+	scanner.Scan()
+
+	return scanner.Text()
+}
+
+// Config describes a plugin for a ThingsType.
+type Config struct {
+	// Type is the name of the ThingsType the plugin deals with.
+	Type database.ThingsType `json:"type"`
+
+	// Label is how the type is shown to users. Defaults to Type.
+	Label string `json:"label"`
+
+	// Exec is the path to the plugin executable.
+	Exec string `json:"exec"`
+
+	// Timeout is how long each request can take, eg. "30s". Defaults to
+	// DefaultTimeout.
+	Timeout string `json:"timeout"`
+}
+
+// LoadConfig reads a JSON file containing an array of Configs.
+func LoadConfig(path string) ([]Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var configs []Config
+
+	if err = json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("invalid plugin config %s: %w", path, err)
+	}
+
+	return configs, nil
+}
+
+// Register registers a new ThingsType for each of the given configs, with a
+// Plugin as its Backend. The Plugin validates addresses of the type, and
+// records stderr output using the given auditor.
+func Register(configs []Config, auditor Auditor) error {
+	for _, config := range configs {
+		if config.Type == "" || config.Exec == "" {
+			return ErrBadConfig
+		}
+
+		var timeout time.Duration
+
+		if config.Timeout != "" {
+			var err error
+
+			if timeout, err = time.ParseDuration(config.Timeout); err != nil {
+				return fmt.Errorf("invalid timeout for plugin type %s: %w", config.Type, err)
+			}
+		}
+
+		plugin := New(config.Type, config.Exec, timeout, auditor)
+
+		if err := database.RegisterThingsType(database.ThingsTypeInfo{
+			Name:     config.Type,
+			Label:    config.Label,
+			Validate: plugin.Validate,
+			Backend:  plugin,
+		}); err != nil {
+			return fmt.Errorf("registering plugin type %s: %w", config.Type, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,250 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package plugin
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/wtsi-hgi/tt/backend"
+	"github.com/wtsi-hgi/tt/database"
+)
+
+const examplePlugin = "example/tt-plugin-scratch"
+
+const slowPlugin = `#!/bin/sh
+sleep 5
+echo '{}'
+`
+
+const brokenPlugin = `#!/bin/sh
+echo "it broke" >&2
+exit 3
+`
+
+const badOutputPlugin = `#!/bin/sh
+echo "not json"
+`
+
+const errorPlugin = `#!/bin/sh
+cat > "$TT_TEST_PLUGIN_REQUEST"
+echo '{"error":"computer says no"}'
+`
+
+type mockAuditor struct {
+	events []database.AuditEvent
+	err    error
+}
+
+func (m *mockAuditor) AddAuditEvent(event database.AuditEvent) error {
+	m.events = append(m.events, event)
+
+	return m.err
+}
+
+func writeScript(dir, name, script string) string {
+	path := filepath.Join(dir, name)
+
+	err := os.WriteFile(path, []byte(script), 0o700)
+	So(err, ShouldBeNil)
+
+	return path
+}
+
+func TestPlugin(t *testing.T) {
+	Convey("Given the example plugin and a scratch area", t, func() {
+		root := t.TempDir()
+		t.Setenv("TT_SCRATCH_ROOT", root)
+
+		exe, err := filepath.Abs(examplePlugin)
+		So(err, ShouldBeNil)
+
+		auditor := &mockAuditor{}
+		p := New("scratch", exe, 0, auditor)
+		So(p.timeout, ShouldEqual, DefaultTimeout)
+
+		ctx := context.Background()
+		dir := filepath.Join(root, "dir")
+		So(os.MkdirAll(filepath.Join(dir, "sub"), 0o700), ShouldBeNil)
+		So(os.WriteFile(filepath.Join(dir, "a"), []byte("a"), 0o600), ShouldBeNil)
+		So(os.WriteFile(filepath.Join(dir, "sub", "b"), []byte("b"), 0o600), ShouldBeNil)
+
+		thing := &database.Thing{ID: 1, Type: "scratch", Address: dir, Remove: time.Now()}
+
+		Convey("You can validate addresses", func() {
+			So(p.Validate(dir), ShouldBeNil)
+
+			err := p.Validate("/elsewhere/dir")
+			So(errors.Is(err, ErrBadAddress), ShouldBeTrue)
+			So(err.Error(), ShouldContainSubstring, "scratch addresses must be under "+root)
+		})
+
+		Convey("You can check if things exist", func() {
+			exists, err := p.Exists(ctx, thing)
+			So(err, ShouldBeNil)
+			So(exists, ShouldBeTrue)
+
+			exists, err = p.Exists(ctx, &database.Thing{ID: 2, Type: "scratch", Address: filepath.Join(root, "missing")})
+			So(err, ShouldBeNil)
+			So(exists, ShouldBeFalse)
+		})
+
+		Convey("You can probe things", func() {
+			result, err := p.Probe(ctx, thing)
+			So(err, ShouldBeNil)
+			So(result.Exists, ShouldBeTrue)
+			So(result.Files, ShouldEqual, 2)
+			So(result.Size, ShouldBeGreaterThan, 0)
+
+			result, err = p.Probe(ctx, &database.Thing{ID: 2, Type: "scratch", Address: filepath.Join(root, "missing")})
+			So(err, ShouldBeNil)
+			So(result.Exists, ShouldBeFalse)
+		})
+
+		Convey("You can remove things, with stderr recorded in their history", func() {
+			So(p.Remove(ctx, thing), ShouldBeNil)
+
+			_, err := os.Stat(dir)
+			So(os.IsNotExist(err), ShouldBeTrue)
+
+			So(auditor.events, ShouldResemble, []database.AuditEvent{{
+				ThingID: 1,
+				Action:  database.AuditPluginOutput,
+				Detail:  "remove: removed " + dir,
+			}})
+
+			Convey("and failure to record that is an error", func() {
+				auditor.err = errors.New("db down")
+
+				err := p.Remove(ctx, thing)
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "db down")
+			})
+		})
+	})
+
+	Convey("Given plugins that misbehave", t, func() {
+		dir := t.TempDir()
+		auditor := &mockAuditor{}
+		ctx := context.Background()
+		thing := &database.Thing{ID: 1, Type: "bad", Address: "a", Description: "desc",
+			Reason: "why", Remove: time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC), Creator: "user"}
+
+		Convey("Slow plugins time out", func() {
+			p := New("bad", writeScript(dir, "slow", slowPlugin), 100*time.Millisecond, auditor)
+
+			start := time.Now()
+			_, err := p.Exists(ctx, thing)
+			So(errors.Is(err, ErrTimeout), ShouldBeTrue)
+			So(time.Since(start), ShouldBeLessThan, 3*time.Second)
+		})
+
+		Convey("Non-zero exits are errors that include the first line of stderr", func() {
+			p := New("bad", writeScript(dir, "broken", brokenPlugin), 0, auditor)
+
+			err := p.Remove(ctx, thing)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "it broke")
+			So(len(auditor.events), ShouldEqual, 1)
+			So(auditor.events[0].Detail, ShouldEqual, "remove: it broke")
+
+			err = p.Validate("a")
+			So(err, ShouldNotBeNil)
+			So(len(auditor.events), ShouldEqual, 1)
+		})
+
+		Convey("Invalid output is an error", func() {
+			p := New("bad", writeScript(dir, "badoutput", badOutputPlugin), 0, auditor)
+
+			_, err := p.Probe(ctx, thing)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "invalid output")
+		})
+
+		Convey("Errors in responses are errors, and plugins get the thing's details", func() {
+			requestPath := filepath.Join(dir, "request")
+			t.Setenv("TT_TEST_PLUGIN_REQUEST", requestPath)
+
+			p := New("bad", writeScript(dir, "error", errorPlugin), 0, auditor)
+
+			err := p.Remove(ctx, thing)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "computer says no")
+
+			request, err := os.ReadFile(requestPath)
+			So(err, ShouldBeNil)
+			So(string(request), ShouldEqual, `{"action":"remove","id":1,"type":"bad","address":"a",`+
+				`"description":"desc","reason":"why","remove":"2030-01-02","creator":"user"}`)
+		})
+	})
+}
+
+func TestRegister(t *testing.T) {
+	Convey("You can load plugin configs from a file and register them", t, func() {
+		dir := t.TempDir()
+		path := writeScript(dir, "plugins.json", `[
+			{"type": "plugin-test-type", "label": "Plugin test", "exec": "/bin/true", "timeout": "5s"}
+		]`)
+
+		configs, err := LoadConfig(path)
+		So(err, ShouldBeNil)
+		So(configs, ShouldResemble, []Config{{Type: "plugin-test-type", Label: "Plugin test",
+			Exec: "/bin/true", Timeout: "5s"}})
+
+		err = Register(configs, nil)
+		So(err, ShouldBeNil)
+
+		_, ok := backend.RemoverFor("plugin-test-type")
+		So(ok, ShouldBeTrue)
+
+		_, ok = backend.ProberFor("plugin-test-type")
+		So(ok, ShouldBeTrue)
+
+		So(database.ThingsType("plugin-test-type").Label(), ShouldEqual, "Plugin test")
+		So(database.ThingsType("plugin-test-type").Backend().(*Plugin).timeout, ShouldEqual, 5*time.Second)
+
+		err = Register(configs, nil)
+		So(errors.Is(err, database.ErrTypeRegistered), ShouldBeTrue)
+
+		err = Register([]Config{{Type: "plugin-test-type2"}}, nil)
+		So(err, ShouldEqual, ErrBadConfig)
+
+		err = Register([]Config{{Type: "plugin-test-type2", Exec: "a", Timeout: "soon"}}, nil)
+		So(err, ShouldNotBeNil)
+		So(strings.Contains(err.Error(), "invalid timeout"), ShouldBeTrue)
+
+		writeScript(dir, "bad.json", `{"type": "x"}`)
+
+		_, err = LoadConfig(filepath.Join(dir, "bad.json"))
+		So(err, ShouldNotBeNil)
+	})
+}
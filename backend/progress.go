@@ -0,0 +1,81 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+package backend
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/wtsi-hgi/tt/database"
+)
+
+type progressKey struct{}
+
+// Progress counts what a Remover, Quarantiner or Archiver has removed so far
+// while removing a thing, so that it can be shown to users. It is safe for
+// concurrent use.
+type Progress struct {
+	files  atomic.Int64
+	bytes  atomic.Int64
+	errors atomic.Int64
+}
+
+// WithProgress returns a copy of ctx that carries the given Progress, for
+// backends to report to when passed the returned context.
+func WithProgress(ctx context.Context, progress *Progress) context.Context {
+	return context.WithValue(ctx, progressKey{}, progress)
+}
+
+// ProgressFrom returns the Progress carried by ctx. If there isn't one, returns
+// a new Progress that nothing will read, so backends can always report to the
+// result.
+func ProgressFrom(ctx context.Context) *Progress {
+	if progress, ok := ctx.Value(progressKey{}).(*Progress); ok {
+		return progress
+	}
+
+	return &Progress{}
+}
+
+// Removed records that the given number of files, totalling the given number of
+// bytes, have been removed.
+func (p *Progress) Removed(files, bytes int64) {
+	p.files.Add(files)
+	p.bytes.Add(bytes)
+}
+
+// Failed records that a file couldn't be removed.
+func (p *Progress) Failed() {
+	p.errors.Add(1)
+}
+
+// Snapshot returns the progress so far.
+func (p *Progress) Snapshot() database.RemovalProgress {
+	return database.RemovalProgress{
+		Files:  p.files.Load(),
+		Bytes:  p.bytes.Load(),
+		Errors: p.errors.Load(),
+	}
+}
@@ -0,0 +1,318 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package s3
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	fakeLastModified = "Mon, 02 Jan 2006 15:04:05 GMT"
+	fakeOwner        = "user"
+)
+
+// fakeS3 is an http.Handler that implements just enough of the S3 API, with
+// path-style bucket lookup, for our S3 backend to be tested against it.
+type fakeS3 struct {
+	mu      sync.Mutex
+	buckets map[string]map[string]map[string]string // bucket -> key -> tags
+	locked  map[string]bool                         // keys that can't be deleted
+	owners  map[string]string                       // key -> owner, if not fakeOwner
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{
+		buckets: make(map[string]map[string]map[string]string),
+		locked:  make(map[string]bool),
+		owners:  make(map[string]string),
+	}
+}
+
+// put creates an object with no tags, creating its bucket if necessary.
+func (f *fakeS3) put(bucket, key string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.buckets[bucket] == nil {
+		f.buckets[bucket] = make(map[string]map[string]string)
+	}
+
+	f.buckets[bucket][key] = make(map[string]string)
+}
+
+// tags returns the tags of an object, or nil if it doesn't exist.
+func (f *fakeS3) tags(bucket, key string) map[string]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.buckets[bucket][key]
+}
+
+// keys returns the sorted keys of the objects in a bucket.
+func (f *fakeS3) keys(bucket string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return sortedKeys(f.buckets[bucket])
+}
+
+func sortedKeys(objects map[string]map[string]string) []string {
+	keys := make([]string, 0, len(objects))
+
+	for key := range objects {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+type fakeTagging struct {
+	XMLName xml.Name `xml:"Tagging"`
+	Tags    []struct {
+		Key   string `xml:"Key"`
+		Value string `xml:"Value"`
+	} `xml:"TagSet>Tag"`
+}
+
+type fakeDelete struct {
+	Objects []struct {
+		Key string `xml:"Key"`
+	} `xml:"Object"`
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	query := r.URL.Query()
+
+	objects, ok := f.buckets[bucket]
+	if !ok {
+		fakeError(w, r, http.StatusNotFound, "NoSuchBucket")
+
+		return
+	}
+
+	if key == "" {
+		f.serveBucket(w, r, bucket, objects)
+
+		return
+	}
+
+	objTags, ok := objects[key]
+	if !ok && r.Method == http.MethodDelete {
+		w.WriteHeader(http.StatusNoContent)
+
+		return
+	}
+
+	if !ok {
+		fakeError(w, r, http.StatusNotFound, "NoSuchKey")
+
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodHead:
+		w.Header().Set("Last-Modified", fakeLastModified)
+		w.Header().Set("ETag", `"etag"`)
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Length", "1")
+	case r.Method == http.MethodGet && query.Has("tagging"):
+		var tagging fakeTagging
+
+		for _, k := range sortedTagKeys(objTags) {
+			tagging.Tags = append(tagging.Tags, struct {
+				Key   string `xml:"Key"`
+				Value string `xml:"Value"`
+			}{k, objTags[k]})
+		}
+
+		writeXML(w, tagging)
+	case r.Method == http.MethodPut && query.Has("tagging"):
+		var tagging fakeTagging
+
+		if err := xml.NewDecoder(r.Body).Decode(&tagging); err != nil {
+			fakeError(w, r, http.StatusBadRequest, "MalformedXML")
+
+			return
+		}
+
+		newTags := make(map[string]string)
+		for _, tag := range tagging.Tags {
+			newTags[tag.Key] = tag.Value
+		}
+
+		objects[key] = newTags
+	case r.Method == http.MethodDelete:
+		delete(objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		fakeError(w, r, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+func (f *fakeS3) serveBucket(w http.ResponseWriter, r *http.Request, bucket string,
+	objects map[string]map[string]string) {
+	query := r.URL.Query()
+
+	switch {
+	case r.Method == http.MethodGet && query.Get("list-type") == "2":
+		writeXML(w, f.listObjects(bucket, objects, query.Get("prefix"),
+			query.Get("continuation-token"), query.Get("max-keys")))
+	case r.Method == http.MethodPost && query.Has("delete"):
+		var del fakeDelete
+
+		if err := xml.NewDecoder(r.Body).Decode(&del); err != nil {
+			fakeError(w, r, http.StatusBadRequest, "MalformedXML")
+
+			return
+		}
+
+		fmt.Fprint(w, `<DeleteResult>`)
+
+		for _, obj := range del.Objects {
+			if f.locked[obj.Key] {
+				fmt.Fprintf(w, `<Error><Key>%s</Key><Code>AccessDenied</Code><Message>locked</Message></Error>`,
+					obj.Key)
+
+				continue
+			}
+
+			delete(objects, obj.Key)
+			fmt.Fprintf(w, `<Deleted><Key>%s</Key></Deleted>`, obj.Key)
+		}
+
+		fmt.Fprint(w, `</DeleteResult>`)
+	default:
+		fakeError(w, r, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+type fakeListResult struct {
+	XMLName               xml.Name `xml:"ListBucketResult"`
+	Name                  string
+	Prefix                string
+	KeyCount              int
+	MaxKeys               int
+	IsTruncated           bool
+	NextContinuationToken string `xml:",omitempty"`
+	Contents              []fakeContent
+}
+
+type fakeContent struct {
+	Key          string
+	LastModified string
+	ETag         string
+	Size         int
+	StorageClass string
+	Owner        fakeObjectOwner
+}
+
+type fakeObjectOwner struct {
+	ID          string
+	DisplayName string
+}
+
+func (f *fakeS3) listObjects(bucket string, objects map[string]map[string]string, prefix, after, maxKeysStr string) fakeListResult {
+	maxKeys, err := strconv.Atoi(maxKeysStr)
+	if err != nil || maxKeys < 1 {
+		maxKeys = 1000
+	}
+
+	result := fakeListResult{Name: bucket, Prefix: prefix, MaxKeys: maxKeys}
+
+	for _, key := range sortedKeys(objects) {
+		if !strings.HasPrefix(key, prefix) || (after != "" && key <= after) {
+			continue
+		}
+
+		if len(result.Contents) == maxKeys {
+			result.IsTruncated = true
+			result.NextContinuationToken = result.Contents[maxKeys-1].Key
+
+			break
+		}
+
+		result.Contents = append(result.Contents, fakeContent{
+			Key:          key,
+			LastModified: time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC).Format(time.RFC3339),
+			ETag:         `"etag"`,
+			Size:         1,
+			StorageClass: "STANDARD",
+			Owner:        fakeObjectOwner{ID: f.owner(key), DisplayName: f.owner(key)},
+		})
+	}
+
+	result.KeyCount = len(result.Contents)
+
+	return result
+}
+
+func (f *fakeS3) owner(key string) string {
+	if owner, ok := f.owners[key]; ok {
+		return owner
+	}
+
+	return fakeOwner
+}
+
+func sortedTagKeys(tags map[string]string) []string {
+	keys := make([]string, 0, len(tags))
+
+	for key := range tags {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+func writeXML(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/xml")
+
+	xml.NewEncoder(w).Encode(v) //nolint:errcheck
+}
+
+func fakeError(w http.ResponseWriter, r *http.Request, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+
+	if r.Method != http.MethodHead {
+		fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+	}
+}
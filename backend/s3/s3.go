@@ -0,0 +1,394 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+// package s3 is a backend for Things of ThingsTypeS3, which have addresses of
+// the form [s3://]bucket/key for a single object, or [s3://]bucket/prefix/ for
+// all the objects under a prefix (with a blank prefix meaning the whole
+// bucket, which can only be registered and removed if Config.WholeBuckets is
+// set).
+
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/tags"
+	"github.com/wtsi-hgi/tt/backend"
+	"github.com/wtsi-hgi/tt/database"
+)
+
+const (
+	ErrBadAddress  = database.Error("S3 addresses must be of the form [s3://]bucket/key")
+	ErrWholeBucket = database.Error("S3 addresses must have a key or prefix, unless whole buckets are allowed")
+
+	DefaultRegion = "us-east-1"
+
+	addressScheme = "s3://"
+
+	// TagID is the object tag that holds the ID of the Thing.
+	TagID = "tt_id"
+
+	// TagRemove is the object tag that holds the removal date of the Thing.
+	TagRemove = "tt_remove"
+)
+
+func init() {
+	if err := database.RegisterThingsType(database.ThingsTypeInfo{
+		Name:     database.ThingsTypeS3,
+		Label:    "S3",
+		Validate: validateAddress,
+	}); err != nil {
+		panic(err)
+	}
+}
+
+// Config configures how to connect to an S3-compatible service.
+type Config struct {
+	// Endpoint is the host:port of the service.
+	Endpoint string
+
+	AccessKey string
+	SecretKey string
+
+	// Region defaults to DefaultRegion.
+	Region string
+
+	// Secure means use https to connect.
+	Secure bool
+
+	// WholeBuckets allows things with a blank prefix, which refer to every
+	// object in a bucket, to be registered and removed.
+	WholeBuckets bool
+}
+
+// S3 is a backend.Remover, backend.Registrar and backend.OwnerVerifier for s3
+// Things. On registration, it tags the objects at the thing's address with the
+// thing's ID and removal date, so that lifecycle rules in the S3 service can
+// act on them.
+type S3 struct {
+	client       *minio.Client
+	wholeBuckets bool
+}
+
+// New returns an S3 that connects to the configured service.
+func New(config Config) (*S3, error) {
+	region := config.Region
+	if region == "" {
+		region = DefaultRegion
+	}
+
+	client, err := minio.New(config.Endpoint, &minio.Options{
+		Creds:        credentials.NewStaticV4(config.AccessKey, config.SecretKey, ""),
+		Secure:       config.Secure,
+		Region:       region,
+		BucketLookup: minio.BucketLookupPath,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &S3{client: client, wholeBuckets: config.WholeBuckets}, nil
+}
+
+// location is a parsed s3 thing address.
+type location struct {
+	bucket string
+	key    string
+}
+
+// parseAddress splits the given address in to its bucket and key.
+func parseAddress(address string) (*location, error) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(address, addressScheme), "/")
+	if bucket == "" {
+		return nil, ErrBadAddress
+	}
+
+	return &location{bucket: bucket, key: key}, nil
+}
+
+// validateAddress returns ErrBadAddress if the given address can't be parsed.
+func validateAddress(address string) error {
+	_, err := parseAddress(address)
+
+	return err
+}
+
+// locate parses the given address like parseAddress(), but also returns
+// ErrWholeBucket if it refers to a whole bucket and those aren't allowed.
+func (s *S3) locate(address string) (*location, error) {
+	loc, err := parseAddress(address)
+	if err != nil {
+		return nil, err
+	}
+
+	if loc.key == "" && !s.wholeBuckets {
+		return nil, ErrWholeBucket
+	}
+
+	return loc, nil
+}
+
+// isPrefix returns true if this location refers to all the objects under a
+// prefix, instead of a single object.
+func (l *location) isPrefix() bool {
+	return l.key == "" || strings.HasSuffix(l.key, "/")
+}
+
+// Exists returns true if the thing's object exists, or if there is at least one
+// object under its prefix.
+func (s *S3) Exists(ctx context.Context, thing *database.Thing) (bool, error) {
+	loc, err := parseAddress(thing.Address)
+	if err != nil {
+		return false, err
+	}
+
+	if !loc.isPrefix() {
+		return s.objectExists(ctx, loc)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	for obj := range s.client.ListObjects(ctx, loc.bucket, minio.ListObjectsOptions{
+		Prefix:    loc.key,
+		Recursive: true,
+		MaxKeys:   1,
+	}) {
+		if obj.Err != nil {
+			return false, ignoreNotFound(obj.Err)
+		}
+
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func (s *S3) objectExists(ctx context.Context, loc *location) (bool, error) {
+	_, err := s.client.StatObject(ctx, loc.bucket, loc.key, minio.StatObjectOptions{})
+	if err != nil {
+		return false, ignoreNotFound(err)
+	}
+
+	return true, nil
+}
+
+// ignoreNotFound returns nil if err is due to a bucket or object not existing,
+// otherwise returns err.
+func ignoreNotFound(err error) error {
+	if minio.ToErrorResponse(err).StatusCode == http.StatusNotFound {
+		return nil
+	}
+
+	return err
+}
+
+// VerifyOwner returns nil if the given user is the owner (by ID or display
+// name, as reported by the S3 service) of the thing's object, or of every
+// object under its prefix. Since there is nothing to check ownership of,
+// addresses with no objects are refused.
+func (s *S3) VerifyOwner(ctx context.Context, thing *database.Thing, identity *backend.Identity) error {
+	loc, err := s.locate(thing.Address)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	owned := false
+
+	for obj := range s.client.ListObjects(ctx, loc.bucket, minio.ListObjectsOptions{
+		Prefix:    loc.key,
+		Recursive: true,
+	}) {
+		if obj.Err != nil {
+			if err := ignoreNotFound(obj.Err); err != nil {
+				return err
+			}
+
+			break
+		}
+
+		if !loc.isPrefix() && obj.Key != loc.key {
+			continue
+		}
+
+		if obj.Owner.ID != identity.Username && obj.Owner.DisplayName != identity.Username {
+			return fmt.Errorf("%w: %s/%s", backend.ErrNotOwner, loc.bucket, obj.Key)
+		}
+
+		owned = true
+	}
+
+	if !owned {
+		return fmt.Errorf("%w: %s has no objects", backend.ErrNotOwner, thing.Address)
+	}
+
+	return nil
+}
+
+// Register tags the thing's object, or every object under its prefix, with
+// the thing's ID and removal date, keeping any existing tags. It is not an
+// error if there are no objects to tag. Returns ErrWholeBucket for things
+// referring to a whole bucket, unless Config.WholeBuckets was set.
+func (s *S3) Register(ctx context.Context, thing *database.Thing) error {
+	loc, err := s.locate(thing.Address)
+	if err != nil {
+		return err
+	}
+
+	ttTags := map[string]string{
+		TagID:     strconv.FormatUint(uint64(thing.ID), 10),
+		TagRemove: thing.Remove.Format(time.DateOnly),
+	}
+
+	return ignoreNotFound(s.forEachObject(ctx, loc, func(key string) error {
+		return s.addTags(ctx, loc.bucket, key, ttTags)
+	}))
+}
+
+// forEachObject calls cb with the key of the location's object, or the key of
+// every object under its prefix. Stops and returns the first error.
+func (s *S3) forEachObject(ctx context.Context, loc *location, cb func(key string) error) error {
+	if !loc.isPrefix() {
+		return cb(loc.key)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	for obj := range s.client.ListObjects(ctx, loc.bucket, minio.ListObjectsOptions{
+		Prefix:    loc.key,
+		Recursive: true,
+	}) {
+		if obj.Err != nil {
+			return obj.Err
+		}
+
+		if err := cb(obj.Key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *S3) addTags(ctx context.Context, bucket, key string, add map[string]string) error {
+	existing, err := s.client.GetObjectTagging(ctx, bucket, key, minio.GetObjectTaggingOptions{})
+	if err != nil {
+		return err
+	}
+
+	merged := existing.ToMap()
+	for k, v := range add {
+		merged[k] = v
+	}
+
+	objTags, err := tags.NewTags(merged, true)
+	if err != nil {
+		return err
+	}
+
+	return s.client.PutObjectTagging(ctx, bucket, key, objTags, minio.PutObjectTaggingOptions{})
+}
+
+// Remove deletes the thing's object, or every object under its prefix,
+// reporting progress to the backend.Progress in ctx as each deletion is
+// confirmed. Returns ErrWholeBucket for things referring to a whole bucket,
+// unless Config.WholeBuckets was set.
+func (s *S3) Remove(ctx context.Context, thing *database.Thing) error {
+	loc, err := s.locate(thing.Address)
+	if err != nil {
+		return err
+	}
+
+	if !loc.isPrefix() {
+		return s.client.RemoveObject(ctx, loc.bucket, loc.key, minio.RemoveObjectOptions{})
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	objects := make(chan minio.ObjectInfo)
+	progress := backend.ProgressFrom(ctx)
+
+	var (
+		listErr error
+		mu      sync.Mutex
+		sizes   = make(map[string]int64)
+	)
+
+	go func() {
+		defer close(objects)
+
+		for obj := range s.client.ListObjects(ctx, loc.bucket, minio.ListObjectsOptions{
+			Prefix:    loc.key,
+			Recursive: true,
+		}) {
+			if obj.Err != nil {
+				listErr = ignoreNotFound(obj.Err)
+
+				return
+			}
+
+			mu.Lock()
+			sizes[obj.Key] = obj.Size
+			mu.Unlock()
+
+			objects <- obj
+		}
+	}()
+
+	var errs []error
+
+	for result := range s.client.RemoveObjectsWithResult(ctx, loc.bucket, objects, minio.RemoveObjectsOptions{}) {
+		if result.Err != nil {
+			progress.Failed()
+
+			errs = append(errs, fmt.Errorf("%s: %w", result.ObjectName, result.Err))
+
+			continue
+		}
+
+		mu.Lock()
+		size := sizes[result.ObjectName]
+		delete(sizes, result.ObjectName)
+		mu.Unlock()
+
+		progress.Removed(1, size)
+	}
+
+	return errors.Join(append(errs, listErr)...)
+}
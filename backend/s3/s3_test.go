@@ -0,0 +1,218 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package s3
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/wtsi-hgi/tt/backend"
+	"github.com/wtsi-hgi/tt/database"
+)
+
+func TestParseAddress(t *testing.T) {
+	Convey("You can parse s3 addresses", t, func() {
+		loc, err := parseAddress("s3://bucket/a/b")
+		So(err, ShouldBeNil)
+		So(loc.bucket, ShouldEqual, "bucket")
+		So(loc.key, ShouldEqual, "a/b")
+		So(loc.isPrefix(), ShouldBeFalse)
+
+		loc, err = parseAddress("bucket/a/")
+		So(err, ShouldBeNil)
+		So(loc.bucket, ShouldEqual, "bucket")
+		So(loc.key, ShouldEqual, "a/")
+		So(loc.isPrefix(), ShouldBeTrue)
+
+		loc, err = parseAddress("bucket")
+		So(err, ShouldBeNil)
+		So(loc.key, ShouldBeBlank)
+		So(loc.isPrefix(), ShouldBeTrue)
+
+		_, err = parseAddress("s3:///key")
+		So(err, ShouldEqual, ErrBadAddress)
+	})
+
+	Convey("The s3 ThingsType is registered with an address validator", t, func() {
+		So(database.ThingsTypeS3.Label(), ShouldEqual, "S3")
+		So(database.ThingsTypeS3.ValidateAddress("s3://bucket/key"), ShouldBeNil)
+		So(database.ThingsTypeS3.ValidateAddress("bucket"), ShouldBeNil)
+		So(database.ThingsTypeS3.ValidateAddress("s3:///key"), ShouldEqual, ErrBadAddress)
+	})
+}
+
+func TestS3(t *testing.T) {
+	Convey("Given a fake S3 service with some objects, and an S3 backend", t, func() {
+		fake := newFakeS3()
+		fake.put("bucket", "a/1")
+		fake.put("bucket", "a/2")
+		fake.put("bucket", "a/b/3")
+		fake.put("bucket", "c")
+		fake.put("other", "d")
+
+		server := httptest.NewServer(fake)
+		defer server.Close()
+
+		s, err := New(Config{
+			Endpoint:  strings.TrimPrefix(server.URL, "http://"),
+			AccessKey: "access",
+			SecretKey: "secret",
+		})
+		So(err, ShouldBeNil)
+
+		ctx := context.Background()
+		remove := time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC)
+		thing := func(address string) *database.Thing {
+			return &database.Thing{ID: 7, Address: address, Type: database.ThingsTypeS3, Remove: remove}
+		}
+
+		Convey("You can tell if objects and prefixes exist", func() {
+			for address, expected := range map[string]bool{
+				"s3://bucket/c":  true,
+				"bucket/a/1":     true,
+				"bucket/a/":      true,
+				"bucket/a/b/":    true,
+				"bucket":         true,
+				"bucket/missing": false,
+				"bucket/x/":      false,
+				"missing/c":      false,
+				"missing/":       false,
+			} {
+				exists, err := s.Exists(ctx, thing(address))
+				So(err, ShouldBeNil)
+				So(exists, ShouldEqual, expected)
+			}
+		})
+
+		Convey("Only the owner of every object at an address can register it", func() {
+			user := &backend.Identity{Username: "user"}
+			other := &backend.Identity{Username: "other"}
+
+			So(s.VerifyOwner(ctx, thing("bucket/c"), user), ShouldBeNil)
+			So(s.VerifyOwner(ctx, thing("bucket/a/"), user), ShouldBeNil)
+
+			for _, address := range []string{"bucket/c", "bucket/a/", "bucket/missing", "missing/c"} {
+				err := s.VerifyOwner(ctx, thing(address), other)
+				So(errors.Is(err, backend.ErrNotOwner), ShouldBeTrue)
+			}
+
+			fake.owners["a/b/3"] = "other"
+
+			So(s.VerifyOwner(ctx, thing("bucket/a/1"), user), ShouldBeNil)
+			So(errors.Is(s.VerifyOwner(ctx, thing("bucket/a/"), user), backend.ErrNotOwner), ShouldBeTrue)
+			So(s.VerifyOwner(ctx, thing("bucket/a/b/3"), other), ShouldBeNil)
+
+			So(s.VerifyOwner(ctx, thing("bucket"), user), ShouldEqual, ErrWholeBucket)
+			So(s.VerifyOwner(ctx, thing("/c"), user), ShouldEqual, ErrBadAddress)
+		})
+
+		Convey("You can tag an object with the thing's ID and removal date, keeping other tags", func() {
+			fake.buckets["bucket"]["c"]["other"] = "tag"
+
+			err := s.Register(ctx, thing("bucket/c"))
+			So(err, ShouldBeNil)
+			So(fake.tags("bucket", "c"), ShouldResemble, map[string]string{
+				"other":   "tag",
+				TagID:     "7",
+				TagRemove: "2030-01-02",
+			})
+			So(fake.tags("bucket", "a/1"), ShouldBeEmpty)
+		})
+
+		Convey("You can tag all the objects under a prefix", func() {
+			err := s.Register(ctx, thing("bucket/a/"))
+			So(err, ShouldBeNil)
+
+			for _, key := range []string{"a/1", "a/2", "a/b/3"} {
+				So(fake.tags("bucket", key)[TagID], ShouldEqual, "7")
+			}
+
+			So(fake.tags("bucket", "c"), ShouldBeEmpty)
+		})
+
+		Convey("Registering things that don't exist does nothing", func() {
+			So(s.Register(ctx, thing("bucket/missing")), ShouldBeNil)
+			So(s.Register(ctx, thing("bucket/x/")), ShouldBeNil)
+			So(s.Register(ctx, thing("missing/x/")), ShouldBeNil)
+		})
+
+		Convey("You can remove an object", func() {
+			err := s.Remove(ctx, thing("bucket/c"))
+			So(err, ShouldBeNil)
+			So(fake.keys("bucket"), ShouldResemble, []string{"a/1", "a/2", "a/b/3"})
+
+			err = s.Remove(ctx, thing("bucket/c"))
+			So(err, ShouldBeNil)
+		})
+
+		Convey("You can remove all the objects under a prefix, with progress counting confirmed deletions", func() {
+			fake.locked["a/2"] = true
+			progress := &backend.Progress{}
+
+			err := s.Remove(backend.WithProgress(ctx, progress), thing("bucket/a/"))
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "a/2")
+			So(fake.keys("bucket"), ShouldResemble, []string{"a/2", "c"})
+			So(fake.keys("other"), ShouldResemble, []string{"d"})
+			So(progress.Snapshot(), ShouldResemble, database.RemovalProgress{Files: 2, Bytes: 2, Errors: 1})
+
+			delete(fake.locked, "a/2")
+
+			err = s.Remove(ctx, thing("bucket/a/"))
+			So(err, ShouldBeNil)
+			So(fake.keys("bucket"), ShouldResemble, []string{"c"})
+
+			err = s.Remove(ctx, thing("missing/a/"))
+			So(err, ShouldBeNil)
+		})
+
+		Convey("Whole buckets can't be registered or removed unless allowed", func() {
+			for _, address := range []string{"bucket", "bucket/", "s3://bucket/"} {
+				So(s.Register(ctx, thing(address)), ShouldEqual, ErrWholeBucket)
+				So(s.Remove(ctx, thing(address)), ShouldEqual, ErrWholeBucket)
+			}
+
+			So(fake.keys("bucket"), ShouldResemble, []string{"a/1", "a/2", "a/b/3", "c"})
+
+			s.wholeBuckets = true
+
+			So(s.Remove(ctx, thing("s3://bucket/")), ShouldBeNil)
+			So(fake.keys("bucket"), ShouldBeEmpty)
+		})
+
+		Convey("Bad addresses are errors", func() {
+			_, err := s.Exists(ctx, thing("/c"))
+			So(err, ShouldEqual, ErrBadAddress)
+			So(s.Register(ctx, thing("/c")), ShouldEqual, ErrBadAddress)
+			So(s.Remove(ctx, thing("/c")), ShouldEqual, ErrBadAddress)
+		})
+	})
+}
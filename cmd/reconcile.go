@@ -0,0 +1,104 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package cmd
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/wtsi-hgi/tt/backend/fs"
+	"github.com/wtsi-hgi/tt/jobs"
+)
+
+// options for this cmd.
+var reconcileDryRun bool
+var reconcileMaxMissing int
+
+// reconcileCmd represents the reconcile command.
+var reconcileCmd = &cobra.Command{
+	Use:   "reconcile",
+	Short: "Mark things that no longer exist as removed",
+	Long: `Mark things that no longer exist as removed.
+
+People often delete their temporary things themselves, without telling tt.
+This command checks the address of every thing that hasn't been removed, and if
+nothing exists there any more, marks it as removed, records in its history that
+it was removed externally, and notifies its subscribers.
+
+Only things with a type that tt has a backend for are reconciled (see 'tt
+server -h' for how to configure the s3, irods and openstack backends, and
+plugins). Things that are in quarantine, on hold, or being removed by tt are
+skipped.
+
+So that an unavailable filesystem isn't mistaken for everything on it having
+been deleted, dir and file things are only considered missing if their parent
+directory still exists. And if more than --max_missing things are missing (0
+for no limit), none of them are marked as removed, and this command fails.
+
+The addresses of the things found to be missing are printed to STDOUT. With
+--dry_run, nothing is changed and nobody is notified.
+
+You will need your database connection details in env vars, as described in
+'tt server -h'. To notify subscribers by email, also set:
+export TT_SMTP_HOST=smtp.example.com
+export TT_SMTP_PORT=25
+export TT_SMTP_FROM=tt@example.com
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		db := openDatabase()
+		defer db.Close()
+
+		setBackends(db, fs.DefaultConcurrency)
+
+		reconciler := jobs.NewReconciler(db, newNotifier(), log.New(os.Stderr, "", 0))
+
+		reconciler.SetMaxMissing(reconcileMaxMissing)
+
+		missing, err := reconciler.Reconcile(context.Background(), reconcileDryRun)
+
+		for _, thing := range missing {
+			cliPrint("%s\t%s\n", thing.Type, thing.Address)
+		}
+
+		if err != nil {
+			die("reconciliation failed: %s", err)
+		}
+
+		info("%d things no longer exist", len(missing))
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(reconcileCmd)
+
+	// flags specific to this sub-command
+	reconcileCmd.Flags().BoolVar(&reconcileDryRun, "dry_run", false,
+		"only report missing things, don't mark them removed")
+	reconcileCmd.Flags().IntVar(&reconcileMaxMissing, "max_missing", jobs.DefaultMaxMissing,
+		"most things that can be missing and still be marked removed (0 for no limit)")
+}
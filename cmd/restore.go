@@ -0,0 +1,107 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package cmd
+
+import (
+	"context"
+	"os/user"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/wtsi-hgi/tt/backend/fs"
+	"github.com/wtsi-hgi/tt/jobs"
+)
+
+// options for this cmd.
+var restoreRemove string
+
+// restoreCmd represents the restore command.
+var restoreCmd = &cobra.Command{
+	Use:   "restore <thing id>",
+	Short: "Restore a quarantined thing",
+	Long: `Restore a quarantined thing.
+
+When the removal date of a dir or file thing passes, and the server has a
+quarantine area configured (see 'tt server -h'), its data is moved in to
+quarantine instead of being deleted. This command moves it back to its original
+address, so long as nothing else has been created there since.
+
+The thing is given a new removal date, which you can specify with --remove
+(YYYY-MM-DD), defaulting to 30 days from now. The restoration is recorded in
+the thing's history.
+
+You will need your database connection details and TT_QUARANTINE_DIR in env
+vars, as described in 'tt server -h'.
+`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		id, err := strconv.ParseUint(args[0], 10, 32)
+		if err != nil {
+			die("invalid thing id: %s", err)
+		}
+
+		var remove time.Time
+
+		if restoreRemove != "" {
+			remove, err = time.Parse(time.DateOnly, restoreRemove)
+			if err != nil {
+				die("invalid --remove: %s", err)
+			}
+		}
+
+		db := openDatabase()
+		defer db.Close()
+
+		setBackends(db, fs.DefaultConcurrency)
+
+		thing, err := jobs.Restore(context.Background(), db, uint32(id), currentUsername(), remove)
+		if err != nil {
+			die("restore failed: %s", err)
+		}
+
+		info("restored %s; it will now be removed on %s", thing.Address, thing.Remove.Format(time.DateOnly))
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(restoreCmd)
+
+	// flags specific to this sub-command
+	restoreCmd.Flags().StringVar(&restoreRemove, "remove", "",
+		"new removal date of the thing, YYYY-MM-DD (default 30 days from now)")
+}
+
+// currentUsername returns the name of the user running this command, or blank
+// if that can't be determined.
+func currentUsername() string {
+	u, err := user.Current()
+	if err != nil {
+		return ""
+	}
+
+	return u.Username
+}
@@ -34,7 +34,15 @@ import (
 
 	"github.com/inconshreveable/log15"
 	"github.com/spf13/cobra"
+	"github.com/wtsi-hgi/tt/backend"
+	"github.com/wtsi-hgi/tt/backend/fs"
+	"github.com/wtsi-hgi/tt/backend/irods"
+	"github.com/wtsi-hgi/tt/backend/openstack"
+	"github.com/wtsi-hgi/tt/backend/plugin"
+	"github.com/wtsi-hgi/tt/backend/s3"
+	"github.com/wtsi-hgi/tt/database"
 	"github.com/wtsi-hgi/tt/database/mysql"
+	"github.com/wtsi-hgi/tt/notify"
 )
 
 // appLogger is used for logging events in our commands.
@@ -44,6 +52,29 @@ const (
 	serverURLEnvKey  = "TT_SERVER_URL"
 	serverCertEnvKey = "TT_SERVER_CERT"
 	serverKeyEnvKey  = "TT_SERVER_KEY"
+	smtpHostEnvKey   = "TT_SMTP_HOST"
+	smtpPortEnvKey   = "TT_SMTP_PORT"
+	smtpFromEnvKey   = "TT_SMTP_FROM"
+	s3EndpointEnvKey = "TT_S3_ENDPOINT"
+	s3AccessEnvKey   = "TT_S3_ACCESS_KEY"
+	s3SecretEnvKey   = "TT_S3_SECRET_KEY"
+	s3RegionEnvKey   = "TT_S3_REGION"
+	s3InsecureEnvKey = "TT_S3_INSECURE"
+	s3BucketsEnvKey  = "TT_S3_WHOLE_BUCKETS"
+	irodsEnvKey      = "TT_IRODS"
+	irodsNoTrashKey  = "TT_IRODS_NO_TRASH"
+	osAuthURLEnvKey  = "OS_AUTH_URL"
+	pluginsEnvKey    = "TT_PLUGINS"
+	quarantineEnvKey = "TT_QUARANTINE_DIR"
+	archiveEnvKey    = "TT_ARCHIVE_DIR"
+	checksumEnvKey   = "TT_FINGERPRINT_CHECKSUM"
+	policiesEnvKey   = "TT_POLICIES"
+	protectEnvKey    = "TT_PROTECTION"
+	calendarEnvKey   = "TT_CALENDAR"
+	linkKeyEnvKey    = "TT_LINK_KEY"
+	oidcIssuerEnvKey = "TT_OIDC_ISSUER"
+	oidcIDEnvKey     = "TT_OIDC_CLIENT_ID"
+	oidcSecretEnvKey = "TT_OIDC_CLIENT_SECRET"
 )
 
 // global options.
@@ -100,6 +131,142 @@ func ensureServerArgs() {
 	}
 }
 
+// openDatabase connects to the database configured by environment variables,
+// and records the registered ThingsTypes in it, dying if it can't.
+func openDatabase() *mysql.MySQLDB {
+	config, err := mysql.ConfigFromEnv()
+	if err != nil {
+		die("failed to get database config: %s", err)
+	}
+
+	db, err := mysql.New(config)
+	if err != nil {
+		die("error opening database: %s", err)
+	}
+
+	if err = db.SyncThingsTypes(); err != nil {
+		die("error recording types of thing in database: %s", err)
+	}
+
+	return db
+}
+
+// setBackends sets the registered backend of each ThingsType that has one, with
+// directories being walked using the given number of goroutines. There is only
+// an s3 backend if the TT_S3_ENDPOINT environment variable is set, an irods
+// backend if TT_IRODS is set, and an openstack backend if OS_AUTH_URL is set.
+// If TT_QUARANTINE_DIR is set, dir and file things are quarantined there when
+// they expire, and if TT_ARCHIVE_DIR is set, they can be archived there. If
+// TT_FINGERPRINT_CHECKSUM is set, their fingerprints include a checksum of
+// their top-level listing.
+// If TT_PLUGINS is set, the plugin types configured in that file are also
+// registered and recorded in the given database, with their output recorded
+// in its audit history.
+func setBackends(db *mysql.MySQLDB, concurrency int) {
+	fsys := fs.New(concurrency)
+	fsys.SetArchiveDir(os.Getenv(archiveEnvKey))
+	fsys.SetListingChecksum(os.Getenv(checksumEnvKey) != "")
+
+	var fsBackend backend.Backend = fsys
+
+	if dir := os.Getenv(quarantineEnvKey); dir != "" {
+		fsBackend = fs.NewQuarantining(fsys, dir)
+	}
+
+	setBackend(database.ThingsTypeDir, fsBackend)
+	setBackend(database.ThingsTypeFile, fsBackend)
+
+	if s3Backend := newS3Backend(); s3Backend != nil {
+		setBackend(database.ThingsTypeS3, s3Backend)
+	}
+
+	if os.Getenv(irodsEnvKey) != "" {
+		setBackend(database.ThingsTypeIrods, irods.New(irods.ICommands{}, os.Getenv(irodsNoTrashKey) == ""))
+	}
+
+	if os.Getenv(osAuthURLEnvKey) != "" {
+		setBackend(database.ThingsTypeOpenstack, openstack.New(openstack.NewClient(openstack.Config{
+			AuthURL:           os.Getenv(osAuthURLEnvKey),
+			Username:          os.Getenv("OS_USERNAME"),
+			Password:          os.Getenv("OS_PASSWORD"),
+			UserDomainName:    os.Getenv("OS_USER_DOMAIN_NAME"),
+			ProjectName:       os.Getenv("OS_PROJECT_NAME"),
+			ProjectDomainName: os.Getenv("OS_PROJECT_DOMAIN_NAME"),
+			RegionName:        os.Getenv("OS_REGION_NAME"),
+		})))
+	}
+
+	registerPlugins(db)
+}
+
+// setBackend sets the backend of the given ThingsType, dying on failure.
+func setBackend(thingsType database.ThingsType, b backend.Backend) {
+	if _, err := database.SetBackend(thingsType, b); err != nil {
+		die("failed to set the %s backend: %s", thingsType, err)
+	}
+}
+
+// registerPlugins registers the plugins configured in the file given by the
+// TT_PLUGINS environment variable, if set, dying on failure.
+func registerPlugins(db *mysql.MySQLDB) {
+	path := os.Getenv(pluginsEnvKey)
+	if path == "" {
+		return
+	}
+
+	configs, err := plugin.LoadConfig(path)
+	if err != nil {
+		die("failed to load plugin config: %s", err)
+	}
+
+	if err = plugin.Register(configs, db); err != nil {
+		die("failed to register plugins: %s", err)
+	}
+
+	if err = db.SyncThingsTypes(); err != nil {
+		die("error recording plugin types in database: %s", err)
+	}
+}
+
+// newS3Backend returns an S3 backend configured by the TT_S3_* environment
+// variables, or nil if TT_S3_ENDPOINT isn't set. Dies if the config is invalid.
+func newS3Backend() *s3.S3 {
+	endpoint := os.Getenv(s3EndpointEnvKey)
+	if endpoint == "" {
+		return nil
+	}
+
+	s3Backend, err := s3.New(s3.Config{
+		Endpoint:  endpoint,
+		AccessKey: os.Getenv(s3AccessEnvKey),
+		SecretKey: os.Getenv(s3SecretEnvKey),
+		Region:    os.Getenv(s3RegionEnvKey),
+		Secure:    os.Getenv(s3InsecureEnvKey) == "",
+
+		WholeBuckets: os.Getenv(s3BucketsEnvKey) != "",
+	})
+	if err != nil {
+		die("failed to configure s3: %s", err)
+	}
+
+	return s3Backend
+}
+
+// newNotifier returns a Notifier that sends emails via the SMTP server
+// configured by the TT_SMTP_HOST, TT_SMTP_PORT and TT_SMTP_FROM environment
+// variables. Returns nil if they are not all set.
+func newNotifier() notify.Notifier {
+	host := os.Getenv(smtpHostEnvKey)
+	port := os.Getenv(smtpPortEnvKey)
+	from := os.Getenv(smtpFromEnvKey)
+
+	if host == "" || port == "" || from == "" {
+		return nil
+	}
+
+	return notify.NewSMTP(host, port, from)
+}
+
 // logToFile logs to the given file.
 func logToFile(path string) {
 	fh, err := log15.FileHandler(path, log15.LogfmtFormat())
@@ -26,20 +26,54 @@
 package cmd
 
 import (
+	"context"
 	"io"
+	"log"
 	"log/syslog"
+	"os"
 	"time"
 
 	"github.com/inconshreveable/log15"
 	"github.com/spf13/cobra"
-	"github.com/wtsi-hgi/tt/database/mysql"
+	"github.com/wtsi-hgi/tt/backend/fs"
+	"github.com/wtsi-hgi/tt/database"
+	"github.com/wtsi-hgi/tt/jobs"
+	"github.com/wtsi-hgi/tt/links"
+	"github.com/wtsi-hgi/tt/policy"
 	"github.com/wtsi-hgi/tt/server"
 )
 
+const (
+	defaultProbeInterval     = 6 * time.Hour
+	defaultReconcileInterval = 24 * time.Hour
+	defaultWarnInterval      = time.Hour
+	removalDispatchInterval  = 30 * time.Second
+	bytesPerGiB              = 1 << 30
+)
+
 // options for this cmd.
 var serverLogPath string
 var serverLDAPFQDN string
 var serverLDAPBindDN string
+var serverProbeInterval time.Duration
+var serverProbeConcurrency int
+var serverReconcileInterval time.Duration
+var serverMaxMissing int
+var serverReapInterval time.Duration
+var serverWarnInterval time.Duration
+var serverFirstWarning time.Duration
+var serverSecondWarning time.Duration
+var serverQuarantinePeriod time.Duration
+var serverRemovalWorkers int
+var serverRemovalLimits map[string]int
+var serverRemovalAttempts int
+var serverRemovalBackoff time.Duration
+var serverLeaseTTL time.Duration
+var serverAdmins []string
+var serverApproverGroup string
+var serverApprovalSize int64
+var serverGracePeriod time.Duration
+var serverSkipWeekends bool
 
 // serverCmd represents the server command.
 var serverCmd = &cobra.Command{
@@ -50,6 +84,9 @@ var serverCmd = &cobra.Command{
 The tt web server is used to present a web interface to a MySQL database that
 can record information about temporary things.
 
+Setting up
+----------
+
 Your --url (in this context, think of it as the bind address) should include the
 port, and for it to work with your --cert, you probably need to specify it as
 fqdn:port. --url defaults to the TT_SERVER_URL env var. --cert and --key (both
@@ -76,27 +113,225 @@ include 'panic' in the message. The messages are tagged 'tt-server', and you
 might want to filter away 'STATUS=200' to find problems.
 If --logfile is supplied, logs to that file instead of syslog.
 
+Logging in
+----------
+
+Only logged in users can register or change things, and they do so as
+themselves. Users log in to the web interface via OIDC (eg. Okta), which you
+configure with these env vars:
+export TT_OIDC_ISSUER=https://example.okta.com/oauth2/default
+export TT_OIDC_CLIENT_ID=client_id
+export TT_OIDC_CLIENT_SECRET=client_secret
+Without them, nobody can log in, so the web interface is read-only. The logged
+in sessions are signed with your --cert and --key.
+
+Registering things
+------------------
+
+Users can only register dir and file things at paths that they (or one of their
+Unix groups) own or can write to, or whose nearest existing parent directory
+they can write to if the path doesn't exist yet. Paths that anyone can write to
+can only be registered by their owner. The ownership checks for other types of
+thing are described under "Removing things" below. Users named in --admins can
+register things at any address, and can place legal holds on things, which
+stop them being warned about or removed until the hold ends or is released.
+
+Administrators can define retention policies for types of thing and storage
+areas in a JSON file given by TT_POLICIES:
+[{"type": "dir", "prefix": "/lustre/scratch/", "default_days": 30,
+  "max_days": 90, "max_extensions": 2, "require_description": true}]
+Type and prefix are both optional; the policy with the longest matching address
+prefix applies to a thing, preferring those for its type. Things registered
+without a removal date get one default_days from now, none can be registered
+or extended to more than max_days from now, nor extended more than
+max_extensions times, and they must have a description if required.
+
+To make sure that nothing important can be registered, and so removed, you can
+protect addresses with a JSON file given by TT_PROTECTION:
+{"allow_roots": {"dir": ["/lustre/scratch"], "file": ["/lustre/scratch"]},
+ "deny_patterns": ["/*", "/lustre/scratch/projects/*"]}
+Things of types with allow_roots must be within (and not be) one of those
+roots, and no thing can have an address matching a deny_patterns glob, or
+containing addresses that could match one (eg. /lustre/scratch/projects). dir
+and file addresses must also pass these checks with their symlinks resolved. The
+reaper also checks this before removing anything, and fails the removal job of
+a protected thing without retrying it.
+
+If you name a Unix group with --approver_group, things registered with a removal
+date later than their retention policy allows are not rejected, but instead
+await approval, as do things whose size when registered is more than
+--approval_size GiB (for types that can be probed). The group's listed members
+are emailed about them, and any member can log in and approve or reject them on
+the /approvals page of the web interface; the subscribers are then emailed the
+decision. Things only count as registered (so are warned about
+and removed) once they're approved.
+
+Background jobs
+---------------
+
+The server probes the addresses of dir and file things every --probe_interval,
+recording whether they still exist, their total size, number of files and
+latest modification time. Directories are walked using up to
+--probe_concurrency goroutines each. Set --probe_interval to 0 to disable this.
+The latest access time of their files is also recorded, so that dir and file
+things can be registered to expire a number of days after they were last used
+(accessed or modified), instead of on a fixed date. For those, the date given
+when registering is the earliest they'll be removed, and the web interface
+shows their removal date as computed from their last use.
+
+Every --warn_interval, subscribers of things that are due to be removed within
+--first_warning are warned about it, and warned again once they're due within
+--second_warning. Set --warn_interval to 0 to disable this. Subscribers who
+snoozed warnings about a thing aren't warned about it until their snooze ends
+(at the latest, when it's due within --second_warning), when they're reminded
+of any warning they missed. If the TT_LINK_KEY env var is set to a secret of
+at least 32 characters, warnings include one-click links, signed with it, that
+let subscribers extend, unsubscribe from or confirm the removal of the thing
+without logging in, or (before --second_warning) snooze warnings about it. The
+links are to https:// followed by --url, so that should be how users reach the
+server.
+
+Every --reconcile_interval, the server also does the equivalent of 'tt
+reconcile', marking things whose address no longer exists as removed. Set it to
+0 to disable this. See 'tt reconcile -h' for how to have subscribers notified,
+and for the meaning of --max_missing.
+
+If --reap_interval is set, every interval the server queues removal jobs for
+things whose removal date has passed. The jobs are carried out in parallel by
+up to --removal_workers workers per type of thing (override this for
+individual types with eg. --removal_limits s3=8,dir=2), which mark them as
+removed and notify subscribers. Failed jobs are retried after
+--removal_backoff, doubling each time, until they have been attempted
+--removal_attempts times, when they are marked as failed with their last error
+shown in the web interface. While a job runs, the files and bytes it has
+removed so far (and any errors) are shown as a progress bar on the thing, which
+every server updates live. There you can also retry failed jobs, or cancel
+queued ones; GET /jobs?state=<queued|running|failed|cancelled> lists them, with
+their progress, as JSON.
+
+So that nothing is removed when nobody can react, you can have removals wait
+for --grace_period after a thing's removal date, never happen at weekends
+(--skip_weekends), and never happen during the events in an iCalendar (.ics)
+file given by TT_CALENDAR, such as institute closure days or maintenance
+windows. A thing due during one of those is removed once it's over. The web
+interface shows each thing's resulting effective removal date.
+
+Removing things
+---------------
+
+Currently only s3, irods, openstack and plugin things can be removed, along with
+dir and file things if you've configured a quarantine area.
+
+dir and file things are never deleted straight away. If you set
+TT_QUARANTINE_DIR to a directory, expired ones are instead moved in to
+<TT_QUARANTINE_DIR>/<thing id>/ (or if that's on a different filesystem,
+renamed in place to .tt-quarantine.<thing id>.<name>), and subscribers are
+notified. They can be restored within --quarantine_period using a button in
+the web interface, or with 'tt restore'. After that they are permanently
+deleted.
+
+Things can also be registered to be archived instead of deleted on expiry, in
+which case dir and file things are packed in to a <thing id>-<name>.tar.gz
+tarball in TT_ARCHIVE_DIR, alongside a <thing id>-<name>.sha256 manifest of the
+checksums of the files within, before being removed. Or they can be registered
+to only have their subscribers notified when their removal date passes.
+
+When dir and file things are registered, a fingerprint of their size, number of
+files and latest modification time is recorded (plus a checksum of their
+top-level listing, if you set TT_FINGERPRINT_CHECKSUM=1). If by their removal
+date they have been modified since, or have grown, or their listing has changed,
+they are not removed, in case they're now being used for something else.
+Instead they are flagged as changed in the web interface, and their subscribers
+are asked to either confirm their removal there, or extend them. Both take a
+new fingerprint.
+
+s3 things can only be removed if you have configured access to your S3 service:
+export TT_S3_ENDPOINT=s3.example.com:443
+export TT_S3_ACCESS_KEY=key
+export TT_S3_SECRET_KEY=secret
+export TT_S3_REGION=us-east-1 # optional
+export TT_S3_INSECURE=1 # optional, to use http instead of https
+export TT_S3_WHOLE_BUCKETS=1 # optional, to allow s3://bucket/ things
+
+With access configured, objects registered as s3 things (s3://bucket/key, or
+s3://bucket/prefix/ for all objects under a prefix) are also tagged with
+tt_id and tt_remove (YYYY-MM-DD), for use by your own lifecycle rules. Things
+referring to every object in a bucket can only be registered and removed if you
+set TT_S3_WHOLE_BUCKETS. s3 things can only be registered by the owner (as
+reported by the S3 service) of every object at their address.
+
+irods things can only be removed if you've set TT_IRODS=1 and have
+authenticated icommands (ils, imeta, irm) in your PATH. Removed collections and
+data objects are moved to the trash, unless you also set TT_IRODS_NO_TRASH=1.
+With TT_IRODS set, collections and data objects registered as irods things
+also get tt_id, tt_remove and tt_creator AVUs, which are kept up to date when
+their removal date is extended, and can only be registered by users that own or
+can modify them (or their nearest existing parent collection), according to
+their ACL.
+
+openstack things can only be removed if you've sourced an openrc file for a
+Keystone v3 project, setting OS_AUTH_URL, OS_USERNAME, OS_PASSWORD,
+OS_PROJECT_NAME and optionally OS_USER_DOMAIN_NAME, OS_PROJECT_DOMAIN_NAME and
+OS_REGION_NAME. Their addresses must be of the form instance/<id>, volume/<id>,
+image/<id> or floatingip/<id or ip address>. Only the OpenStack user that
+created an instance or volume (which that project user must be able to look up
+in Keystone) can register it; images and floating IPs don't record who created
+them, so only users named in --admins can register those.
+
+Site-specific types of thing can be handled by plugin executables, configured
+in a JSON file given by TT_PLUGINS:
+[{"type": "scratch", "label": "Scratch", "exec": "/path/to/plugin",
+  "timeout": "1m"}]
+Each plugin is sent a JSON request on stdin to validate, check the existence
+of, size or remove a thing, and must reply with JSON on stdout. Anything it
+writes to stderr is recorded in the thing's history. See
+backend/plugin/example/tt-plugin-scratch in the tt repo for an example.
+
+Running several servers
+-----------------------
+
+You can run several servers against the same database (eg. behind a load
+balancer). They elect a leader using a lease in the database, and only the
+leader runs the background jobs described above, so that things aren't removed
+or notified about twice. The leader renews its lease every third of
+--lease_ttl; if it dies, another server takes over once the lease expires. The
+/status page shows which server is currently the leader.
+
 This command will block forever in the foreground; you can background it with
 ctrl-z; bg. Or better yet, use the daemonize program to daemonize this.
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		logWriter := setServerLogger(serverLogPath)
 
-		config, err := mysql.ConfigFromEnv()
-		if err != nil {
-			die("failed to get database config: %s", err)
-		}
+		db := openDatabase()
 
 		ensureServerArgs()
 
-		database, err := mysql.New(config)
-		if err != nil {
-			die("error opening database: %s", err)
-		}
+		setBackends(db, serverProbeConcurrency)
+
+		removalLimits := parseRemovalLimits()
+		instance := jobs.Identity()
+		protection := loadProtection()
+		schedule := loadSchedule(logWriter)
+		signer := newLinkSigner()
 
 		conf := server.Config{
 			HTTPLogger: logWriter,
-			Database:   database,
+			Database:   db,
+			Admins:     serverAdmins,
+			Instance:   instance,
+			Policies:   loadPolicies(),
+			Protection: protection,
+
+			ApproverGroup: serverApproverGroup,
+			ApprovalSize:  serverApprovalSize * bytesPerGiB,
+			Notifier:      newNotifier(),
+			Schedule:      schedule,
+			SecondWarning: serverSecondWarning,
+			Links:         signer,
+
+			CertFile: serverCert,
+			KeyFile:  serverKey,
 		}
 
 		s, err := server.New(conf)
@@ -104,8 +339,28 @@ ctrl-z; bg. Or better yet, use the daemonize program to daemonize this.
 			die("failed to configure server: %s", err)
 		}
 
+		if issuer := os.Getenv(oidcIssuerEnvKey); issuer != "" {
+			s.AddOIDCRoutes(serverURL, issuer, os.Getenv(oidcIDEnvKey), os.Getenv(oidcSecretEnvKey))
+		}
+
 		defer s.Stop()
 
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		if serverLeaseTTL <= 0 {
+			die("--lease_ttl must be positive")
+		}
+
+		leader := jobs.NewLeader(db, database.SchedulerLease, instance, log.New(logWriter, "leader: ", 0))
+		leader.SetTTL(serverLeaseTTL)
+
+		go leader.Run(ctx, func(ctx context.Context) {
+			startJobs(ctx, db, instance, protection, schedule, signer, removalLimits, logWriter)
+		})
+
+		go s.WatchProgress(ctx, server.DefaultProgressInterval)
+
 		sayStarted()
 
 		err = s.Start(serverURL, serverCert, serverKey)
@@ -121,6 +376,124 @@ func init() {
 	// flags specific to this sub-command
 	serverCmd.Flags().StringVar(&serverLogPath, "logfile", "",
 		"log to this file instead of syslog")
+	serverCmd.Flags().DurationVar(&serverProbeInterval, "probe_interval", defaultProbeInterval,
+		"how often to probe the addresses of things (0 to disable)")
+	serverCmd.Flags().IntVar(&serverProbeConcurrency, "probe_concurrency", fs.DefaultConcurrency,
+		"number of goroutines to walk each directory with when probing")
+	serverCmd.Flags().DurationVar(&serverReconcileInterval, "reconcile_interval", defaultReconcileInterval,
+		"how often to mark things whose address no longer exists as removed (0 to disable)")
+	serverCmd.Flags().IntVar(&serverMaxMissing, "max_missing", jobs.DefaultMaxMissing,
+		"most things a reconciliation can find missing and still mark removed (0 for no limit)")
+	serverCmd.Flags().DurationVar(&serverReapInterval, "reap_interval", 0,
+		"how often to remove things whose removal date has passed (0 to disable)")
+	serverCmd.Flags().DurationVar(&serverWarnInterval, "warn_interval", defaultWarnInterval,
+		"how often to warn subscribers of things that are due to be removed soon (0 to disable)")
+	serverCmd.Flags().DurationVar(&serverFirstWarning, "first_warning", jobs.DefaultFirstWarning,
+		"how long before their removal date to first warn subscribers of things")
+	serverCmd.Flags().DurationVar(&serverSecondWarning, "second_warning", jobs.DefaultSecondWarning,
+		"how long before their removal date to warn subscribers of things again")
+	serverCmd.Flags().DurationVar(&serverQuarantinePeriod, "quarantine_period", jobs.DefaultQuarantine,
+		"how long to keep quarantined things before removing them, if TT_QUARANTINE_DIR is set")
+	serverCmd.Flags().IntVar(&serverRemovalWorkers, "removal_workers", jobs.DefaultWorkerLimit,
+		"maximum number of removal jobs to run at once for each type of thing")
+	serverCmd.Flags().StringToIntVar(&serverRemovalLimits, "removal_limits", nil,
+		"comma separated type=n overrides of --removal_workers for particular types")
+	serverCmd.Flags().IntVar(&serverRemovalAttempts, "removal_attempts", jobs.DefaultMaxAttempts,
+		"number of times to attempt a removal job before marking it as failed")
+	serverCmd.Flags().DurationVar(&serverRemovalBackoff, "removal_backoff", jobs.DefaultBackoff,
+		"how long to wait before first retrying a failed removal job")
+	serverCmd.Flags().DurationVar(&serverLeaseTTL, "lease_ttl", jobs.DefaultLeaseTTL,
+		"how long the leader that runs the background jobs holds its lease for between renewals")
+	serverCmd.Flags().StringSliceVar(&serverAdmins, "admins", nil,
+		"comma separated names of users who can register things they don't own, and place holds on things")
+	serverCmd.Flags().StringVar(&serverApproverGroup, "approver_group", "",
+		"name of the Unix group whose members approve things that need approval")
+	serverCmd.Flags().Int64Var(&serverApprovalSize, "approval_size", 0,
+		"things bigger than this many GiB need approval, if there is an --approver_group")
+	serverCmd.Flags().DurationVar(&serverGracePeriod, "grace_period", 0,
+		"how long after their removal date to wait before removing things")
+	serverCmd.Flags().BoolVar(&serverSkipWeekends, "skip_weekends", false,
+		"don't remove things at weekends")
+}
+
+// startJobs starts, in goroutines, the background jobs that have been enabled
+// by their interval options. They will stop when ctx is done, eg. because we
+// stopped being the leader. The reaper won't remove things at addresses not
+// allowed by the given protection, nor at times not allowed by the given
+// schedule. Warnings include one-click links signed by the given signer, if
+// not nil. Removal jobs are run on behalf of instance, our leader's lease
+// holder, and those of the types in removalLimits are limited to those numbers
+// of workers.
+func startJobs(ctx context.Context, db database.Queries, instance string, protection *policy.Protection,
+	schedule *policy.Schedule,
+	signer *links.Signer, removalLimits map[database.ThingsType]int, logWriter io.Writer) {
+	if serverProbeInterval > 0 {
+		prober := jobs.NewProber(db, log.New(logWriter, "prober: ", 0))
+
+		go prober.Run(ctx, serverProbeInterval)
+	}
+
+	if serverReconcileInterval > 0 {
+		reconciler := jobs.NewReconciler(db, newNotifier(), log.New(logWriter, "reconciler: ", 0))
+		reconciler.SetMaxMissing(serverMaxMissing)
+
+		go reconciler.Run(ctx, serverReconcileInterval)
+	}
+
+	if serverWarnInterval > 0 {
+		warner := jobs.NewWarner(db, newNotifier(), log.New(logWriter, "warner: ", 0))
+		warner.SetPeriods(serverFirstWarning, serverSecondWarning)
+		warner.SetLinks(signer)
+		warner.SetSchedule(schedule)
+
+		go warner.Run(ctx, serverWarnInterval)
+	}
+
+	if serverReapInterval > 0 {
+		reaper := jobs.NewReaper(db, newNotifier(), log.New(logWriter, "reaper: ", 0))
+		reaper.SetQuarantinePeriod(serverQuarantinePeriod)
+		reaper.SetProtection(protection)
+		reaper.SetSchedule(schedule)
+
+		go reaper.Run(ctx, serverReapInterval)
+		go newWorkers(db, reaper, instance, removalLimits, logWriter).Run(ctx, removalDispatchInterval)
+	}
+}
+
+// parseRemovalLimits returns our --removal_limits keyed on ThingsType, dying if
+// any of them are for types that aren't registered, or are negative.
+func parseRemovalLimits() map[database.ThingsType]int {
+	limits := make(map[database.ThingsType]int, len(serverRemovalLimits))
+
+	for name, limit := range serverRemovalLimits {
+		thingsType, err := database.NewThingsType(name)
+		if err != nil || thingsType == database.ThingsTypeNil {
+			die("invalid --removal_limits type '%s'", name)
+		}
+
+		if limit < 0 {
+			die("invalid --removal_limits limit for %s: %d", name, limit)
+		}
+
+		limits[thingsType] = limit
+	}
+
+	return limits
+}
+
+// newWorkers returns removal Workers running on behalf of instance, configured
+// with our removal options and the given per-type limits.
+func newWorkers(db database.Queries, reaper *jobs.Reaper, instance string, limits map[database.ThingsType]int,
+	logWriter io.Writer) *jobs.Workers {
+	workers := jobs.NewWorkers(db, reaper, instance, log.New(logWriter, "removal: ", 0))
+	workers.SetLimit("", serverRemovalWorkers)
+	workers.SetRetries(serverRemovalAttempts, serverRemovalBackoff)
+
+	for thingsType, limit := range limits {
+		workers.SetLimit(thingsType, limit)
+	}
+
+	return workers
 }
 
 // setServerLogger makes our appLogger log to the given path if non-blank,
@@ -169,3 +542,77 @@ func sayStarted() {
 
 	info("server started")
 }
+
+// loadPolicies loads the retention policies configured in the file given by the
+// TT_POLICIES environment variable, if set, dying on failure. It must be called
+// after any plugin types have been registered, so that their policies are
+// valid.
+func loadPolicies() policy.Policies {
+	path := os.Getenv(policiesEnvKey)
+	if path == "" {
+		return nil
+	}
+
+	policies, err := policy.Load(path)
+	if err != nil {
+		die("failed to load policy config: %s", err)
+	}
+
+	return policies
+}
+
+// loadProtection loads the protected addresses configured in the file given by
+// the TT_PROTECTION environment variable, if set, dying on failure.
+func loadProtection() *policy.Protection {
+	path := os.Getenv(protectEnvKey)
+	if path == "" {
+		return nil
+	}
+
+	protection, err := policy.LoadProtection(path)
+	if err != nil {
+		die("failed to load protection config: %s", err)
+	}
+
+	return protection
+}
+
+// loadSchedule returns the removal schedule configured by --grace_period,
+// --skip_weekends and the iCalendar file given by the TT_CALENDAR environment
+// variable, dying if the file can't be loaded, and logging any events in it
+// that are skipped. Returns nil if none of those are set.
+func loadSchedule(logWriter io.Writer) *policy.Schedule {
+	schedule := &policy.Schedule{Grace: serverGracePeriod, SkipWeekends: serverSkipWeekends}
+
+	if path := os.Getenv(calendarEnvKey); path != "" {
+		closures, err := policy.LoadCalendar(path, log.New(logWriter, "calendar: ", 0))
+		if err != nil {
+			die("failed to load calendar: %s", err)
+		}
+
+		schedule.Closures = closures
+	}
+
+	if schedule.Grace == 0 && !schedule.SkipWeekends && schedule.Closures == nil {
+		return nil
+	}
+
+	return schedule
+}
+
+// newLinkSigner returns a Signer for one-click links to this server, using the
+// key given by the TT_LINK_KEY environment variable, dying if it's too short.
+// Returns nil if it isn't set.
+func newLinkSigner() *links.Signer {
+	key := os.Getenv(linkKeyEnvKey)
+	if key == "" {
+		return nil
+	}
+
+	signer, err := links.NewSigner([]byte(key), "https://"+serverURL)
+	if err != nil {
+		die("invalid %s: %s", linkKeyEnvKey, err)
+	}
+
+	return signer
+}
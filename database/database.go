@@ -25,29 +25,216 @@
 
 package database
 
+import (
+	"time"
+
+	null "github.com/guregu/null/v5"
+)
+
 // Queries are used to interact with a database of Things, Users and
 // Subscribers.
+//
+// Methods that change a Thing or its RemovalJob also keep the Thing's State in
+// sync with its ComputeState(). They make no changes and return an error that
+// Is() ErrBadTransition if the Thing can't make the resulting State change, or
+// ErrNoThing if there's no Thing with the given ID.
 type Queries interface {
 	// CreateUser creates a new user with the given name and email. The returned
 	// user will have its ID set.
 	CreateUser(name, email string) (*User, error)
 
+	// GetUserByName returns the user with the given name, or an error if there
+	// isn't one.
+	GetUserByName(name string) (*User, error)
+
 	// CreateThing creates a new Thing with the given details. The returned
 	// Thing will have its ID set to an auto-increment value, and Created time
 	// set to now. The supplied Creator must match the Name of an existing User,
-	// and will be recored as a Subscriber of the new Thing.
+	// and will be recored as a Subscriber of the new Thing. The Address is
+	// stored in its CanonicalAddress() form. If a Thing with the same canonical
+	// address and type already exists and has not been removed, returns a
+	// *DuplicateError.
 	CreateThing(args CreateThingParams) (*Thing, error)
 
+	// GetThing returns the Thing with the given ID, or ErrNoThing if there
+	// isn't one.
+	GetThing(id uint32) (*Thing, error)
+
 	// GetThings returns things that match the given parameters. Also in the
 	// result is the last page that would return things if Page and
 	// ThingsPerPage are > 0.
 	GetThings(params GetThingsParams) (*GetThingsResult, error)
 
+	// UpdateProbe records the given result of probing the thing with the given
+	// ID, along with the time it was probed.
+	UpdateProbe(id uint32, result ProbeResult) error
+
+	// GetSubscribers returns the users subscribed to the thing with the given
+	// ID.
+	GetSubscribers(thingID uint32) ([]User, error)
+
+	// Unsubscribe stops the user with the given ID being subscribed to the
+	// thing with the given ID, and deletes any Snooze or Confirmation they
+	// had of it.
+	Unsubscribe(thingID, userID uint32) error
+
+	// AddAuditEvent records the given event in the history of its Thing. If
+	// the event's Time is zero, it will be set to now.
+	AddAuditEvent(event AuditEvent) error
+
+	// GetAuditEvents returns the history of the thing with the given ID,
+	// oldest first.
+	GetAuditEvents(thingID uint32) ([]AuditEvent, error)
+
 	// DeleteUser deletes the user with the given ID. This will also delete any
 	// subscriptions the user had (but not any Things the user created).
 	DeleteUser(id uint32) error
 
-	// DeleteThing deletes the thing with the given ID.
+	// MarkRemoved records that the thing with the given ID has been removed.
+	// The thing is kept for historical purposes, but a new Thing with the same
+	// address and type can then be created.
+	MarkRemoved(id uint32) error
+
+	// ExtendRemoval changes the removal date of the thing with the given ID,
+	// and clears its record of warnings having been sent, of it having expired
+	// and of it having changed. Any removal job it has that isn't running is
+	// deleted.
+	ExtendRemoval(id uint32, remove time.Time) error
+
+	// SetWarned records when the first and second warnings of the upcoming
+	// removal of the thing with the given ID were sent, with null meaning not
+	// sent.
+	SetWarned(id uint32, warned1, warned2 null.Time) error
+
+	// MarkQuarantined records that what was at the address of the thing with
+	// the given ID has been moved to the given quarantine location, where it
+	// will stay until the given time before being permanently removed.
+	MarkQuarantined(id uint32, location string, until time.Time) error
+
+	// MarkArchived records that the thing with the given ID was archived to
+	// the given path.
+	MarkArchived(id uint32, path string) error
+
+	// MarkExpired records that subscribers of the thing with the given ID have
+	// been told that its removal date has passed, without it being removed.
+	MarkExpired(id uint32) error
+
+	// MarkRestored records that the quarantined thing with the given ID has
+	// been moved back to its address, and gives it the new removal date,
+	// clearing its record of warnings having been sent. Any removal job it has
+	// that isn't running is deleted.
+	MarkRestored(id uint32, remove time.Time) error
+
+	// SetFingerprint records the given Fingerprint (which can be nil) of the
+	// thing with the given ID, and clears any record of it having changed.
+	SetFingerprint(id uint32, fingerprint *Fingerprint) error
+
+	// SetApproval records the approval state of the thing with the given ID.
+	// Rejected things are also marked as removed, so that a new Thing with the
+	// same address and type can be created.
+	SetApproval(id uint32, state ApprovalState) error
+
+	// SetHold places a legal hold on the thing with the given ID for the given
+	// reason, until the given time (or indefinitely if null). Any removal job
+	// it has that isn't running is deleted.
+	SetHold(id uint32, reason string, until null.Time) error
+
+	// ReleaseHold removes any legal hold on the thing with the given ID.
+	ReleaseHold(id uint32) error
+
+	// SetSnooze stops the user with the given ID being warned about the thing
+	// with the given ID until the given time, replacing any existing snooze.
+	SetSnooze(thingID, userID uint32, until time.Time) error
+
+	// GetSnoozes returns the Snoozes of the thing with the given ID, including
+	// any that have ended.
+	GetSnoozes(thingID uint32) ([]Snooze, error)
+
+	// GetEndedSnoozes returns all the Snoozes that ended before the given
+	// time.
+	GetEndedSnoozes(before time.Time) ([]Snooze, error)
+
+	// MarkSnoozeMissed records that a warning about the thing with the given
+	// ID wasn't sent to the user with the given ID because of their Snooze.
+	MarkSnoozeMissed(thingID, userID uint32) error
+
+	// DeleteSnooze deletes any Snooze the user with the given ID has of the
+	// thing with the given ID.
+	DeleteSnooze(thingID, userID uint32) error
+
+	// ConfirmRemoval records that the user with the given ID has confirmed
+	// that the thing with the given ID can be removed at the given time,
+	// replacing any earlier Confirmation of theirs.
+	ConfirmRemoval(thingID, userID uint32, remove time.Time) error
+
+	// GetConfirmations returns the Confirmations of the thing with the given
+	// ID, including any of removal at times it no longer has.
+	GetConfirmations(thingID uint32) ([]Confirmation, error)
+
+	// UseLink calls use, and if it returns nil, records that the one-click link
+	// with the given ID, which expires at the given time, has been used.
+	// Returns ErrLinkUsed, without calling use, if it already had been.
+	// Concurrent uses of the same link wait for each other, so at most one of
+	// them succeeds.
+	UseLink(id string, expires time.Time, use func() error) error
+
+	// MarkChanged records that the thing with the given ID wasn't removed
+	// because its address no longer matched its Fingerprint.
+	MarkChanged(id uint32) error
+
+	// EnqueueJob queues a RemovalJob to carry out the given action on the thing
+	// with the given ID. Does nothing if the thing already has a job.
+	EnqueueJob(thingID uint32, action JobAction) error
+
+	// GetRemovalJobs returns the RemovalJobs in the given state, or all of
+	// them if state is blank, in order of their NextAttempt.
+	GetRemovalJobs(state JobState) ([]RemovalJob, error)
+
+	// StartJob marks the job of the thing with the given ID as being run by
+	// runner (the holder of a Lease), increments its number of attempts, and
+	// resets its progress.
+	StartJob(thingID uint32, runner string) error
+
+	// UpdateJobProgress records how far the running job of the thing with the
+	// given ID has got.
+	UpdateJobProgress(thingID uint32, progress RemovalProgress) error
+
+	// FinishJob deletes the job of the thing with the given ID, since it
+	// succeeded.
+	FinishJob(thingID uint32) error
+
+	// FailJob records that the job of the thing with the given ID failed with
+	// the given error, and puts it in the given state (JobQueued to try again
+	// at the given time, or JobFailed to give up).
+	FailJob(thingID uint32, lastError string, state JobState, nextAttempt time.Time) error
+
+	// RetryJob queues the failed or cancelled job of the thing with the given
+	// ID to be tried again now, with its attempts reset. Returns ErrNoJob if
+	// there's no job, or ErrJobNotFailed if it isn't failed or cancelled.
+	RetryJob(thingID uint32) error
+
+	// CancelJob cancels the job of the thing with the given ID. Returns
+	// ErrNoJob if there's no job, or ErrJobRunning if it's running.
+	CancelJob(thingID uint32) error
+
+	// ResetRunningJobs queues any jobs that were left marked as running by a
+	// runner that no longer holds an unexpired Lease, eg. because the process
+	// running them was killed.
+	ResetRunningJobs() error
+
+	// AcquireLease takes or renews the named Lease for holder until ttl from
+	// now, returning true if holder now holds it. Returns false if another
+	// holder has a lease that hasn't expired.
+	AcquireLease(name, holder string, ttl time.Duration) (bool, error)
+
+	// ReleaseLease gives up the named Lease, if holder holds it.
+	ReleaseLease(name, holder string) error
+
+	// GetLeases returns all the Leases, whether or not they have expired.
+	GetLeases() ([]Lease, error)
+
+	// DeleteThing deletes the thing with the given ID, but not its history of
+	// AuditEvents.
 	DeleteThing(id uint32) error
 
 	// Close releases any resources associated with doing the Queries, such as
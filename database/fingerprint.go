@@ -0,0 +1,70 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// Fingerprint is a snapshot of what existed at a Thing's address when it was
+// registered, used to avoid removing data that has since been reused for
+// something else.
+type Fingerprint struct {
+	Size     int64
+	Files    int64
+	Modified time.Time
+	Checksum string // of the top-level listing, blank if not taken
+}
+
+// Changes returns descriptions of the ways in which the current Fingerprint of
+// a Thing's address materially differs from this one, or nothing if it
+// doesn't. Changes are material if the data has been modified since, has more
+// files or bytes, or its top-level listing has changed. Having fewer files or
+// bytes alone is not material, since that's expected of someone tidying up
+// data that is due to be removed. Modification times are compared to the
+// microsecond, since that's all that databases store.
+func (f *Fingerprint) Changes(current *Fingerprint) []string {
+	var changes []string
+
+	if current.Modified.Truncate(time.Microsecond).After(f.Modified.Truncate(time.Microsecond)) {
+		changes = append(changes, "modified at "+current.Modified.Format(time.DateTime))
+	}
+
+	if current.Files > f.Files {
+		changes = append(changes, fmt.Sprintf("files increased from %d to %d", f.Files, current.Files))
+	}
+
+	if current.Size > f.Size {
+		changes = append(changes, fmt.Sprintf("size increased from %d to %d bytes", f.Size, current.Size))
+	}
+
+	if f.Checksum != "" && current.Checksum != "" && current.Checksum != f.Checksum {
+		changes = append(changes, "top-level listing changed")
+	}
+
+	return changes
+}
@@ -0,0 +1,67 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package database
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFingerprintChanges(t *testing.T) {
+	Convey("Given a Fingerprint", t, func() {
+		modified := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+		fingerprint := &Fingerprint{Size: 100, Files: 10, Modified: modified, Checksum: "abc"}
+
+		Convey("The same or a shrunken fingerprint isn't a material change", func() {
+			So(fingerprint.Changes(fingerprint), ShouldBeEmpty)
+			So(fingerprint.Changes(&Fingerprint{Size: 50, Files: 5, Modified: modified, Checksum: "abc"}),
+				ShouldBeEmpty)
+			So(fingerprint.Changes(&Fingerprint{Size: 100, Files: 10, Modified: modified}), ShouldBeEmpty)
+		})
+
+		Convey("Modification times are only compared to the microsecond", func() {
+			So(fingerprint.Changes(&Fingerprint{Size: 100, Files: 10, Modified: modified.Add(999)}), ShouldBeEmpty)
+			So(fingerprint.Changes(&Fingerprint{Size: 100, Files: 10, Modified: modified.Add(time.Microsecond)}),
+				ShouldResemble, []string{"modified at 2025-01-02 03:04:05"})
+		})
+
+		Convey("Newer, bigger or differently listed data is", func() {
+			So(fingerprint.Changes(&Fingerprint{
+				Size:     101,
+				Files:    11,
+				Modified: modified.Add(time.Hour),
+				Checksum: "def",
+			}), ShouldResemble, []string{
+				"modified at 2025-01-02 04:04:05",
+				"files increased from 10 to 11",
+				"size increased from 100 to 101 bytes",
+				"top-level listing changed",
+			})
+		})
+	})
+}
@@ -0,0 +1,86 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package mysql
+
+import (
+	"time"
+
+	"github.com/wtsi-hgi/tt/database"
+)
+
+const confirmRemoval = `
+INSERT INTO confirmations (
+  thing_id, user_id, remove
+) VALUES (
+  ?, ?, ?
+)
+ON DUPLICATE KEY UPDATE remove = VALUES(remove)
+`
+
+// ConfirmRemoval records that the user with the given ID has confirmed that the
+// thing with the given ID can be removed at the given time, replacing any
+// earlier confirmation of theirs.
+func (m *MySQLDB) ConfirmRemoval(thingID, userID uint32, remove time.Time) error {
+	_, err := m.pool.Exec(confirmRemoval, thingID, userID, remove)
+
+	return err
+}
+
+const deleteConfirmation = `DELETE FROM confirmations WHERE thing_id = ? AND user_id = ?`
+
+const getConfirmations = `
+SELECT confirmations.thing_id, users.id, users.name, users.email, confirmations.remove
+FROM confirmations
+JOIN users ON users.id = confirmations.user_id
+WHERE confirmations.thing_id = ?
+ORDER BY users.id
+`
+
+// GetConfirmations returns the confirmations of the thing with the given ID,
+// including any of removal at times it no longer has.
+func (m *MySQLDB) GetConfirmations(thingID uint32) ([]database.Confirmation, error) {
+	rows, err := m.pool.Query(getConfirmations, thingID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var confirmations []database.Confirmation
+
+	for rows.Next() {
+		var confirmation database.Confirmation
+
+		if err := rows.Scan(&confirmation.ThingID, &confirmation.User.ID, &confirmation.User.Name,
+			&confirmation.User.Email, &confirmation.Remove); err != nil {
+			return nil, err
+		}
+
+		confirmations = append(confirmations, confirmation)
+	}
+
+	return confirmations, rows.Err()
+}
@@ -0,0 +1,110 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+package mysql
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/wtsi-hgi/tt/database"
+)
+
+const createLease = `
+INSERT INTO leases (
+  name, holder, acquired, expires
+) VALUES (
+  ?, ?, NOW(6), NOW(6) + INTERVAL ? MICROSECOND
+)
+ON DUPLICATE KEY UPDATE name = name
+`
+
+const takeLease = `
+UPDATE leases
+SET acquired = IF(holder = ?, acquired, NOW(6)), holder = ?, expires = NOW(6) + INTERVAL ? MICROSECOND
+WHERE name = ? AND (holder = ? OR expires <= NOW(6))
+`
+
+const getLeaseHolder = `SELECT holder FROM leases WHERE name = ?`
+
+// AcquireLease takes or renews the named lease for holder until ttl from now,
+// returning true if holder now holds it. Returns false if another holder has a
+// lease that hasn't expired. The database server's clock is used both to set
+// and check expiry, so that the clocks of competing holders don't matter.
+func (m *MySQLDB) AcquireLease(name, holder string, ttl time.Duration) (bool, error) {
+	micros := ttl.Microseconds()
+
+	if _, err := m.pool.Exec(createLease, name, holder, micros); err != nil {
+		return false, err
+	}
+
+	if _, err := m.pool.Exec(takeLease, holder, holder, micros, name, holder); err != nil {
+		return false, err
+	}
+
+	var current string
+
+	err := m.pool.QueryRow(getLeaseHolder, name).Scan(&current)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+
+	return current == holder, err
+}
+
+const releaseLease = `DELETE FROM leases WHERE name = ? AND holder = ?`
+
+// ReleaseLease gives up the named lease, if holder holds it.
+func (m *MySQLDB) ReleaseLease(name, holder string) error {
+	_, err := m.pool.Exec(releaseLease, name, holder)
+
+	return err
+}
+
+const getLeases = `SELECT name, holder, acquired, expires FROM leases ORDER BY name`
+
+// GetLeases returns all the leases, whether or not they have expired.
+func (m *MySQLDB) GetLeases() ([]database.Lease, error) {
+	rows, err := m.pool.Query(getLeases)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var leases []database.Lease
+
+	for rows.Next() {
+		var lease database.Lease
+
+		if err := rows.Scan(&lease.Name, &lease.Holder, &lease.Acquired, &lease.Expires); err != nil {
+			return nil, err
+		}
+
+		leases = append(leases, lease)
+	}
+
+	return leases, rows.Err()
+}
@@ -0,0 +1,77 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package mysql
+
+import (
+	"errors"
+	"time"
+
+	gsdmysql "github.com/go-sql-driver/mysql"
+	"github.com/wtsi-hgi/tt/database"
+)
+
+const (
+	deleteExpiredLinks = `DELETE FROM used_links WHERE expires < ?`
+	useLink            = `INSERT INTO used_links (id, expires) VALUES (?, ?)`
+)
+
+// UseLink calls use, and if it returns nil, records that the one-click link
+// with the given ID, which expires at the given time, has been used. Returns
+// database.ErrLinkUsed, without calling use, if it already had been. Records of
+// links that have expired, which can't be used again anyway, are forgotten.
+//
+// The record is inserted in a transaction that is only committed if use
+// succeeds, so a failed use doesn't burn the link, and a concurrent use of the
+// same link waits on its row until then, only to find it used.
+func (m *MySQLDB) UseLink(id string, expires time.Time, use func() error) error {
+	if _, err := m.pool.Exec(deleteExpiredLinks, time.Now()); err != nil {
+		return err
+	}
+
+	tx, err := m.pool.Begin()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(useLink, id, expires)
+
+	var mysqlErr *gsdmysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == errCodeDuplicateEntry {
+		err = database.ErrLinkUsed
+	}
+
+	if err == nil {
+		err = use()
+	}
+
+	if err != nil {
+		tx.Rollback()
+
+		return err
+	}
+
+	return tx.Commit()
+}
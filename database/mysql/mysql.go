@@ -35,6 +35,7 @@ import (
 
 	gsdmysql "github.com/go-sql-driver/mysql"
 	"github.com/joho/godotenv"
+	"github.com/wtsi-hgi/tt/database"
 )
 
 //go:embed schema.sql
@@ -151,7 +152,34 @@ func (m *MySQLDB) Reset() error {
 		}
 	}
 
-	return tx.Commit()
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	return m.SyncThingsTypes()
+}
+
+const upsertThingsType = `
+INSERT INTO thing_types (name, label) VALUES (?, ?)
+ON DUPLICATE KEY UPDATE label = VALUES(label)
+`
+
+// SyncThingsTypes records all the ThingsTypes registered with
+// database.RegisterThingsType() in the thing_types table, so that Things of
+// those types can be created. Existing types have their labels updated. Types
+// that are no longer registered are left alone, so existing Things of those
+// types are kept.
+//
+// Reset() calls this for you, but you should call it yourself after New() when
+// using an existing database.
+func (m *MySQLDB) SyncThingsTypes() error {
+	for _, info := range database.ThingsTypeInfos() {
+		if _, err := m.pool.Exec(upsertThingsType, info.Name, info.Label); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 // Close closes the database connection. Not strictly necessary to call this.
@@ -27,11 +27,16 @@ package mysql
 
 import (
 	"database/sql"
+	"errors"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
+	null "github.com/guregu/null/v5"
 	. "github.com/smartystreets/goconvey/convey"
+	_ "github.com/wtsi-hgi/tt/backend/openstack"
+	_ "github.com/wtsi-hgi/tt/backend/s3"
 	"github.com/wtsi-hgi/tt/database"
 	"github.com/wtsi-hgi/tt/internal"
 )
@@ -239,6 +244,42 @@ func TestMySQL(t *testing.T) {
 			So(err, ShouldBeNil)
 			So(count, ShouldEqual, 0)
 
+			count, err = countTableRows(db.pool, "thing_types")
+			So(err, ShouldBeNil)
+			So(count, ShouldEqual, len(database.ThingsTypes()))
+
+			Convey("You can only create things of registered types", func() {
+				user, err := db.CreateUser("user", "user@example.com")
+				So(err, ShouldBeNil)
+
+				params := database.CreateThingParams{
+					Address: "/lustre/a",
+					Type:    "mysql-test-type",
+					Reason:  "reason",
+					Remove:  time.Now(),
+					Creator: user.Name,
+				}
+
+				_, err = db.CreateThing(params)
+				So(err, ShouldNotBeNil)
+
+				err = database.RegisterThingsType(database.ThingsTypeInfo{Name: params.Type, Label: "Test"})
+				if err != nil {
+					So(err, ShouldEqual, database.ErrTypeRegistered)
+				}
+
+				err = db.SyncThingsTypes()
+				So(err, ShouldBeNil)
+
+				thing, err := db.CreateThing(params)
+				So(err, ShouldBeNil)
+
+				thing, err = db.GetThing(thing.ID)
+				So(err, ShouldBeNil)
+				So(thing.Type, ShouldEqual, params.Type)
+				So(thing.Type.Label(), ShouldEqual, "Test")
+			})
+
 			Convey("You can then add users and things", func() {
 				expectedUsers, expectedThings, expectedSubs := internal.GetExampleData()
 
@@ -471,6 +512,772 @@ func TestMySQL(t *testing.T) {
 					So(result.Things[0].ID, ShouldEqual, 5)
 				})
 
+				Convey("Then you can get a thing by its ID", func() {
+					thing, err := db.GetThing(2)
+					So(err, ShouldBeNil)
+					thing.Created = time.Time{}
+					So(thing, ShouldResemble, &expectedThings[1])
+
+					_, err = db.GetThing(uint32(numThings + 1))
+					So(err, ShouldEqual, database.ErrNoThing)
+				})
+
+				Convey("Then you can't create things with the same canonical address and type", func() {
+					_, err := db.CreateThing(database.CreateThingParams{
+						Address: " " + expectedThings[2].Address + "/./",
+						Type:    expectedThings[2].Type,
+						Reason:  "reason",
+						Remove:  expectedThings[2].Remove,
+						Creator: expectedUsers[0].Name,
+					})
+					So(err, ShouldNotBeNil)
+					So(errors.Is(err, database.ErrDuplicate), ShouldBeTrue)
+
+					var dupErr *database.DuplicateError
+					So(errors.As(err, &dupErr), ShouldBeTrue)
+					So(dupErr.ExistingID, ShouldEqual, expectedThings[2].ID)
+
+					_, err = db.CreateThing(database.CreateThingParams{
+						Address: expectedThings[2].Address,
+						Type:    database.ThingsTypeS3,
+						Reason:  "reason",
+						Remove:  expectedThings[2].Remove,
+						Creator: expectedUsers[0].Name,
+					})
+					So(err, ShouldBeNil)
+
+					prefix := "/" + strings.Repeat("a", 200)
+
+					for _, suffix := range []string{"/b", "/c"} {
+						thing, err := db.CreateThing(database.CreateThingParams{
+							Address: prefix + suffix,
+							Type:    database.ThingsTypeDir,
+							Reason:  "reason",
+							Remove:  expectedThings[2].Remove,
+							Creator: expectedUsers[0].Name,
+						})
+						So(err, ShouldBeNil)
+						So(thing.Address, ShouldEqual, prefix+suffix)
+					}
+
+					count, err = countTableRows(db.pool, "things")
+					So(err, ShouldBeNil)
+					So(count, ShouldEqual, numThings+3)
+				})
+
+				Convey("Then you can mark things removed, re-register their address, and find previous ones", func() {
+					old := expectedThings[2]
+
+					err := db.MarkRemoved(old.ID)
+					So(err, ShouldBeNil)
+
+					params := database.CreateThingParams{
+						Address: old.Address + "/",
+						Type:    old.Type,
+						Reason:  "again",
+						Remove:  old.Remove,
+						Creator: expectedUsers[0].Name,
+					}
+
+					thing, err := db.CreateThing(params)
+					So(err, ShouldBeNil)
+					So(thing.Address, ShouldEqual, old.Address)
+
+					_, err = db.CreateThing(params)
+					So(errors.Is(err, database.ErrDuplicate), ShouldBeTrue)
+
+					var dupErr *database.DuplicateError
+					So(errors.As(err, &dupErr), ShouldBeTrue)
+					So(dupErr.ExistingID, ShouldEqual, thing.ID)
+
+					result, err := db.GetThings(database.GetThingsParams{
+						FilterOnType:    old.Type,
+						FilterOnAddress: old.Address,
+					})
+					So(err, ShouldBeNil)
+					So(len(result.Things), ShouldEqual, 2)
+
+					result, err = db.GetThings(database.GetThingsParams{
+						FilterOnType:    old.Type,
+						FilterOnAddress: old.Address,
+						FilterOnRemoved: null.BoolFrom(true),
+						Page:            1,
+						ThingsPerPage:   10,
+					})
+					So(err, ShouldBeNil)
+					So(len(result.Things), ShouldEqual, 1)
+					So(result.LastPage, ShouldEqual, 1)
+					So(result.Things[0].ID, ShouldEqual, old.ID)
+					So(result.Things[0].Removed, ShouldBeTrue)
+
+					result, err = db.GetThings(database.GetThingsParams{
+						FilterOnRemoved: null.BoolFrom(false),
+					})
+					So(err, ShouldBeNil)
+					So(len(result.Things), ShouldEqual, numThings)
+				})
+
+				Convey("Then you can record probe results, and sort on them", func() {
+					modified := time.Date(2025, 2, 3, 4, 5, 6, 0, time.UTC)
+					accessed := time.Date(2025, 3, 4, 5, 6, 7, 0, time.UTC)
+
+					err := db.UpdateProbe(3, database.ProbeResult{Exists: true, Size: 100, Files: 2, Modified: modified,
+						Accessed: accessed})
+					So(err, ShouldBeNil)
+
+					err = db.UpdateProbe(4, database.ProbeResult{Exists: true, Size: 200, Files: 1, Modified: modified})
+					So(err, ShouldBeNil)
+
+					err = db.UpdateProbe(5, database.ProbeResult{Size: 300})
+					So(err, ShouldBeNil)
+
+					thing, err := db.GetThing(3)
+					So(err, ShouldBeNil)
+					So(thing.Exists, ShouldResemble, null.BoolFrom(true))
+					So(thing.Size, ShouldResemble, null.IntFrom(100))
+					So(thing.Files, ShouldResemble, null.IntFrom(2))
+					So(thing.Modified.Time.UTC(), ShouldEqual, modified)
+					So(thing.Accessed.Time.UTC(), ShouldEqual, accessed)
+					So(thing.Probed.Valid, ShouldBeTrue)
+
+					thing, err = db.GetThing(5)
+					So(err, ShouldBeNil)
+					So(thing.Exists, ShouldResemble, null.BoolFrom(false))
+					So(thing.Size.Valid, ShouldBeFalse)
+					So(thing.Modified.Valid, ShouldBeFalse)
+					So(thing.Accessed.Valid, ShouldBeFalse)
+
+					result, err := db.GetThings(database.GetThingsParams{
+						OrderBy:        database.OrderBySize,
+						OrderDirection: database.OrderDesc,
+					})
+					So(err, ShouldBeNil)
+					So(result.Things[0].ID, ShouldEqual, 4)
+					So(result.Things[1].ID, ShouldEqual, 3)
+				})
+
+				Convey("Then you can extend the removal date of things", func() {
+					remove := expectedThings[0].Remove.AddDate(1, 0, 0)
+
+					err := db.ExtendRemoval(1, remove)
+					So(err, ShouldBeNil)
+
+					thing, err := db.GetThing(1)
+					So(err, ShouldBeNil)
+					So(thing.Remove.UTC(), ShouldEqual, remove)
+					So(thing.Warned1.Valid, ShouldBeFalse)
+					So(thing.Warned2.Valid, ShouldBeFalse)
+
+					thing, err = db.GetThing(2)
+					So(err, ShouldBeNil)
+					So(thing.Remove.UTC(), ShouldEqual, expectedThings[1].Remove)
+					So(thing.Creator, ShouldEqual, expectedUsers[1].Name)
+				})
+
+				Convey("Then you can record things being archived or expired", func() {
+					err := db.MarkArchived(1, "/archive/1-a.tar.gz")
+					So(err, ShouldBeNil)
+
+					err = db.MarkExpired(2)
+					So(err, ShouldBeNil)
+
+					thing, err := db.GetThing(1)
+					So(err, ShouldBeNil)
+					So(thing.Archive.String, ShouldEqual, "/archive/1-a.tar.gz")
+					So(thing.Expired.Valid, ShouldBeFalse)
+
+					thing, err = db.GetThing(2)
+					So(err, ShouldBeNil)
+					So(thing.Archive.Valid, ShouldBeFalse)
+					So(thing.Expired.Valid, ShouldBeTrue)
+
+					err = db.ExtendRemoval(2, expectedThings[1].Remove.AddDate(1, 0, 0))
+					So(err, ShouldBeNil)
+
+					thing, err = db.GetThing(2)
+					So(err, ShouldBeNil)
+					So(thing.Expired.Valid, ShouldBeFalse)
+				})
+
+				Convey("Then you can record the fingerprints of things, and that they changed", func() {
+					thing, err := db.GetThing(1)
+					So(err, ShouldBeNil)
+					So(thing.Fingerprint, ShouldBeNil)
+					So(thing.Changed.Valid, ShouldBeFalse)
+
+					fingerprint := &database.Fingerprint{
+						Size:     10,
+						Files:    2,
+						Modified: time.Date(2025, 1, 2, 3, 4, 5, 123456000, time.UTC),
+						Checksum: strings.Repeat("a", 64),
+					}
+
+					err = db.SetFingerprint(1, fingerprint)
+					So(err, ShouldBeNil)
+
+					err = db.MarkChanged(1)
+					So(err, ShouldBeNil)
+
+					thing, err = db.GetThing(1)
+					So(err, ShouldBeNil)
+					So(thing.Fingerprint, ShouldResemble, fingerprint)
+					So(thing.Fingerprint.Changes(fingerprint), ShouldBeEmpty)
+					So(thing.Changed.Valid, ShouldBeTrue)
+
+					err = db.ExtendRemoval(1, expectedThings[0].Remove.AddDate(1, 0, 0))
+					So(err, ShouldBeNil)
+
+					thing, err = db.GetThing(1)
+					So(err, ShouldBeNil)
+					So(thing.Changed.Valid, ShouldBeFalse)
+
+					So(db.MarkChanged(1), ShouldBeNil)
+
+					fingerprint = &database.Fingerprint{Size: 20, Files: 3}
+
+					err = db.SetFingerprint(1, fingerprint)
+					So(err, ShouldBeNil)
+
+					thing, err = db.GetThing(1)
+					So(err, ShouldBeNil)
+					So(thing.Fingerprint, ShouldResemble, fingerprint)
+					So(thing.Changed.Valid, ShouldBeFalse)
+
+					err = db.SetFingerprint(1, nil)
+					So(err, ShouldBeNil)
+
+					thing, err = db.GetThing(1)
+					So(err, ShouldBeNil)
+					So(thing.Fingerprint, ShouldBeNil)
+				})
+
+				Convey("Then you can create things with other on expiry actions", func() {
+					thing, err := db.CreateThing(database.CreateThingParams{
+						Address:  "/archive/me",
+						Type:     database.ThingsTypeDir,
+						Reason:   "reason",
+						Remove:   expectedThings[0].Remove,
+						Creator:  expectedUsers[0].Name,
+						OnExpiry: database.ExpiryArchive,
+					})
+					So(err, ShouldBeNil)
+
+					thing, err = db.GetThing(thing.ID)
+					So(err, ShouldBeNil)
+					So(thing.OnExpiry, ShouldEqual, database.ExpiryArchive)
+					So(thing.ExpiryMode, ShouldEqual, database.ExpiryModeFixed)
+
+					thing, err = db.CreateThing(database.CreateThingParams{
+						Address:     "/scratch/me",
+						Type:        database.ThingsTypeDir,
+						Reason:      "reason",
+						Remove:      expectedThings[0].Remove,
+						Creator:     expectedUsers[0].Name,
+						ExpiryMode:  database.ExpiryModeAccess,
+						ExpireAfter: 30,
+					})
+					So(err, ShouldBeNil)
+					So(thing.ExpiryMode, ShouldEqual, database.ExpiryModeAccess)
+
+					thing, err = db.GetThing(thing.ID)
+					So(err, ShouldBeNil)
+					So(thing.ExpiryMode, ShouldEqual, database.ExpiryModeAccess)
+					So(thing.ExpireAfter, ShouldEqual, 30)
+				})
+
+				Convey("Then you can create things that need approval, and approve or reject them", func() {
+					params := database.CreateThingParams{
+						Address:        "/big/me",
+						Type:           database.ThingsTypeDir,
+						Reason:         "reason",
+						Remove:         expectedThings[0].Remove,
+						Creator:        expectedUsers[0].Name,
+						Approval:       database.ApprovalPending,
+						ApprovalReason: "too big",
+					}
+
+					thing, err := db.CreateThing(params)
+					So(err, ShouldBeNil)
+					So(thing.Registered(), ShouldBeFalse)
+
+					id := thing.ID
+
+					thing, err = db.GetThing(id)
+					So(err, ShouldBeNil)
+					So(thing.Approval, ShouldEqual, database.ApprovalPending)
+					So(thing.ApprovalReason, ShouldEqual, "too big")
+					So(thing.State, ShouldEqual, database.StatePending)
+
+					result, err := db.GetThings(database.GetThingsParams{FilterOnState: database.StatePending})
+					So(err, ShouldBeNil)
+					So(len(result.Things), ShouldEqual, 1)
+					So(result.Things[0].ID, ShouldEqual, id)
+
+					So(db.SetApproval(id, database.ApprovalApproved), ShouldBeNil)
+
+					thing, err = db.GetThing(id)
+					So(err, ShouldBeNil)
+					So(thing.Approval, ShouldEqual, database.ApprovalApproved)
+					So(thing.Registered(), ShouldBeTrue)
+					So(thing.Removed, ShouldBeFalse)
+					So(thing.State, ShouldEqual, database.StateActive)
+
+					result, err = db.GetThings(database.GetThingsParams{FilterOnState: database.StatePending})
+					So(err, ShouldBeNil)
+					So(result.Things, ShouldBeEmpty)
+
+					So(db.SetApproval(id, database.ApprovalRejected), ShouldBeNil)
+
+					thing, err = db.GetThing(id)
+					So(err, ShouldBeNil)
+					So(thing.Approval, ShouldEqual, database.ApprovalRejected)
+					So(thing.Removed, ShouldBeTrue)
+					So(thing.State, ShouldEqual, database.StateRemoved)
+
+					_, err = db.CreateThing(params)
+					So(err, ShouldBeNil)
+
+					thing, err = db.GetThing(1)
+					So(err, ShouldBeNil)
+					So(thing.Approval, ShouldEqual, database.ApprovalNotNeeded)
+				})
+
+				Convey("Then you can record warnings being sent, and forget them", func() {
+					warned := time.Date(2025, 2, 3, 0, 0, 0, 0, time.UTC)
+
+					err := db.SetWarned(1, null.TimeFrom(warned), null.Time{})
+					So(err, ShouldBeNil)
+
+					thing, err := db.GetThing(1)
+					So(err, ShouldBeNil)
+					So(thing.Warned1.Time.UTC(), ShouldEqual, warned)
+					So(thing.Warned2.Valid, ShouldBeFalse)
+
+					err = db.SetWarned(1, null.TimeFrom(warned), null.TimeFrom(warned.AddDate(0, 0, 1)))
+					So(err, ShouldBeNil)
+
+					thing, err = db.GetThing(1)
+					So(err, ShouldBeNil)
+					So(thing.Warned2.Time.UTC(), ShouldEqual, warned.AddDate(0, 0, 1))
+
+					err = db.ExtendRemoval(1, expectedThings[0].Remove.AddDate(1, 0, 0))
+					So(err, ShouldBeNil)
+
+					thing, err = db.GetThing(1)
+					So(err, ShouldBeNil)
+					So(thing.Warned1.Valid, ShouldBeFalse)
+					So(thing.Warned2.Valid, ShouldBeFalse)
+				})
+
+				Convey("Then things' states follow their lifecycle, and invalid transitions are refused", func() {
+					stateOf := func(id uint32) database.State {
+						thing, err := db.GetThing(id)
+						So(err, ShouldBeNil)
+
+						return thing.State
+					}
+
+					So(stateOf(1), ShouldEqual, database.StateActive)
+
+					So(db.SetWarned(1, null.TimeFrom(time.Now()), null.Time{}), ShouldBeNil)
+					So(stateOf(1), ShouldEqual, database.StateWarned)
+
+					So(db.EnqueueJob(1, database.JobRemove), ShouldBeNil)
+					So(stateOf(1), ShouldEqual, database.StateRemoving)
+
+					So(db.StartJob(1, "a"), ShouldBeNil)
+					So(db.FailJob(1, "failed", database.JobFailed, time.Now()), ShouldBeNil)
+					So(stateOf(1), ShouldEqual, database.StateFailed)
+
+					So(db.CancelJob(1), ShouldBeNil)
+					So(stateOf(1), ShouldEqual, database.StateWarned)
+
+					So(db.RetryJob(1), ShouldBeNil)
+					So(stateOf(1), ShouldEqual, database.StateRemoving)
+
+					So(db.StartJob(1, "a"), ShouldBeNil)
+					So(db.FinishJob(1), ShouldBeNil)
+					So(stateOf(1), ShouldEqual, database.StateWarned)
+
+					So(db.MarkRemoved(1), ShouldBeNil)
+					So(stateOf(1), ShouldEqual, database.StateRemoved)
+
+					result, err := db.GetThings(database.GetThingsParams{FilterOnState: database.StateRemoved})
+					So(err, ShouldBeNil)
+					So(len(result.Things), ShouldEqual, 1)
+					So(result.Things[0].ID, ShouldEqual, 1)
+
+					pending, err := db.CreateThing(database.CreateThingParams{
+						Address:  "/pending",
+						Type:     database.ThingsTypeDir,
+						Reason:   "reason",
+						Remove:   expectedThings[0].Remove,
+						Creator:  expectedUsers[0].Name,
+						Approval: database.ApprovalPending,
+					})
+					So(err, ShouldBeNil)
+					So(pending.State, ShouldEqual, database.StatePending)
+
+					err = db.EnqueueJob(pending.ID, database.JobRemove)
+					So(err, ShouldWrap, database.ErrBadTransition)
+
+					thing, err := db.GetThing(pending.ID)
+					So(err, ShouldBeNil)
+					So(thing.State, ShouldEqual, database.StatePending)
+					So(thing.Job, ShouldBeEmpty)
+
+					So(db.MarkExpired(2), ShouldBeNil)
+					So(stateOf(2), ShouldEqual, database.StateExpired)
+
+					So(db.ExtendRemoval(2, expectedThings[1].Remove.AddDate(1, 0, 0)), ShouldBeNil)
+					So(stateOf(2), ShouldEqual, database.StateActive)
+
+					So(db.MarkRemoved(0), ShouldEqual, database.ErrNoThing)
+				})
+
+				Convey("Then you can place holds on things, deleting idle removal jobs, and release them", func() {
+					So(db.EnqueueJob(1, database.JobRemove), ShouldBeNil)
+
+					until := time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC)
+
+					err := db.SetHold(1, "investigation", null.TimeFrom(until))
+					So(err, ShouldBeNil)
+
+					thing, err := db.GetThing(1)
+					So(err, ShouldBeNil)
+					So(thing.HoldReason, ShouldResemble, null.StringFrom("investigation"))
+					So(thing.HoldUntil.Time.UTC(), ShouldEqual, until)
+					So(thing.Job, ShouldBeEmpty)
+					So(thing.State, ShouldEqual, database.StateActive)
+
+					So(db.SetHold(2, "paper", null.Time{}), ShouldBeNil)
+
+					thing, err = db.GetThing(2)
+					So(err, ShouldBeNil)
+					So(thing.HoldReason.String, ShouldEqual, "paper")
+					So(thing.HoldUntil.Valid, ShouldBeFalse)
+
+					So(db.ReleaseHold(1), ShouldBeNil)
+
+					thing, err = db.GetThing(1)
+					So(err, ShouldBeNil)
+					So(thing.HoldReason.Valid, ShouldBeFalse)
+					So(thing.HoldUntil.Valid, ShouldBeFalse)
+				})
+
+				Convey("Then users can snooze warnings about things, until the snoozes are deleted", func() {
+					until := time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC)
+
+					So(db.SetSnooze(1, 1, until.AddDate(0, 0, -1)), ShouldBeNil)
+					So(db.SetSnooze(1, 1, until), ShouldBeNil)
+					So(db.SetSnooze(1, 2, until.AddDate(0, 0, 1)), ShouldBeNil)
+					So(db.SetSnooze(2, 1, until.AddDate(0, 0, 1)), ShouldBeNil)
+
+					snoozes, err := db.GetSnoozes(1)
+					So(err, ShouldBeNil)
+					So(len(snoozes), ShouldEqual, 2)
+					So(snoozes[0].ThingID, ShouldEqual, 1)
+					So(snoozes[0].User, ShouldResemble, expectedUsers[0])
+					So(snoozes[0].Until.UTC(), ShouldEqual, until)
+					So(snoozes[0].Missed, ShouldBeFalse)
+					So(snoozes[1].User, ShouldResemble, expectedUsers[1])
+
+					So(db.MarkSnoozeMissed(1, 1), ShouldBeNil)
+
+					snoozes, err = db.GetEndedSnoozes(until.Add(time.Hour))
+					So(err, ShouldBeNil)
+					So(len(snoozes), ShouldEqual, 1)
+					So(snoozes[0].User.ID, ShouldEqual, 1)
+					So(snoozes[0].Missed, ShouldBeTrue)
+
+					snoozes, err = db.GetEndedSnoozes(until.AddDate(0, 0, 2))
+					So(err, ShouldBeNil)
+					So(len(snoozes), ShouldEqual, 3)
+					So(snoozes[2].ThingID, ShouldEqual, 2)
+
+					So(db.DeleteSnooze(1, 1), ShouldBeNil)
+
+					snoozes, err = db.GetSnoozes(1)
+					So(err, ShouldBeNil)
+					So(len(snoozes), ShouldEqual, 1)
+					So(snoozes[0].User.ID, ShouldEqual, 2)
+				})
+
+				Convey("Then users can confirm the removal of things, replacing earlier confirmations", func() {
+					remove := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+
+					So(db.ConfirmRemoval(1, 1, remove.AddDate(0, 0, -1)), ShouldBeNil)
+					So(db.ConfirmRemoval(1, 1, remove), ShouldBeNil)
+					So(db.ConfirmRemoval(1, 2, remove.AddDate(0, 0, 1)), ShouldBeNil)
+					So(db.ConfirmRemoval(2, 1, remove), ShouldBeNil)
+
+					confirmations, err := db.GetConfirmations(1)
+					So(err, ShouldBeNil)
+					So(len(confirmations), ShouldEqual, 2)
+					So(confirmations[0].ThingID, ShouldEqual, 1)
+					So(confirmations[0].User, ShouldResemble, expectedUsers[0])
+					So(confirmations[0].Remove.UTC(), ShouldEqual, remove)
+					So(confirmations[0].Covers(remove), ShouldBeTrue)
+					So(confirmations[1].User, ShouldResemble, expectedUsers[1])
+					So(confirmations[1].Covers(remove), ShouldBeFalse)
+				})
+
+				Convey("Then users can unsubscribe from things, deleting their snoozes and confirmations", func() {
+					So(db.SetSnooze(1, 1, time.Now().Add(time.Hour)), ShouldBeNil)
+					So(db.ConfirmRemoval(1, 1, time.Now().Add(time.Hour)), ShouldBeNil)
+
+					users, err := db.GetSubscribers(1)
+					So(err, ShouldBeNil)
+					So(len(users), ShouldEqual, 1)
+					So(users[0].ID, ShouldEqual, 1)
+
+					So(db.Unsubscribe(1, 1), ShouldBeNil)
+
+					users, err = db.GetSubscribers(1)
+					So(err, ShouldBeNil)
+					So(users, ShouldBeEmpty)
+
+					snoozes, err := db.GetSnoozes(1)
+					So(err, ShouldBeNil)
+					So(snoozes, ShouldBeEmpty)
+
+					confirmations, err := db.GetConfirmations(1)
+					So(err, ShouldBeNil)
+					So(confirmations, ShouldBeEmpty)
+				})
+
+				Convey("Then links can only be used once, until they expire", func() {
+					expires := time.Now().Add(time.Hour)
+					uses := 0
+					use := func() error {
+						uses++
+
+						return nil
+					}
+
+					So(db.UseLink("a", expires, use), ShouldBeNil)
+					So(db.UseLink("b", expires, use), ShouldBeNil)
+					So(db.UseLink("a", expires, use), ShouldEqual, database.ErrLinkUsed)
+					So(uses, ShouldEqual, 2)
+
+					So(db.UseLink("c", time.Now().Add(-time.Hour), use), ShouldBeNil)
+					So(db.UseLink("c", expires, use), ShouldBeNil)
+					So(uses, ShouldEqual, 4)
+
+					Convey("but failed uses don't use them up", func() {
+						errFailed := errors.New("failed")
+
+						So(db.UseLink("d", expires, func() error { return errFailed }), ShouldEqual, errFailed)
+						So(db.UseLink("d", expires, use), ShouldBeNil)
+						So(db.UseLink("d", expires, use), ShouldEqual, database.ErrLinkUsed)
+						So(uses, ShouldEqual, 5)
+					})
+				})
+
+				Convey("Then you can quarantine and restore things", func() {
+					thing, err := db.GetThing(1)
+					So(err, ShouldBeNil)
+					So(thing.Quarantined(), ShouldBeFalse)
+
+					until := time.Now().Add(time.Hour).Truncate(time.Second).UTC()
+
+					err = db.MarkQuarantined(1, "/quarantine/1/a", until)
+					So(err, ShouldBeNil)
+
+					thing, err = db.GetThing(1)
+					So(err, ShouldBeNil)
+					So(thing.Quarantined(), ShouldBeTrue)
+					So(thing.Quarantine.String, ShouldEqual, "/quarantine/1/a")
+					So(thing.QuarantineUntil.Time.UTC(), ShouldEqual, until)
+
+					remove := expectedThings[0].Remove.AddDate(0, 1, 0)
+
+					err = db.MarkRestored(1, remove)
+					So(err, ShouldBeNil)
+
+					thing, err = db.GetThing(1)
+					So(err, ShouldBeNil)
+					So(thing.Quarantined(), ShouldBeFalse)
+					So(thing.Quarantine.Valid, ShouldBeFalse)
+					So(thing.Remove.UTC(), ShouldEqual, remove)
+				})
+
+				Convey("Then you can queue removal jobs and track their progress", func() {
+					err := db.EnqueueJob(1, database.JobRemove)
+					So(err, ShouldBeNil)
+
+					err = db.EnqueueJob(2, database.JobQuarantine)
+					So(err, ShouldBeNil)
+
+					err = db.EnqueueJob(1, database.JobArchive)
+					So(err, ShouldBeNil)
+
+					jobs, err := db.GetRemovalJobs("")
+					So(err, ShouldBeNil)
+					So(len(jobs), ShouldEqual, 2)
+					So(jobs[0].ThingID, ShouldEqual, 1)
+					So(jobs[0].Type, ShouldEqual, expectedThings[0].Type)
+					So(jobs[0].Action, ShouldEqual, database.JobRemove)
+					So(jobs[0].State, ShouldEqual, database.JobQueued)
+					So(jobs[0].Attempts, ShouldEqual, 0)
+					So(jobs[0].LastError.Valid, ShouldBeFalse)
+
+					thing, err := db.GetThing(1)
+					So(err, ShouldBeNil)
+					So(thing.Job, ShouldEqual, database.JobQueued)
+
+					thing, err = db.GetThing(3)
+					So(err, ShouldBeNil)
+					So(thing.Job, ShouldEqual, database.JobState(""))
+
+					So(db.StartJob(1, "a"), ShouldBeNil)
+					So(db.CancelJob(1), ShouldEqual, database.ErrJobRunning)
+					So(db.RetryJob(1), ShouldEqual, database.ErrJobNotFailed)
+
+					next := time.Now().Add(time.Hour).Truncate(time.Second).UTC()
+
+					err = db.FailJob(1, "remove failed", database.JobFailed, next)
+					So(err, ShouldBeNil)
+
+					jobs, err = db.GetRemovalJobs(database.JobFailed)
+					So(err, ShouldBeNil)
+					So(len(jobs), ShouldEqual, 1)
+					So(jobs[0].Attempts, ShouldEqual, 1)
+					So(jobs[0].NextAttempt.UTC(), ShouldEqual, next)
+
+					thing, err = db.GetThing(1)
+					So(err, ShouldBeNil)
+					So(thing.Job, ShouldEqual, database.JobFailed)
+					So(thing.JobError.String, ShouldEqual, "remove failed")
+
+					So(db.RetryJob(1), ShouldBeNil)
+
+					jobs, err = db.GetRemovalJobs(database.JobQueued)
+					So(err, ShouldBeNil)
+					So(len(jobs), ShouldEqual, 2)
+
+					for _, job := range jobs {
+						So(job.Attempts, ShouldEqual, 0)
+					}
+
+					So(db.CancelJob(2), ShouldBeNil)
+					So(db.CancelJob(2), ShouldBeNil)
+					So(db.CancelJob(3), ShouldEqual, database.ErrNoJob)
+					So(db.RetryJob(3), ShouldEqual, database.ErrNoJob)
+
+					ok, err := db.AcquireLease(database.SchedulerLease, "a", time.Hour)
+					So(err, ShouldBeNil)
+					So(ok, ShouldBeTrue)
+
+					So(db.StartJob(2, "a"), ShouldBeNil)
+					So(db.ResetRunningJobs(), ShouldBeNil)
+
+					jobs, err = db.GetRemovalJobs(database.JobRunning)
+					So(err, ShouldBeNil)
+					So(len(jobs), ShouldEqual, 1)
+					So(jobs[0].Runner, ShouldEqual, "a")
+
+					So(db.ReleaseLease(database.SchedulerLease, "a"), ShouldBeNil)
+					So(db.ResetRunningJobs(), ShouldBeNil)
+
+					jobs, err = db.GetRemovalJobs(database.JobRunning)
+					So(err, ShouldBeNil)
+					So(jobs, ShouldBeEmpty)
+
+					So(db.FinishJob(2), ShouldBeNil)
+					So(db.ExtendRemoval(1, expectedThings[0].Remove.AddDate(0, 1, 0)), ShouldBeNil)
+
+					jobs, err = db.GetRemovalJobs("")
+					So(err, ShouldBeNil)
+					So(jobs, ShouldBeEmpty)
+				})
+
+				Convey("Then you can take, renew and release leases", func() {
+					ok, err := db.AcquireLease(database.SchedulerLease, "a", time.Hour)
+					So(err, ShouldBeNil)
+					So(ok, ShouldBeTrue)
+
+					ok, err = db.AcquireLease(database.SchedulerLease, "b", time.Hour)
+					So(err, ShouldBeNil)
+					So(ok, ShouldBeFalse)
+
+					leases, err := db.GetLeases()
+					So(err, ShouldBeNil)
+					So(len(leases), ShouldEqual, 1)
+					So(leases[0].Holder, ShouldEqual, "a")
+					So(leases[0].Expired(time.Now()), ShouldBeFalse)
+
+					acquired := leases[0].Acquired
+
+					ok, err = db.AcquireLease(database.SchedulerLease, "a", -time.Second)
+					So(err, ShouldBeNil)
+					So(ok, ShouldBeTrue)
+
+					leases, err = db.GetLeases()
+					So(err, ShouldBeNil)
+					So(leases[0].Acquired, ShouldEqual, acquired)
+					So(leases[0].Expired(time.Now()), ShouldBeTrue)
+
+					ok, err = db.AcquireLease(database.SchedulerLease, "b", time.Hour)
+					So(err, ShouldBeNil)
+					So(ok, ShouldBeTrue)
+
+					So(db.ReleaseLease(database.SchedulerLease, "a"), ShouldBeNil)
+
+					leases, err = db.GetLeases()
+					So(err, ShouldBeNil)
+					So(len(leases), ShouldEqual, 1)
+					So(leases[0].Holder, ShouldEqual, "b")
+
+					So(db.ReleaseLease(database.SchedulerLease, "b"), ShouldBeNil)
+
+					leases, err = db.GetLeases()
+					So(err, ShouldBeNil)
+					So(leases, ShouldBeEmpty)
+				})
+
+				Convey("Then you can get subscribers, and record and get the history of things", func() {
+					users, err := db.GetSubscribers(1)
+					So(err, ShouldBeNil)
+					So(users, ShouldResemble, []database.User{expectedUsers[0]})
+
+					users, err = db.GetSubscribers(uint32(numThings + 1))
+					So(err, ShouldBeNil)
+					So(users, ShouldBeEmpty)
+
+					before := time.Now().Add(-time.Second)
+
+					err = db.AddAuditEvent(database.AuditEvent{
+						ThingID: 2,
+						Action:  database.AuditRemovedExternally,
+						Detail:  "detail",
+					})
+					So(err, ShouldBeNil)
+
+					later := time.Date(2100, 1, 2, 3, 4, 5, 0, time.UTC)
+
+					err = db.AddAuditEvent(database.AuditEvent{
+						ThingID: 2,
+						Time:    later,
+						Actor:   expectedUsers[1].Name,
+						Action:  database.AuditRemovedExternally,
+					})
+					So(err, ShouldBeNil)
+
+					events, err := db.GetAuditEvents(2)
+					So(err, ShouldBeNil)
+					So(len(events), ShouldEqual, 2)
+					So(events[0].Time, ShouldHappenAfter, before)
+					So(events[0].Actor, ShouldBeBlank)
+					So(events[0].Detail, ShouldEqual, "detail")
+					So(events[1].Time.UTC(), ShouldEqual, later)
+					So(events[1].Actor, ShouldEqual, expectedUsers[1].Name)
+
+					events, err = db.GetAuditEvents(1)
+					So(err, ShouldBeNil)
+					So(events, ShouldBeEmpty)
+				})
+
 				Convey("Then you can delete users and things", func() {
 					err = db.DeleteUser(2)
 					So(err, ShouldBeNil)
@@ -487,9 +1294,17 @@ func TestMySQL(t *testing.T) {
 					So(err, ShouldBeNil)
 					So(count, ShouldEqual, numThings/2)
 
+					So(db.AddAuditEvent(database.AuditEvent{ThingID: 3, Actor: "a", Action: database.AuditDeleted}),
+						ShouldBeNil)
+
 					err = db.DeleteThing(3)
 					So(err, ShouldBeNil)
 
+					events, err := db.GetAuditEvents(3)
+					So(err, ShouldBeNil)
+					So(len(events), ShouldEqual, 1)
+					So(events[0].Action, ShouldEqual, database.AuditDeleted)
+
 					count, err = countTableRows(db.pool, "users")
 					So(err, ShouldBeNil)
 					So(count, ShouldEqual, 1)
@@ -27,15 +27,22 @@ package mysql
 
 import (
 	"database/sql"
+	"errors"
 	"math"
 	"strconv"
 	"strings"
 	"time"
 
+	gsdmysql "github.com/go-sql-driver/mysql"
+	null "github.com/guregu/null/v5"
 	"github.com/wtsi-hgi/tt/database"
 )
 
-const ErrNoUser = database.Error("No User found with that name")
+const (
+	ErrNoUser = database.Error("No User found with that name")
+
+	errCodeDuplicateEntry = 1062
+)
 
 const createUser = `INSERT INTO users (name, email) VALUES (?, ?)`
 
@@ -114,9 +121,10 @@ func (m *MySQLDB) GetUserByName(name string) (*database.User, error) {
 
 const createThing = `
 INSERT INTO things (
-  address, type, created, description, reason, remove
+  address, type, created, description, reason, remove, on_expiry, expiry_mode, expire_after, approval,
+  approval_reason, state
 ) VALUES (
-  ?, ?, ?, ?, ?, ?
+  ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
 )
 `
 
@@ -128,12 +136,36 @@ INSERT INTO subscribers (
 )
 `
 
+const getThingIDByAddress = `
+SELECT id
+FROM things
+WHERE live_address_hash = UNHEX(SHA2(?, 256)) AND type = ?
+`
+
 // CreateThing creates a new thing with the given details. The returned thing
 // will have its ID set to an auto-increment value, and Created time set to now.
 // The supplied Creator must match the Name of an existing User, and will be
-// recored as a Subscriber of the new Thing.
+// recored as a Subscriber of the new Thing. The Address is stored in its
+// CanonicalAddress() form, and if a thing with the same canonical address and
+// type already exists that hasn't been removed, a *database.DuplicateError is
+// returned. A blank OnExpiry is stored as database.ExpiryDelete, and a blank
+// ExpiryMode as database.ExpiryModeFixed.
 func (m *MySQLDB) CreateThing(args database.CreateThingParams) (*database.Thing, error) {
 	created := time.Now()
+	args.Address = args.Type.CanonicalAddress(args.Address)
+
+	if args.OnExpiry == "" {
+		args.OnExpiry = database.ExpiryDelete
+	}
+
+	if args.ExpiryMode == "" {
+		args.ExpiryMode = database.ExpiryModeFixed
+	}
+
+	state := database.StateActive
+	if args.Approval == database.ApprovalPending {
+		state = database.StatePending
+	}
 
 	user, err := m.GetUserByName(args.Creator)
 	if err != nil {
@@ -152,11 +184,17 @@ func (m *MySQLDB) CreateThing(args database.CreateThingParams) (*database.Thing,
 		args.Description,
 		args.Reason,
 		args.Remove,
+		args.OnExpiry,
+		args.ExpiryMode,
+		args.ExpireAfter,
+		args.Approval,
+		args.ApprovalReason,
+		state,
 	)
 	if err != nil {
 		tx.Rollback()
 
-		return nil, err
+		return nil, m.duplicateError(err, args)
 	}
 
 	_, err = tx.Exec(createSubscription, user.ID, id, 1)
@@ -179,23 +217,151 @@ func (m *MySQLDB) CreateThing(args database.CreateThingParams) (*database.Thing,
 		Description: args.Description,
 		Reason:      args.Reason,
 		Remove:      args.Remove,
+		Creator:     user.Name,
+		OnExpiry:    args.OnExpiry,
+		ExpiryMode:  args.ExpiryMode,
+		ExpireAfter: args.ExpireAfter,
+
+		Approval:       args.Approval,
+		ApprovalReason: args.ApprovalReason,
+		State:          state,
 	}, nil
 }
 
+// duplicateError converts the given error from inserting a thing in to a
+// *database.DuplicateError if it was due to a thing with the same address and
+// type already existing. Otherwise returns the given error.
+func (m *MySQLDB) duplicateError(err error, args database.CreateThingParams) error {
+	var mysqlErr *gsdmysql.MySQLError
+	if !errors.As(err, &mysqlErr) || mysqlErr.Number != errCodeDuplicateEntry {
+		return err
+	}
+
+	var id uint32
+
+	if errq := m.pool.QueryRow(getThingIDByAddress, args.Address, args.Type).Scan(&id); errq != nil {
+		return err
+	}
+
+	return &database.DuplicateError{ExistingID: id}
+}
+
 const getThings = `
-SELECT things.id, address, type, created, description, reason, remove, warned1, warned2, removed
+SELECT things.id, address, type, things.created, description, reason, remove, warned1, warned2, removed,
+  address_exists, size, files, modified, accessed, probed, quarantine, quarantine_until, on_expiry, archive,
+  expiry_mode, expire_after, expired,
+  fingerprint_size, fingerprint_files, fingerprint_modified, fingerprint_checksum, changed,
+  approval, approval_reason, hold_reason, hold_until, things.state,
+  removal_jobs.state, removal_jobs.last_error, removal_jobs.files_removed, removal_jobs.bytes_removed,
+  removal_jobs.removal_errors,
+  (SELECT users.name FROM subscribers JOIN users ON users.id = subscribers.user_id
+   WHERE subscribers.thing_id = things.id AND subscribers.creator = 1 LIMIT 1)
 FROM things
+LEFT JOIN removal_jobs ON removal_jobs.thing_id = things.id
 `
 
+const getThing = getThings + `WHERE things.id = ?`
+
+// GetThing returns the thing with the given ID, or database.ErrNoThing if
+// there isn't one.
+func (m *MySQLDB) GetThing(id uint32) (*database.Thing, error) {
+	thing, err := scanThing(m.pool.QueryRow(getThing, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, database.ErrNoThing
+	}
+
+	return thing, err
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanThing scans the columns selected by getThings in to a new Thing.
+func scanThing(row scanner) (*database.Thing, error) {
+	var (
+		thing       database.Thing
+		fingerprint [2]sql.NullInt64
+		modified    sql.NullTime
+		checksum    sql.NullString
+		jobState    sql.NullString
+		progress    [3]sql.NullInt64
+		creator     sql.NullString
+	)
+
+	if err := row.Scan(
+		&thing.ID,
+		&thing.Address,
+		&thing.Type,
+		&thing.Created,
+		&thing.Description,
+		&thing.Reason,
+		&thing.Remove,
+		&thing.Warned1,
+		&thing.Warned2,
+		&thing.Removed,
+		&thing.Exists,
+		&thing.Size,
+		&thing.Files,
+		&thing.Modified,
+		&thing.Accessed,
+		&thing.Probed,
+		&thing.Quarantine,
+		&thing.QuarantineUntil,
+		&thing.OnExpiry,
+		&thing.Archive,
+		&thing.ExpiryMode,
+		&thing.ExpireAfter,
+		&thing.Expired,
+		&fingerprint[0],
+		&fingerprint[1],
+		&modified,
+		&checksum,
+		&thing.Changed,
+		&thing.Approval,
+		&thing.ApprovalReason,
+		&thing.HoldReason,
+		&thing.HoldUntil,
+		&thing.State,
+		&jobState,
+		&thing.JobError,
+		&progress[0],
+		&progress[1],
+		&progress[2],
+		&creator,
+	); err != nil {
+		return nil, err
+	}
+
+	if fingerprint[0].Valid {
+		thing.Fingerprint = &database.Fingerprint{
+			Size:     fingerprint[0].Int64,
+			Files:    fingerprint[1].Int64,
+			Modified: modified.Time,
+			Checksum: checksum.String,
+		}
+	}
+
+	thing.Job = database.JobState(jobState.String)
+	thing.JobProgress = database.RemovalProgress{
+		Files:  progress[0].Int64,
+		Bytes:  progress[1].Int64,
+		Errors: progress[2].Int64,
+	}
+	thing.Creator = creator.String
+
+	return &thing, nil
+}
+
 // GetThings returns things that match the given parameters. Also in the result
 // is the last page that would return things if Page and ThingsPerPage are > 0.
 func (m *MySQLDB) GetThings(params database.GetThingsParams) (*database.GetThingsResult, error) {
 	var sql strings.Builder
 
 	sql.WriteString(getThings)
-	getThingsParamsToSQL(params, &sql)
+	args := getThingsParamsToSQL(params, &sql)
 
-	rows, err := m.pool.Query(sql.String())
+	rows, err := m.pool.Query(sql.String(), args...)
 	if err != nil {
 		return nil, err
 	}
@@ -205,24 +371,12 @@ func (m *MySQLDB) GetThings(params database.GetThingsParams) (*database.GetThing
 	var things []database.Thing
 
 	for rows.Next() {
-		var thing database.Thing
-
-		if err := rows.Scan(
-			&thing.ID,
-			&thing.Address,
-			&thing.Type,
-			&thing.Created,
-			&thing.Description,
-			&thing.Reason,
-			&thing.Remove,
-			&thing.Warned1,
-			&thing.Warned2,
-			&thing.Removed,
-		); err != nil {
+		thing, err := scanThing(rows)
+		if err != nil {
 			return nil, err
 		}
 
-		things = append(things, thing)
+		things = append(things, *thing)
 	}
 
 	if err := rows.Close(); err != nil {
@@ -244,20 +398,50 @@ func (m *MySQLDB) GetThings(params database.GetThingsParams) (*database.GetThing
 	}, nil
 }
 
-func getThingsParamsToSQL(params database.GetThingsParams, sql *strings.Builder) {
-	whereSQL(params, sql)
+func getThingsParamsToSQL(params database.GetThingsParams, sql *strings.Builder) []any {
+	args := whereSQL(params, sql)
 	orderSQL(params, sql)
 	limitSQL(params, sql)
+
+	return args
 }
 
-func whereSQL(params database.GetThingsParams, sql *strings.Builder) {
-	if params.FilterOnType == database.ThingsTypeNil {
-		return
+// whereSQL writes a WHERE clause for the filters set in params to sql, and
+// returns the values for its placeholders.
+func whereSQL(params database.GetThingsParams, sql *strings.Builder) []any {
+	var (
+		conditions []string
+		args       []any
+	)
+
+	if params.FilterOnType != database.ThingsTypeNil {
+		conditions = append(conditions, "type = ?")
+		args = append(args, params.FilterOnType)
+	}
+
+	if params.FilterOnAddress != "" {
+		conditions = append(conditions, "address_hash = UNHEX(SHA2(?, 256))")
+		args = append(args, params.FilterOnAddress)
 	}
 
-	sql.WriteString("\nWHERE type = '")
-	sql.WriteString(string(params.FilterOnType))
-	sql.WriteString("'")
+	if params.FilterOnRemoved.Valid {
+		conditions = append(conditions, "removed = ?")
+		args = append(args, params.FilterOnRemoved.Bool)
+	}
+
+	if params.FilterOnState != "" {
+		conditions = append(conditions, "things.state = ?")
+		args = append(args, params.FilterOnState)
+	}
+
+	if len(conditions) == 0 {
+		return nil
+	}
+
+	sql.WriteString("\nWHERE ")
+	sql.WriteString(strings.Join(conditions, " AND "))
+
+	return args
 }
 
 func orderSQL(params database.GetThingsParams, sql *strings.Builder) {
@@ -305,9 +489,9 @@ func (m *MySQLDB) calculateLastPage(params database.GetThingsParams) (int, error
 	)
 
 	sql.WriteString(countThings)
-	whereSQL(params, &sql)
+	args := whereSQL(params, &sql)
 
-	row := m.pool.QueryRow(sql.String())
+	row := m.pool.QueryRow(sql.String(), args...)
 
 	if err := row.Scan(&count); err != nil {
 		return 0, err
@@ -316,6 +500,26 @@ func (m *MySQLDB) calculateLastPage(params database.GetThingsParams) (int, error
 	return int(math.Ceil(float64(count) / float64(params.ThingsPerPage))), nil
 }
 
+const updateProbe = `
+UPDATE things
+SET address_exists = ?, size = ?, files = ?, modified = ?, accessed = ?, probed = ?
+WHERE id = ?
+`
+
+// UpdateProbe records the given result of probing the thing with the given ID,
+// along with the time it was probed. Size, files, modified and accessed are
+// stored as NULL if the result says the thing doesn't exist.
+func (m *MySQLDB) UpdateProbe(id uint32, result database.ProbeResult) error {
+	size := null.NewInt(result.Size, result.Exists)
+	files := null.NewInt(result.Files, result.Exists)
+	modified := null.NewTime(result.Modified, result.Exists && !result.Modified.IsZero())
+	accessed := null.NewTime(result.Accessed, result.Exists && !result.Accessed.IsZero())
+
+	_, err := m.pool.Exec(updateProbe, result.Exists, size, files, modified, accessed, time.Now(), id)
+
+	return err
+}
+
 const deleteUser = `DELETE FROM users WHERE id = ?`
 
 // DeleteUser deletes the user with the given ID. This will also delete any
@@ -326,36 +530,387 @@ func (m *MySQLDB) DeleteUser(id uint32) error {
 	return err
 }
 
-const deleteThing = `DELETE FROM things WHERE id = ?`
+const markRemoved = `
+UPDATE things
+SET removed = 1
+WHERE id = ?
+`
 
-// DeleteThing deletes the thing with the given ID.
-func (m *MySQLDB) DeleteThing(id uint32) error {
-	_, err := m.pool.Exec(deleteThing, id)
+// MarkRemoved records that the thing with the given ID has been removed. The
+// thing is kept for historical purposes, but a new thing with the same address
+// and type can now be created.
+func (m *MySQLDB) MarkRemoved(id uint32) error {
+	return m.changeThing(id, execute(markRemoved, id))
+}
+
+const extendRemoval = `
+UPDATE things
+SET remove = ?, warned1 = NULL, warned2 = NULL, expired = NULL, changed = NULL
+WHERE id = ?
+`
+
+// ExtendRemoval changes the removal date of the thing with the given ID, and
+// clears its record of warnings having been sent, of it having expired and of
+// it having changed, since they were about the old date. Any removal job it has
+// that isn't running is deleted.
+func (m *MySQLDB) ExtendRemoval(id uint32, remove time.Time) error {
+	return m.updateAndDeleteIdleJob(extendRemoval, remove, id)
+}
+
+// updateAndDeleteIdleJob executes the given update of a thing, whose ID must be
+// the last arg, and deletes its removal job if it isn't running, in a
+// transaction.
+func (m *MySQLDB) updateAndDeleteIdleJob(update string, args ...any) error {
+	id := args[len(args)-1]
+
+	return m.changeThing(id, execute(update, args...), execute(deleteIdleJob, id))
+}
+
+// change is a statement that changes a thing or its removal job within a
+// transaction.
+type change func(tx *sql.Tx) error
+
+// execute returns a change that executes the given query with the given args.
+func execute(query string, args ...any) change {
+	return func(tx *sql.Tx) error {
+		_, err := tx.Exec(query, args...)
+
+		return err
+	}
+}
+
+const (
+	lockThingState = `SELECT state FROM things WHERE id = ? FOR UPDATE`
+	setThingState  = `UPDATE things SET state = ? WHERE id = ?`
+)
+
+// changeThing makes the given changes to the thing with the given ID in a
+// transaction, then updates its state to match. If the thing can't make that
+// state transition, the changes are rolled back and an error that Is()
+// database.ErrBadTransition is returned. Returns database.ErrNoThing if there's
+// no thing with that ID.
+func (m *MySQLDB) changeThing(id any, changes ...change) error {
+	tx, err := m.pool.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err = changeThingInTx(tx, id, changes); err != nil {
+		tx.Rollback()
+
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func changeThingInTx(tx *sql.Tx, id any, changes []change) error {
+	var from database.State
+
+	err := tx.QueryRow(lockThingState, id).Scan(&from)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.ErrNoThing
+	} else if err != nil {
+		return err
+	}
+
+	for _, change := range changes {
+		if err = change(tx); err != nil {
+			return err
+		}
+	}
+
+	thing, err := scanThing(tx.QueryRow(getThing, id))
+	if err != nil {
+		return err
+	}
+
+	to := thing.ComputeState()
+	if err = from.To(to); err != nil {
+		return err
+	}
+
+	if to == from {
+		return nil
+	}
+
+	_, err = tx.Exec(setThingState, to, id)
 
 	return err
 }
 
-const extendRemoval = `
+const setWarned = `
 UPDATE things
-SET remove = ?, warned1 = NULL, warned2 = NULL
+SET warned1 = ?, warned2 = ?
 WHERE id = ?
 `
 
-const updateDescription = `
+// SetWarned records when the first and second warnings of the upcoming removal
+// of the thing with the given ID were sent, with null meaning not sent.
+func (m *MySQLDB) SetWarned(id uint32, warned1, warned2 null.Time) error {
+	return m.changeThing(id, execute(setWarned, warned1, warned2, id))
+}
+
+const markQuarantined = `
 UPDATE things
-SET description = ?
+SET quarantine = ?, quarantine_until = ?
+WHERE id = ?
+`
+
+// MarkQuarantined records that what was at the address of the thing with the
+// given ID has been moved to the given quarantine location, where it will stay
+// until the given time before being permanently removed.
+func (m *MySQLDB) MarkQuarantined(id uint32, location string, until time.Time) error {
+	return m.changeThing(id, execute(markQuarantined, location, until, id))
+}
+
+const markArchived = `
+UPDATE things
+SET archive = ?
+WHERE id = ?
+`
+
+// MarkArchived records that the thing with the given ID was archived to the
+// given path.
+func (m *MySQLDB) MarkArchived(id uint32, path string) error {
+	_, err := m.pool.Exec(markArchived, path, id)
+
+	return err
+}
+
+const markExpired = `
+UPDATE things
+SET expired = ?
+WHERE id = ?
+`
+
+// MarkExpired records that subscribers of the thing with the given ID have
+// been told that its removal date has passed, without it being removed.
+func (m *MySQLDB) MarkExpired(id uint32) error {
+	return m.changeThing(id, execute(markExpired, time.Now(), id))
+}
+
+const markRestored = `
+UPDATE things
+SET quarantine = NULL, quarantine_until = NULL, remove = ?, warned1 = NULL, warned2 = NULL
+WHERE id = ?
+`
+
+// MarkRestored records that the quarantined thing with the given ID has been
+// moved back to its address, and gives it the new removal date, clearing its
+// record of warnings having been sent. Any removal job it has that isn't
+// running is deleted.
+func (m *MySQLDB) MarkRestored(id uint32, remove time.Time) error {
+	return m.updateAndDeleteIdleJob(markRestored, remove, id)
+}
+
+const setFingerprint = `
+UPDATE things
+SET fingerprint_size = ?, fingerprint_files = ?, fingerprint_modified = ?, fingerprint_checksum = ?,
+  changed = NULL
+WHERE id = ?
+`
+
+// SetFingerprint records the given fingerprint (which can be nil) of the thing
+// with the given ID, and clears any record of it having changed.
+func (m *MySQLDB) SetFingerprint(id uint32, fingerprint *database.Fingerprint) error {
+	var (
+		size, files null.Int
+		modified    null.Time
+		checksum    null.String
+	)
+
+	if fingerprint != nil {
+		size = null.IntFrom(fingerprint.Size)
+		files = null.IntFrom(fingerprint.Files)
+		modified = null.NewTime(fingerprint.Modified, !fingerprint.Modified.IsZero())
+		checksum = null.NewString(fingerprint.Checksum, fingerprint.Checksum != "")
+	}
+
+	_, err := m.pool.Exec(setFingerprint, size, files, modified, checksum, id)
+
+	return err
+}
+
+const setApproval = `
+UPDATE things
+SET approval = ?, removed = removed OR ?
+WHERE id = ?
+`
+
+// SetApproval records the approval state of the thing with the given ID.
+// Rejected things are also marked as removed, so that a new thing with the same
+// address and type can be created.
+func (m *MySQLDB) SetApproval(id uint32, state database.ApprovalState) error {
+	return m.changeThing(id, execute(setApproval, state, state == database.ApprovalRejected, id))
+}
+
+const setHold = `
+UPDATE things
+SET hold_reason = ?, hold_until = ?
 WHERE id = ?
 `
 
-const firstWarningSent = `
+// SetHold places a legal hold on the thing with the given ID for the given
+// reason, until the given time (or indefinitely if null). Any removal job it
+// has that isn't running is deleted.
+func (m *MySQLDB) SetHold(id uint32, reason string, until null.Time) error {
+	return m.updateAndDeleteIdleJob(setHold, reason, until, id)
+}
+
+const releaseHold = `
 UPDATE things
-SET warned1 = ?
+SET hold_reason = NULL, hold_until = NULL
 WHERE id = ?
 `
 
-const secondWarningSent = `
+// ReleaseHold removes any legal hold on the thing with the given ID.
+func (m *MySQLDB) ReleaseHold(id uint32) error {
+	_, err := m.pool.Exec(releaseHold, id)
+
+	return err
+}
+
+const markChanged = `
 UPDATE things
-SET warned2 = ?
+SET changed = ?
+WHERE id = ?
+`
+
+// MarkChanged records that the thing with the given ID wasn't removed because
+// its address no longer matched its fingerprint.
+func (m *MySQLDB) MarkChanged(id uint32) error {
+	_, err := m.pool.Exec(markChanged, time.Now(), id)
+
+	return err
+}
+
+const deleteThing = `DELETE FROM things WHERE id = ?`
+
+// DeleteThing deletes the thing with the given ID, along with its subscribers,
+// jobs, snoozes and confirmations, but not its audit events.
+func (m *MySQLDB) DeleteThing(id uint32) error {
+	_, err := m.pool.Exec(deleteThing, id)
+
+	return err
+}
+
+const getSubscribers = `
+SELECT users.id, users.name, users.email
+FROM subscribers
+JOIN users ON users.id = subscribers.user_id
+WHERE subscribers.thing_id = ?
+ORDER BY users.id
+`
+
+// GetSubscribers returns the users subscribed to the thing with the given ID.
+func (m *MySQLDB) GetSubscribers(thingID uint32) ([]database.User, error) {
+	rows, err := m.pool.Query(getSubscribers, thingID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var users []database.User
+
+	for rows.Next() {
+		var user database.User
+
+		if err := rows.Scan(&user.ID, &user.Name, &user.Email); err != nil {
+			return nil, err
+		}
+
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+// Unsubscribe stops the user with the given ID being subscribed to the thing
+// with the given ID, and deletes any snooze or confirmation they had of it.
+func (m *MySQLDB) Unsubscribe(thingID, userID uint32) error {
+	tx, err := m.pool.Begin()
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(unsubscribe, userID, thingID); err != nil {
+		tx.Rollback()
+
+		return err
+	}
+
+	for _, query := range []string{deleteSnooze, deleteConfirmation} {
+		if _, err = tx.Exec(query, thingID, userID); err != nil {
+			tx.Rollback()
+
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+const addAuditEvent = `
+INSERT INTO audit (
+  thing_id, time, actor, action, detail
+) VALUES (
+  ?, ?, ?, ?, ?
+)
+`
+
+// AddAuditEvent records the given event in the history of its thing. If the
+// event's Time is zero, it will be set to now.
+func (m *MySQLDB) AddAuditEvent(event database.AuditEvent) error {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	_, err := m.pool.Exec(addAuditEvent, event.ThingID, event.Time, event.Actor, event.Action, event.Detail)
+
+	return err
+}
+
+const getAuditEvents = `
+SELECT thing_id, time, actor, action, detail
+FROM audit
+WHERE thing_id = ?
+ORDER BY time, id
+`
+
+// GetAuditEvents returns the history of the thing with the given ID, oldest
+// first.
+func (m *MySQLDB) GetAuditEvents(thingID uint32) ([]database.AuditEvent, error) {
+	rows, err := m.pool.Query(getAuditEvents, thingID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var events []database.AuditEvent
+
+	for rows.Next() {
+		var (
+			event  database.AuditEvent
+			detail sql.NullString
+		)
+
+		if err := rows.Scan(&event.ThingID, &event.Time, &event.Actor, &event.Action, &detail); err != nil {
+			return nil, err
+		}
+
+		event.Detail = detail.String
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+const updateDescription = `
+UPDATE things
+SET description = ?
 WHERE id = ?
 `
 
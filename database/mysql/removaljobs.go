@@ -0,0 +1,245 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+package mysql
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/wtsi-hgi/tt/database"
+)
+
+const enqueueJob = `
+INSERT INTO removal_jobs (
+  thing_id, action, state, attempts, next_attempt, created
+) VALUES (
+  ?, ?, ?, 0, ?, ?
+)
+ON DUPLICATE KEY UPDATE thing_id = thing_id
+`
+
+// EnqueueJob queues a RemovalJob to carry out the given action on the thing
+// with the given ID, to be run now. Does nothing if the thing already has a
+// job.
+func (m *MySQLDB) EnqueueJob(thingID uint32, action database.JobAction) error {
+	now := time.Now()
+
+	return m.changeThing(thingID, execute(enqueueJob, thingID, action, database.JobQueued, now, now))
+}
+
+const getRemovalJobs = `
+SELECT removal_jobs.thing_id, things.type, action, removal_jobs.state, attempts, runner, next_attempt, last_error,
+  removal_jobs.created, files_removed, bytes_removed, removal_errors
+FROM removal_jobs
+JOIN things ON things.id = removal_jobs.thing_id
+`
+
+// GetRemovalJobs returns the RemovalJobs in the given state, or all of them if
+// state is blank, in order of their NextAttempt.
+func (m *MySQLDB) GetRemovalJobs(state database.JobState) ([]database.RemovalJob, error) {
+	var (
+		query strings.Builder
+		args  []any
+	)
+
+	query.WriteString(getRemovalJobs)
+
+	if state != "" {
+		query.WriteString("WHERE removal_jobs.state = ?\n")
+
+		args = append(args, state)
+	}
+
+	query.WriteString("ORDER BY next_attempt, removal_jobs.thing_id")
+
+	rows, err := m.pool.Query(query.String(), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var jobs []database.RemovalJob
+
+	for rows.Next() {
+		var job database.RemovalJob
+
+		if err := rows.Scan(&job.ThingID, &job.Type, &job.Action, &job.State, &job.Attempts, &job.Runner,
+			&job.NextAttempt, &job.LastError, &job.Created,
+			&job.Progress.Files, &job.Progress.Bytes, &job.Progress.Errors); err != nil {
+			return nil, err
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	return jobs, rows.Err()
+}
+
+const startJob = `
+UPDATE removal_jobs
+SET state = ?, runner = ?, attempts = attempts + 1, files_removed = 0, bytes_removed = 0, removal_errors = 0
+WHERE thing_id = ?
+`
+
+// StartJob marks the job of the thing with the given ID as being run by runner
+// (the holder of a Lease), increments its number of attempts, and resets its
+// progress.
+func (m *MySQLDB) StartJob(thingID uint32, runner string) error {
+	return m.changeThing(thingID, execute(startJob, database.JobRunning, runner, thingID))
+}
+
+const updateJobProgress = `
+UPDATE removal_jobs
+SET files_removed = ?, bytes_removed = ?, removal_errors = ?
+WHERE thing_id = ?
+`
+
+// UpdateJobProgress records how far the running job of the thing with the
+// given ID has got.
+func (m *MySQLDB) UpdateJobProgress(thingID uint32, progress database.RemovalProgress) error {
+	_, err := m.pool.Exec(updateJobProgress, progress.Files, progress.Bytes, progress.Errors, thingID)
+
+	return err
+}
+
+const finishJob = `DELETE FROM removal_jobs WHERE thing_id = ?`
+
+// FinishJob deletes the job of the thing with the given ID, since it succeeded.
+func (m *MySQLDB) FinishJob(thingID uint32) error {
+	return m.changeThing(thingID, execute(finishJob, thingID))
+}
+
+const failJob = `
+UPDATE removal_jobs
+SET state = ?, next_attempt = ?, last_error = ?
+WHERE thing_id = ?
+`
+
+// FailJob records that the job of the thing with the given ID failed with the
+// given error, and puts it in the given state (JobQueued to try again at the
+// given time, or JobFailed to give up).
+func (m *MySQLDB) FailJob(thingID uint32, lastError string, state database.JobState, nextAttempt time.Time) error {
+	return m.changeThing(thingID, execute(failJob, state, nextAttempt, lastError, thingID))
+}
+
+const retryJob = `
+UPDATE removal_jobs
+SET state = ?, attempts = 0, next_attempt = ?
+WHERE thing_id = ? AND state IN (?, ?)
+`
+
+// RetryJob queues the failed or cancelled job of the thing with the given ID to
+// be tried again now, with its attempts reset. Returns ErrNoJob if there's no
+// job, or ErrJobNotFailed if it isn't failed or cancelled.
+func (m *MySQLDB) RetryJob(thingID uint32) error {
+	return m.changeThing(thingID, func(tx *sql.Tx) error {
+		result, err := tx.Exec(retryJob, database.JobQueued, time.Now(), thingID,
+			database.JobFailed, database.JobCancelled)
+		if err != nil {
+			return err
+		}
+
+		if changed, err := result.RowsAffected(); err != nil || changed > 0 {
+			return err
+		}
+
+		if _, err = jobState(tx, thingID); err != nil {
+			return err
+		}
+
+		return database.ErrJobNotFailed
+	})
+}
+
+const getJobState = `SELECT state FROM removal_jobs WHERE thing_id = ?`
+
+// jobState returns the state of the job of the thing with the given ID, or
+// ErrNoJob if it has none.
+func jobState(tx *sql.Tx, thingID uint32) (database.JobState, error) {
+	var state database.JobState
+
+	err := tx.QueryRow(getJobState, thingID).Scan(&state)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", database.ErrNoJob
+	}
+
+	return state, err
+}
+
+const cancelJob = `
+UPDATE removal_jobs
+SET state = ?
+WHERE thing_id = ? AND state != ?
+`
+
+// CancelJob cancels the job of the thing with the given ID. Returns ErrNoJob if
+// there's no job, or ErrJobRunning if it's running.
+func (m *MySQLDB) CancelJob(thingID uint32) error {
+	return m.changeThing(thingID, func(tx *sql.Tx) error {
+		result, err := tx.Exec(cancelJob, database.JobCancelled, thingID, database.JobRunning)
+		if err != nil {
+			return err
+		}
+
+		if changed, err := result.RowsAffected(); err != nil || changed > 0 {
+			return err
+		}
+
+		state, err := jobState(tx, thingID)
+		if err != nil {
+			return err
+		}
+
+		if state == database.JobRunning {
+			return database.ErrJobRunning
+		}
+
+		return nil
+	})
+}
+
+const resetRunningJobs = `
+UPDATE removal_jobs
+LEFT JOIN leases ON leases.holder = removal_jobs.runner AND leases.expires > NOW(6)
+SET removal_jobs.state = ?
+WHERE removal_jobs.state = ? AND leases.holder IS NULL
+`
+
+// ResetRunningJobs queues any jobs that were left marked as running by a
+// runner that no longer holds an unexpired lease, eg. because the process
+// running them was killed.
+func (m *MySQLDB) ResetRunningJobs() error {
+	_, err := m.pool.Exec(resetRunningJobs, database.JobQueued, database.JobRunning)
+
+	return err
+}
+
+const deleteIdleJob = `
+DELETE FROM removal_jobs
+WHERE thing_id = ? AND state != 'running'
+`
@@ -1,4 +1,4 @@
-DROP TABLE IF EXISTS subscribers, things, users;
+DROP TABLE IF EXISTS leases, used_links, confirmations, snoozes, removal_jobs, audit, subscribers, things, thing_types, users;
 
 CREATE TABLE users (
     id int unsigned NOT NULL AUTO_INCREMENT PRIMARY KEY,
@@ -8,10 +8,16 @@ CREATE TABLE users (
     UNIQUE(email)
 ) ENGINE=INNODB;
 
+CREATE TABLE thing_types (
+    name varchar(64) NOT NULL PRIMARY KEY,
+    label varchar(256) NOT NULL
+) ENGINE=INNODB;
+
 CREATE TABLE things (
     id int unsigned NOT NULL AUTO_INCREMENT PRIMARY KEY,
     address varchar(4096) NOT NULL,
-    type enum('dir', 'file', 'irods', 'openstack', 's3') NOT NULL,
+    address_hash binary(32) AS (UNHEX(SHA2(address, 256))) STORED NOT NULL,
+    type varchar(64) NOT NULL,
     created date NOT NULL,
     description text(4096),
     reason tinytext NOT NULL,
@@ -19,7 +25,34 @@ CREATE TABLE things (
     warned1 date,
     warned2 date,
     removed bool NOT NULL default 0,
-    UNIQUE(address(170), type)
+    address_exists bool,
+    size bigint,
+    files bigint,
+    modified datetime,
+    accessed datetime,
+    probed datetime,
+    quarantine varchar(4096),
+    quarantine_until datetime,
+    on_expiry varchar(16) NOT NULL default 'delete',
+    archive varchar(4096),
+    expiry_mode varchar(16) NOT NULL default 'fixed',
+    expire_after int unsigned NOT NULL default 0,
+    expired datetime,
+    fingerprint_size bigint,
+    fingerprint_files bigint,
+    fingerprint_modified datetime(6),
+    fingerprint_checksum char(64),
+    changed datetime,
+    approval varchar(16) NOT NULL default '',
+    approval_reason varchar(1024) NOT NULL default '',
+    hold_reason varchar(1024),
+    hold_until datetime,
+    state varchar(16) NOT NULL default 'active',
+    live_address_hash binary(32) AS (IF(removed, NULL, address_hash)) STORED,
+    KEY (address_hash, type),
+    KEY (state),
+    UNIQUE(live_address_hash, type),
+    FOREIGN KEY (type) REFERENCES thing_types(name)
 ) ENGINE=INNODB;
 
 CREATE TABLE subscribers (
@@ -34,4 +67,68 @@ CREATE TABLE subscribers (
         ON DELETE CASCADE,
     FOREIGN KEY (thing_id) REFERENCES things(id)
         ON DELETE CASCADE
-) ENGINE=INNODB;
\ No newline at end of file
+) ENGINE=INNODB;
+
+CREATE TABLE audit (
+    id int unsigned NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    thing_id int unsigned NOT NULL,
+    time datetime NOT NULL,
+    actor varchar(256) NOT NULL,
+    action varchar(64) NOT NULL,
+    detail text,
+    KEY (thing_id)
+) ENGINE=INNODB;
+
+CREATE TABLE removal_jobs (
+    thing_id int unsigned NOT NULL PRIMARY KEY,
+    action varchar(16) NOT NULL,
+    state varchar(16) NOT NULL,
+    attempts int NOT NULL default 0,
+    runner varchar(255) NOT NULL default '',
+    next_attempt datetime NOT NULL,
+    last_error text,
+    files_removed bigint NOT NULL default 0,
+    bytes_removed bigint NOT NULL default 0,
+    removal_errors bigint NOT NULL default 0,
+    created datetime NOT NULL,
+    KEY (state, next_attempt),
+    FOREIGN KEY (thing_id) REFERENCES things(id)
+        ON DELETE CASCADE
+) ENGINE=INNODB;
+
+CREATE TABLE snoozes (
+    thing_id int unsigned NOT NULL,
+    user_id int unsigned NOT NULL,
+    until datetime NOT NULL,
+    missed bool NOT NULL default 0,
+    PRIMARY KEY (thing_id, user_id),
+    KEY (until),
+    FOREIGN KEY (thing_id) REFERENCES things(id)
+        ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id)
+        ON DELETE CASCADE
+) ENGINE=INNODB;
+
+CREATE TABLE confirmations (
+    thing_id int unsigned NOT NULL,
+    user_id int unsigned NOT NULL,
+    remove datetime NOT NULL,
+    PRIMARY KEY (thing_id, user_id),
+    FOREIGN KEY (thing_id) REFERENCES things(id)
+        ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id)
+        ON DELETE CASCADE
+) ENGINE=INNODB;
+
+CREATE TABLE used_links (
+    id varchar(32) NOT NULL PRIMARY KEY,
+    expires datetime NOT NULL,
+    KEY (expires)
+) ENGINE=INNODB;
+
+CREATE TABLE leases (
+    name varchar(64) NOT NULL PRIMARY KEY,
+    holder varchar(255) NOT NULL,
+    acquired datetime(6) NOT NULL,
+    expires datetime(6) NOT NULL
+) ENGINE=INNODB;
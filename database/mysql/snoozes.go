@@ -0,0 +1,110 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package mysql
+
+import (
+	"time"
+
+	"github.com/wtsi-hgi/tt/database"
+)
+
+const setSnooze = `
+INSERT INTO snoozes (
+  thing_id, user_id, until
+) VALUES (
+  ?, ?, ?
+)
+ON DUPLICATE KEY UPDATE until = VALUES(until)
+`
+
+// SetSnooze stops the user with the given ID being warned about the thing with
+// the given ID until the given time, replacing any existing snooze.
+func (m *MySQLDB) SetSnooze(thingID, userID uint32, until time.Time) error {
+	_, err := m.pool.Exec(setSnooze, thingID, userID, until)
+
+	return err
+}
+
+const getSnoozes = `
+SELECT snoozes.thing_id, users.id, users.name, users.email, snoozes.until, snoozes.missed
+FROM snoozes
+JOIN users ON users.id = snoozes.user_id
+`
+
+// GetSnoozes returns the snoozes of the thing with the given ID, including any
+// that have ended.
+func (m *MySQLDB) GetSnoozes(thingID uint32) ([]database.Snooze, error) {
+	return m.getSnoozes(getSnoozes+"WHERE snoozes.thing_id = ? ORDER BY users.id", thingID)
+}
+
+// GetEndedSnoozes returns all the snoozes that ended before the given time.
+func (m *MySQLDB) GetEndedSnoozes(before time.Time) ([]database.Snooze, error) {
+	return m.getSnoozes(getSnoozes+"WHERE snoozes.until < ? ORDER BY snoozes.thing_id, users.id", before)
+}
+
+func (m *MySQLDB) getSnoozes(query string, args ...any) ([]database.Snooze, error) {
+	rows, err := m.pool.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var snoozes []database.Snooze
+
+	for rows.Next() {
+		var snooze database.Snooze
+
+		if err := rows.Scan(&snooze.ThingID, &snooze.User.ID, &snooze.User.Name, &snooze.User.Email,
+			&snooze.Until, &snooze.Missed); err != nil {
+			return nil, err
+		}
+
+		snoozes = append(snoozes, snooze)
+	}
+
+	return snoozes, rows.Err()
+}
+
+const markSnoozeMissed = `UPDATE snoozes SET missed = 1 WHERE thing_id = ? AND user_id = ?`
+
+// MarkSnoozeMissed records that a warning about the thing with the given ID
+// wasn't sent to the user with the given ID because of their snooze.
+func (m *MySQLDB) MarkSnoozeMissed(thingID, userID uint32) error {
+	_, err := m.pool.Exec(markSnoozeMissed, thingID, userID)
+
+	return err
+}
+
+const deleteSnooze = `DELETE FROM snoozes WHERE thing_id = ? AND user_id = ?`
+
+// DeleteSnooze deletes any snooze the user with the given ID has of the thing
+// with the given ID.
+func (m *MySQLDB) DeleteSnooze(thingID, userID uint32) error {
+	_, err := m.pool.Exec(deleteSnooze, thingID, userID)
+
+	return err
+}
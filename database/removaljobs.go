@@ -0,0 +1,101 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package database
+
+import (
+	"time"
+
+	null "github.com/guregu/null/v5"
+)
+
+const (
+	ErrNoJob        = Error("That thing has no removal job")
+	ErrJobRunning   = Error("That removal job is already running")
+	ErrJobNotFailed = Error("Only failed or cancelled removal jobs can be retried")
+	ErrBadJobState  = Error("Invalid removal job state")
+)
+
+// JobAction is what a RemovalJob does to its Thing.
+type JobAction string
+
+const (
+	JobRemove     JobAction = "remove"
+	JobQuarantine JobAction = "quarantine"
+	JobPurge      JobAction = "purge"
+	JobArchive    JobAction = "archive"
+)
+
+// JobState is where a RemovalJob is in its lifecycle. Jobs that succeed are
+// deleted, so there is no state for that.
+type JobState string
+
+const (
+	// JobQueued jobs are waiting to be run at or after their NextAttempt.
+	JobQueued JobState = "queued"
+
+	// JobRunning jobs are currently being run by a worker.
+	JobRunning JobState = "running"
+
+	// JobFailed jobs failed too many times, and will only be run again if
+	// retried.
+	JobFailed JobState = "failed"
+
+	// JobCancelled jobs will not be run unless retried.
+	JobCancelled JobState = "cancelled"
+)
+
+// NewJobState converts a string to a JobState, returning an error if it's not
+// valid. Blank strings are returned as is, meaning any state.
+func NewJobState(str string) (JobState, error) {
+	switch state := JobState(str); state {
+	case "", JobQueued, JobRunning, JobFailed, JobCancelled:
+		return state, nil
+	}
+
+	return "", ErrBadJobState
+}
+
+// RemovalJob is a persisted request to carry out a JobAction on a Thing. Each
+// Thing has at most one RemovalJob.
+type RemovalJob struct {
+	ThingID     uint32
+	Type        ThingsType // of the Thing
+	Action      JobAction
+	State       JobState
+	Attempts    int
+	Runner      string // Identity of the process that last started running it
+	NextAttempt time.Time
+	LastError   null.String
+	Created     time.Time
+	Progress    RemovalProgress // of the current or last attempt
+}
+
+// RemovalProgress is how far a running RemovalJob has got.
+type RemovalProgress struct {
+	Files  int64 // number of files removed
+	Bytes  int64 // number of bytes removed
+	Errors int64 // number of files that couldn't be removed
+}
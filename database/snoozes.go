@@ -0,0 +1,53 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package database
+
+import "time"
+
+// DefaultSnoozeDays is how many days warnings are snoozed for by default.
+const DefaultSnoozeDays = 7
+
+// Snooze records that a User doesn't want to be warned about the upcoming
+// removal of a Thing until a later time, without extending it. Missed is true
+// if a warning they would have been sent was held back, in which case they are
+// reminded when the Snooze ends.
+type Snooze struct {
+	ThingID uint32
+	User    User
+	Until   time.Time
+	Missed  bool
+}
+
+// Active returns true if the Snooze hasn't ended as of the given time.
+func (s *Snooze) Active(now time.Time) bool {
+	return now.Before(s.Until)
+}
+
+// SnoozeParams holds the number of days a User wants to stop being warned
+// about a Thing for.
+type SnoozeParams struct {
+	Days int `binding:"gte=0"`
+}
@@ -0,0 +1,120 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package database
+
+import (
+	"fmt"
+	"slices"
+)
+
+const (
+	ErrBadState      = Error("Invalid thing state")
+	ErrBadTransition = Error("Invalid thing state transition")
+)
+
+// State is where a Thing is in its lifecycle.
+type State string
+
+const (
+	// StatePending Things are awaiting approval before they count as
+	// registered.
+	StatePending State = "pending"
+
+	// StateActive Things are registered, and not yet due for removal.
+	StateActive State = "active"
+
+	// StateWarned Things have had their subscribers warned of their upcoming
+	// removal.
+	StateWarned State = "warned"
+
+	// StateExpired Things are only notified about on expiry, and their
+	// subscribers have been told that their removal date has passed.
+	StateExpired State = "expired"
+
+	// StateQuarantined Things have had their data moved to quarantine, and are
+	// awaiting permanent removal.
+	StateQuarantined State = "quarantined"
+
+	// StateRemoving Things have a removal job that is queued or running.
+	StateRemoving State = "removing"
+
+	// StateFailed Things have a removal job that failed too many times.
+	StateFailed State = "failed"
+
+	// StateRemoved Things have been removed (or were rejected, so never
+	// registered). This is the final state.
+	StateRemoved State = "removed"
+)
+
+// transitions are the States each State can change to, other than itself.
+var transitions = map[State][]State{
+	StatePending:     {StateActive, StateRemoved},
+	StateActive:      {StateWarned, StateExpired, StateRemoving, StateQuarantined, StateRemoved},
+	StateWarned:      {StateActive, StateExpired, StateRemoving, StateQuarantined, StateRemoved},
+	StateExpired:     {StateActive, StateRemoving, StateQuarantined, StateRemoved},
+	StateRemoving:    {StateActive, StateWarned, StateExpired, StateQuarantined, StateFailed, StateRemoved},
+	StateFailed:      {StateRemoving, StateActive, StateWarned, StateExpired, StateQuarantined, StateRemoved},
+	StateQuarantined: {StateActive, StateRemoving, StateFailed, StateRemoved},
+	StateRemoved:     {},
+}
+
+// States returns all the States, in lifecycle order.
+func States() []State {
+	return []State{StatePending, StateActive, StateWarned, StateExpired, StateRemoving, StateFailed,
+		StateQuarantined, StateRemoved}
+}
+
+// NewState converts a string to a State, returning an error if it's not valid.
+// Blank strings are returned as is, meaning any state.
+func NewState(str string) (State, error) {
+	state := State(str)
+	if _, ok := transitions[state]; ok || state == "" {
+		return state, nil
+	}
+
+	return "", ErrBadState
+}
+
+// CanBecome returns true if a Thing in this State can change to the given
+// State. Any State can "change" to itself, except that nothing can become
+// blank.
+func (s State) CanBecome(to State) bool {
+	if s == to {
+		return to != ""
+	}
+
+	return slices.Contains(transitions[s], to)
+}
+
+// To returns an error that Is() ErrBadTransition if this State can't become the
+// given State.
+func (s State) To(to State) error {
+	if s.CanBecome(to) {
+		return nil
+	}
+
+	return fmt.Errorf("%w from %s to %s", ErrBadTransition, s, to)
+}
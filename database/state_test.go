@@ -0,0 +1,95 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package database
+
+import (
+	"testing"
+
+	null "github.com/guregu/null/v5"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestState(t *testing.T) {
+	Convey("You can convert strings to States", t, func() {
+		for _, state := range States() {
+			got, err := NewState(string(state))
+			So(err, ShouldBeNil)
+			So(got, ShouldEqual, state)
+		}
+
+		got, err := NewState("")
+		So(err, ShouldBeNil)
+		So(got, ShouldBeEmpty)
+
+		_, err = NewState("foo")
+		So(err, ShouldEqual, ErrBadState)
+	})
+
+	Convey("States can only make valid transitions", t, func() {
+		So(StateActive.To(StateActive), ShouldBeNil)
+		So(StateActive.To(StateWarned), ShouldBeNil)
+		So(StateWarned.To(StateRemoving), ShouldBeNil)
+		So(StateRemoving.To(StateFailed), ShouldBeNil)
+		So(StateFailed.To(StateRemoving), ShouldBeNil)
+		So(StateRemoving.To(StateRemoved), ShouldBeNil)
+		So(StatePending.To(StateActive), ShouldBeNil)
+
+		err := StatePending.To(StateRemoving)
+		So(err, ShouldWrap, ErrBadTransition)
+		So(err.Error(), ShouldEqual, "Invalid thing state transition from pending to removing")
+
+		So(StateRemoved.To(StateActive), ShouldWrap, ErrBadTransition)
+		So(StateActive.To(StateFailed), ShouldWrap, ErrBadTransition)
+		So(StateActive.To(""), ShouldWrap, ErrBadTransition)
+		So(State("").CanBecome(""), ShouldBeFalse)
+	})
+
+	Convey("Things compute their State from their other fields", t, func() {
+		thing := &Thing{}
+		So(thing.ComputeState(), ShouldEqual, StateActive)
+
+		thing.Warned1 = null.TimeFrom(thing.Remove)
+		So(thing.ComputeState(), ShouldEqual, StateWarned)
+
+		thing.Expired = null.TimeFrom(thing.Remove)
+		So(thing.ComputeState(), ShouldEqual, StateExpired)
+
+		thing.QuarantineUntil = null.TimeFrom(thing.Remove)
+		So(thing.ComputeState(), ShouldEqual, StateQuarantined)
+
+		thing.Approval = ApprovalPending
+		So(thing.ComputeState(), ShouldEqual, StatePending)
+
+		thing.Job = JobFailed
+		So(thing.ComputeState(), ShouldEqual, StateFailed)
+
+		thing.Job = JobRunning
+		So(thing.ComputeState(), ShouldEqual, StateRemoving)
+
+		thing.Removed = true
+		So(thing.ComputeState(), ShouldEqual, StateRemoved)
+	})
+}
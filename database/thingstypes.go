@@ -0,0 +1,262 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package database
+
+import (
+	"context"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// ThingsType is the name of a registered type of Thing.
+type ThingsType string
+
+const (
+	ThingsTypeNil       ThingsType = ""
+	ThingsTypeDir       ThingsType = "dir"
+	ThingsTypeFile      ThingsType = "file"
+	ThingsTypeIrods     ThingsType = "irods"
+	ThingsTypeOpenstack ThingsType = "openstack"
+	ThingsTypeS3        ThingsType = "s3"
+)
+
+// Backend knows how to deal with Things of a particular ThingsType. Optional
+// capabilities, such as removing things, are provided by also implementing the
+// interfaces in the backend package.
+type Backend interface {
+	// Exists returns true if something currently exists at the thing's
+	// address.
+	Exists(ctx context.Context, thing *Thing) (bool, error)
+}
+
+// ThingsTypeInfo describes a ThingsType being registered with
+// RegisterThingsType().
+type ThingsTypeInfo struct {
+	Name ThingsType
+
+	// Label is how the type is shown to users. Defaults to Name.
+	Label string
+
+	// Canonicalise, if set, returns the canonical form of an address that has
+	// already had leading and trailing whitespace removed.
+	Canonicalise func(address string) string
+
+	// Validate, if set, returns an error if the given canonical address is not
+	// valid for this type.
+	Validate func(address string) error
+
+	// Backend, if set, is used to inspect and act on things of this type. It
+	// can also be set later with SetBackend(), eg. once it has been configured.
+	Backend Backend
+}
+
+var (
+	thingsTypesMu  sync.RWMutex
+	thingsTypes    []ThingsTypeInfo
+	thingsTypeName = regexp.MustCompile(`^[a-z0-9_-]{1,64}$`)
+)
+
+// init registers the ThingsTypes whose addresses are paths. The openstack and
+// s3 types are registered by their backend packages, which know what their
+// addresses look like.
+func init() {
+	for _, info := range []ThingsTypeInfo{
+		{Name: ThingsTypeDir, Label: "Directory", Canonicalise: filepath.Clean, Validate: validateAbsolute},
+		{Name: ThingsTypeFile, Label: "File", Canonicalise: filepath.Clean, Validate: validateAbsolute},
+		{Name: ThingsTypeIrods, Label: "iRODS", Canonicalise: path.Clean, Validate: validateAbsolute},
+	} {
+		if err := RegisterThingsType(info); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// RegisterThingsType makes a new ThingsType available, so that Things of that
+// type can be created. The dir, file and irods types are registered
+// automatically, and the openstack and s3 types when their backend packages are
+// imported. Others should be registered before opening a database, so that it
+// can record them.
+//
+// Returns ErrBadTypeName if the name is invalid, or ErrTypeRegistered if a
+// type with that name has already been registered.
+func RegisterThingsType(info ThingsTypeInfo) error {
+	if !thingsTypeName.MatchString(string(info.Name)) {
+		return ErrBadTypeName
+	}
+
+	if info.Label == "" {
+		info.Label = string(info.Name)
+	}
+
+	thingsTypesMu.Lock()
+	defer thingsTypesMu.Unlock()
+
+	if slices.ContainsFunc(thingsTypes, func(i ThingsTypeInfo) bool { return i.Name == info.Name }) {
+		return ErrTypeRegistered
+	}
+
+	thingsTypes = append(thingsTypes, info)
+
+	return nil
+}
+
+// SetBackend sets the Backend of the registered ThingsType with the given
+// name, returning the Backend it had before. Returns ErrBadType if there is no
+// such registered type.
+func SetBackend(name ThingsType, backend Backend) (Backend, error) {
+	thingsTypesMu.Lock()
+	defer thingsTypesMu.Unlock()
+
+	for i, info := range thingsTypes {
+		if info.Name == name {
+			thingsTypes[i].Backend = backend
+
+			return info.Backend, nil
+		}
+	}
+
+	return nil, ErrBadType
+}
+
+// ThingsTypeInfos returns the details of all registered ThingsTypes, in the
+// order they were registered.
+func ThingsTypeInfos() []ThingsTypeInfo {
+	thingsTypesMu.RLock()
+	defer thingsTypesMu.RUnlock()
+
+	return slices.Clone(thingsTypes)
+}
+
+// ThingsTypes returns all registered ThingsTypes, in the order they were
+// registered.
+func ThingsTypes() []ThingsType {
+	infos := ThingsTypeInfos()
+	types := make([]ThingsType, len(infos))
+
+	for i, info := range infos {
+		types[i] = info.Name
+	}
+
+	return types
+}
+
+// NewThingsType converts the given str to a ThingsType, but only if it is
+// blank (ThingsTypeNil) or the name of a registered ThingsType. Returns
+// ErrBadType if not.
+func NewThingsType(str string) (ThingsType, error) {
+	thingsType := ThingsType(str)
+	if thingsType == ThingsTypeNil {
+		return thingsType, nil
+	}
+
+	if _, ok := thingsType.info(); !ok {
+		return "", ErrBadType
+	}
+
+	return thingsType, nil
+}
+
+func (t ThingsType) info() (ThingsTypeInfo, bool) {
+	thingsTypesMu.RLock()
+	defer thingsTypesMu.RUnlock()
+
+	for _, info := range thingsTypes {
+		if info.Name == t {
+			return info, true
+		}
+	}
+
+	return ThingsTypeInfo{}, false
+}
+
+// Label returns the registered label of this ThingsType, or its name if it
+// isn't registered.
+func (t ThingsType) Label() string {
+	info, ok := t.info()
+	if !ok {
+		return string(t)
+	}
+
+	return info.Label
+}
+
+// Backend returns the registered Backend of this ThingsType, or nil if it
+// doesn't have one.
+func (t ThingsType) Backend() Backend {
+	info, _ := t.info()
+
+	return info.Backend
+}
+
+// CanonicalAddress returns the given address in the canonical form for this
+// ThingsType, so that different ways of writing the same address are treated as
+// the same. Leading and trailing whitespace is always removed, and then the
+// type's Canonicalise function is applied, eg. to clean dir, file and irods
+// addresses of redundant separators and dot elements.
+func (t ThingsType) CanonicalAddress(address string) string {
+	address = strings.TrimSpace(address)
+	if address == "" {
+		return address
+	}
+
+	if info, ok := t.info(); ok && info.Canonicalise != nil {
+		address = info.Canonicalise(address)
+	}
+
+	return address
+}
+
+// ValidateAddress returns an error if the CanonicalAddress() of the given
+// address is blank or not valid for this ThingsType.
+func (t ThingsType) ValidateAddress(address string) error {
+	info, ok := t.info()
+	if !ok {
+		return ErrBadType
+	}
+
+	address = t.CanonicalAddress(address)
+	if address == "" {
+		return ErrEmptyAddress
+	}
+
+	if info.Validate == nil {
+		return nil
+	}
+
+	return info.Validate(address)
+}
+
+func validateAbsolute(address string) error {
+	if !path.IsAbs(address) {
+		return ErrNotAbsolute
+	}
+
+	return nil
+}
@@ -0,0 +1,154 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestNewThingsTypes(t *testing.T) {
+	Convey("You can convert strings to ThingsType*, unless it's invalid", t, func() {
+		tt, err := NewThingsType("")
+		So(err, ShouldBeNil)
+		So(tt, ShouldEqual, ThingsTypeNil)
+
+		tt, err = NewThingsType("dir")
+		So(err, ShouldBeNil)
+		So(tt, ShouldEqual, ThingsTypeDir)
+
+		tt, err = NewThingsType("file")
+		So(err, ShouldBeNil)
+		So(tt, ShouldEqual, ThingsTypeFile)
+
+		tt, err = NewThingsType("irods")
+		So(err, ShouldBeNil)
+		So(tt, ShouldEqual, ThingsTypeIrods)
+
+		_, err = NewThingsType("invalid")
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestCanonicalAddress(t *testing.T) {
+	Convey("Addresses are canonicalised according to their ThingsType", t, func() {
+		So(ThingsTypeDir.CanonicalAddress(" /a//b/./c/ "), ShouldEqual, "/a/b/c")
+		So(ThingsTypeFile.CanonicalAddress("/a/b/../c.txt"), ShouldEqual, "/a/c.txt")
+		So(ThingsTypeDir.CanonicalAddress(""), ShouldEqual, "")
+		So(ThingsType("unregistered").CanonicalAddress(" bucket/a//b/ "), ShouldEqual, "bucket/a//b/")
+		So(ThingsTypeIrods.CanonicalAddress("/zone/a"), ShouldEqual, "/zone/a")
+		So(ThingsTypeIrods.CanonicalAddress("/zone//a/./b/"), ShouldEqual, "/zone/a/b")
+	})
+}
+
+func TestValidateAddress(t *testing.T) {
+	Convey("Addresses are validated according to their ThingsType", t, func() {
+		So(ThingsTypeDir.ValidateAddress(" /a/b/ "), ShouldBeNil)
+		So(ThingsTypeFile.ValidateAddress("a/b"), ShouldEqual, ErrNotAbsolute)
+		So(ThingsTypeIrods.ValidateAddress("/zone/a"), ShouldBeNil)
+		So(ThingsTypeIrods.ValidateAddress("zone/a"), ShouldEqual, ErrNotAbsolute)
+		So(ThingsTypeDir.ValidateAddress(" "), ShouldEqual, ErrEmptyAddress)
+
+		So(ThingsType("invalid").ValidateAddress("/a"), ShouldEqual, ErrBadType)
+	})
+}
+
+func TestRegisterThingsType(t *testing.T) {
+	Convey("The path-based ThingsTypes are registered, with labels", t, func() {
+		So(ThingsTypes(), ShouldResemble, []ThingsType{ThingsTypeDir, ThingsTypeFile, ThingsTypeIrods})
+		So(ThingsTypeIrods.Label(), ShouldEqual, "iRODS")
+		So(ThingsType("invalid").Label(), ShouldEqual, "invalid")
+	})
+
+	Convey("You can register new ThingsTypes", t, func() {
+		errBad := errors.New("bad")
+		name := ThingsType("lustre-quota")
+
+		err := RegisterThingsType(ThingsTypeInfo{
+			Name:         name,
+			Canonicalise: strings.ToLower,
+			Validate: func(address string) error {
+				if !strings.HasPrefix(address, "lustre:") {
+					return errBad
+				}
+
+				return nil
+			},
+		})
+		So(err, ShouldBeNil)
+
+		defer unregisterThingsType(name)
+
+		tt, err := NewThingsType("lustre-quota")
+		So(err, ShouldBeNil)
+		So(tt, ShouldEqual, name)
+		So(tt.Label(), ShouldEqual, "lustre-quota")
+		So(ThingsTypes(), ShouldContain, name)
+		So(tt.CanonicalAddress(" Lustre:/A "), ShouldEqual, "lustre:/a")
+		So(tt.ValidateAddress("Lustre:/A"), ShouldBeNil)
+		So(tt.ValidateAddress("/a"), ShouldEqual, errBad)
+		So(tt.Backend(), ShouldBeNil)
+
+		Convey("and set their Backend", func() {
+			var b Backend = existsBackend{}
+
+			previous, err := SetBackend(name, b)
+			So(err, ShouldBeNil)
+			So(previous, ShouldBeNil)
+			So(tt.Backend(), ShouldEqual, b)
+
+			_, err = SetBackend("unregistered", b)
+			So(err, ShouldEqual, ErrBadType)
+		})
+
+		Convey("But not twice, or with bad names", func() {
+			So(RegisterThingsType(ThingsTypeInfo{Name: name}), ShouldEqual, ErrTypeRegistered)
+			So(RegisterThingsType(ThingsTypeInfo{Name: "Bad Name"}), ShouldEqual, ErrBadTypeName)
+			So(RegisterThingsType(ThingsTypeInfo{Name: ThingsTypeNil}), ShouldEqual, ErrBadTypeName)
+		})
+	})
+}
+
+type existsBackend struct{}
+
+func (existsBackend) Exists(context.Context, *Thing) (bool, error) { return true, nil }
+
+func unregisterThingsType(name ThingsType) {
+	thingsTypesMu.Lock()
+	defer thingsTypesMu.Unlock()
+
+	for i, info := range thingsTypes {
+		if info.Name == name {
+			thingsTypes = append(thingsTypes[:i], thingsTypes[i+1:]...)
+
+			return
+		}
+	}
+}
@@ -26,6 +26,7 @@
 package database
 
 import (
+	"fmt"
 	"time"
 
 	null "github.com/guregu/null/v5"
@@ -37,56 +38,40 @@ func (e Error) Error() string { return string(e) }
 
 const (
 	ErrBadType           = Error("Invalid things type")
+	ErrBadTypeName       = Error("Things type names must be 1-64 lowercase letters, digits, - or _")
+	ErrTypeRegistered    = Error("A things type with that name has already been registered")
+	ErrEmptyAddress      = Error("An address is required")
+	ErrNotAbsolute       = Error("Address must be an absolute path")
 	ErrBadOrderBy        = Error("Invalid order")
 	ErrBadOrderDirection = Error("Invalid direction")
+	ErrDuplicate         = Error("A thing with that address and type already exists")
+	ErrNoThing           = Error("No Thing found with that ID")
+	ErrThingRemoved      = Error("That thing has already been removed")
+	ErrNotQuarantined    = Error("That thing is not in quarantine")
+	ErrBadExpiryAction   = Error("Invalid on expiry action")
+	ErrNotChanged        = Error("That thing has not changed since it was registered")
+	ErrBadExpiryMode     = Error("Invalid expiry mode")
+	ErrBadExpireAfter    = Error("Things that expire after they were last used need a number of days to expire after")
+	ErrNotPending        = Error("That thing is not awaiting approval")
+	ErrNotHeld           = Error("That thing is not on hold")
+	ErrHoldEnded         = Error("A hold must end in the future")
+	ErrThingHeld         = Error("That thing is on hold")
+	ErrLinkUsed          = Error("That link has already been used")
 )
 
-type ThingsType string
-
-const (
-	ThingsTypeNil       ThingsType = ""
-	ThingsTypeDir       ThingsType = "dir"
-	ThingsTypeFile      ThingsType = "file"
-	ThingsTypeIrods     ThingsType = "irods"
-	ThingsTypeOpenstack ThingsType = "openstack"
-	ThingsTypeS3        ThingsType = "s3"
-)
-
-func ThingsTypes() []ThingsType {
-	return []ThingsType{
-		ThingsTypeDir,
-		ThingsTypeFile,
-		ThingsTypeIrods,
-		ThingsTypeOpenstack,
-		ThingsTypeS3,
-	}
+// DuplicateError is returned by CreateThing() when a Thing with the same
+// canonical address and type already exists. It Is() ErrDuplicate, and tells
+// you the ID of the existing Thing.
+type DuplicateError struct {
+	ExistingID uint32
 }
 
-// NewThingsType converts the given str to a ThingsType, but only if it matches
-// one of the allowed ThingsType* constants. Returns an error if not.
-func NewThingsType(str string) (ThingsType, error) {
-	var thingsType ThingsType
-
-	switch ThingsType(str) {
-	case ThingsTypeNil:
-		thingsType = ThingsTypeNil
-	case ThingsTypeDir:
-		thingsType = ThingsTypeDir
-	case ThingsTypeFile:
-		thingsType = ThingsTypeFile
-	case ThingsTypeIrods:
-		thingsType = ThingsTypeIrods
-	case ThingsTypeOpenstack:
-		thingsType = ThingsTypeOpenstack
-	case ThingsTypeS3:
-		thingsType = ThingsTypeS3
-	default:
-		return "", ErrBadType
-	}
-
-	return thingsType, nil
+func (e *DuplicateError) Error() string {
+	return fmt.Sprintf("%s (id %d)", ErrDuplicate, e.ExistingID)
 }
 
+func (e *DuplicateError) Is(target error) bool { return target == ErrDuplicate }
+
 type OrderBy string
 
 const (
@@ -94,6 +79,10 @@ const (
 	OrderByType    OrderBy = "type"
 	OrderByReason  OrderBy = "reason"
 	OrderByRemove  OrderBy = "remove"
+
+	OrderBySize     OrderBy = "size"
+	OrderByFiles    OrderBy = "files"
+	OrderByModified OrderBy = "modified"
 )
 
 // NewOrderBy converts the given str to an OrderBy, but only if it matches
@@ -109,6 +98,12 @@ func NewOrderBy(str string) (OrderBy, error) {
 		orderBy = OrderByType
 	case OrderByReason:
 		orderBy = OrderByReason
+	case OrderBySize:
+		orderBy = OrderBySize
+	case OrderByFiles:
+		orderBy = OrderByFiles
+	case OrderByModified:
+		orderBy = OrderByModified
 	case "", OrderByRemove:
 		orderBy = OrderByRemove
 	default:
@@ -143,15 +138,103 @@ func NewOrderDirection(str string) (OrderDirection, error) {
 	return orderDir, nil
 }
 
+// ExpiryAction is what should happen to a Thing when its removal date passes.
+type ExpiryAction string
+
+const (
+	// ExpiryDelete removes the Thing (possibly via quarantine).
+	ExpiryDelete ExpiryAction = "delete"
+
+	// ExpiryArchive packs the Thing in to an archive before removing it.
+	ExpiryArchive ExpiryAction = "archive"
+
+	// ExpiryNotify only tells the Thing's subscribers that its removal date
+	// has passed.
+	ExpiryNotify ExpiryAction = "notify"
+)
+
+// NewExpiryAction converts the given str to an ExpiryAction, but only if it
+// matches one of the Expiry* constants. Returns an error if not. Blank str
+// returns the default ExpiryDelete.
+func NewExpiryAction(str string) (ExpiryAction, error) {
+	var action ExpiryAction
+
+	switch ExpiryAction(str) {
+	case "", ExpiryDelete:
+		action = ExpiryDelete
+	case ExpiryArchive:
+		action = ExpiryArchive
+	case ExpiryNotify:
+		action = ExpiryNotify
+	default:
+		return "", ErrBadExpiryAction
+	}
+
+	return action, nil
+}
+
+// ExpiryMode is how a Thing's removal date is determined.
+type ExpiryMode string
+
+const (
+	// ExpiryModeFixed removes the Thing on its Remove date.
+	ExpiryModeFixed ExpiryMode = "fixed"
+
+	// ExpiryModeAccess removes the Thing ExpireAfter days after it was last
+	// accessed or modified, as found by probing it, or on its Remove date if
+	// that's later.
+	ExpiryModeAccess ExpiryMode = "atime"
+)
+
+// NewExpiryMode converts the given str to an ExpiryMode, but only if it matches
+// one of the ExpiryMode* constants. Returns an error if not. Blank str returns
+// the default ExpiryModeFixed.
+func NewExpiryMode(str string) (ExpiryMode, error) {
+	var mode ExpiryMode
+
+	switch ExpiryMode(str) {
+	case "", ExpiryModeFixed:
+		mode = ExpiryModeFixed
+	case ExpiryModeAccess:
+		mode = ExpiryModeAccess
+	default:
+		return "", ErrBadExpiryMode
+	}
+
+	return mode, nil
+}
+
+// ApprovalState is where a Thing is in the approval process that things
+// needing approval go through before they count as registered.
+type ApprovalState string
+
+const (
+	// ApprovalNotNeeded Things were registered straight away.
+	ApprovalNotNeeded ApprovalState = ""
+
+	// ApprovalPending Things are waiting for an approver to approve or reject
+	// them.
+	ApprovalPending ApprovalState = "pending"
+
+	// ApprovalApproved Things were approved, so are now registered.
+	ApprovalApproved ApprovalState = "approved"
+
+	// ApprovalRejected Things were rejected, so were never registered.
+	ApprovalRejected ApprovalState = "rejected"
+)
+
 // GetThingsParams, when default value and provided to GetThings(), will get
 // all things. Optionally set any of the values to filter, order or get a
 // certain page of results.
 type GetThingsParams struct {
-	FilterOnType   ThingsType
-	OrderBy        OrderBy        // defaults to OrderByRemove
-	OrderDirection OrderDirection // defaults to OrderAsc
-	Page           int            // treated as 0 if ThingsPerPage is < 1
-	ThingsPerPage  int            // treated as infinite if Page is < 1
+	FilterOnType    ThingsType
+	FilterOnAddress string         // matched against canonical addresses
+	FilterOnRemoved null.Bool      // unset to get both removed and live things
+	FilterOnState   State          // blank to get things in any state
+	OrderBy         OrderBy        // defaults to OrderByRemove
+	OrderDirection  OrderDirection // defaults to OrderAsc
+	Page            int            // treated as 0 if ThingsPerPage is < 1
+	ThingsPerPage   int            // treated as infinite if Page is < 1
 }
 
 // GetThingsResult is the type returned by GetThings(). The Things property will
@@ -175,8 +258,34 @@ type CreateThingParams struct {
 	Type        ThingsType
 	Description string
 	Reason      string
-	Remove      time.Time `time_format:"2006-01-02"`
-	Creator     string    // Creator must correspond to the Name of a User.
+	Remove      time.Time    `time_format:"2006-01-02"`
+	Creator     string       `form:"-" json:"-"` // the Name of a User, not bound from forms
+	OnExpiry    ExpiryAction // defaults to ExpiryDelete
+	ExpiryMode  ExpiryMode   // defaults to ExpiryModeFixed
+	ExpireAfter int          // days after last use, for ExpiryModeAccess
+
+	// Approval is ApprovalPending for things that need approval, with
+	// ApprovalReason saying why. These aren't bound from forms.
+	Approval       ApprovalState `form:"-"`
+	ApprovalReason string        `form:"-"`
+}
+
+// HoldParams holds the reason for a legal hold being placed on a Thing, and
+// the optional date it ends.
+type HoldParams struct {
+	Reason string    `binding:"required"`
+	Until  time.Time `time_format:"2006-01-02"`
+}
+
+// ExtendParams holds the new removal date of a Thing being extended.
+type ExtendParams struct {
+	Remove time.Time `time_format:"2006-01-02" binding:"required"`
+}
+
+// RestoreParams holds the optional new removal date of a quarantined Thing
+// being restored.
+type RestoreParams struct {
+	Remove time.Time `time_format:"2006-01-02"`
 }
 
 type Thing struct {
@@ -190,6 +299,158 @@ type Thing struct {
 	Warned1     null.Time
 	Warned2     null.Time
 	Removed     bool
+	Exists      null.Bool // null until the Thing has been probed
+	Size        null.Int  // total bytes, null until probed
+	Files       null.Int  // number of files, null until probed
+	Modified    null.Time // latest modification time, null until probed
+	Accessed    null.Time // latest access time of its files, null until probed
+	Probed      null.Time // when the Thing was last probed
+	Creator     string    // Name of the User that created the Thing
+
+	// Quarantine is where the Thing's data was moved to when its removal date
+	// passed, and QuarantineUntil is when it will be permanently removed from
+	// there. Both null unless the Thing has been quarantined.
+	Quarantine      null.String
+	QuarantineUntil null.Time
+
+	OnExpiry ExpiryAction
+	Archive  null.String // where the Thing was archived to, if it was
+
+	// ExpiryMode is how EffectiveRemove() is determined, with ExpireAfter
+	// being the number of days for ExpiryModeAccess.
+	ExpiryMode  ExpiryMode
+	ExpireAfter int
+
+	// Expired is when the subscribers of an ExpiryNotify Thing were told that
+	// its removal date had passed.
+	Expired null.Time
+
+	// Fingerprint is what existed at the Thing's address when it was
+	// registered (or last confirmed), nil if its type can't be fingerprinted
+	// or nothing existed there. Changed is when removal was refused because
+	// the address no longer matched the Fingerprint.
+	Fingerprint *Fingerprint
+	Changed     null.Time
+
+	// Approval is where the Thing is in the approval process, if it needed
+	// approval, with ApprovalReason saying why it did.
+	Approval       ApprovalState
+	ApprovalReason string
+
+	// HoldReason is why an admin placed a legal hold on the Thing, which stops
+	// it being warned about or removed until HoldUntil (or indefinitely, if
+	// that's null). Null if it isn't on hold.
+	HoldReason null.String
+	HoldUntil  null.Time
+
+	// State is where the Thing is in its lifecycle, kept in sync with the
+	// fields above and its RemovalJob by the database.
+	State State
+
+	Job         JobState        // state of the Thing's RemovalJob, blank if none
+	JobError    null.String     // last error of the Thing's RemovalJob
+	JobProgress RemovalProgress // of the Thing's RemovalJob
+}
+
+// Quarantined returns true if the Thing's data is currently in quarantine,
+// awaiting permanent removal.
+func (t *Thing) Quarantined() bool {
+	return t.QuarantineUntil.Valid && !t.Removed
+}
+
+// ComputeState returns the State the Thing is in according to its other fields
+// and the state of its RemovalJob. Databases use this to keep its State in sync
+// after changing it.
+func (t *Thing) ComputeState() State {
+	switch {
+	case t.Removed:
+		return StateRemoved
+	case t.Job == JobQueued || t.Job == JobRunning:
+		return StateRemoving
+	case t.Job == JobFailed:
+		return StateFailed
+	case t.Approval == ApprovalPending:
+		return StatePending
+	case t.Quarantined():
+		return StateQuarantined
+	case t.Expired.Valid:
+		return StateExpired
+	case t.Warned1.Valid:
+		return StateWarned
+	}
+
+	return StateActive
+}
+
+// Held returns true if the Thing has a legal hold that hasn't ended by the
+// given time.
+func (t *Thing) Held(now time.Time) bool {
+	return t.HoldReason.Valid && (!t.HoldUntil.Valid || now.Before(t.HoldUntil.Time))
+}
+
+// Registered returns true if the Thing didn't need approval, or was approved.
+// Only registered Things are warned about and removed.
+func (t *Thing) Registered() bool {
+	return t.Approval == ApprovalNotNeeded || t.Approval == ApprovalApproved
+}
+
+// LastUsed returns the latest of the Thing's Modified and Accessed times, null
+// if neither is known.
+func (t *Thing) LastUsed() null.Time {
+	if t.Accessed.Valid && (!t.Modified.Valid || t.Accessed.Time.After(t.Modified.Time)) {
+		return t.Accessed
+	}
+
+	return t.Modified
+}
+
+// EffectiveRemove returns the date the Thing is due to be removed. For
+// ExpiryModeAccess Things, that's ExpireAfter days after it was LastUsed(),
+// unless its Remove date is later. Otherwise it's just its Remove date.
+func (t *Thing) EffectiveRemove() time.Time {
+	lastUsed := t.LastUsed()
+	if t.ExpiryMode != ExpiryModeAccess || !lastUsed.Valid {
+		return t.Remove
+	}
+
+	y, m, d := lastUsed.Time.Date()
+	remove := time.Date(y, m, d+t.ExpireAfter, 0, 0, 0, 0, t.Remove.Location())
+
+	if remove.Before(t.Remove) {
+		return t.Remove
+	}
+
+	return remove
+}
+
+// RemovalPercent returns how far through removing the Thing's files its running
+// RemovalJob is, as a percentage of the number of files it had when last probed
+// (or its size, if the number of files isn't known). Returns 0 if neither is
+// known.
+func (t *Thing) RemovalPercent() int {
+	done, total := t.JobProgress.Files, t.Files.Int64
+	if total <= 0 {
+		done, total = t.JobProgress.Bytes, t.Size.Int64
+	}
+
+	if total <= 0 {
+		return 0
+	}
+
+	const percent = 100
+
+	return int(min(done*percent/total, percent))
+}
+
+// ProbeResult describes what was found at a Thing's address when it was
+// probed. Size, Files, Modified and Accessed are only meaningful if Exists is
+// true, and Accessed is zero if the backend can't tell.
+type ProbeResult struct {
+	Exists   bool
+	Size     int64
+	Files    int64
+	Modified time.Time
+	Accessed time.Time
 }
 
 type Subscriber struct {
@@ -197,3 +458,38 @@ type Subscriber struct {
 	ThingID uint32
 	Creator bool
 }
+
+type AuditAction string
+
+const (
+	AuditRemovedExternally AuditAction = "removed externally"
+	AuditRemoved           AuditAction = "removed"
+	AuditExtended          AuditAction = "extended"
+	AuditPluginOutput      AuditAction = "plugin output"
+	AuditQuarantined       AuditAction = "quarantined"
+	AuditRestored          AuditAction = "restored"
+	AuditArchived          AuditAction = "archived"
+	AuditExpired           AuditAction = "expired"
+	AuditJobRetried        AuditAction = "removal retried"
+	AuditJobCancelled      AuditAction = "removal cancelled"
+	AuditChanged           AuditAction = "changed"
+	AuditConfirmed         AuditAction = "confirmed"
+	AuditWarned            AuditAction = "warned"
+	AuditPending           AuditAction = "awaiting approval"
+	AuditApproved          AuditAction = "approved"
+	AuditRejected          AuditAction = "rejected"
+	AuditHeld              AuditAction = "held"
+	AuditHoldReleased      AuditAction = "hold released"
+	AuditSnoozed           AuditAction = "snoozed"
+	AuditUnsubscribed      AuditAction = "unsubscribed"
+	AuditDeleted           AuditAction = "deleted"
+)
+
+// AuditEvent records something that happened to a Thing, for its history.
+type AuditEvent struct {
+	ThingID uint32
+	Time    time.Time
+	Actor   string // Name of the User responsible, blank if tt itself.
+	Action  AuditAction
+	Detail  string
+}
@@ -26,39 +26,25 @@
 package database
 
 import (
+	"errors"
 	"testing"
+	"time"
 
+	null "github.com/guregu/null/v5"
 	. "github.com/smartystreets/goconvey/convey"
 )
 
-func TestNewThingsTypes(t *testing.T) {
-	Convey("You can convert strings to ThingsType*, unless it's invalid", t, func() {
-		tt, err := NewThingsType("")
-		So(err, ShouldBeNil)
-		So(tt, ShouldEqual, ThingsTypeNil)
-
-		tt, err = NewThingsType("dir")
-		So(err, ShouldBeNil)
-		So(tt, ShouldEqual, ThingsTypeDir)
-
-		tt, err = NewThingsType("file")
-		So(err, ShouldBeNil)
-		So(tt, ShouldEqual, ThingsTypeFile)
+func TestDuplicateError(t *testing.T) {
+	Convey("A DuplicateError is ErrDuplicate and records the existing ID", t, func() {
+		var err error = &DuplicateError{ExistingID: 3}
 
-		tt, err = NewThingsType("irods")
-		So(err, ShouldBeNil)
-		So(tt, ShouldEqual, ThingsTypeIrods)
+		So(errors.Is(err, ErrDuplicate), ShouldBeTrue)
+		So(err.Error(), ShouldContainSubstring, "(id 3)")
 
-		tt, err = NewThingsType("openstack")
-		So(err, ShouldBeNil)
-		So(tt, ShouldEqual, ThingsTypeOpenstack)
+		var dupErr *DuplicateError
 
-		tt, err = NewThingsType("s3")
-		So(err, ShouldBeNil)
-		So(tt, ShouldEqual, ThingsTypeS3)
-
-		_, err = NewThingsType("invalid")
-		So(err, ShouldNotBeNil)
+		So(errors.As(err, &dupErr), ShouldBeTrue)
+		So(dupErr.ExistingID, ShouldEqual, 3)
 	})
 }
 
@@ -84,6 +70,18 @@ func TestNewOrderBy(t *testing.T) {
 		So(err, ShouldBeNil)
 		So(ob, ShouldEqual, OrderByRemove)
 
+		ob, err = NewOrderBy("size")
+		So(err, ShouldBeNil)
+		So(ob, ShouldEqual, OrderBySize)
+
+		ob, err = NewOrderBy("files")
+		So(err, ShouldBeNil)
+		So(ob, ShouldEqual, OrderByFiles)
+
+		ob, err = NewOrderBy("modified")
+		So(err, ShouldBeNil)
+		So(ob, ShouldEqual, OrderByModified)
+
 		_, err = NewOrderBy("invalid")
 		So(err, ShouldNotBeNil)
 	})
@@ -107,3 +105,81 @@ func TestNewOrderDirection(t *testing.T) {
 		So(err, ShouldNotBeNil)
 	})
 }
+
+func TestNewExpiryAction(t *testing.T) {
+	Convey("You can convert strings to Expiry*, unless it's invalid", t, func() {
+		action, err := NewExpiryAction("")
+		So(err, ShouldBeNil)
+		So(action, ShouldEqual, ExpiryDelete)
+
+		action, err = NewExpiryAction("delete")
+		So(err, ShouldBeNil)
+		So(action, ShouldEqual, ExpiryDelete)
+
+		action, err = NewExpiryAction("archive")
+		So(err, ShouldBeNil)
+		So(action, ShouldEqual, ExpiryArchive)
+
+		action, err = NewExpiryAction("notify")
+		So(err, ShouldBeNil)
+		So(action, ShouldEqual, ExpiryNotify)
+
+		_, err = NewExpiryAction("invalid")
+		So(err, ShouldEqual, ErrBadExpiryAction)
+	})
+}
+
+func TestNewExpiryMode(t *testing.T) {
+	Convey("You can convert strings to ExpiryMode*, unless it's invalid", t, func() {
+		mode, err := NewExpiryMode("")
+		So(err, ShouldBeNil)
+		So(mode, ShouldEqual, ExpiryModeFixed)
+
+		mode, err = NewExpiryMode("fixed")
+		So(err, ShouldBeNil)
+		So(mode, ShouldEqual, ExpiryModeFixed)
+
+		mode, err = NewExpiryMode("atime")
+		So(err, ShouldBeNil)
+		So(mode, ShouldEqual, ExpiryModeAccess)
+
+		_, err = NewExpiryMode("invalid")
+		So(err, ShouldEqual, ErrBadExpiryMode)
+	})
+}
+
+func TestEffectiveRemove(t *testing.T) {
+	Convey("Given a Thing with a removal date", t, func() {
+		remove := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+		thing := &Thing{Remove: remove, ExpireAfter: 30}
+
+		Convey("Its effective removal date is its removal date if it has a fixed expiry mode", func() {
+			thing.Accessed = null.TimeFrom(time.Date(2025, 6, 1, 9, 0, 0, 0, time.UTC))
+			So(thing.EffectiveRemove(), ShouldEqual, remove)
+		})
+
+		Convey("If it expires after it was last used", func() {
+			thing.ExpiryMode = ExpiryModeAccess
+
+			Convey("its effective removal date is its removal date until it's probed", func() {
+				So(thing.LastUsed().Valid, ShouldBeFalse)
+				So(thing.EffectiveRemove(), ShouldEqual, remove)
+			})
+
+			Convey("its effective removal date is ExpireAfter days after the later of its access and modification", func() {
+				thing.Modified = null.TimeFrom(time.Date(2025, 4, 1, 9, 0, 0, 0, time.UTC))
+				So(thing.LastUsed(), ShouldEqual, thing.Modified)
+				So(thing.EffectiveRemove(), ShouldEqual, time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC))
+
+				thing.Accessed = null.TimeFrom(time.Date(2025, 4, 10, 9, 0, 0, 0, time.UTC))
+				So(thing.LastUsed(), ShouldEqual, thing.Accessed)
+				So(thing.EffectiveRemove(), ShouldEqual, time.Date(2025, 5, 10, 0, 0, 0, 0, time.UTC))
+			})
+
+			Convey("but never before its removal date", func() {
+				thing.Accessed = null.TimeFrom(time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC))
+				So(thing.EffectiveRemove(), ShouldEqual, remove)
+			})
+		})
+	})
+}
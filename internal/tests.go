@@ -88,6 +88,10 @@ func GetExampleData() ([]database.User, []database.Thing, []database.Subscriber)
 				Description: "desc",
 				Reason:      reasons[i],
 				Remove:      remove,
+				Creator:     creator.Name,
+				OnExpiry:    database.ExpiryDelete,
+				ExpiryMode:  database.ExpiryModeFixed,
+				State:       database.StateActive,
 			}
 			expectedThings[i] = expectedThing
 
@@ -0,0 +1,96 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+// package jobs holds the background jobs that a tt server runs periodically to
+// keep its database in sync with the real temporary things.
+
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	null "github.com/guregu/null/v5"
+	"github.com/wtsi-hgi/tt/database"
+	"github.com/wtsi-hgi/tt/notify"
+)
+
+// runEvery calls job immediately and then every interval, until ctx is done.
+// Errors returned by job are logged to logger prefixed with name.
+func runEvery(ctx context.Context, interval time.Duration, name string, logger *log.Logger, job func(context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := job(ctx); err != nil && ctx.Err() == nil {
+			logger.Printf("%s failed: %s", name, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// liveThings returns all the Things in the database that have not been
+// removed, and are Registered() (ie. aren't awaiting approval).
+func liveThings(db database.Queries) ([]database.Thing, error) {
+	result, err := db.GetThings(database.GetThingsParams{
+		FilterOnRemoved: null.BoolFrom(false),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	things := result.Things[:0]
+
+	for _, thing := range result.Things {
+		if thing.Registered() {
+			things = append(things, thing)
+		}
+	}
+
+	return things, nil
+}
+
+// notifySubscribers sends the given message to the subscribers of the given
+// thing using the given notifier. Does nothing if notifier is nil or there are
+// no subscribers.
+func notifySubscribers(db database.Queries, notifier notify.Notifier, thing *database.Thing,
+	subject, body string) error {
+	if notifier == nil {
+		return nil
+	}
+
+	users, err := db.GetSubscribers(thing.ID)
+	if err != nil || len(users) == 0 {
+		return err
+	}
+
+	return notifier.Notify(users, subject, body)
+}
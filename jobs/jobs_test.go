@@ -0,0 +1,619 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"slices"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	null "github.com/guregu/null/v5"
+	"github.com/wtsi-hgi/tt/backend"
+	_ "github.com/wtsi-hgi/tt/backend/s3"
+	"github.com/wtsi-hgi/tt/database"
+)
+
+// mockDB implements the parts of database.Queries that the jobs use, storing
+// things in memory. Calling other methods will panic.
+type mockDB struct {
+	database.Queries
+
+	mu     sync.Mutex
+	things []database.Thing
+	probes map[uint32]database.ProbeResult
+	audit  []database.AuditEvent
+	subs   map[uint32][]database.User
+	jobs   map[uint32]*database.RemovalJob
+	leases map[string]database.Lease
+
+	snoozes       []database.Snooze
+	confirmations []database.Confirmation
+}
+
+func newMockDB(things ...database.Thing) *mockDB {
+	return &mockDB{
+		things: things,
+		probes: make(map[uint32]database.ProbeResult),
+		subs:   make(map[uint32][]database.User),
+		jobs:   make(map[uint32]*database.RemovalJob),
+		leases: make(map[string]database.Lease),
+	}
+}
+
+func (m *mockDB) GetThings(params database.GetThingsParams) (*database.GetThingsResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var things []database.Thing
+
+	for _, thing := range m.things {
+		if params.FilterOnRemoved.Valid && thing.Removed != params.FilterOnRemoved.Bool {
+			continue
+		}
+
+		if job, ok := m.jobs[thing.ID]; ok {
+			thing.Job = job.State
+		}
+
+		things = append(things, thing)
+	}
+
+	return &database.GetThingsResult{Things: things}, nil
+}
+
+func (m *mockDB) UpdateProbe(id uint32, result database.ProbeResult) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.probes[id] = result
+
+	return nil
+}
+
+func (m *mockDB) MarkRemoved(id uint32) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, thing := range m.things {
+		if thing.ID == id {
+			m.things[i].Removed = true
+		}
+	}
+
+	return nil
+}
+
+func (m *mockDB) GetThing(id uint32) (*database.Thing, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, thing := range m.things {
+		if thing.ID == id {
+			return &thing, nil
+		}
+	}
+
+	return nil, database.ErrNoThing
+}
+
+func (m *mockDB) MarkQuarantined(id uint32, location string, until time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, thing := range m.things {
+		if thing.ID == id {
+			m.things[i].Quarantine = null.StringFrom(location)
+			m.things[i].QuarantineUntil = null.TimeFrom(until)
+		}
+	}
+
+	return nil
+}
+
+func (m *mockDB) MarkArchived(id uint32, path string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, thing := range m.things {
+		if thing.ID == id {
+			m.things[i].Archive = null.StringFrom(path)
+		}
+	}
+
+	return nil
+}
+
+func (m *mockDB) MarkExpired(id uint32) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, thing := range m.things {
+		if thing.ID == id {
+			m.things[i].Expired = null.TimeFrom(time.Now())
+		}
+	}
+
+	return nil
+}
+
+func (m *mockDB) SetWarned(id uint32, warned1, warned2 null.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, thing := range m.things {
+		if thing.ID == id {
+			m.things[i].Warned1 = warned1
+			m.things[i].Warned2 = warned2
+		}
+	}
+
+	return nil
+}
+
+func (m *mockDB) MarkChanged(id uint32) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, thing := range m.things {
+		if thing.ID == id {
+			m.things[i].Changed = null.TimeFrom(time.Now())
+		}
+	}
+
+	return nil
+}
+
+func (m *mockDB) MarkRestored(id uint32, remove time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, thing := range m.things {
+		if thing.ID == id {
+			m.things[i].Quarantine = null.String{}
+			m.things[i].QuarantineUntil = null.Time{}
+			m.things[i].Remove = remove
+		}
+	}
+
+	return nil
+}
+
+func (m *mockDB) GetSubscribers(thingID uint32) ([]database.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.subs[thingID], nil
+}
+
+func (m *mockDB) GetSnoozes(thingID uint32) ([]database.Snooze, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var snoozes []database.Snooze
+
+	for _, snooze := range m.snoozes {
+		if snooze.ThingID == thingID {
+			snoozes = append(snoozes, snooze)
+		}
+	}
+
+	return snoozes, nil
+}
+
+func (m *mockDB) GetEndedSnoozes(before time.Time) ([]database.Snooze, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var snoozes []database.Snooze
+
+	for _, snooze := range m.snoozes {
+		if snooze.Until.Before(before) {
+			snoozes = append(snoozes, snooze)
+		}
+	}
+
+	return snoozes, nil
+}
+
+func (m *mockDB) MarkSnoozeMissed(thingID, userID uint32) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, snooze := range m.snoozes {
+		if snooze.ThingID == thingID && snooze.User.ID == userID {
+			m.snoozes[i].Missed = true
+		}
+	}
+
+	return nil
+}
+
+func (m *mockDB) DeleteSnooze(thingID, userID uint32) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.snoozes = slices.DeleteFunc(m.snoozes, func(snooze database.Snooze) bool {
+		return snooze.ThingID == thingID && snooze.User.ID == userID
+	})
+
+	return nil
+}
+
+func (m *mockDB) GetConfirmations(thingID uint32) ([]database.Confirmation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var confirmations []database.Confirmation
+
+	for _, confirmation := range m.confirmations {
+		if confirmation.ThingID == thingID {
+			confirmations = append(confirmations, confirmation)
+		}
+	}
+
+	return confirmations, nil
+}
+
+func (m *mockDB) AddAuditEvent(event database.AuditEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.audit = append(m.audit, event)
+
+	return nil
+}
+
+func (m *mockDB) EnqueueJob(thingID uint32, action database.JobAction) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.jobs[thingID]; ok {
+		return nil
+	}
+
+	job := &database.RemovalJob{ThingID: thingID, Action: action, State: database.JobQueued}
+
+	for _, thing := range m.things {
+		if thing.ID == thingID {
+			job.Type = thing.Type
+		}
+	}
+
+	m.jobs[thingID] = job
+
+	return nil
+}
+
+func (m *mockDB) GetRemovalJobs(state database.JobState) ([]database.RemovalJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var jobs []database.RemovalJob
+
+	for _, job := range m.jobs {
+		if state == "" || job.State == state {
+			jobs = append(jobs, *job)
+		}
+	}
+
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].ThingID < jobs[j].ThingID })
+
+	return jobs, nil
+}
+
+func (m *mockDB) StartJob(thingID uint32, runner string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.jobs[thingID].State = database.JobRunning
+	m.jobs[thingID].Runner = runner
+	m.jobs[thingID].Attempts++
+
+	return nil
+}
+
+func (m *mockDB) UpdateJobProgress(thingID uint32, progress database.RemovalProgress) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.jobs[thingID].Progress = progress
+
+	return nil
+}
+
+func (m *mockDB) FinishJob(thingID uint32) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.jobs, thingID)
+
+	return nil
+}
+
+func (m *mockDB) FailJob(thingID uint32, lastError string, state database.JobState, nextAttempt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job := m.jobs[thingID]
+	job.State = state
+	job.LastError = null.StringFrom(lastError)
+	job.NextAttempt = nextAttempt
+
+	return nil
+}
+
+func (m *mockDB) ResetRunningJobs() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+
+	for _, job := range m.jobs {
+		if job.State == database.JobRunning && !m.holdsLease(job.Runner, now) {
+			job.State = database.JobQueued
+		}
+	}
+
+	return nil
+}
+
+// holdsLease returns true if holder holds a lease that hasn't expired as of
+// now. You must hold the lock.
+func (m *mockDB) holdsLease(holder string, now time.Time) bool {
+	for _, lease := range m.leases {
+		if lease.Holder == holder && !lease.Expired(now) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (m *mockDB) AcquireLease(name, holder string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+
+	lease, ok := m.leases[name]
+	if ok && lease.Holder != holder && !lease.Expired(now) {
+		return false, nil
+	}
+
+	if !ok || lease.Holder != holder {
+		lease = database.Lease{Name: name, Holder: holder, Acquired: now}
+	}
+
+	lease.Expires = now.Add(ttl)
+	m.leases[name] = lease
+
+	return true, nil
+}
+
+func (m *mockDB) ReleaseLease(name, holder string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.leases[name].Holder == holder {
+		delete(m.leases, name)
+	}
+
+	return nil
+}
+
+// mockNotifier records the notifications it is asked to send.
+type mockNotifier struct {
+	mu       sync.Mutex
+	messages []mockMessage
+}
+
+type mockMessage struct {
+	users   []database.User
+	subject string
+	body    string
+}
+
+func (m *mockNotifier) Notify(users []database.User, subject, body string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.messages = append(m.messages, mockMessage{users: users, subject: subject, body: body})
+
+	return nil
+}
+
+// mockBackend is a backend.Prober that returns canned results per address.
+type mockBackend struct {
+	results map[string]*database.ProbeResult
+	err     error
+}
+
+func (m *mockBackend) Exists(_ context.Context, thing *database.Thing) (bool, error) {
+	if m.err != nil {
+		return false, m.err
+	}
+
+	result, ok := m.results[thing.Address]
+
+	return ok && result.Exists, nil
+}
+
+func (m *mockBackend) Probe(_ context.Context, thing *database.Thing) (*database.ProbeResult, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	result, ok := m.results[thing.Address]
+	if !ok {
+		return &database.ProbeResult{}, nil
+	}
+
+	return result, nil
+}
+
+// mockRemover is a mockBackend that is also a backend.Remover, recording the
+// IDs of the things it removes, and reporting that it removed a 5 byte file
+// each time it tries.
+type mockRemover struct {
+	mockBackend
+
+	mu        sync.Mutex
+	removed   []uint32
+	removeErr error
+}
+
+func (m *mockRemover) Remove(ctx context.Context, thing *database.Thing) error {
+	backend.ProgressFrom(ctx).Removed(1, 5)
+
+	if m.removeErr != nil {
+		return m.removeErr
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.removed = append(m.removed, thing.ID)
+
+	return nil
+}
+
+// mockQuarantiner is a mockBackend that is also a backend.Quarantiner,
+// recording the IDs of the things it quarantines, restores and purges.
+type mockQuarantiner struct {
+	mockBackend
+
+	mu          sync.Mutex
+	quarantined []uint32
+	restored    []uint32
+	purged      []uint32
+	err         error
+}
+
+func (m *mockQuarantiner) Quarantine(_ context.Context, thing *database.Thing) (string, error) {
+	if m.err != nil {
+		return "", m.err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.quarantined = append(m.quarantined, thing.ID)
+
+	return "/quarantine" + thing.Address, nil
+}
+
+func (m *mockQuarantiner) Restore(_ context.Context, thing *database.Thing) error {
+	if m.err != nil {
+		return m.err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.restored = append(m.restored, thing.ID)
+
+	return nil
+}
+
+func (m *mockQuarantiner) Purge(_ context.Context, thing *database.Thing) error {
+	if m.err != nil {
+		return m.err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.purged = append(m.purged, thing.ID)
+
+	return nil
+}
+
+// mockFingerprinter is a mockQuarantiner that is also a backend.Fingerprinter,
+// returning the fingerprint it has for a thing's address.
+type mockFingerprinter struct {
+	mockQuarantiner
+
+	fingerprints   map[string]*database.Fingerprint
+	fingerprintErr error
+}
+
+func (m *mockFingerprinter) Fingerprint(_ context.Context, thing *database.Thing) (*database.Fingerprint, error) {
+	return m.fingerprints[thing.Address], m.fingerprintErr
+}
+
+// mockArchiver is a mockBackend that is also a backend.Archiver, recording the
+// IDs of the things it archives. If removeErr is set, archives are made but
+// removing the things afterwards fails with it.
+type mockArchiver struct {
+	mockBackend
+
+	mu        sync.Mutex
+	archived  []uint32
+	err       error
+	removeErr error
+}
+
+func (m *mockArchiver) Archive(_ context.Context, thing *database.Thing) (string, error) {
+	if m.err != nil {
+		return "", m.err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.archived = append(m.archived, thing.ID)
+
+	return "/archive" + thing.Address + ".tar.gz", m.removeErr
+}
+
+func newTestLogger() (*log.Logger, *bytes.Buffer) {
+	var buf bytes.Buffer
+
+	return log.New(&buf, "", 0), &buf
+}
+
+// useBackends sets the registered Backend of every ThingsType to the one in the
+// given map (nil if it isn't in it), restoring the previous ones once the test
+// finishes.
+func useBackends(t *testing.T, backends map[database.ThingsType]backend.Backend) {
+	t.Helper()
+
+	for _, thingsType := range database.ThingsTypes() {
+		previous, err := database.SetBackend(thingsType, backends[thingsType])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		t.Cleanup(func() {
+			database.SetBackend(thingsType, previous) //nolint:errcheck
+		})
+	}
+}
@@ -0,0 +1,158 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/wtsi-hgi/tt/database"
+)
+
+const DefaultLeaseTTL = time.Minute
+
+// Leader uses a database.Lease to elect one of several tt servers sharing a
+// database as the leader, so that only it runs the scheduled jobs. Otherwise
+// they would, for example, notify subscribers twice.
+type Leader struct {
+	db     database.Queries
+	name   string
+	holder string
+	ttl    time.Duration
+	logger *log.Logger
+
+	mu      sync.Mutex
+	leading bool
+}
+
+// NewLeader returns a Leader that competes for the lease with the given name
+// (eg. database.SchedulerLease) on behalf of holder, which should uniquely
+// identify this process (see Identity()). Problems are logged to the given
+// logger.
+func NewLeader(db database.Queries, name, holder string, logger *log.Logger) *Leader {
+	return &Leader{
+		db:     db,
+		name:   name,
+		holder: holder,
+		ttl:    DefaultLeaseTTL,
+		logger: logger,
+	}
+}
+
+// Identity returns a string identifying this process, made from the hostname
+// and pid, suitable for use as a Leader's holder.
+func Identity() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+
+	return fmt.Sprintf("%s:%d", host, os.Getpid())
+}
+
+// SetTTL sets how long the lease is taken for each time it's renewed. If the
+// leader dies, another can only take over once this has passed. The default
+// is DefaultLeaseTTL.
+func (l *Leader) SetTTL(ttl time.Duration) {
+	l.ttl = ttl
+}
+
+// IsLeader returns true if we currently hold the lease.
+func (l *Leader) IsLeader() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.leading
+}
+
+// Run tries to take the lease now, and then renew or take it every third of
+// the TTL, until ctx is done, when the lease is released.
+//
+// Each time we become leader, lead is called with a context that is cancelled
+// when we stop being leader (because the lease couldn't be renewed) or ctx is
+// done. lead should start the jobs that only the leader should run, stopping
+// them when its context is done.
+func (l *Leader) Run(ctx context.Context, lead func(context.Context)) {
+	var cancel context.CancelFunc
+
+	defer func() {
+		if cancel != nil {
+			cancel()
+		}
+
+		l.release()
+	}()
+
+	runEvery(ctx, l.ttl/3, "electing leader", l.logger, func(ctx context.Context) error {
+		leading, err := l.db.AcquireLease(l.name, l.holder, l.ttl)
+		if err != nil {
+			leading = false
+		}
+
+		l.setLeading(leading)
+
+		switch {
+		case leading && cancel == nil:
+			l.logger.Printf("%s became leader", l.holder)
+
+			var leadCtx context.Context
+
+			leadCtx, cancel = context.WithCancel(ctx)
+
+			lead(leadCtx)
+		case !leading && cancel != nil:
+			l.logger.Printf("%s stopped being leader", l.holder)
+
+			cancel()
+			cancel = nil
+		}
+
+		return err
+	})
+}
+
+func (l *Leader) setLeading(leading bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.leading = leading
+}
+
+// release gives up the lease, if we hold it.
+func (l *Leader) release() {
+	if !l.IsLeader() {
+		return
+	}
+
+	l.setLeading(false)
+
+	if err := l.db.ReleaseLease(l.name, l.holder); err != nil {
+		l.logger.Printf("releasing lease failed: %s", err)
+	}
+}
@@ -0,0 +1,135 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+package jobs
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/wtsi-hgi/tt/database"
+)
+
+func TestLeader(t *testing.T) {
+	Convey("Given two leaders competing for the same lease", t, func() {
+		mdb := newMockDB()
+		logger, logs := newTestLogger()
+		ttl := 30 * time.Millisecond
+
+		a := NewLeader(mdb, database.SchedulerLease, "a", logger)
+		a.SetTTL(ttl)
+
+		b := NewLeader(mdb, database.SchedulerLease, "b", logger)
+		b.SetTTL(ttl)
+
+		var (
+			mu  sync.Mutex
+			led []string
+		)
+
+		leadFunc := func(name string, started chan<- context.Context) func(context.Context) {
+			return func(ctx context.Context) {
+				mu.Lock()
+				led = append(led, name)
+				mu.Unlock()
+
+				started <- ctx
+			}
+		}
+
+		Convey("Only one leads, until it stops and the other takes over", func() {
+			ctxA, cancelA := context.WithCancel(context.Background())
+			ctxB, cancelB := context.WithCancel(context.Background())
+
+			defer cancelB()
+
+			startedA := make(chan context.Context, 1)
+			startedB := make(chan context.Context, 1)
+
+			doneA := make(chan struct{})
+
+			go func() {
+				a.Run(ctxA, leadFunc("a", startedA))
+				close(doneA)
+			}()
+
+			leadCtxA := <-startedA
+
+			So(a.IsLeader(), ShouldBeTrue)
+
+			go b.Run(ctxB, leadFunc("b", startedB))
+
+			time.Sleep(2 * ttl)
+
+			So(b.IsLeader(), ShouldBeFalse)
+
+			mu.Lock()
+			So(led, ShouldResemble, []string{"a"})
+			mu.Unlock()
+
+			cancelA()
+			<-doneA
+
+			So(leadCtxA.Err(), ShouldNotBeNil)
+			So(a.IsLeader(), ShouldBeFalse)
+
+			<-startedB
+
+			So(b.IsLeader(), ShouldBeTrue)
+			So(mdb.leases[database.SchedulerLease].Holder, ShouldEqual, "b")
+			So(logs.String(), ShouldContainSubstring, "b became leader")
+		})
+
+		Convey("A leader that can't renew its lease stops leading", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			started := make(chan context.Context, 1)
+
+			go a.Run(ctx, leadFunc("a", started))
+
+			leadCtx := <-started
+
+			mdb.mu.Lock()
+			mdb.leases[database.SchedulerLease] = database.Lease{
+				Name:    database.SchedulerLease,
+				Holder:  "b",
+				Expires: time.Now().Add(time.Hour),
+			}
+			mdb.mu.Unlock()
+
+			<-leadCtx.Done()
+
+			So(a.IsLeader(), ShouldBeFalse)
+			So(logs.String(), ShouldContainSubstring, "a stopped being leader")
+		})
+	})
+
+	Convey("Identity includes the pid", t, func() {
+		So(Identity(), ShouldContainSubstring, ":")
+	})
+}
@@ -0,0 +1,95 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/wtsi-hgi/tt/backend"
+	"github.com/wtsi-hgi/tt/database"
+)
+
+// Prober probes the live Things whose ThingsType has a backend.Prober, and
+// records what it finds in the database.
+type Prober struct {
+	db     database.Queries
+	logger *log.Logger
+}
+
+// NewProber returns a Prober that probes the things in the given database
+// using the backends registered for their types, logging problems to the given
+// logger.
+func NewProber(db database.Queries, logger *log.Logger) *Prober {
+	return &Prober{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// ProbeAll probes every live thing once, except those in quarantine. Failure to
+// probe an individual thing is logged, but does not stop the others being
+// probed. Returns an error if the things couldn't be retrieved, or ctx is done.
+func (p *Prober) ProbeAll(ctx context.Context) error {
+	things, err := liveThings(p.db)
+	if err != nil {
+		return err
+	}
+
+	for i := range things {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		thing := &things[i]
+
+		prober, ok := backend.ProberFor(thing.Type)
+		if !ok || thing.Quarantined() {
+			continue
+		}
+
+		if err := p.probe(ctx, prober, thing); err != nil {
+			p.logger.Printf("probing thing %d (%s) failed: %s", thing.ID, thing.Address, err)
+		}
+	}
+
+	return nil
+}
+
+func (p *Prober) probe(ctx context.Context, prober backend.Prober, thing *database.Thing) error {
+	result, err := prober.Probe(ctx, thing)
+	if err != nil {
+		return err
+	}
+
+	return p.db.UpdateProbe(thing.ID, *result)
+}
+
+// Run calls ProbeAll() now and then every interval, until ctx is done.
+func (p *Prober) Run(ctx context.Context, interval time.Duration) {
+	runEvery(ctx, interval, "probing", p.logger, p.ProbeAll)
+}
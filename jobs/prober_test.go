@@ -0,0 +1,98 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/wtsi-hgi/tt/backend"
+	"github.com/wtsi-hgi/tt/database"
+)
+
+func TestProber(t *testing.T) {
+	Convey("Given a database of things and some backends", t, func() {
+		modified := time.Now().Truncate(time.Second)
+
+		mdb := newMockDB(
+			database.Thing{ID: 1, Address: "/a", Type: database.ThingsTypeDir},
+			database.Thing{ID: 2, Address: "/b", Type: database.ThingsTypeDir},
+			database.Thing{ID: 3, Address: "/c", Type: database.ThingsTypeDir, Removed: true},
+			database.Thing{ID: 4, Address: "/d", Type: database.ThingsTypeFile},
+			database.Thing{ID: 5, Address: "/a", Type: database.ThingsTypeS3},
+		)
+
+		dirResult := &database.ProbeResult{Exists: true, Size: 10, Files: 2, Modified: modified}
+		mb := &mockBackend{results: map[string]*database.ProbeResult{"/a": dirResult}}
+		backends := map[database.ThingsType]backend.Backend{
+			database.ThingsTypeDir: mb,
+		}
+		useBackends(t, backends)
+
+		logger, logs := newTestLogger()
+		p := NewProber(mdb, logger)
+
+		Convey("You can probe all live things that have a Prober", func() {
+			err := p.ProbeAll(context.Background())
+			So(err, ShouldBeNil)
+			So(len(mdb.probes), ShouldEqual, 2)
+			So(mdb.probes[1], ShouldResemble, *dirResult)
+			So(mdb.probes[2].Exists, ShouldBeFalse)
+			So(logs.String(), ShouldBeBlank)
+		})
+
+		Convey("Probe failures are logged", func() {
+			mb.err = errors.New("probe failed")
+
+			err := p.ProbeAll(context.Background())
+			So(err, ShouldBeNil)
+			So(len(mdb.probes), ShouldEqual, 0)
+			So(logs.String(), ShouldContainSubstring, "probing thing 1 (/a) failed: probe failed")
+		})
+
+		Convey("You can run it periodically until cancelled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+
+			go func() {
+				p.Run(ctx, time.Millisecond)
+				close(done)
+			}()
+
+			<-time.After(20 * time.Millisecond)
+			cancel()
+			<-done
+
+			mdb.mu.Lock()
+			defer mdb.mu.Unlock()
+
+			So(len(mdb.probes), ShouldEqual, 2)
+		})
+	})
+}
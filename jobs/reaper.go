@@ -0,0 +1,506 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	null "github.com/guregu/null/v5"
+	"github.com/wtsi-hgi/tt/backend"
+	"github.com/wtsi-hgi/tt/database"
+	"github.com/wtsi-hgi/tt/notify"
+	"github.com/wtsi-hgi/tt/policy"
+)
+
+const (
+	reapDetail        = "removal date passed"
+	purgeDetail       = "quarantine period ended"
+	DefaultQuarantine = 14 * 24 * time.Hour
+
+	errBadAction = database.Error("invalid removal job action")
+	errCantAct   = database.Error("has no backend that can carry out its removal job")
+)
+
+// Reaper removes live Things whose scheduled removal date (see
+// policy.Schedule.Scheduled()) has passed, using the backend.Remover for their
+// ThingsType. If their ThingsType has a backend.Quarantiner, they are instead
+// quarantined, and only permanently removed once their quarantine period has
+// ended. Things of types without either are left alone.
+//
+// Things with an OnExpiry of ExpiryArchive are instead archived using the
+// backend.Archiver for their type, while subscribers of ExpiryNotify things
+// are only told that the removal date has passed.
+//
+// Things of types with a backend.Fingerprinter that have materially changed
+// since their Fingerprint was taken are not removed, but flagged as changed,
+// with their subscribers asked to confirm their removal or extend them.
+//
+// Things at addresses not allowed by the Reaper's policy.Protection are never
+// removed, and things on legal hold are left alone until their hold ends.
+//
+// If the Reaper has a policy.Schedule, things are only acted on once its grace
+// period after their removal date has passed, and only at times it allows.
+type Reaper struct {
+	db         database.Queries
+	notifier   notify.Notifier
+	logger     *log.Logger
+	now        func() time.Time
+	quarantine time.Duration
+	protection *policy.Protection
+	schedule   *policy.Schedule
+}
+
+// NewReaper returns a Reaper that removes expired things in the given database
+// using the backends registered for their types, and tells subscribers about
+// things it removes using the given notifier (which can be nil to not notify
+// anyone). Problems are logged to the given logger.
+func NewReaper(db database.Queries, notifier notify.Notifier, logger *log.Logger) *Reaper {
+	return &Reaper{
+		db:       db,
+		notifier: notifier,
+		logger:   logger,
+		now:      time.Now,
+
+		quarantine: DefaultQuarantine,
+	}
+}
+
+// SetQuarantinePeriod sets how long things stay in quarantine before being
+// permanently removed. The default is DefaultQuarantine.
+func (r *Reaper) SetQuarantinePeriod(period time.Duration) {
+	r.quarantine = period
+}
+
+// SetProtection sets the policy.Protection that things' addresses are checked
+// against before they're quarantined, archived or removed. The default is nil,
+// which allows all addresses.
+func (r *Reaper) SetProtection(protection *policy.Protection) {
+	r.protection = protection
+}
+
+// SetSchedule sets the policy.Schedule that decides when things that are due
+// are acted on. The default is nil, which acts on them as soon as they're due.
+func (r *Reaper) SetSchedule(schedule *policy.Schedule) {
+	r.schedule = schedule
+}
+
+// Reap removes every live thing whose removal date is before now and that has
+// a Remover, and returns the ones that were removed. Removed things are marked
+// as such, the removal is recorded in their history, and their subscribers are
+// notified.
+//
+// Expired things that have a Quarantiner are instead quarantined, which is
+// recorded and notified in the same way, but they are only returned (and
+// removed) by a call after their quarantine period has ended.
+//
+// Things are dealt with one at a time; use Enqueue() and Workers to remove them
+// in parallel with retries instead.
+//
+// Failure to remove or update an individual thing is logged, but does not stop
+// the others being reaped. Returns an error if the things couldn't be
+// retrieved, or ctx is done.
+func (r *Reaper) Reap(ctx context.Context) ([]database.Thing, error) {
+	things, err := liveThings(r.db)
+	if err != nil {
+		return nil, err
+	}
+
+	now := r.now()
+
+	var removed []database.Thing
+
+	for i := range things {
+		if err := ctx.Err(); err != nil {
+			return removed, err
+		}
+
+		thing := &things[i]
+
+		action, ok := r.due(thing, now)
+		if !ok {
+			continue
+		}
+
+		acted, err := r.Execute(ctx, thing, action)
+		if err != nil {
+			r.logger.Print(err)
+		}
+
+		if acted && action != database.JobQuarantine {
+			removed = append(removed, *thing)
+		}
+	}
+
+	return removed, nil
+}
+
+// Enqueue queues a RemovalJob for every live thing that needs to be
+// quarantined, purged, archived or removed now, for Workers to carry out.
+// Things that already have a job are left alone, as are things whose job failed
+// or was cancelled, until the job is retried. Subscribers of expired things
+// that are only to be notified about are notified straight away.
+//
+// Returns the number of things that needed a job, and an error if the things
+// couldn't be retrieved, a job couldn't be queued, or ctx is done.
+func (r *Reaper) Enqueue(ctx context.Context) (int, error) {
+	things, err := liveThings(r.db)
+	if err != nil {
+		return 0, err
+	}
+
+	now := r.now()
+	n := 0
+
+	for i := range things {
+		if err := ctx.Err(); err != nil {
+			return n, err
+		}
+
+		action, ok := r.due(&things[i], now)
+		if !ok || things[i].Job != "" {
+			continue
+		}
+
+		if err := r.db.EnqueueJob(things[i].ID, action); err != nil {
+			return n, err
+		}
+
+		n++
+	}
+
+	return n, nil
+}
+
+// due returns the JobAction that needs to be carried out on the thing now, if
+// any. Nothing is due for things on hold, or at times the Reaper's schedule
+// doesn't allow. As a side effect, the subscribers of an expired ExpiryNotify
+// thing are notified.
+func (r *Reaper) due(thing *database.Thing, now time.Time) (database.JobAction, bool) {
+	if thing.Held(now) || !r.schedule.Allowed(now) {
+		return "", false
+	}
+
+	if thing.Quarantined() {
+		if now.Before(thing.QuarantineUntil.Time) {
+			return "", false
+		}
+
+		_, ok := backend.QuarantinerFor(thing.Type)
+
+		return database.JobPurge, ok
+	}
+
+	if !r.schedule.Scheduled(thing).Before(now) || thing.Changed.Valid {
+		return "", false
+	}
+
+	switch thing.OnExpiry {
+	case database.ExpiryNotify:
+		if err := r.markExpired(thing); err != nil {
+			r.logger.Printf("marking thing %d (%s) expired failed: %s", thing.ID, thing.Address, err)
+		}
+
+		return "", false
+	case database.ExpiryArchive:
+		if _, ok := backend.ArchiverFor(thing.Type); !ok {
+			r.logger.Printf("thing %d (%s) can't be archived", thing.ID, thing.Address)
+
+			return "", false
+		}
+
+		return database.JobArchive, true
+	}
+
+	if _, ok := backend.QuarantinerFor(thing.Type); ok {
+		return database.JobQuarantine, true
+	}
+
+	_, ok := backend.RemoverFor(thing.Type)
+
+	return database.JobRemove, ok
+}
+
+// Execute carries out the given action on the thing, recording it and notifying
+// its subscribers. Returns true if the backend action was carried out, even if
+// recording it then failed.
+//
+// Unless purging it from quarantine, the thing's address is first checked
+// against the Reaper's policy.Protection, returning an error if it's protected,
+// then it is checked against its Fingerprint, and nothing is done if it has
+// changed.
+func (r *Reaper) Execute(ctx context.Context, thing *database.Thing, action database.JobAction) (bool, error) {
+	if action != database.JobPurge {
+		if err := r.protection.Check(thing.Type, thing.Address); err != nil {
+			return false, fmt.Errorf("refusing to %s thing %d: %w", action, thing.ID, err)
+		}
+
+		if changed, err := r.checkFingerprint(ctx, thing); changed || err != nil {
+			return false, err
+		}
+	}
+
+	switch action {
+	case database.JobQuarantine:
+		return r.quarantineThing(ctx, thing)
+	case database.JobPurge:
+		return r.purge(ctx, thing)
+	case database.JobArchive:
+		return r.archive(ctx, thing)
+	case database.JobRemove:
+		return r.remove(ctx, thing)
+	}
+
+	return false, fmt.Errorf("%w: %s", errBadAction, action)
+}
+
+// checkFingerprint returns true if what exists at the thing's address has
+// materially changed since its Fingerprint was taken, in which case the thing
+// is marked as changed, and its subscribers are asked to confirm its removal or
+// extend it. Things without a Fingerprint, or of types without a
+// backend.Fingerprinter, are never considered changed.
+func (r *Reaper) checkFingerprint(ctx context.Context, thing *database.Thing) (bool, error) {
+	fingerprinter, ok := backend.FingerprinterFor(thing.Type)
+	if !ok || thing.Fingerprint == nil {
+		return false, nil
+	}
+
+	current, err := fingerprinter.Fingerprint(ctx, thing)
+	if err != nil {
+		return false, fmt.Errorf("fingerprinting thing %d (%s) failed: %w", thing.ID, thing.Address, err)
+	}
+
+	if current == nil {
+		return false, nil
+	}
+
+	changes := thing.Fingerprint.Changes(current)
+	if len(changes) == 0 {
+		return false, nil
+	}
+
+	if err = r.markChanged(thing, strings.Join(changes, "; ")); err != nil {
+		return true, fmt.Errorf("marking thing %d (%s) changed failed: %w", thing.ID, thing.Address, err)
+	}
+
+	return true, nil
+}
+
+func (r *Reaper) markChanged(thing *database.Thing, changes string) error {
+	if err := r.db.MarkChanged(thing.ID); err != nil {
+		return err
+	}
+
+	if err := r.db.AddAuditEvent(database.AuditEvent{
+		ThingID: thing.ID,
+		Action:  database.AuditChanged,
+		Detail:  reapDetail + "; not removed, since it changed after it was registered: " + changes,
+	}); err != nil {
+		return err
+	}
+
+	return notifySubscribers(r.db, r.notifier, thing,
+		fmt.Sprintf("tt: %s has changed, so has not been removed", thing.Address),
+		fmt.Sprintf("The %s %s, registered with tt because \"%s\", was due for removal on %s, "+
+			"but has changed since it was registered (%s), so tt has not removed it in case it is now "+
+			"being used for something else. If it should still be removed, confirm its removal using the "+
+			"tt web interface; otherwise please extend its removal date.\n",
+			thing.Type, thing.Address, thing.Reason, r.schedule.Scheduled(thing).Format(time.DateOnly), changes))
+}
+
+// remove removes the thing using the backend.Remover for its type.
+func (r *Reaper) remove(ctx context.Context, thing *database.Thing) (bool, error) {
+	remover, ok := backend.RemoverFor(thing.Type)
+	if !ok {
+		return false, fmt.Errorf("thing %d (%s) %w", thing.ID, thing.Address, errCantAct)
+	}
+
+	if err := remover.Remove(ctx, thing); err != nil {
+		return false, fmt.Errorf("removing thing %d (%s) failed: %w", thing.ID, thing.Address, err)
+	}
+
+	if err := r.markRemoved(thing, reapDetail); err != nil {
+		return true, fmt.Errorf("marking thing %d (%s) removed failed: %w", thing.ID, thing.Address, err)
+	}
+
+	return true, nil
+}
+
+// quarantineThing moves the thing's data in to quarantine until the end of the
+// quarantine period, recording that and notifying its subscribers.
+func (r *Reaper) quarantineThing(ctx context.Context, thing *database.Thing) (bool, error) {
+	quarantiner, ok := backend.QuarantinerFor(thing.Type)
+	if !ok {
+		return false, fmt.Errorf("thing %d (%s) %w", thing.ID, thing.Address, errCantAct)
+	}
+
+	location, err := quarantiner.Quarantine(ctx, thing)
+	if err != nil {
+		return false, fmt.Errorf("quarantining thing %d (%s) failed: %w", thing.ID, thing.Address, err)
+	}
+
+	until := r.now().Add(r.quarantine)
+
+	if err = r.markQuarantined(thing, location, until); err != nil {
+		return true, fmt.Errorf("marking thing %d (%s) quarantined failed: %w", thing.ID, thing.Address, err)
+	}
+
+	return true, nil
+}
+
+func (r *Reaper) markQuarantined(thing *database.Thing, location string, until time.Time) error {
+	if err := r.db.MarkQuarantined(thing.ID, location, until); err != nil {
+		return err
+	}
+
+	if err := r.db.AddAuditEvent(database.AuditEvent{
+		ThingID: thing.ID,
+		Action:  database.AuditQuarantined,
+		Detail:  fmt.Sprintf("%s; moved to %s until %s", reapDetail, location, until.Format(time.DateOnly)),
+	}); err != nil {
+		return err
+	}
+
+	return notifySubscribers(r.db, r.notifier, thing,
+		fmt.Sprintf("tt: %s has been quarantined", thing.Address),
+		fmt.Sprintf("The %s %s, registered with tt because \"%s\", was due for removal on %s "+
+			"and has now been moved to quarantine. It will be permanently removed on %s, unless you "+
+			"restore it before then using the tt web interface, or with: tt restore %d\n",
+			thing.Type, thing.Address, thing.Reason, r.schedule.Scheduled(thing).Format(time.DateOnly),
+			until.Format(time.DateOnly), thing.ID))
+}
+
+// purge permanently removes the quarantined thing.
+func (r *Reaper) purge(ctx context.Context, thing *database.Thing) (bool, error) {
+	quarantiner, ok := backend.QuarantinerFor(thing.Type)
+	if !ok {
+		return false, fmt.Errorf("thing %d (%s) %w", thing.ID, thing.Address, errCantAct)
+	}
+
+	if err := quarantiner.Purge(ctx, thing); err != nil {
+		return false, fmt.Errorf("purging thing %d (%s) failed: %w", thing.ID, thing.Quarantine.String, err)
+	}
+
+	if err := r.markRemoved(thing, purgeDetail); err != nil {
+		return true, fmt.Errorf("marking thing %d (%s) removed failed: %w", thing.ID, thing.Address, err)
+	}
+
+	return true, nil
+}
+
+// markExpired tells the subscribers of an ExpiryNotify thing that its removal
+// date has passed, if they haven't been told already.
+func (r *Reaper) markExpired(thing *database.Thing) error {
+	if thing.Expired.Valid {
+		return nil
+	}
+
+	if err := r.db.MarkExpired(thing.ID); err != nil {
+		return err
+	}
+
+	if err := r.db.AddAuditEvent(database.AuditEvent{
+		ThingID: thing.ID,
+		Action:  database.AuditExpired,
+		Detail:  reapDetail + "; not removed, since it is to be notified about only",
+	}); err != nil {
+		return err
+	}
+
+	return notifySubscribers(r.db, r.notifier, thing,
+		fmt.Sprintf("tt: %s has passed its removal date", thing.Address),
+		fmt.Sprintf("The %s %s, registered with tt because \"%s\", was due for removal on %s. "+
+			"As requested, tt has not removed it; please remove it yourself, or extend its removal date.\n",
+			thing.Type, thing.Address, thing.Reason, r.schedule.Scheduled(thing).Format(time.DateOnly)))
+}
+
+// archive archives and removes the thing using the backend.Archiver for its
+// type.
+func (r *Reaper) archive(ctx context.Context, thing *database.Thing) (bool, error) {
+	archiver, ok := backend.ArchiverFor(thing.Type)
+	if !ok {
+		return false, fmt.Errorf("thing %d (%s) %w", thing.ID, thing.Address, errCantAct)
+	}
+
+	path, err := archiver.Archive(ctx, thing)
+	if path == "" {
+		return false, fmt.Errorf("archiving thing %d (%s) failed: %w", thing.ID, thing.Address, err)
+	}
+
+	thing.Archive = null.StringFrom(path)
+
+	if errm := r.db.MarkArchived(thing.ID, path); errm != nil {
+		return true, fmt.Errorf("marking thing %d (%s) archived failed: %w", thing.ID, thing.Address, errm)
+	}
+
+	if err != nil {
+		return true, fmt.Errorf("removing thing %d (%s) after archiving it to %s failed: %w",
+			thing.ID, thing.Address, path, err)
+	}
+
+	if err = r.markRemoved(thing, reapDetail+"; archived to "+path); err != nil {
+		return true, fmt.Errorf("marking thing %d (%s) archived failed: %w", thing.ID, thing.Address, err)
+	}
+
+	return true, nil
+}
+
+func (r *Reaper) markRemoved(thing *database.Thing, detail string) error {
+	if err := r.db.MarkRemoved(thing.ID); err != nil {
+		return err
+	}
+
+	if err := r.db.AddAuditEvent(database.AuditEvent{
+		ThingID: thing.ID,
+		Action:  database.AuditRemoved,
+		Detail:  detail,
+	}); err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("The %s %s, registered with tt because \"%s\", was due for removal on %s "+
+		"and has now been removed.\n",
+		thing.Type, thing.Address, thing.Reason, r.schedule.Scheduled(thing).Format(time.DateOnly))
+
+	if thing.Archive.Valid {
+		body += fmt.Sprintf("An archive of it has been kept at %s\n", thing.Archive.String)
+	}
+
+	return notifySubscribers(r.db, r.notifier, thing, fmt.Sprintf("tt: %s has been removed", thing.Address), body)
+}
+
+// Run calls Enqueue() now and then every interval, until ctx is done. Use
+// Workers to carry out the queued jobs.
+func (r *Reaper) Run(ctx context.Context, interval time.Duration) {
+	runEvery(ctx, interval, "reaping", r.logger, func(ctx context.Context) error {
+		_, err := r.Enqueue(ctx)
+
+		return err
+	})
+}
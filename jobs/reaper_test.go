@@ -0,0 +1,383 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	null "github.com/guregu/null/v5"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/wtsi-hgi/tt/backend"
+	"github.com/wtsi-hgi/tt/database"
+	"github.com/wtsi-hgi/tt/policy"
+)
+
+func TestReaper(t *testing.T) {
+	Convey("Given a database of things, some of which have expired", t, func() {
+		now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+		past := now.AddDate(0, 0, -1)
+		future := now.AddDate(0, 0, 1)
+
+		mdb := newMockDB(
+			database.Thing{ID: 1, Address: "b/a", Type: database.ThingsTypeS3, Remove: future},
+			database.Thing{ID: 2, Address: "b/b", Type: database.ThingsTypeS3, Remove: past, Reason: "testing"},
+			database.Thing{ID: 3, Address: "b/c", Type: database.ThingsTypeS3, Remove: past, Removed: true},
+			database.Thing{ID: 4, Address: "/d", Type: database.ThingsTypeDir, Remove: past},
+		)
+
+		user := database.User{ID: 1, Name: "user", Email: "user@example.com"}
+		mdb.subs[2] = []database.User{user}
+
+		mr := &mockRemover{}
+		backends := map[database.ThingsType]backend.Backend{
+			database.ThingsTypeS3:  mr,
+			database.ThingsTypeDir: &mockBackend{},
+		}
+		useBackends(t, backends)
+		mn := &mockNotifier{}
+		logger, logs := newTestLogger()
+		r := NewReaper(mdb, mn, logger)
+		r.now = func() time.Time { return now }
+
+		Convey("You can remove expired things that have a remover, recording it and notifying subscribers", func() {
+			removed, err := r.Reap(context.Background())
+			So(err, ShouldBeNil)
+			So(len(removed), ShouldEqual, 1)
+			So(removed[0].ID, ShouldEqual, 2)
+			So(mr.removed, ShouldResemble, []uint32{2})
+			So(mdb.things[0].Removed, ShouldBeFalse)
+			So(mdb.things[1].Removed, ShouldBeTrue)
+			So(mdb.things[3].Removed, ShouldBeFalse)
+
+			So(len(mdb.audit), ShouldEqual, 1)
+			So(mdb.audit[0].ThingID, ShouldEqual, 2)
+			So(mdb.audit[0].Action, ShouldEqual, database.AuditRemoved)
+
+			So(len(mn.messages), ShouldEqual, 1)
+			So(mn.messages[0].users, ShouldResemble, []database.User{user})
+			So(mn.messages[0].subject, ShouldContainSubstring, "b/b")
+			So(mn.messages[0].body, ShouldContainSubstring, "testing")
+			So(logs.String(), ShouldBeBlank)
+
+			removed, err = r.Reap(context.Background())
+			So(err, ShouldBeNil)
+			So(removed, ShouldBeEmpty)
+		})
+
+		Convey("Things that expire after they were last used are removed based on that", func() {
+			mdb.things[1].ExpiryMode = database.ExpiryModeAccess
+			mdb.things[1].ExpireAfter = 7
+			mdb.things[1].Accessed = null.TimeFrom(now.AddDate(0, 0, -3))
+
+			removed, err := r.Reap(context.Background())
+			So(err, ShouldBeNil)
+			So(removed, ShouldBeEmpty)
+
+			mdb.things[1].Accessed = null.TimeFrom(now.AddDate(0, 0, -8))
+
+			removed, err = r.Reap(context.Background())
+			So(err, ShouldBeNil)
+			So(len(removed), ShouldEqual, 1)
+			So(mn.messages[0].body, ShouldContainSubstring, "was due for removal on 2025-05-31")
+		})
+
+		Convey("Things that fail to be removed are logged and left alone", func() {
+			mr.removeErr = errors.New("remove failed")
+
+			removed, err := r.Reap(context.Background())
+			So(err, ShouldBeNil)
+			So(removed, ShouldBeEmpty)
+			So(mdb.things[1].Removed, ShouldBeFalse)
+			So(mdb.audit, ShouldBeEmpty)
+			So(logs.String(), ShouldContainSubstring, "removing thing 2 (b/b) failed: remove failed")
+		})
+
+		Convey("Things that aren't registered because they're awaiting approval are left alone", func() {
+			mdb.things[1].Approval = database.ApprovalPending
+
+			removed, err := r.Reap(context.Background())
+			So(err, ShouldBeNil)
+			So(removed, ShouldBeEmpty)
+			So(mr.removed, ShouldBeEmpty)
+
+			mdb.things[1].Approval = database.ApprovalApproved
+
+			removed, err = r.Reap(context.Background())
+			So(err, ShouldBeNil)
+			So(len(removed), ShouldEqual, 1)
+		})
+
+		Convey("Things on hold are left alone until their hold ends", func() {
+			mdb.things[1].HoldReason = null.StringFrom("investigation")
+			mdb.things[1].HoldUntil = null.TimeFrom(future)
+
+			removed, err := r.Reap(context.Background())
+			So(err, ShouldBeNil)
+			So(removed, ShouldBeEmpty)
+			So(mr.removed, ShouldBeEmpty)
+
+			n, err := r.Enqueue(context.Background())
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 0)
+
+			r.now = func() time.Time { return future }
+
+			removed, err = r.Reap(context.Background())
+			So(err, ShouldBeNil)
+			So(len(removed), ShouldEqual, 1)
+			So(removed[0].ID, ShouldEqual, 2)
+		})
+
+		Convey("Things are only acted on after the grace period, at times the schedule allows", func() {
+			r.SetSchedule(&policy.Schedule{Grace: 48 * time.Hour})
+
+			removed, err := r.Reap(context.Background())
+			So(err, ShouldBeNil)
+			So(removed, ShouldBeEmpty)
+
+			r.now = func() time.Time { return past.Add(49 * time.Hour) }
+			r.SetSchedule(&policy.Schedule{Grace: 48 * time.Hour, Closures: []policy.Closure{
+				{Start: now, End: now.AddDate(0, 0, 2)},
+			}})
+
+			removed, err = r.Reap(context.Background())
+			So(err, ShouldBeNil)
+			So(removed, ShouldBeEmpty)
+
+			n, err := r.Enqueue(context.Background())
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 0)
+
+			r.now = func() time.Time { return now.AddDate(0, 0, 2).Add(time.Minute) }
+
+			removed, err = r.Reap(context.Background())
+			So(err, ShouldBeNil)
+			So(len(removed), ShouldEqual, 1)
+			So(removed[0].ID, ShouldEqual, 2)
+		})
+
+		Convey("Things at protected addresses are logged and left alone", func() {
+			r.SetProtection(&policy.Protection{DenyPatterns: []string{"b/*"}})
+
+			removed, err := r.Reap(context.Background())
+			So(err, ShouldBeNil)
+			So(removed, ShouldBeEmpty)
+			So(mr.removed, ShouldBeEmpty)
+			So(mdb.things[1].Removed, ShouldBeFalse)
+			So(logs.String(), ShouldContainSubstring, "refusing to remove thing 2: "+policy.ErrProtected.Error()+
+				": b/b matches b/*")
+		})
+
+		Convey("Expired things with a quarantiner are quarantined, then removed after the quarantine period", func() {
+			mq := &mockQuarantiner{}
+			backends[database.ThingsTypeDir] = mq
+			useBackends(t, backends)
+			r.SetQuarantinePeriod(2 * time.Hour)
+			mdb.subs[4] = []database.User{user}
+
+			removed, err := r.Reap(context.Background())
+			So(err, ShouldBeNil)
+			So(len(removed), ShouldEqual, 1)
+			So(removed[0].ID, ShouldEqual, 2)
+			So(mq.quarantined, ShouldResemble, []uint32{4})
+			So(mdb.things[3].Removed, ShouldBeFalse)
+			So(mdb.things[3].Quarantine.String, ShouldEqual, "/quarantine/d")
+			So(mdb.things[3].QuarantineUntil.Time, ShouldEqual, now.Add(2*time.Hour))
+
+			So(len(mdb.audit), ShouldEqual, 2)
+			So(mdb.audit[1].ThingID, ShouldEqual, 4)
+			So(mdb.audit[1].Action, ShouldEqual, database.AuditQuarantined)
+			So(mdb.audit[1].Detail, ShouldContainSubstring, "/quarantine/d")
+
+			So(len(mn.messages), ShouldEqual, 2)
+			So(mn.messages[1].subject, ShouldEqual, "tt: /d has been quarantined")
+			So(mn.messages[1].body, ShouldContainSubstring, "tt restore 4")
+
+			removed, err = r.Reap(context.Background())
+			So(err, ShouldBeNil)
+			So(removed, ShouldBeEmpty)
+			So(mq.quarantined, ShouldResemble, []uint32{4})
+			So(mq.purged, ShouldBeEmpty)
+
+			r.now = func() time.Time { return now.Add(3 * time.Hour) }
+
+			removed, err = r.Reap(context.Background())
+			So(err, ShouldBeNil)
+			So(len(removed), ShouldEqual, 1)
+			So(removed[0].ID, ShouldEqual, 4)
+			So(mq.purged, ShouldResemble, []uint32{4})
+			So(mdb.things[3].Removed, ShouldBeTrue)
+			So(mdb.audit[2].Action, ShouldEqual, database.AuditRemoved)
+			So(mdb.audit[2].Detail, ShouldEqual, purgeDetail)
+			So(logs.String(), ShouldBeBlank)
+		})
+
+		Convey("Expired things that should be archived are archived instead", func() {
+			ma := &mockArchiver{}
+			backends[database.ThingsTypeDir] = ma
+			useBackends(t, backends)
+			mdb.things[3].OnExpiry = database.ExpiryArchive
+			mdb.subs[4] = []database.User{user}
+
+			removed, err := r.Reap(context.Background())
+			So(err, ShouldBeNil)
+			So(len(removed), ShouldEqual, 2)
+			So(removed[1].ID, ShouldEqual, 4)
+			So(ma.archived, ShouldResemble, []uint32{4})
+			So(mdb.things[3].Removed, ShouldBeTrue)
+			So(mdb.things[3].Archive.String, ShouldEqual, "/archive/d.tar.gz")
+			So(mdb.audit[1].Detail, ShouldEqual, "removal date passed; archived to /archive/d.tar.gz")
+			So(mn.messages[1].body, ShouldContainSubstring, "An archive of it has been kept at /archive/d.tar.gz")
+
+			Convey("unless that fails", func() {
+				mdb.things[3].Removed = false
+				ma.err = errors.New("disk full")
+
+				_, err := r.Reap(context.Background())
+				So(err, ShouldBeNil)
+				So(mdb.things[3].Removed, ShouldBeFalse)
+				So(logs.String(), ShouldContainSubstring, "archiving thing 4 (/d) failed: disk full")
+			})
+
+			Convey("but the archive is still recorded if removal fails afterwards", func() {
+				mdb.things[3].Removed = false
+				mdb.things[3].Archive.Valid = false
+				ma.removeErr = errors.New("permission denied")
+
+				_, err := r.Reap(context.Background())
+				So(err, ShouldBeNil)
+				So(mdb.things[3].Removed, ShouldBeFalse)
+				So(mdb.things[3].Archive.String, ShouldEqual, "/archive/d.tar.gz")
+				So(logs.String(), ShouldContainSubstring,
+					"removing thing 4 (/d) after archiving it to /archive/d.tar.gz failed: permission denied")
+			})
+
+			Convey("or their type can't be archived", func() {
+				mdb.things[1].Removed = false
+				mdb.things[1].OnExpiry = database.ExpiryArchive
+
+				removed, err := r.Reap(context.Background())
+				So(err, ShouldBeNil)
+				So(removed, ShouldBeEmpty)
+				So(mr.removed, ShouldResemble, []uint32{2})
+				So(logs.String(), ShouldContainSubstring, "thing 2 (b/b) can't be archived")
+			})
+		})
+
+		Convey("Expired things that have changed since they were fingerprinted are flagged instead", func() {
+			mf := &mockFingerprinter{fingerprints: make(map[string]*database.Fingerprint)}
+			backends[database.ThingsTypeDir] = mf
+			useBackends(t, backends)
+			mdb.subs[4] = []database.User{user}
+
+			fingerprint := &database.Fingerprint{Size: 10, Files: 2, Modified: past.Add(-time.Hour)}
+			mdb.things[3].Fingerprint = fingerprint
+			mf.fingerprints["/d"] = &database.Fingerprint{Size: 20, Files: 2, Modified: past.Add(-time.Hour)}
+
+			_, err := r.Reap(context.Background())
+			So(err, ShouldBeNil)
+			So(mf.quarantined, ShouldBeEmpty)
+			So(mdb.things[3].Changed.Valid, ShouldBeTrue)
+			So(mdb.audit[1].ThingID, ShouldEqual, 4)
+			So(mdb.audit[1].Action, ShouldEqual, database.AuditChanged)
+			So(mdb.audit[1].Detail, ShouldContainSubstring, "size increased from 10 to 20 bytes")
+			So(len(mn.messages), ShouldEqual, 2)
+			So(mn.messages[1].subject, ShouldEqual, "tt: /d has changed, so has not been removed")
+			So(mn.messages[1].body, ShouldContainSubstring, "confirm its removal")
+
+			_, err = r.Reap(context.Background())
+			So(err, ShouldBeNil)
+			So(mf.quarantined, ShouldBeEmpty)
+			So(len(mn.messages), ShouldEqual, 2)
+
+			Convey("but they can be once their fingerprint is updated", func() {
+				mdb.things[3].Changed = null.Time{}
+				mdb.things[3].Fingerprint = mf.fingerprints["/d"]
+
+				_, err = r.Reap(context.Background())
+				So(err, ShouldBeNil)
+				So(mf.quarantined, ShouldResemble, []uint32{4})
+			})
+		})
+
+		Convey("Expired things that haven't materially changed are still removed", func() {
+			mf := &mockFingerprinter{fingerprints: make(map[string]*database.Fingerprint)}
+			backends[database.ThingsTypeDir] = mf
+			useBackends(t, backends)
+
+			mdb.things[3].Fingerprint = &database.Fingerprint{Size: 10, Files: 2, Modified: past}
+			mf.fingerprints["/d"] = &database.Fingerprint{Size: 5, Files: 1, Modified: past}
+
+			_, err := r.Reap(context.Background())
+			So(err, ShouldBeNil)
+			So(mf.quarantined, ShouldResemble, []uint32{4})
+			So(mdb.things[3].Changed.Valid, ShouldBeFalse)
+
+			Convey("but not if they can't be fingerprinted", func() {
+				mdb.things[3].Quarantine = null.String{}
+				mdb.things[3].QuarantineUntil = null.Time{}
+				mf.fingerprintErr = errors.New("permission denied")
+
+				_, err := r.Reap(context.Background())
+				So(err, ShouldBeNil)
+				So(mf.quarantined, ShouldResemble, []uint32{4})
+				So(logs.String(), ShouldContainSubstring, "fingerprinting thing 4 (/d) failed: permission denied")
+			})
+		})
+
+		Convey("Subscribers of expired things that are only to be notified about are told once", func() {
+			mdb.things[1].OnExpiry = database.ExpiryNotify
+
+			removed, err := r.Reap(context.Background())
+			So(err, ShouldBeNil)
+			So(removed, ShouldBeEmpty)
+			So(mr.removed, ShouldBeEmpty)
+			So(mdb.things[1].Removed, ShouldBeFalse)
+			So(mdb.things[1].Expired.Valid, ShouldBeTrue)
+			So(mdb.audit[0].Action, ShouldEqual, database.AuditExpired)
+			So(len(mn.messages), ShouldEqual, 1)
+			So(mn.messages[0].subject, ShouldEqual, "tt: b/b has passed its removal date")
+
+			_, err = r.Reap(context.Background())
+			So(err, ShouldBeNil)
+			So(len(mn.messages), ShouldEqual, 1)
+			So(len(mdb.audit), ShouldEqual, 1)
+		})
+
+		Convey("Things that fail to be quarantined are logged and left alone", func() {
+			backends[database.ThingsTypeDir] = &mockQuarantiner{err: errors.New("rename failed")}
+			useBackends(t, backends)
+
+			_, err := r.Reap(context.Background())
+			So(err, ShouldBeNil)
+			So(mdb.things[3].Quarantined(), ShouldBeFalse)
+			So(logs.String(), ShouldContainSubstring, "quarantining thing 4 (/d) failed: rename failed")
+		})
+	})
+}
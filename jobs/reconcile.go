@@ -0,0 +1,208 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/wtsi-hgi/tt/backend"
+	"github.com/wtsi-hgi/tt/database"
+	"github.com/wtsi-hgi/tt/notify"
+)
+
+const (
+	reconcileDetail = "address no longer exists; it was removed outside of tt"
+
+	// DefaultMaxMissing is the default for the most things a single
+	// reconciliation can find missing before it assumes something is wrong,
+	// such as a filesystem not being mounted, and marks none of them removed.
+	DefaultMaxMissing = 100
+
+	ErrTooManyMissing = database.Error("Too many things are missing, so none were marked as removed")
+)
+
+// Reconciler finds live Things whose address no longer exists according to the
+// backend for their ThingsType, and marks them as removed.
+type Reconciler struct {
+	db         database.Queries
+	notifier   notify.Notifier
+	logger     *log.Logger
+	maxMissing int
+}
+
+// NewReconciler returns a Reconciler that checks the things in the given
+// database using the backends registered for their types, and tells
+// subscribers about things it marks as removed using the given notifier (which
+// can be nil to not notify anyone). Problems are logged to the given logger.
+func NewReconciler(db database.Queries, notifier notify.Notifier, logger *log.Logger) *Reconciler {
+	return &Reconciler{
+		db:         db,
+		notifier:   notifier,
+		logger:     logger,
+		maxMissing: DefaultMaxMissing,
+	}
+}
+
+// SetMaxMissing sets the most things a single reconciliation can find missing
+// and still mark them removed. The default is DefaultMaxMissing; n < 1 means no
+// limit.
+func (r *Reconciler) SetMaxMissing(n int) {
+	r.maxMissing = n
+}
+
+// Reconcile checks if every live thing still exists (ignoring those in
+// quarantine, which have been moved away on purpose, those on hold, and those
+// with a queued or running removal job), and returns the ones that don't.
+// Things whose backend is a RootChecker are only considered missing if the
+// location their address is in still exists.
+//
+// Unless dryRun is true, the missing things are then marked as removed, the
+// fact that they were removed externally is recorded in their history, and
+// their subscribers are notified. If more things are missing than allowed by
+// SetMaxMissing(), none of them are marked and ErrTooManyMissing is returned.
+//
+// Failure to check or update an individual thing is logged, but does not stop
+// the others being reconciled. Returns an error if the things couldn't be
+// retrieved, or ctx is done.
+func (r *Reconciler) Reconcile(ctx context.Context, dryRun bool) ([]database.Thing, error) {
+	missing, err := r.findMissing(ctx)
+	if err != nil || dryRun {
+		return missing, err
+	}
+
+	if r.maxMissing > 0 && len(missing) > r.maxMissing {
+		return missing, ErrTooManyMissing
+	}
+
+	for i := range missing {
+		thing := &missing[i]
+
+		if err := r.markRemoved(thing); err != nil {
+			r.logger.Printf("marking thing %d (%s) removed failed: %s", thing.ID, thing.Address, err)
+		}
+	}
+
+	return missing, nil
+}
+
+// findMissing returns the live things that Reconcile() should consider
+// removed.
+func (r *Reconciler) findMissing(ctx context.Context) ([]database.Thing, error) {
+	things, err := liveThings(r.db)
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []database.Thing
+
+	now := time.Now()
+
+	for i := range things {
+		if err := ctx.Err(); err != nil {
+			return missing, err
+		}
+
+		thing := &things[i]
+
+		b := thing.Type.Backend()
+		if b == nil || thing.Quarantined() || thing.Held(now) ||
+			thing.Job == database.JobQueued || thing.Job == database.JobRunning {
+			continue
+		}
+
+		gone, err := r.gone(ctx, b, thing)
+		if err != nil {
+			r.logger.Printf("checking thing %d (%s) failed: %s", thing.ID, thing.Address, err)
+
+			continue
+		}
+
+		if gone {
+			missing = append(missing, *thing)
+		}
+	}
+
+	return missing, nil
+}
+
+// gone returns true if nothing exists at the thing's address. If its backend is
+// a RootChecker, the location its address is in must still exist too, else the
+// thing is logged and left alone.
+func (r *Reconciler) gone(ctx context.Context, b backend.Backend, thing *database.Thing) (bool, error) {
+	exists, err := b.Exists(ctx, thing)
+	if err != nil || exists {
+		return false, err
+	}
+
+	rc, ok := backend.RootCheckerFor(thing.Type)
+	if !ok {
+		return true, nil
+	}
+
+	rootExists, err := rc.RootExists(ctx, thing)
+	if err != nil {
+		return false, err
+	}
+
+	if !rootExists {
+		r.logger.Printf("thing %d (%s) is missing, but so is the location it was in, so it was left alone",
+			thing.ID, thing.Address)
+	}
+
+	return rootExists, nil
+}
+
+func (r *Reconciler) markRemoved(thing *database.Thing) error {
+	if err := r.db.MarkRemoved(thing.ID); err != nil {
+		return err
+	}
+
+	if err := r.db.AddAuditEvent(database.AuditEvent{
+		ThingID: thing.ID,
+		Action:  database.AuditRemovedExternally,
+		Detail:  reconcileDetail,
+	}); err != nil {
+		return err
+	}
+
+	return notifySubscribers(r.db, r.notifier, thing,
+		fmt.Sprintf("tt: %s no longer exists", thing.Address),
+		fmt.Sprintf("The %s %s, registered with tt because \"%s\", no longer exists.\n\n"+
+			"It has been marked as removed, and you don't need to do anything.\n",
+			thing.Type, thing.Address, thing.Reason))
+}
+
+// Run calls Reconcile() now and then every interval, until ctx is done.
+func (r *Reconciler) Run(ctx context.Context, interval time.Duration) {
+	runEvery(ctx, interval, "reconciling", r.logger, func(ctx context.Context) error {
+		_, err := r.Reconcile(ctx, false)
+
+		return err
+	})
+}
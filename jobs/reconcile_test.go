@@ -0,0 +1,169 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	null "github.com/guregu/null/v5"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/wtsi-hgi/tt/backend"
+	"github.com/wtsi-hgi/tt/database"
+)
+
+func TestReconciler(t *testing.T) {
+	Convey("Given a database of things, some of which no longer exist", t, func() {
+		mdb := newMockDB(
+			database.Thing{ID: 1, Address: "/a", Type: database.ThingsTypeDir},
+			database.Thing{ID: 2, Address: "/b", Type: database.ThingsTypeDir, Reason: "testing"},
+			database.Thing{ID: 3, Address: "/c", Type: database.ThingsTypeDir, Removed: true},
+			database.Thing{ID: 4, Address: "/d", Type: database.ThingsTypeS3},
+			database.Thing{ID: 5, Address: "/e", Type: database.ThingsTypeDir,
+				Quarantine: null.StringFrom("/q/e"), QuarantineUntil: null.TimeFrom(time.Now())},
+			database.Thing{ID: 6, Address: "/f", Type: database.ThingsTypeDir, HoldReason: null.StringFrom("r")},
+			database.Thing{ID: 7, Address: "/g", Type: database.ThingsTypeDir, Job: database.JobRunning},
+		)
+
+		user := database.User{ID: 1, Name: "user", Email: "user@example.com"}
+		mdb.subs[2] = []database.User{user}
+
+		mb := &mockBackend{results: map[string]*database.ProbeResult{"/a": {Exists: true}}}
+		backends := map[database.ThingsType]backend.Backend{database.ThingsTypeDir: mb}
+		useBackends(t, backends)
+		mn := &mockNotifier{}
+		logger, logs := newTestLogger()
+		r := NewReconciler(mdb, mn, logger)
+
+		Convey("You can do a dry run to find the missing things", func() {
+			missing, err := r.Reconcile(context.Background(), true)
+			So(err, ShouldBeNil)
+			So(len(missing), ShouldEqual, 1)
+			So(missing[0].ID, ShouldEqual, 2)
+			So(mdb.things[1].Removed, ShouldBeFalse)
+			So(mdb.audit, ShouldBeEmpty)
+			So(mn.messages, ShouldBeEmpty)
+		})
+
+		Convey("You can mark missing things removed, recording it and notifying subscribers", func() {
+			missing, err := r.Reconcile(context.Background(), false)
+			So(err, ShouldBeNil)
+			So(len(missing), ShouldEqual, 1)
+			So(mdb.things[0].Removed, ShouldBeFalse)
+			So(mdb.things[1].Removed, ShouldBeTrue)
+			So(mdb.things[3].Removed, ShouldBeFalse)
+
+			So(len(mdb.audit), ShouldEqual, 1)
+			So(mdb.audit[0].ThingID, ShouldEqual, 2)
+			So(mdb.audit[0].Action, ShouldEqual, database.AuditRemovedExternally)
+			So(mdb.audit[0].Actor, ShouldBeBlank)
+
+			So(len(mn.messages), ShouldEqual, 1)
+			So(mn.messages[0].users, ShouldResemble, []database.User{user})
+			So(mn.messages[0].subject, ShouldContainSubstring, "/b")
+			So(mn.messages[0].body, ShouldContainSubstring, "testing")
+			So(logs.String(), ShouldBeBlank)
+
+			missing, err = r.Reconcile(context.Background(), false)
+			So(err, ShouldBeNil)
+			So(missing, ShouldBeEmpty)
+		})
+
+		Convey("Things that can't be checked are logged and left alone", func() {
+			mb.err = errors.New("check failed")
+
+			missing, err := r.Reconcile(context.Background(), false)
+			So(err, ShouldBeNil)
+			So(missing, ShouldBeEmpty)
+			So(mdb.things[1].Removed, ShouldBeFalse)
+			So(logs.String(), ShouldContainSubstring, "checking thing 2 (/b) failed: check failed")
+		})
+
+		Convey("Things aren't marked removed if the location they were in is missing", func() {
+			mrc := &mockRootChecker{mockBackend: mb, roots: map[string]bool{}}
+			useBackends(t, map[database.ThingsType]backend.Backend{database.ThingsTypeDir: mrc})
+
+			missing, err := r.Reconcile(context.Background(), false)
+			So(err, ShouldBeNil)
+			So(missing, ShouldBeEmpty)
+			So(mdb.things[1].Removed, ShouldBeFalse)
+			So(logs.String(), ShouldContainSubstring, "thing 2 (/b) is missing, but so is the location it was in")
+
+			mrc.roots["/b"] = true
+
+			missing, err = r.Reconcile(context.Background(), false)
+			So(err, ShouldBeNil)
+			So(len(missing), ShouldEqual, 1)
+			So(mdb.things[1].Removed, ShouldBeTrue)
+		})
+
+		Convey("Nothing is marked removed if too many things are missing", func() {
+			mdb.things[0].Address = "/missing"
+			r.SetMaxMissing(1)
+
+			missing, err := r.Reconcile(context.Background(), false)
+			So(err, ShouldEqual, ErrTooManyMissing)
+			So(len(missing), ShouldEqual, 2)
+			So(mdb.things[0].Removed, ShouldBeFalse)
+			So(mdb.things[1].Removed, ShouldBeFalse)
+			So(mn.messages, ShouldBeEmpty)
+
+			missing, err = r.Reconcile(context.Background(), true)
+			So(err, ShouldBeNil)
+			So(len(missing), ShouldEqual, 2)
+
+			r.SetMaxMissing(0)
+
+			missing, err = r.Reconcile(context.Background(), false)
+			So(err, ShouldBeNil)
+			So(len(missing), ShouldEqual, 2)
+			So(mdb.things[0].Removed, ShouldBeTrue)
+		})
+
+		Convey("Without a notifier, nobody is notified", func() {
+			r = NewReconciler(mdb, nil, logger)
+
+			missing, err := r.Reconcile(context.Background(), false)
+			So(err, ShouldBeNil)
+			So(len(missing), ShouldEqual, 1)
+			So(mdb.things[1].Removed, ShouldBeTrue)
+			So(mn.messages, ShouldBeEmpty)
+		})
+	})
+}
+
+// mockRootChecker is a mockBackend that is also a backend.RootChecker, where
+// the location of the things with the addresses in roots exists.
+type mockRootChecker struct {
+	*mockBackend
+	roots map[string]bool
+}
+
+func (m *mockRootChecker) RootExists(_ context.Context, thing *database.Thing) (bool, error) {
+	return m.roots[thing.Address], nil
+}
@@ -0,0 +1,83 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/wtsi-hgi/tt/backend"
+	"github.com/wtsi-hgi/tt/database"
+)
+
+// DefaultRestoreExtension is how long after being restored a thing's new
+// removal date is, if one isn't specified.
+const DefaultRestoreExtension = 30 * 24 * time.Hour
+
+// Restore moves the data of the quarantined thing with the given ID back to its
+// address, using the backend.Quarantiner for its type. The thing is given the
+// new removal date remove (or DefaultRestoreExtension from now, if zero), and
+// the restoration is recorded in its history as being done by actor.
+//
+// Returns the restored thing, or database.ErrNotQuarantined if it isn't in
+// quarantine.
+func Restore(ctx context.Context, db database.Queries, id uint32, actor string,
+	remove time.Time) (*database.Thing, error) {
+	thing, err := db.GetThing(id)
+	if err != nil {
+		return nil, err
+	}
+
+	quarantiner, ok := backend.QuarantinerFor(thing.Type)
+	if !thing.Quarantined() || !ok {
+		return nil, database.ErrNotQuarantined
+	}
+
+	if remove.IsZero() {
+		remove = time.Now().Add(DefaultRestoreExtension).Truncate(24 * time.Hour)
+	}
+
+	if err = quarantiner.Restore(ctx, thing); err != nil {
+		return nil, err
+	}
+
+	if err = db.MarkRestored(thing.ID, remove); err != nil {
+		return nil, err
+	}
+
+	if err = db.AddAuditEvent(database.AuditEvent{
+		ThingID: thing.ID,
+		Actor:   actor,
+		Action:  database.AuditRestored,
+		Detail: fmt.Sprintf("restored from %s; removal date changed from %s to %s", thing.Quarantine.String,
+			thing.Remove.Format(time.DateOnly), remove.Format(time.DateOnly)),
+	}); err != nil {
+		return nil, err
+	}
+
+	return db.GetThing(thing.ID)
+}
@@ -0,0 +1,102 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	null "github.com/guregu/null/v5"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/wtsi-hgi/tt/backend"
+	"github.com/wtsi-hgi/tt/database"
+)
+
+func TestRestore(t *testing.T) {
+	Convey("Given a database with a quarantined thing", t, func() {
+		remove := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+		mdb := newMockDB(
+			database.Thing{ID: 1, Address: "/a", Type: database.ThingsTypeDir, Remove: remove},
+			database.Thing{ID: 2, Address: "/b", Type: database.ThingsTypeDir, Remove: remove,
+				Quarantine: null.StringFrom("/quarantine/b"), QuarantineUntil: null.TimeFrom(remove.AddDate(0, 0, 14))},
+		)
+
+		mq := &mockQuarantiner{}
+		backends := map[database.ThingsType]backend.Backend{database.ThingsTypeDir: mq}
+		useBackends(t, backends)
+		ctx := context.Background()
+
+		Convey("You can restore it with a new removal date, recording who did it", func() {
+			newRemove := remove.AddDate(0, 1, 0)
+
+			thing, err := Restore(ctx, mdb, 2, "user", newRemove)
+			So(err, ShouldBeNil)
+			So(thing.Quarantined(), ShouldBeFalse)
+			So(thing.Remove, ShouldEqual, newRemove)
+			So(mq.restored, ShouldResemble, []uint32{2})
+
+			So(len(mdb.audit), ShouldEqual, 1)
+			So(mdb.audit[0].Actor, ShouldEqual, "user")
+			So(mdb.audit[0].Action, ShouldEqual, database.AuditRestored)
+			So(mdb.audit[0].Detail, ShouldEqual,
+				"restored from /quarantine/b; removal date changed from 2025-06-01 to 2025-07-01")
+
+			_, err = Restore(ctx, mdb, 2, "user", newRemove)
+			So(err, ShouldEqual, database.ErrNotQuarantined)
+		})
+
+		Convey("Without a removal date, it gets the default extension", func() {
+			thing, err := Restore(ctx, mdb, 2, "", time.Time{})
+			So(err, ShouldBeNil)
+			So(thing.Remove, ShouldHappenAfter, time.Now().Add(DefaultRestoreExtension-48*time.Hour))
+		})
+
+		Convey("You can't restore things that aren't quarantined, don't exist or have no quarantiner", func() {
+			_, err := Restore(ctx, mdb, 1, "", time.Time{})
+			So(err, ShouldEqual, database.ErrNotQuarantined)
+
+			_, err = Restore(ctx, mdb, 3, "", time.Time{})
+			So(err, ShouldEqual, database.ErrNoThing)
+
+			useBackends(t, nil)
+
+			_, err = Restore(ctx, mdb, 2, "", time.Time{})
+			So(err, ShouldEqual, database.ErrNotQuarantined)
+		})
+
+		Convey("Failure to restore is returned, and the thing stays quarantined", func() {
+			mq.err = errors.New("rename failed")
+
+			_, err := Restore(ctx, mdb, 2, "", time.Time{})
+			So(err, ShouldEqual, mq.err)
+			So(mdb.things[1].Quarantined(), ShouldBeTrue)
+			So(mdb.audit, ShouldBeEmpty)
+		})
+	})
+}
@@ -0,0 +1,426 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	null "github.com/guregu/null/v5"
+	"github.com/wtsi-hgi/tt/database"
+	"github.com/wtsi-hgi/tt/links"
+	"github.com/wtsi-hgi/tt/notify"
+	"github.com/wtsi-hgi/tt/policy"
+)
+
+const (
+	DefaultFirstWarning  = 14 * 24 * time.Hour
+	DefaultSecondWarning = 3 * 24 * time.Hour
+)
+
+// Warner warns the subscribers of live Things that are due to be removed soon,
+// based on when their policy.Schedule says they'll actually be removed: once
+// when that's within the first warning period, and again when it's within the
+// second.
+type Warner struct {
+	db       database.Queries
+	notifier notify.Notifier
+	logger   *log.Logger
+	now      func() time.Time
+	first    time.Duration
+	second   time.Duration
+	links    *links.Signer
+	schedule *policy.Schedule
+}
+
+// NewWarner returns a Warner that warns about things in the given database
+// using the given notifier (which can be nil to only record warnings as if
+// they had been sent). Problems are logged to the given logger.
+func NewWarner(db database.Queries, notifier notify.Notifier, logger *log.Logger) *Warner {
+	return &Warner{
+		db:       db,
+		notifier: notifier,
+		logger:   logger,
+		now:      time.Now,
+		first:    DefaultFirstWarning,
+		second:   DefaultSecondWarning,
+	}
+}
+
+// SetPeriods sets how long before their removal date the first and second
+// warnings about things are sent. The defaults are DefaultFirstWarning and
+// DefaultSecondWarning.
+func (w *Warner) SetPeriods(first, second time.Duration) {
+	w.first = first
+	w.second = second
+}
+
+// SetSchedule sets the policy.Schedule that decides when things that are due
+// will actually be removed, which is the date they're warned about. It should
+// be the same as the Reaper's. The default is nil, which warns about their
+// EffectiveRemove() date.
+func (w *Warner) SetSchedule(schedule *policy.Schedule) {
+	w.schedule = schedule
+}
+
+// SetLinks makes warnings include one-click links, signed by the given Signer,
+// that let each subscriber extend the thing's removal date, unsubscribe from it
+// or confirm its removal without logging in. The links expire when the thing is
+// due for removal. Each subscriber is then sent their own copy of each warning.
+func (w *Warner) SetLinks(signer *links.Signer) {
+	w.links = signer
+}
+
+// Warn sends the warnings that are due for every live thing that isn't in
+// quarantine or on hold, recording them in the database and in the things'
+// history, and returns the number sent. A thing whose removal date gets closer
+// than the second warning period without having been warned only gets the
+// second warning.
+//
+// Things whose removal date has moved later than a warning period, such as
+// those that expire after they were last used, have that warning forgotten, so
+// that they'll be warned again.
+//
+// Subscribers who have snoozed warnings about a thing aren't sent them until
+// their snooze ends, when they're reminded of any they missed.
+//
+// Failure to warn about an individual thing is logged, but does not stop the
+// others being warned about. Returns an error if the things couldn't be
+// retrieved, or ctx is done.
+func (w *Warner) Warn(ctx context.Context) (int, error) {
+	things, err := liveThings(w.db)
+	if err != nil {
+		return 0, err
+	}
+
+	now := w.now()
+	n := 0
+
+	w.remindSnoozed(things, now)
+
+	for i := range things {
+		if err := ctx.Err(); err != nil {
+			return n, err
+		}
+
+		thing := &things[i]
+		if thing.Quarantined() || thing.Held(now) {
+			continue
+		}
+
+		sent, err := w.warn(thing, now)
+		if err != nil {
+			w.logger.Printf("warning about thing %d (%s) failed: %s", thing.ID, thing.Address, err)
+		}
+
+		if sent {
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// warn sends the warning about the thing that is due now, if any, returning
+// true if one was sent.
+func (w *Warner) warn(thing *database.Thing, now time.Time) (bool, error) {
+	remove := w.schedule.Scheduled(thing)
+	if !now.Before(remove) {
+		return false, nil
+	}
+
+	left := remove.Sub(now)
+	warned1, warned2 := thing.Warned1, thing.Warned2
+
+	if left > w.first {
+		warned1 = null.Time{}
+	}
+
+	if left > w.second {
+		warned2 = null.Time{}
+	}
+
+	var which string
+
+	switch {
+	case left <= w.second && !warned2.Valid:
+		which = "second"
+		warned2 = null.TimeFrom(now)
+
+		if !warned1.Valid {
+			warned1 = warned2
+		}
+	case left <= w.first && !warned1.Valid:
+		which = "first"
+		warned1 = null.TimeFrom(now)
+	default:
+		if warned1.Equal(thing.Warned1) && warned2.Equal(thing.Warned2) {
+			return false, nil
+		}
+
+		return false, w.db.SetWarned(thing.ID, warned1, warned2)
+	}
+
+	if err := w.db.SetWarned(thing.ID, warned1, warned2); err != nil {
+		return false, err
+	}
+
+	date := remove.Format(time.DateOnly)
+
+	if err := w.db.AddAuditEvent(database.AuditEvent{
+		ThingID: thing.ID,
+		Action:  database.AuditWarned,
+		Detail:  fmt.Sprintf("%s warning of removal on %s", which, date),
+	}); err != nil {
+		return false, err
+	}
+
+	return true, w.notifyUndecided(thing, now, remove,
+		fmt.Sprintf("tt: %s is due for removal on %s", thing.Address, date),
+		warningBody(thing, date, left > w.second))
+}
+
+// notifyUndecided sends the given warning about the thing's removal at the
+// given time to its subscribers, except those who have already confirmed that
+// it can be removed then, and those who have snoozed warnings about it, whose
+// snoozes are marked as having missed it instead.
+func (w *Warner) notifyUndecided(thing *database.Thing, now, remove time.Time, subject, body string) error {
+	if w.notifier == nil {
+		return nil
+	}
+
+	users, err := w.db.GetSubscribers(thing.ID)
+	if err != nil || len(users) == 0 {
+		return err
+	}
+
+	snoozes, err := w.db.GetSnoozes(thing.ID)
+	if err != nil {
+		return err
+	}
+
+	confirmations, err := w.db.GetConfirmations(thing.ID)
+	if err != nil {
+		return err
+	}
+
+	var undecided []database.User
+
+	for _, user := range users {
+		if confirmedBy(confirmations, user.ID, remove) {
+			continue
+		}
+
+		active := func(s database.Snooze) bool { return s.User.ID == user.ID && s.Active(now) }
+
+		if !slices.ContainsFunc(snoozes, active) {
+			undecided = append(undecided, user)
+
+			continue
+		}
+
+		if err = w.db.MarkSnoozeMissed(thing.ID, user.ID); err != nil {
+			return err
+		}
+	}
+
+	if len(undecided) == 0 {
+		return nil
+	}
+
+	return w.notify(thing, undecided, subject, body)
+}
+
+// confirmedBy returns true if one of the confirmations is by the user with the
+// given ID, of removal at the given time.
+func confirmedBy(confirmations []database.Confirmation, userID uint32, remove time.Time) bool {
+	return slices.ContainsFunc(confirmations, func(c database.Confirmation) bool {
+		return c.User.ID == userID && c.Covers(remove)
+	})
+}
+
+// notify sends the given warning about the thing to the given users, each with
+// their own one-click links if SetLinks() was used.
+func (w *Warner) notify(thing *database.Thing, users []database.User, subject, body string) error {
+	if w.links == nil {
+		return w.notifier.Notify(users, subject, body)
+	}
+
+	errs := make([]error, len(users))
+
+	for i, user := range users {
+		linksBody, err := w.linksBody(thing, user)
+		if err == nil {
+			err = w.notifier.Notify([]database.User{user}, subject, body+linksBody)
+		}
+
+		errs[i] = err
+	}
+
+	return errors.Join(errs...)
+}
+
+// linksBody returns a paragraph of one-click links for the given user to act on
+// the thing. The link to snooze warnings is only included before the second
+// warning period, and only works until then.
+func (w *Warner) linksBody(thing *database.Thing, user database.User) (string, error) {
+	expires := w.schedule.Scheduled(thing)
+	args := []any{expires.Format(time.DateOnly), links.ExtendDays}
+
+	for _, action := range []links.Action{links.Extend, links.Unsubscribe, links.ConfirmRemoval} {
+		url, err := w.links.URL(action, thing.ID, user.ID, expires)
+		if err != nil {
+			return "", err
+		}
+
+		args = append(args, url)
+	}
+
+	body := fmt.Sprintf("\nOr, without logging in, use one of these links (each works once, until %s):\n"+
+		"Extend its removal date by %d days: %s\n"+
+		"Stop being told about it: %s\n"+
+		"Confirm it can be removed, and not be warned about it again: %s\n", args...)
+
+	snoozeBy := expires.Add(-w.second)
+	if !w.now().Before(snoozeBy) {
+		return body, nil
+	}
+
+	url, err := w.links.URL(links.Snooze, thing.ID, user.ID, snoozeBy)
+	if err != nil {
+		return "", err
+	}
+
+	return body + fmt.Sprintf("Stop being warned about it for %d days, without extending it (works until %s): %s\n",
+		database.DefaultSnoozeDays, snoozeBy.Format(time.DateOnly), url), nil
+}
+
+// remindSnoozed deletes the snoozes that have ended, first reminding their
+// users of any warnings they missed about the given things, if those are still
+// due for removal.
+func (w *Warner) remindSnoozed(things []database.Thing, now time.Time) {
+	snoozes, err := w.db.GetEndedSnoozes(now)
+	if err != nil {
+		w.logger.Printf("getting ended snoozes failed: %s", err)
+
+		return
+	}
+
+	for _, snooze := range snoozes {
+		if err = w.remind(things, snooze, now); err != nil {
+			w.logger.Printf("reminding %s about thing %d after their snooze failed: %s",
+				snooze.User.Name, snooze.ThingID, err)
+
+			continue
+		}
+
+		if err = w.db.DeleteSnooze(snooze.ThingID, snooze.User.ID); err != nil {
+			w.logger.Printf("deleting snooze of thing %d by %s failed: %s", snooze.ThingID, snooze.User.Name, err)
+		}
+	}
+}
+
+// remind sends the snooze's user a reminder of the warning they missed about
+// its thing, if any, and if the thing is one of the given ones and is still
+// due for removal, unless they have since confirmed that removal. The reminder
+// is recorded in the thing's history.
+func (w *Warner) remind(things []database.Thing, snooze database.Snooze, now time.Time) error {
+	i := slices.IndexFunc(things, func(t database.Thing) bool { return t.ID == snooze.ThingID })
+	if !snooze.Missed || i == -1 {
+		return nil
+	}
+
+	thing := &things[i]
+	remove := w.schedule.Scheduled(thing)
+
+	if thing.Quarantined() || thing.Held(now) || !now.Before(remove) {
+		return nil
+	}
+
+	confirmations, err := w.db.GetConfirmations(thing.ID)
+	if err != nil || confirmedBy(confirmations, snooze.User.ID, remove) {
+		return err
+	}
+
+	date := remove.Format(time.DateOnly)
+
+	if err := w.db.AddAuditEvent(database.AuditEvent{
+		ThingID: thing.ID,
+		Action:  database.AuditWarned,
+		Detail:  fmt.Sprintf("reminded %s of removal on %s after their snooze", snooze.User.Name, date),
+	}); err != nil {
+		return err
+	}
+
+	if w.notifier == nil {
+		return nil
+	}
+
+	return w.notify(thing, []database.User{snooze.User},
+		fmt.Sprintf("tt: %s is due for removal on %s", thing.Address, date),
+		warningBody(thing, date, remove.Sub(now) > w.second))
+}
+
+// warningBody returns the body of a warning that the thing is due for removal
+// on the given date. If snoozable, it says how to snooze further warnings,
+// which can only be done before the second warning period.
+func warningBody(thing *database.Thing, date string, snoozable bool) string {
+	body := fmt.Sprintf("The %s %s, registered with tt because \"%s\", is due for removal on %s.\n",
+		thing.Type, thing.Address, thing.Reason, date)
+
+	switch thing.OnExpiry {
+	case database.ExpiryArchive:
+		body += "It will be archived before it is removed.\n"
+	case database.ExpiryNotify:
+		body += "tt won't remove it, but you will be told when that date passes, so that you can.\n"
+	}
+
+	if thing.ExpiryMode == database.ExpiryModeAccess {
+		body += fmt.Sprintf("That's %d days after it was last used, so using it will postpone its removal.\n",
+			thing.ExpireAfter)
+	}
+
+	body += "If you still need it, please log in to the tt web interface and extend its removal date."
+
+	if snoozable {
+		body += " Or, to not be warned about it again until shortly before then, snooze its warnings there."
+	}
+
+	return body + "\n"
+}
+
+// Run calls Warn() now and then every interval, until ctx is done.
+func (w *Warner) Run(ctx context.Context, interval time.Duration) {
+	runEvery(ctx, interval, "warning", w.logger, func(ctx context.Context) error {
+		_, err := w.Warn(ctx)
+
+		return err
+	})
+}
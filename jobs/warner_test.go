@@ -0,0 +1,328 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package jobs
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	null "github.com/guregu/null/v5"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/wtsi-hgi/tt/database"
+	"github.com/wtsi-hgi/tt/links"
+	"github.com/wtsi-hgi/tt/policy"
+)
+
+func TestWarner(t *testing.T) {
+	Convey("Given a database of things due to be removed at various times", t, func() {
+		now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+		day := func(n int) time.Time { return time.Date(2025, 6, 1+n, 0, 0, 0, 0, time.UTC) }
+
+		mdb := newMockDB(
+			database.Thing{ID: 1, Address: "/a", Type: database.ThingsTypeDir, Remove: day(30), Reason: "far"},
+			database.Thing{ID: 2, Address: "/b", Type: database.ThingsTypeDir, Remove: day(10), Reason: "soon"},
+			database.Thing{ID: 3, Address: "/c", Type: database.ThingsTypeDir, Remove: day(2), Reason: "very soon"},
+			database.Thing{ID: 4, Address: "/d", Type: database.ThingsTypeDir, Remove: day(-1), Reason: "past"},
+			database.Thing{ID: 5, Address: "/e", Type: database.ThingsTypeDir, Remove: day(2), Removed: true},
+			database.Thing{ID: 6, Address: "/f", Type: database.ThingsTypeDir, Remove: day(2),
+				QuarantineUntil: null.TimeFrom(day(10))},
+		)
+
+		user := database.User{ID: 1, Name: "user", Email: "user@example.com"}
+		for id := uint32(1); id <= 6; id++ {
+			mdb.subs[id] = []database.User{user}
+		}
+
+		mn := &mockNotifier{}
+		logger, logs := newTestLogger()
+		w := NewWarner(mdb, mn, logger)
+		w.now = func() time.Time { return now }
+
+		Convey("Subscribers of things due within a warning period are warned once", func() {
+			n, err := w.Warn(context.Background())
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 2)
+			So(logs.String(), ShouldBeBlank)
+
+			So(mdb.things[0].Warned1.Valid, ShouldBeFalse)
+			So(mdb.things[1].Warned1.Time, ShouldEqual, now)
+			So(mdb.things[1].Warned2.Valid, ShouldBeFalse)
+			So(mdb.things[2].Warned1.Time, ShouldEqual, now)
+			So(mdb.things[2].Warned2.Time, ShouldEqual, now)
+			So(mdb.things[3].Warned1.Valid, ShouldBeFalse)
+			So(mdb.things[5].Warned1.Valid, ShouldBeFalse)
+
+			So(len(mn.messages), ShouldEqual, 2)
+			So(mn.messages[0].subject, ShouldEqual, "tt: /b is due for removal on 2025-06-11")
+			So(mn.messages[0].body, ShouldContainSubstring, "soon")
+			So(mn.messages[1].subject, ShouldEqual, "tt: /c is due for removal on 2025-06-03")
+
+			So(len(mdb.audit), ShouldEqual, 2)
+			So(mdb.audit[0].Action, ShouldEqual, database.AuditWarned)
+			So(mdb.audit[0].Detail, ShouldEqual, "first warning of removal on 2025-06-11")
+			So(mdb.audit[1].Detail, ShouldEqual, "second warning of removal on 2025-06-03")
+
+			n, err = w.Warn(context.Background())
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 0)
+			So(len(mn.messages), ShouldEqual, 2)
+
+			Convey("and again when the second warning period is reached", func() {
+				w.now = func() time.Time { return now.AddDate(0, 0, 8) }
+
+				n, err = w.Warn(context.Background())
+				So(err, ShouldBeNil)
+				So(n, ShouldEqual, 1)
+				So(mdb.things[1].Warned1.Time, ShouldEqual, now)
+				So(mdb.things[1].Warned2.Valid, ShouldBeTrue)
+				So(mdb.audit[2].Detail, ShouldEqual, "second warning of removal on 2025-06-11")
+			})
+
+			Convey("but warnings are forgotten if their removal date moves later", func() {
+				mdb.things[2].ExpiryMode = database.ExpiryModeAccess
+				mdb.things[2].ExpireAfter = 7
+				mdb.things[2].Accessed = null.TimeFrom(now)
+
+				n, err = w.Warn(context.Background())
+				So(err, ShouldBeNil)
+				So(n, ShouldEqual, 0)
+				So(mdb.things[2].Warned1.Valid, ShouldBeTrue)
+				So(mdb.things[2].Warned2.Valid, ShouldBeFalse)
+
+				w.now = func() time.Time { return now.AddDate(0, 0, 5) }
+
+				n, err = w.Warn(context.Background())
+				So(err, ShouldBeNil)
+				So(n, ShouldEqual, 1)
+				So(mn.messages[2].subject, ShouldEqual, "tt: /c is due for removal on 2025-06-08")
+				So(mn.messages[2].body, ShouldContainSubstring, "7 days after it was last used")
+			})
+		})
+
+		Convey("Things on hold aren't warned about until their hold ends", func() {
+			mdb.things[1].HoldReason = null.StringFrom("investigation")
+			mdb.things[1].HoldUntil = null.TimeFrom(day(5))
+			mdb.things[2].HoldReason = null.StringFrom("paper revision")
+
+			n, err := w.Warn(context.Background())
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 0)
+			So(mdb.things[1].Warned1.Valid, ShouldBeFalse)
+			So(mdb.things[2].Warned1.Valid, ShouldBeFalse)
+
+			w.now = func() time.Time { return day(6) }
+
+			n, err = w.Warn(context.Background())
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 1)
+			So(mdb.things[1].Warned1.Valid, ShouldBeTrue)
+			So(mn.messages[0].subject, ShouldEqual, "tt: /b is due for removal on 2025-06-11")
+		})
+
+		Convey("Subscribers who snoozed warnings aren't warned until reminded when their snooze ends", func() {
+			other := database.User{ID: 2, Name: "other", Email: "other@example.com"}
+			mdb.subs[2] = []database.User{user, other}
+			mdb.snoozes = []database.Snooze{
+				{ThingID: 2, User: user, Until: day(5)},
+				{ThingID: 3, User: user, Until: day(-1), Missed: true},
+			}
+
+			n, err := w.Warn(context.Background())
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 2)
+			So(logs.String(), ShouldBeBlank)
+
+			So(len(mn.messages), ShouldEqual, 3)
+			So(mn.messages[0].users, ShouldResemble, []database.User{user})
+			So(mn.messages[0].subject, ShouldEqual, "tt: /c is due for removal on 2025-06-03")
+			So(mn.messages[1].users, ShouldResemble, []database.User{other})
+			So(mn.messages[1].subject, ShouldEqual, "tt: /b is due for removal on 2025-06-11")
+			So(mn.messages[1].body, ShouldContainSubstring, "snooze its warnings")
+			So(mn.messages[2].users, ShouldResemble, []database.User{user})
+			So(mn.messages[2].subject, ShouldEqual, "tt: /c is due for removal on 2025-06-03")
+			So(mn.messages[0].body, ShouldNotContainSubstring, "snooze")
+			So(mn.messages[2].body, ShouldNotContainSubstring, "snooze")
+
+			So(mdb.audit[0].Detail, ShouldEqual, "reminded user of removal on 2025-06-03 after their snooze")
+			So(mdb.snoozes, ShouldResemble, []database.Snooze{{ThingID: 2, User: user, Until: day(5), Missed: true}})
+
+			w.now = func() time.Time { return day(6) }
+
+			n, err = w.Warn(context.Background())
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 0)
+			So(len(mn.messages), ShouldEqual, 4)
+			So(mn.messages[3].users, ShouldResemble, []database.User{user})
+			So(mn.messages[3].subject, ShouldEqual, "tt: /b is due for removal on 2025-06-11")
+			So(mdb.snoozes, ShouldBeEmpty)
+		})
+
+		Convey("Subscribers who confirmed removal at the due time aren't warned or reminded about it", func() {
+			other := database.User{ID: 2, Name: "other", Email: "other@example.com"}
+			mdb.subs[2] = []database.User{user, other}
+			mdb.confirmations = []database.Confirmation{
+				{ThingID: 2, User: user, Remove: day(10)},
+				{ThingID: 3, User: user, Remove: day(1)},
+			}
+			mdb.snoozes = []database.Snooze{{ThingID: 2, User: user, Until: day(-1), Missed: true}}
+
+			n, err := w.Warn(context.Background())
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 2)
+			So(logs.String(), ShouldBeBlank)
+
+			So(len(mn.messages), ShouldEqual, 2)
+			So(mn.messages[0].users, ShouldResemble, []database.User{other})
+			So(mn.messages[0].subject, ShouldEqual, "tt: /b is due for removal on 2025-06-11")
+			So(mn.messages[1].users, ShouldResemble, []database.User{user})
+			So(mn.messages[1].subject, ShouldEqual, "tt: /c is due for removal on 2025-06-03")
+
+			So(len(mdb.audit), ShouldEqual, 2)
+			So(mdb.snoozes, ShouldBeEmpty)
+		})
+
+		Convey("Warnings can include each subscriber's own signed one-click links", func() {
+			signer, err := links.NewSigner([]byte(strings.Repeat("k", links.MinKeyLength)), "https://tt")
+			So(err, ShouldBeNil)
+
+			w.SetLinks(signer)
+
+			other := database.User{ID: 2, Name: "other", Email: "other@example.com"}
+			mdb.subs[2] = []database.User{user, other}
+
+			n, err := w.Warn(context.Background())
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 2)
+
+			So(len(mn.messages), ShouldEqual, 3)
+			So(mn.messages[0].users, ShouldResemble, []database.User{user})
+			So(mn.messages[1].users, ShouldResemble, []database.User{other})
+			So(mn.messages[0].subject, ShouldEqual, mn.messages[1].subject)
+			So(mn.messages[0].body, ShouldContainSubstring, "each works once, until 2025-06-11")
+
+			linkURLs := regexp.MustCompile(`https://tt` + links.Path + `(\S+)`)
+			urls := linkURLs.FindAllStringSubmatch(mn.messages[1].body, -1)
+			So(len(urls), ShouldEqual, 4)
+
+			var actions []links.Action
+
+			for _, url := range urls {
+				link, err := signer.Verify(url[1], now)
+				So(err, ShouldBeNil)
+				So(link.ThingID, ShouldEqual, 2)
+				So(link.UserID, ShouldEqual, other.ID)
+
+				if link.Action == links.Snooze {
+					So(link.Expires, ShouldEqual, day(10).Add(-DefaultSecondWarning))
+				} else {
+					So(link.Expires, ShouldEqual, day(10))
+				}
+
+				actions = append(actions, link.Action)
+			}
+
+			So(actions, ShouldResemble, []links.Action{links.Extend, links.Unsubscribe, links.ConfirmRemoval,
+				links.Snooze})
+			So(mn.messages[1].body, ShouldContainSubstring, "works until 2025-06-08")
+
+			Convey("but second warnings don't include a snooze link", func() {
+				So(mn.messages[2].subject, ShouldEqual, "tt: /c is due for removal on 2025-06-03")
+
+				urls = linkURLs.FindAllStringSubmatch(mn.messages[2].body, -1)
+				So(len(urls), ShouldEqual, 3)
+
+				for _, url := range urls {
+					link, err := signer.Verify(url[1], now)
+					So(err, ShouldBeNil)
+					So(link.Action, ShouldNotEqual, links.Snooze)
+				}
+
+				So(mn.messages[2].body, ShouldNotContainSubstring, "snooze")
+				So(mn.messages[2].body, ShouldNotContainSubstring, "Stop being warned")
+			})
+		})
+
+		Convey("Warnings are about when the schedule says things will actually be removed", func() {
+			signer, err := links.NewSigner([]byte(strings.Repeat("k", links.MinKeyLength)), "https://tt")
+			So(err, ShouldBeNil)
+
+			w.SetLinks(signer)
+			w.SetSchedule(&policy.Schedule{Grace: 10 * 24 * time.Hour})
+
+			n, err := w.Warn(context.Background())
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 2)
+			So(mdb.things[1].Warned1.Valid, ShouldBeFalse)
+			So(mn.messages[0].subject, ShouldEqual, "tt: /c is due for removal on 2025-06-13")
+			So(mn.messages[1].subject, ShouldEqual, "tt: /d is due for removal on 2025-06-10")
+			So(mn.messages[1].body, ShouldContainSubstring, "each works once, until 2025-06-10")
+			So(mdb.audit[1].Detail, ShouldEqual, "first warning of removal on 2025-06-10")
+		})
+
+		Convey("Warnings mention what will happen to things", func() {
+			mdb.things[1].OnExpiry = database.ExpiryArchive
+			mdb.things[2].OnExpiry = database.ExpiryNotify
+
+			_, err := w.Warn(context.Background())
+			So(err, ShouldBeNil)
+			So(mn.messages[0].body, ShouldContainSubstring, "archived")
+			So(mn.messages[1].body, ShouldContainSubstring, "tt won't remove it")
+		})
+
+		Convey("Run warns until the context is cancelled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+
+			done := make(chan struct{})
+
+			go func() {
+				w.Run(ctx, time.Hour)
+				close(done)
+			}()
+
+			So(func() bool {
+				for range 100 {
+					mdb.mu.Lock()
+					warned := mdb.things[1].Warned1.Valid
+					mdb.mu.Unlock()
+
+					if warned {
+						return true
+					}
+
+					time.Sleep(10 * time.Millisecond)
+				}
+
+				return false
+			}(), ShouldBeTrue)
+
+			cancel()
+			<-done
+		})
+	})
+}
@@ -0,0 +1,337 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+package jobs
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/wtsi-hgi/tt/backend"
+	"github.com/wtsi-hgi/tt/database"
+	"github.com/wtsi-hgi/tt/policy"
+)
+
+const (
+	DefaultWorkerLimit = 4
+	DefaultMaxAttempts = 5
+	DefaultBackoff     = time.Minute
+	maxBackoff         = 24 * time.Hour
+
+	DefaultProgressInterval = 2 * time.Second
+)
+
+// Workers carry out the RemovalJobs queued by a Reaper, running jobs for
+// different things in parallel, with a limit on how many of each ThingsType
+// run at once. Jobs that fail are retried with exponential backoff, and are
+// marked as failed, with their last error recorded, once they have been
+// attempted too many times.
+//
+// While they run, the progress that backends report (see
+// backend.WithProgress()) is regularly recorded on the jobs.
+type Workers struct {
+	db               database.Queries
+	reaper           *Reaper
+	runner           string
+	logger           *log.Logger
+	limits           map[database.ThingsType]int
+	defaultLimit     int
+	maxAttempts      int
+	backoff          time.Duration
+	progressInterval time.Duration
+
+	mu      sync.Mutex
+	running map[database.ThingsType]int
+	wg      sync.WaitGroup
+}
+
+// NewWorkers returns Workers that carry out the jobs in the given database
+// using the given reaper, on behalf of runner, which should be the holder of
+// the Leader's lease (see Identity()). Problems are logged to the given logger.
+func NewWorkers(db database.Queries, reaper *Reaper, runner string, logger *log.Logger) *Workers {
+	return &Workers{
+		db:               db,
+		reaper:           reaper,
+		runner:           runner,
+		logger:           logger,
+		limits:           make(map[database.ThingsType]int),
+		defaultLimit:     DefaultWorkerLimit,
+		maxAttempts:      DefaultMaxAttempts,
+		backoff:          DefaultBackoff,
+		progressInterval: DefaultProgressInterval,
+		running:          make(map[database.ThingsType]int),
+	}
+}
+
+// SetLimit sets the maximum number of jobs for things of the given type that
+// will run at once. Types without their own limit use the default limit, which
+// you can set by passing a blank thingsType. The default is
+// DefaultWorkerLimit.
+func (w *Workers) SetLimit(thingsType database.ThingsType, limit int) {
+	if thingsType == "" {
+		w.defaultLimit = limit
+
+		return
+	}
+
+	w.limits[thingsType] = limit
+}
+
+// SetRetries sets how many times a job is attempted before it is marked as
+// failed, and how long to wait before retrying it the first time; each
+// subsequent wait is twice as long as the last, up to a day. The defaults are
+// DefaultMaxAttempts and DefaultBackoff.
+func (w *Workers) SetRetries(maxAttempts int, backoff time.Duration) {
+	w.maxAttempts = maxAttempts
+	w.backoff = backoff
+}
+
+// Dispatch starts running, in goroutines, the queued jobs whose next attempt
+// is due, as far as the limits allow. Returns the number of jobs started.
+// Use Wait() to wait for them to finish.
+func (w *Workers) Dispatch(ctx context.Context) (int, error) {
+	jobs, err := w.db.GetRemovalJobs(database.JobQueued)
+	if err != nil {
+		return 0, err
+	}
+
+	now := w.reaper.now()
+	started := 0
+
+	for _, job := range jobs {
+		if job.NextAttempt.After(now) {
+			break
+		}
+
+		if !w.acquire(job.Type) {
+			continue
+		}
+
+		if err := w.db.StartJob(job.ThingID, w.runner); err != nil {
+			w.release(job.Type)
+
+			return started, err
+		}
+
+		job.State = database.JobRunning
+		job.Runner = w.runner
+		job.Attempts++
+		started++
+
+		w.wg.Add(1)
+
+		go w.run(ctx, job)
+	}
+
+	return started, nil
+}
+
+// acquire returns true if another job of the given type can be run now,
+// counting it as running.
+func (w *Workers) acquire(thingsType database.ThingsType) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	limit, ok := w.limits[thingsType]
+	if !ok {
+		limit = w.defaultLimit
+	}
+
+	if w.running[thingsType] >= limit {
+		return false
+	}
+
+	w.running[thingsType]++
+
+	return true
+}
+
+func (w *Workers) release(thingsType database.ThingsType) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.running[thingsType]--
+}
+
+// SetProgressInterval sets how often the progress of running jobs is recorded.
+// The default is DefaultProgressInterval.
+func (w *Workers) SetProgressInterval(interval time.Duration) {
+	w.progressInterval = interval
+}
+
+// run carries out the given job, deleting it if it succeeded, or recording its
+// failure.
+func (w *Workers) run(ctx context.Context, job database.RemovalJob) {
+	defer w.wg.Done()
+	defer w.release(job.Type)
+
+	progress := &backend.Progress{}
+	stop := w.recordProgress(job.ThingID, progress)
+
+	acted, err := w.execute(backend.WithProgress(ctx, progress), job)
+
+	stop()
+
+	if err != nil && !acted {
+		w.fail(job, err)
+
+		return
+	}
+
+	if err != nil {
+		w.logger.Print(err)
+	}
+
+	if err = w.db.FinishJob(job.ThingID); err != nil {
+		w.logger.Printf("finishing removal job for thing %d failed: %s", job.ThingID, err)
+	}
+}
+
+// recordProgress records the given progress on the job of the thing with the
+// given ID every progressInterval, if it has changed, until the returned func
+// is called, which also records it a final time.
+func (w *Workers) recordProgress(thingID uint32, progress *backend.Progress) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(w.progressInterval)
+		defer ticker.Stop()
+
+		var last database.RemovalProgress
+
+		for {
+			select {
+			case <-done:
+				w.updateProgress(thingID, progress.Snapshot(), &last)
+
+				return
+			case <-ticker.C:
+				w.updateProgress(thingID, progress.Snapshot(), &last)
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// updateProgress records the given progress on the job of the thing with the
+// given ID if it's different to last, which is then updated.
+func (w *Workers) updateProgress(thingID uint32, current database.RemovalProgress, last *database.RemovalProgress) {
+	if current == *last {
+		return
+	}
+
+	if err := w.db.UpdateJobProgress(thingID, current); err != nil {
+		w.logger.Printf("recording progress of removal job for thing %d failed: %s", thingID, err)
+
+		return
+	}
+
+	*last = current
+}
+
+// execute carries out the job's action on its thing, if the action is still
+// needed.
+func (w *Workers) execute(ctx context.Context, job database.RemovalJob) (bool, error) {
+	thing, err := w.db.GetThing(job.ThingID)
+	if errors.Is(err, database.ErrNoThing) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	if thing.Removed {
+		return false, nil
+	}
+
+	if action, ok := w.reaper.due(thing, w.reaper.now()); !ok || action != job.Action {
+		return false, nil
+	}
+
+	return w.reaper.Execute(ctx, thing, job.Action)
+}
+
+// fail records that the job failed with the given error, queuing it to be
+// retried after a backoff, or marking it as failed if it has been attempted
+// too many times, or its thing's address is protected.
+func (w *Workers) fail(job database.RemovalJob, jobErr error) {
+	state := database.JobQueued
+	if job.Attempts >= w.maxAttempts || errors.Is(jobErr, policy.ErrProtected) {
+		state = database.JobFailed
+	}
+
+	w.logger.Printf("attempt %d of %s job for thing %d failed: %s", job.Attempts, job.Action, job.ThingID, jobErr)
+
+	err := w.db.FailJob(job.ThingID, jobErr.Error(), state, w.reaper.now().Add(w.backoffFor(job.Attempts)))
+	if err != nil {
+		w.logger.Printf("recording failure of removal job for thing %d failed: %s", job.ThingID, err)
+	}
+}
+
+// backoffFor returns how long to wait before retrying a job that has been
+// attempted the given number of times.
+func (w *Workers) backoffFor(attempts int) time.Duration {
+	backoff := w.backoff
+
+	for range attempts - 1 {
+		backoff *= 2
+
+		if backoff >= maxBackoff {
+			return maxBackoff
+		}
+	}
+
+	return backoff
+}
+
+// Wait waits for the jobs started by Dispatch() to finish.
+func (w *Workers) Wait() {
+	w.wg.Wait()
+}
+
+// Run queues any jobs left running by a previous process whose lease has
+// expired to be run again, then calls Dispatch() now and then every interval,
+// until ctx is done, when it waits for running jobs to finish.
+func (w *Workers) Run(ctx context.Context, interval time.Duration) {
+	if err := w.db.ResetRunningJobs(); err != nil {
+		w.logger.Printf("resetting running removal jobs failed: %s", err)
+	}
+
+	runEvery(ctx, interval, "dispatching removal jobs", w.logger, func(ctx context.Context) error {
+		_, err := w.Dispatch(ctx)
+
+		return err
+	})
+
+	w.Wait()
+}
@@ -0,0 +1,312 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/wtsi-hgi/tt/backend"
+	"github.com/wtsi-hgi/tt/database"
+	"github.com/wtsi-hgi/tt/policy"
+)
+
+func TestWorkers(t *testing.T) {
+	Convey("Given a database of expired things and a reaper", t, func() {
+		now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+		past := now.AddDate(0, 0, -1)
+		future := now.AddDate(0, 0, 1)
+
+		mdb := newMockDB(
+			database.Thing{ID: 1, Address: "b/a", Type: database.ThingsTypeS3, Remove: future},
+			database.Thing{ID: 2, Address: "b/b", Type: database.ThingsTypeS3, Remove: past},
+			database.Thing{ID: 3, Address: "b/c", Type: database.ThingsTypeS3, Remove: past},
+			database.Thing{ID: 4, Address: "/d", Type: database.ThingsTypeDir, Remove: past},
+			database.Thing{ID: 5, Address: "b/e", Type: database.ThingsTypeS3, Remove: past, Removed: true},
+		)
+
+		mr := &mockRemover{}
+		mq := &mockQuarantiner{}
+		backends := map[database.ThingsType]backend.Backend{
+			database.ThingsTypeS3:  mr,
+			database.ThingsTypeDir: mq,
+		}
+		useBackends(t, backends)
+		logger, logs := newTestLogger()
+		r := NewReaper(mdb, nil, logger)
+		r.now = func() time.Time { return now }
+
+		w := NewWorkers(mdb, r, "a", logger)
+
+		Convey("Enqueue queues a job for each thing that needs one", func() {
+			n, err := r.Enqueue(context.Background())
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 3)
+
+			jobs, err := mdb.GetRemovalJobs(database.JobQueued)
+			So(err, ShouldBeNil)
+			So(len(jobs), ShouldEqual, 3)
+			So(jobs[0].ThingID, ShouldEqual, 2)
+			So(jobs[0].Action, ShouldEqual, database.JobRemove)
+			So(jobs[2].ThingID, ShouldEqual, 4)
+			So(jobs[2].Action, ShouldEqual, database.JobQuarantine)
+
+			n, err = r.Enqueue(context.Background())
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 0)
+
+			Convey("which workers then carry out", func() {
+				started, err := w.Dispatch(context.Background())
+				So(err, ShouldBeNil)
+				So(started, ShouldEqual, 3)
+
+				w.Wait()
+
+				So(mr.removed, ShouldContain, uint32(2))
+				So(mr.removed, ShouldContain, uint32(3))
+				So(mq.quarantined, ShouldResemble, []uint32{4})
+				So(mdb.jobs, ShouldBeEmpty)
+				So(mdb.things[1].Removed, ShouldBeTrue)
+				So(mdb.things[3].Quarantined(), ShouldBeTrue)
+				So(logs.String(), ShouldBeBlank)
+			})
+
+			Convey("with no more of a type running at once than its limit", func() {
+				w.SetLimit(database.ThingsTypeS3, 1)
+
+				started, err := w.Dispatch(context.Background())
+				So(err, ShouldBeNil)
+				So(started, ShouldEqual, 2)
+
+				w.Wait()
+
+				So(len(mr.removed), ShouldEqual, 1)
+				So(len(mdb.jobs), ShouldEqual, 1)
+
+				started, err = w.Dispatch(context.Background())
+				So(err, ShouldBeNil)
+				So(started, ShouldEqual, 1)
+
+				w.Wait()
+
+				So(len(mr.removed), ShouldEqual, 2)
+				So(mdb.jobs, ShouldBeEmpty)
+
+				w.SetLimit("", 0)
+				mdb.things[0].Remove = past
+
+				_, err = r.Enqueue(context.Background())
+				So(err, ShouldBeNil)
+
+				started, err = w.Dispatch(context.Background())
+				So(err, ShouldBeNil)
+				So(started, ShouldEqual, 1)
+
+				w.Wait()
+
+				So(mdb.jobs, ShouldBeEmpty)
+			})
+
+			Convey("failed jobs are retried with backoff, until they have failed too often", func() {
+				mr.removeErr = errors.New("remove failed")
+				w.SetLimit(database.ThingsTypeDir, 0)
+				w.SetRetries(3, time.Minute)
+
+				started, err := w.Dispatch(context.Background())
+				So(err, ShouldBeNil)
+				So(started, ShouldEqual, 2)
+
+				w.Wait()
+
+				job := mdb.jobs[2]
+				So(job.State, ShouldEqual, database.JobQueued)
+				So(job.Attempts, ShouldEqual, 1)
+				So(job.NextAttempt, ShouldEqual, now.Add(time.Minute))
+				So(job.LastError.String, ShouldEqual, "removing thing 2 (b/b) failed: remove failed")
+				So(job.Progress, ShouldResemble, database.RemovalProgress{Files: 1, Bytes: 5})
+				So(logs.String(), ShouldContainSubstring, "attempt 1 of remove job for thing 2 failed")
+
+				started, err = w.Dispatch(context.Background())
+				So(err, ShouldBeNil)
+				So(started, ShouldEqual, 0)
+
+				now = now.Add(time.Minute)
+
+				started, err = w.Dispatch(context.Background())
+				So(err, ShouldBeNil)
+				So(started, ShouldEqual, 2)
+
+				w.Wait()
+
+				So(job.Attempts, ShouldEqual, 2)
+				So(job.NextAttempt, ShouldEqual, now.Add(2*time.Minute))
+
+				now = now.Add(2 * time.Minute)
+
+				_, err = w.Dispatch(context.Background())
+				So(err, ShouldBeNil)
+
+				w.Wait()
+
+				So(job.Attempts, ShouldEqual, 3)
+				So(job.State, ShouldEqual, database.JobFailed)
+
+				now = now.Add(time.Hour)
+
+				started, err = w.Dispatch(context.Background())
+				So(err, ShouldBeNil)
+				So(started, ShouldEqual, 0)
+
+				n, err := r.Enqueue(context.Background())
+				So(err, ShouldBeNil)
+				So(n, ShouldEqual, 0)
+				So(mdb.things[1].Removed, ShouldBeFalse)
+			})
+
+			Convey("jobs for things at protected addresses fail without being retried", func() {
+				r.SetProtection(&policy.Protection{
+					AllowRoots: map[database.ThingsType][]string{database.ThingsTypeS3: {"b/c"}},
+				})
+
+				_, err := w.Dispatch(context.Background())
+				So(err, ShouldBeNil)
+
+				w.Wait()
+
+				So(mr.removed, ShouldBeEmpty)
+				So(mdb.jobs[2].State, ShouldEqual, database.JobFailed)
+				So(mdb.jobs[2].Attempts, ShouldEqual, 1)
+				So(mdb.jobs[2].LastError.String, ShouldContainSubstring, "b/b is not within any of the allowed s3 roots")
+				So(mdb.things[1].Removed, ShouldBeFalse)
+			})
+
+			Convey("progress is recorded while jobs run", func() {
+				release := make(chan struct{})
+				backends[database.ThingsTypeS3] = &blockingRemover{release: release}
+				useBackends(t, backends)
+				w.SetLimit(database.ThingsTypeDir, 0)
+				w.SetProgressInterval(time.Millisecond)
+
+				_, err := w.Dispatch(context.Background())
+				So(err, ShouldBeNil)
+
+				progressOf := func(id uint32) database.RemovalProgress {
+					mdb.mu.Lock()
+					defer mdb.mu.Unlock()
+
+					return mdb.jobs[id].Progress
+				}
+
+				for progressOf(2).Files == 0 || progressOf(3).Files == 0 {
+					time.Sleep(time.Millisecond)
+				}
+
+				So(progressOf(2), ShouldResemble, database.RemovalProgress{Files: 1, Bytes: 5})
+
+				close(release)
+				w.Wait()
+
+				So(mdb.jobs[2], ShouldBeNil)
+				So(mdb.things[1].Removed, ShouldBeTrue)
+			})
+
+			Convey("jobs that are no longer needed are dropped without doing anything", func() {
+				mdb.things[1].Remove = future
+				mdb.things[2].Removed = true
+
+				_, err := w.Dispatch(context.Background())
+				So(err, ShouldBeNil)
+
+				w.Wait()
+
+				So(mr.removed, ShouldBeEmpty)
+				So(mq.quarantined, ShouldResemble, []uint32{4})
+				So(mdb.jobs, ShouldBeEmpty)
+			})
+
+			Convey("Run resets jobs left running by expired leaders, then dispatches until cancelled", func() {
+				mdb.jobs[2].State = database.JobRunning
+				mdb.jobs[2].Runner = "old"
+				mdb.jobs[4].State = database.JobRunning
+				mdb.jobs[4].Runner = "other"
+				mdb.leases["old"] = database.Lease{Name: "old", Holder: "old", Expires: now.Add(-time.Second)}
+				mdb.leases["other"] = database.Lease{Name: "other", Holder: "other", Expires: time.Now().Add(time.Hour)}
+
+				ctx, cancel := context.WithCancel(context.Background())
+
+				go func() {
+					for {
+						mdb.mu.Lock()
+						_, running := mdb.jobs[2]
+						mdb.mu.Unlock()
+
+						if !running {
+							cancel()
+
+							return
+						}
+
+						time.Sleep(time.Millisecond)
+					}
+				}()
+
+				w.Run(ctx, time.Millisecond)
+
+				So(mr.removed, ShouldContain, uint32(2))
+				So(mq.quarantined, ShouldBeEmpty)
+				So(mdb.jobs[4].State, ShouldEqual, database.JobRunning)
+				So(mdb.jobs[4].Runner, ShouldEqual, "other")
+			})
+		})
+	})
+
+	Convey("backoffFor doubles with each attempt, up to a limit", t, func() {
+		w := NewWorkers(nil, nil, "", nil)
+
+		So(w.backoffFor(1), ShouldEqual, DefaultBackoff)
+		So(w.backoffFor(2), ShouldEqual, 2*DefaultBackoff)
+		So(w.backoffFor(4), ShouldEqual, 8*DefaultBackoff)
+		So(w.backoffFor(100), ShouldEqual, maxBackoff)
+	})
+}
+
+// blockingRemover is a backend.Remover that reports removing a 5 byte file,
+// then waits for release to be closed before succeeding.
+type blockingRemover struct {
+	mockBackend
+
+	release chan struct{}
+}
+
+func (b *blockingRemover) Remove(ctx context.Context, _ *database.Thing) error {
+	backend.ProgressFrom(ctx).Removed(1, 5)
+
+	<-b.release
+
+	return nil
+}
@@ -0,0 +1,198 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+// package links lets tt email users one-click links that perform an action on
+// a Thing without them needing to log in. Links are signed with a secret key so
+// that they can't be forged or altered, and expire.
+
+package links
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Error string
+
+func (e Error) Error() string { return string(e) }
+
+const (
+	ErrShortKey    = Error("link signing keys must be at least 32 bytes")
+	ErrBadLink     = Error("That link is not valid")
+	ErrLinkExpired = Error("That link has expired")
+)
+
+const (
+	// MinKeyLength is the minimum length in bytes of a Signer's key.
+	MinKeyLength = 32
+
+	// Path is the path of the server route that handles links, which are
+	// followed by their token.
+	Path = "/links/"
+
+	// ExtendDays is how many days Extend links extend a Thing's removal date
+	// by.
+	ExtendDays = 30
+
+	idBytes      = 16
+	payloadParts = 5
+	separator    = "."
+)
+
+// Action is what following a link does.
+type Action string
+
+const (
+	Extend         Action = "extend"
+	Unsubscribe    Action = "unsubscribe"
+	ConfirmRemoval Action = "remove"
+	Snooze         Action = "snooze"
+)
+
+// Actions returns all the valid Actions.
+func Actions() []Action {
+	return []Action{Extend, Unsubscribe, ConfirmRemoval, Snooze}
+}
+
+// Link describes a signed link: the Action it lets the User with UserID take on
+// the Thing with ThingID until it Expires. ID uniquely identifies it, so that
+// it can only be used once.
+type Link struct {
+	Action  Action
+	ThingID uint32
+	UserID  uint32
+	Expires time.Time
+	ID      string
+}
+
+// Signer creates and verifies signed links.
+type Signer struct {
+	key     []byte
+	baseURL string
+}
+
+// NewSigner returns a Signer that signs links with the given secret key, which
+// must be at least MinKeyLength bytes long, and creates URLs of them at the
+// given base URL of the tt server, eg. "https://tt.example.com:8080".
+func NewSigner(key []byte, baseURL string) (*Signer, error) {
+	if len(key) < MinKeyLength {
+		return nil, ErrShortKey
+	}
+
+	return &Signer{key: key, baseURL: strings.TrimSuffix(baseURL, "/")}, nil
+}
+
+// URL returns the URL of a new signed link that lets the user with the given ID
+// take the given action on the thing with the given ID until the given time.
+// Each returned link has a different random ID.
+func (s *Signer) URL(action Action, thingID, userID uint32, expires time.Time) (string, error) {
+	id := make([]byte, idBytes)
+
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+
+	payload := strings.Join([]string{
+		string(action),
+		strconv.FormatUint(uint64(thingID), 10),
+		strconv.FormatUint(uint64(userID), 10),
+		strconv.FormatInt(expires.Unix(), 10),
+		base64.RawURLEncoding.EncodeToString(id),
+	}, separator)
+
+	return s.baseURL + Path + payload + separator + s.sign(payload), nil
+}
+
+// sign returns the URL-safe HMAC-SHA256 signature of the given payload.
+func (s *Signer) sign(payload string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(payload))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Verify returns the Link described by the given token, which is the part of a
+// URL returned by URL() after Path.
+//
+// Returns ErrBadLink if the token wasn't signed with our key or is malformed,
+// or ErrLinkExpired if the link expired before the given time.
+func (s *Signer) Verify(token string, now time.Time) (*Link, error) {
+	i := strings.LastIndex(token, separator)
+	if i == -1 {
+		return nil, ErrBadLink
+	}
+
+	payload, signature := token[:i], token[i+1:]
+
+	if !hmac.Equal([]byte(signature), []byte(s.sign(payload))) {
+		return nil, ErrBadLink
+	}
+
+	link, err := parse(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	if !now.Before(link.Expires) {
+		return nil, ErrLinkExpired
+	}
+
+	return link, nil
+}
+
+// parse returns the Link described by the given signed payload.
+func parse(payload string) (*Link, error) {
+	parts := strings.Split(payload, separator)
+	if len(parts) != payloadParts {
+		return nil, ErrBadLink
+	}
+
+	action := Action(parts[0])
+	if !slices.Contains(Actions(), action) {
+		return nil, ErrBadLink
+	}
+
+	thingID, errt := strconv.ParseUint(parts[1], 10, 32)
+	userID, erru := strconv.ParseUint(parts[2], 10, 32)
+	expires, erre := strconv.ParseInt(parts[3], 10, 64)
+
+	if errt != nil || erru != nil || erre != nil || parts[4] == "" {
+		return nil, ErrBadLink
+	}
+
+	return &Link{
+		Action:  action,
+		ThingID: uint32(thingID),
+		UserID:  uint32(userID),
+		Expires: time.Unix(expires, 0),
+		ID:      parts[4],
+	}, nil
+}
@@ -0,0 +1,115 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package links
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSigner(t *testing.T) {
+	Convey("Signers need a long enough key", t, func() {
+		_, err := NewSigner([]byte("short"), "https://tt")
+		So(err, ShouldEqual, ErrShortKey)
+	})
+
+	Convey("Given a Signer", t, func() {
+		key := []byte(strings.Repeat("k", MinKeyLength))
+
+		s, err := NewSigner(key, "https://tt.example.com:8080/")
+		So(err, ShouldBeNil)
+
+		now := time.Now()
+		expires := now.Add(time.Hour).Truncate(time.Second)
+
+		url, err := s.URL(Extend, 3, 7, expires)
+		So(err, ShouldBeNil)
+		So(url, ShouldStartWith, "https://tt.example.com:8080"+Path+"extend.3.7.")
+
+		token := strings.TrimPrefix(url, "https://tt.example.com:8080"+Path)
+
+		Convey("you can verify the links it creates", func() {
+			link, err := s.Verify(token, now)
+			So(err, ShouldBeNil)
+			So(link.Action, ShouldEqual, Extend)
+			So(link.ThingID, ShouldEqual, 3)
+			So(link.UserID, ShouldEqual, 7)
+			So(link.Expires, ShouldEqual, expires)
+			So(link.ID, ShouldNotBeBlank)
+
+			url2, err := s.URL(Extend, 3, 7, expires)
+			So(err, ShouldBeNil)
+			So(url2, ShouldNotEqual, url)
+
+			link2, err := s.Verify(strings.TrimPrefix(url2, "https://tt.example.com:8080"+Path), now)
+			So(err, ShouldBeNil)
+			So(link2.ID, ShouldNotEqual, link.ID)
+		})
+
+		Convey("links expire", func() {
+			_, err := s.Verify(token, expires)
+			So(err, ShouldEqual, ErrLinkExpired)
+		})
+
+		Convey("altered or forged links are rejected", func() {
+			for _, bad := range []string{
+				strings.Replace(token, "extend.3.7.", "remove.3.7.", 1),
+				strings.Replace(token, "extend.3.7.", "extend.4.7.", 1),
+				strings.Replace(token, "extend.3.7.", "extend.3.8.", 1),
+				token[:len(token)-1],
+				token + "x",
+				"",
+				"extend",
+			} {
+				_, err := s.Verify(bad, now)
+				So(err, ShouldEqual, ErrBadLink)
+			}
+
+			other, err := NewSigner([]byte(strings.Repeat("o", MinKeyLength)), "https://tt.example.com:8080")
+			So(err, ShouldBeNil)
+
+			_, err = other.Verify(token, now)
+			So(err, ShouldEqual, ErrBadLink)
+
+			forged, err := other.URL(Extend, 3, 7, expires)
+			So(err, ShouldBeNil)
+
+			_, err = s.Verify(strings.TrimPrefix(forged, "https://tt.example.com:8080"+Path), now)
+			So(err, ShouldEqual, ErrBadLink)
+		})
+
+		Convey("links signed for unknown actions are rejected", func() {
+			url, err := s.URL(Action("delete"), 3, 7, expires)
+			So(err, ShouldBeNil)
+
+			_, err = s.Verify(strings.TrimPrefix(url, "https://tt.example.com:8080"+Path), now)
+			So(err, ShouldEqual, ErrBadLink)
+		})
+	})
+}
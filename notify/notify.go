@@ -0,0 +1,96 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+// package notify lets tt tell users about things happening to the Things they
+// are subscribed to.
+
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/wtsi-hgi/tt/database"
+)
+
+type Error string
+
+func (e Error) Error() string { return string(e) }
+
+const ErrNoRecipients = Error("no recipients to notify")
+
+// Notifier sends a message to some users.
+type Notifier interface {
+	// Notify sends a message with the given subject and body to all the given
+	// users.
+	Notify(users []database.User, subject, body string) error
+}
+
+// SMTP is a Notifier that sends emails via an SMTP server.
+type SMTP struct {
+	addr string
+	from string
+}
+
+// NewSMTP returns an SMTP Notifier that sends emails from the given address via
+// the SMTP server at host:port, without authentication.
+func NewSMTP(host, port, from string) *SMTP {
+	return &SMTP{
+		addr: host + ":" + port,
+		from: from,
+	}
+}
+
+// Notify emails the given subject and body to all the given users in a single
+// message.
+func (s *SMTP) Notify(users []database.User, subject, body string) error {
+	if len(users) == 0 {
+		return ErrNoRecipients
+	}
+
+	to := make([]string, len(users))
+
+	for i, user := range users {
+		to[i] = user.Email
+	}
+
+	return smtp.SendMail(s.addr, nil, s.from, to, s.message(to, subject, body))
+}
+
+func (s *SMTP) message(to []string, subject, body string) []byte {
+	var msg bytes.Buffer
+
+	fmt.Fprintf(&msg, "From: %s\r\n", s.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	return msg.Bytes()
+}
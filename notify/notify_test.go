@@ -0,0 +1,149 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package notify
+
+import (
+	"bufio"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/wtsi-hgi/tt/database"
+)
+
+func TestSMTP(t *testing.T) {
+	Convey("Given an SMTP server, you can notify users by email", t, func() {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+
+		defer ln.Close()
+
+		received := make(chan *fakeMail, 1)
+
+		go serveFakeSMTP(ln, received)
+
+		host, port, err := net.SplitHostPort(ln.Addr().String())
+		So(err, ShouldBeNil)
+
+		n := NewSMTP(host, port, "tt@example.com")
+
+		users := []database.User{
+			{Name: "a", Email: "a@example.com"},
+			{Name: "b", Email: "b@example.com"},
+		}
+
+		err = n.Notify(users, "subject", "line1\nline2")
+		So(err, ShouldBeNil)
+
+		mail := <-received
+		So(mail.from, ShouldEqual, "tt@example.com")
+		So(mail.to, ShouldResemble, []string{"a@example.com", "b@example.com"})
+		So(mail.data, ShouldContainSubstring, "Subject: subject\r\n")
+		So(mail.data, ShouldContainSubstring, "To: a@example.com, b@example.com\r\n")
+		So(mail.data, ShouldEndWith, "\r\n\r\nline1\r\nline2")
+
+		err = n.Notify(nil, "subject", "body")
+		So(err, ShouldEqual, ErrNoRecipients)
+	})
+}
+
+type fakeMail struct {
+	from string
+	to   []string
+	data string
+}
+
+// serveFakeSMTP accepts a single connection on ln and speaks just enough SMTP
+// to receive a mail, which it sends on received.
+func serveFakeSMTP(ln net.Listener, received chan *fakeMail) {
+	conn, err := ln.Accept()
+	if err != nil {
+		return
+	}
+
+	defer conn.Close()
+
+	tp := textproto.NewConn(conn)
+	mail := &fakeMail{}
+
+	tp.PrintfLine("220 localhost ESMTP") //nolint:errcheck
+
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+
+		cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+
+		switch cmd {
+		case "EHLO", "HELO":
+			tp.PrintfLine("250 localhost") //nolint:errcheck
+		case "MAIL":
+			mail.from = strings.Trim(strings.TrimPrefix(line, "MAIL FROM:"), "<>")
+			tp.PrintfLine("250 OK") //nolint:errcheck
+		case "RCPT":
+			mail.to = append(mail.to, strings.Trim(strings.TrimPrefix(line, "RCPT TO:"), "<>"))
+			tp.PrintfLine("250 OK") //nolint:errcheck
+		case "DATA":
+			tp.PrintfLine("354 go ahead") //nolint:errcheck
+
+			data, err := readDotData(tp.R)
+			if err != nil {
+				return
+			}
+
+			mail.data = data
+			tp.PrintfLine("250 OK") //nolint:errcheck
+		case "QUIT":
+			tp.PrintfLine("221 bye") //nolint:errcheck
+			received <- mail
+
+			return
+		default:
+			tp.PrintfLine("250 OK") //nolint:errcheck
+		}
+	}
+}
+
+func readDotData(r *bufio.Reader) (string, error) {
+	var lines []string
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+
+		if line == ".\r\n" {
+			return strings.TrimSuffix(strings.Join(lines, ""), "\r\n"), nil
+		}
+
+		lines = append(lines, line)
+	}
+}
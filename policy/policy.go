@@ -0,0 +1,243 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+// package policy lets administrators define retention rules for the things
+// registered in different storage areas, which the server enforces when things
+// are created and extended, and protect addresses that must never be removed.
+
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/wtsi-hgi/tt/database"
+)
+
+const (
+	ErrNoRemove            = database.Error("A removal date is required")
+	ErrRemoveTooLate       = database.Error("That removal date is later than policy allows")
+	ErrTooManyExtensions   = database.Error("That thing has been extended as many times as policy allows")
+	ErrRemoveNotFuture     = database.Error("A new removal date must be in the future")
+	ErrRemoveNotLater      = database.Error("A new removal date must be later than the current one")
+	ErrNeedsDescription    = database.Error("Policy requires things here to have a description")
+	ErrBadPolicy           = database.Error("Policies can't have negative numbers of days or extensions")
+	errDefaultAfterMaximum = database.Error("default_days can't be more than max_days")
+
+	day = 24 * time.Hour
+)
+
+// Policy is a set of retention rules for things of a ThingsType, or of any type
+// if Type is blank, whose address starts with Prefix (any address if blank).
+type Policy struct {
+	Type   database.ThingsType `json:"type"`
+	Prefix string              `json:"prefix"`
+
+	// DefaultDays is how many days from now things are kept for if they're
+	// registered without a removal date. 0 means they must be given one.
+	DefaultDays int `json:"default_days"`
+
+	// MaxDays is the most days from now that a thing's removal date can be set
+	// to, when registered or extended. 0 means no limit.
+	MaxDays int `json:"max_days"`
+
+	// MaxExtensions is how many times a thing can be extended. nil means no
+	// limit.
+	MaxExtensions *int `json:"max_extensions"`
+
+	// RequireDescription requires things to be registered with a description.
+	RequireDescription bool `json:"require_description"`
+}
+
+// String describes which things the Policy applies to, for use in messages.
+func (p *Policy) String() string {
+	things := "things"
+	if p.Type != "" {
+		things = string(p.Type) + " things"
+	}
+
+	if p.Prefix == "" {
+		return things
+	}
+
+	return things + " under " + p.Prefix
+}
+
+// Validate returns an error if the Policy has a ThingsType that hasn't been
+// registered, or invalid numbers of days or extensions.
+func (p *Policy) Validate() error {
+	if p.Type != "" {
+		if _, err := database.NewThingsType(string(p.Type)); err != nil {
+			return fmt.Errorf("policy for %s: %w", p, err)
+		}
+	}
+
+	if p.DefaultDays < 0 || p.MaxDays < 0 || (p.MaxExtensions != nil && *p.MaxExtensions < 0) {
+		return fmt.Errorf("policy for %s: %w", p, ErrBadPolicy)
+	}
+
+	if p.MaxDays > 0 && p.DefaultDays > p.MaxDays {
+		return fmt.Errorf("policy for %s: %w", p, errDefaultAfterMaximum)
+	}
+
+	return nil
+}
+
+// Create applies the Policy to the params of a thing being created at the given
+// time: giving it the default removal date if it doesn't have one, and
+// returning an error if it has no removal date, a removal date later than
+// allowed, or no description when one is required. Things that expire after
+// they were last used are also limited to expiring MaxDays after that.
+func (p *Policy) Create(params *database.CreateThingParams, now time.Time) error {
+	if p.RequireDescription && strings.TrimSpace(params.Description) == "" {
+		return fmt.Errorf("%w (%s)", ErrNeedsDescription, p)
+	}
+
+	if params.Remove.IsZero() && p.DefaultDays > 0 {
+		params.Remove = daysFrom(now, p.DefaultDays)
+	}
+
+	if params.Remove.IsZero() {
+		return ErrNoRemove
+	}
+
+	if params.ExpiryMode == database.ExpiryModeAccess && p.MaxDays > 0 && params.ExpireAfter > p.MaxDays {
+		return fmt.Errorf("%w: %s can expire at most %d days after they were last used",
+			ErrRemoveTooLate, p, p.MaxDays)
+	}
+
+	return p.checkRemove(params.Remove, now)
+}
+
+// Extend returns an error if a thing that is currently due for removal on the
+// given date, and has already been extended the given number of times, can't
+// be extended again at the given time, or can't be extended to the given
+// removal date. New removal dates must be in the future and later than the
+// current one.
+func (p *Policy) Extend(remove, current time.Time, extensions int, now time.Time) error {
+	if !remove.After(now) {
+		return fmt.Errorf("%w (not %s)", ErrRemoveNotFuture, remove.Format(time.DateOnly))
+	}
+
+	if !remove.After(current) {
+		return fmt.Errorf("%w (currently %s)", ErrRemoveNotLater, current.Format(time.DateOnly))
+	}
+
+	if p.MaxExtensions != nil && extensions >= *p.MaxExtensions {
+		return fmt.Errorf("%w: %s can be extended at most %d times", ErrTooManyExtensions, p, *p.MaxExtensions)
+	}
+
+	return p.checkRemove(remove, now)
+}
+
+// checkRemove returns an error if the given removal date is more than MaxDays
+// after now.
+func (p *Policy) checkRemove(remove, now time.Time) error {
+	if p.MaxDays == 0 {
+		return nil
+	}
+
+	latest := daysFrom(now, p.MaxDays)
+	if remove.After(latest) {
+		return fmt.Errorf("%w: %s can be kept for at most %d days, until %s",
+			ErrRemoveTooLate, p, p.MaxDays, latest.Format(time.DateOnly))
+	}
+
+	return nil
+}
+
+// daysFrom returns the date the given number of days after the date of t.
+func daysFrom(t time.Time, days int) time.Time {
+	y, m, d := t.Date()
+
+	return time.Date(y, m, d+days, 0, 0, 0, 0, time.UTC)
+}
+
+// Policies holds the retention rules for different ThingsTypes and storage
+// areas.
+type Policies []Policy
+
+// Load reads a JSON file containing an array of Policy, and validates them.
+func Load(path string) (Policies, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var policies Policies
+
+	if err = json.Unmarshal(data, &policies); err != nil {
+		return nil, fmt.Errorf("invalid policy config %s: %w", path, err)
+	}
+
+	for i := range policies {
+		if err = policies[i].Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return policies, nil
+}
+
+// For returns the Policy that applies to things of the given type at the given
+// (canonical) address: the one with the longest Prefix that the address is at
+// or under, preferring those for the specific type over those for any type.
+// Returns a Policy with no rules, other than requiring a removal date, if none
+// match.
+func (ps Policies) For(thingsType database.ThingsType, address string) *Policy {
+	best := &Policy{}
+	found := false
+
+	for i := range ps {
+		p := &ps[i]
+
+		if (p.Type != "" && p.Type != thingsType) || !under(address, p.Prefix) {
+			continue
+		}
+
+		if !found || len(p.Prefix) > len(best.Prefix) ||
+			(len(p.Prefix) == len(best.Prefix) && best.Type == "" && p.Type != "") {
+			best = p
+			found = true
+		}
+	}
+
+	return best
+}
+
+// under returns true if the address is the prefix, or is beneath it as a path,
+// so that "/a/b" isn't treated as being under "/a/bc". A blank prefix matches
+// everything.
+func under(address, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix == "" || address == prefix {
+		return true
+	}
+
+	return strings.HasPrefix(address, prefix+"/")
+}
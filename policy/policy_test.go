@@ -0,0 +1,190 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package policy
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	_ "github.com/wtsi-hgi/tt/backend/s3"
+	"github.com/wtsi-hgi/tt/database"
+)
+
+const testPolicies = `[
+	{"prefix": "/lustre/", "default_days": 30, "max_days": 90},
+	{"type": "dir", "prefix": "/lustre/scratch/", "max_days": 60, "max_extensions": 2},
+	{"prefix": "/lustre/scratch/", "require_description": true},
+	{"type": "s3", "max_days": 365}
+]`
+
+func writePolicies(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "policies.json")
+
+	So(os.WriteFile(path, []byte(content), 0o600), ShouldBeNil)
+
+	return path
+}
+
+func TestPolicies(t *testing.T) {
+	Convey("Given a policy config file", t, func() {
+		policies, err := Load(writePolicies(t, testPolicies))
+		So(err, ShouldBeNil)
+		So(len(policies), ShouldEqual, 4)
+
+		now := time.Date(2025, 1, 10, 15, 4, 5, 0, time.UTC)
+
+		Convey("You can find the most specific policy for a thing", func() {
+			p := policies.For(database.ThingsTypeDir, "/lustre/scratch/a")
+			So(p, ShouldEqual, &policies[1])
+			So(p.String(), ShouldEqual, "dir things under /lustre/scratch/")
+
+			So(policies.For(database.ThingsTypeFile, "/lustre/scratch/a"), ShouldEqual, &policies[2])
+			So(policies.For(database.ThingsTypeFile, "/lustre/other/a"), ShouldEqual, &policies[0])
+			So(policies.For(database.ThingsTypeS3, "bucket/key"), ShouldEqual, &policies[3])
+
+			p = policies.For(database.ThingsTypeFile, "/nfs/a")
+			So(*p, ShouldResemble, Policy{})
+			So(p.String(), ShouldEqual, "things")
+		})
+
+		Convey("Prefixes only match whole path components", func() {
+			policies = Policies{{Prefix: "/lustre/scratch"}, {Prefix: "/nfs/"}}
+
+			So(policies.For(database.ThingsTypeDir, "/lustre/scratch"), ShouldEqual, &policies[0])
+			So(policies.For(database.ThingsTypeFile, "/lustre/scratch/a"), ShouldEqual, &policies[0])
+			So(*policies.For(database.ThingsTypeFile, "/lustre/scratch123/a"), ShouldResemble, Policy{})
+			So(*policies.For(database.ThingsTypeFile, "/lustre/scratc"), ShouldResemble, Policy{})
+
+			So(policies.For(database.ThingsTypeDir, "/nfs"), ShouldEqual, &policies[1])
+			So(policies.For(database.ThingsTypeFile, "/nfs/a"), ShouldEqual, &policies[1])
+			So(*policies.For(database.ThingsTypeFile, "/nfsx/a"), ShouldResemble, Policy{})
+		})
+
+		Convey("Creating things applies default removal dates", func() {
+			params := &database.CreateThingParams{Type: database.ThingsTypeFile, Address: "/lustre/other/a"}
+
+			So(policies.For(params.Type, params.Address).Create(params, now), ShouldBeNil)
+			So(params.Remove, ShouldEqual, time.Date(2025, 2, 9, 0, 0, 0, 0, time.UTC))
+
+			params = &database.CreateThingParams{Type: database.ThingsTypeFile, Address: "/nfs/a"}
+			So(policies.For(params.Type, params.Address).Create(params, now), ShouldEqual, ErrNoRemove)
+		})
+
+		Convey("Creating things enforces maximum removal dates", func() {
+			p := policies.For(database.ThingsTypeFile, "/lustre/other/a")
+			params := &database.CreateThingParams{Remove: time.Date(2025, 4, 10, 0, 0, 0, 0, time.UTC)}
+			So(p.Create(params, now), ShouldBeNil)
+
+			params.Remove = params.Remove.Add(day)
+			err := p.Create(params, now)
+			So(errors.Is(err, ErrRemoveTooLate), ShouldBeTrue)
+			So(err.Error(), ShouldEqual, "That removal date is later than policy allows: "+
+				"things under /lustre/ can be kept for at most 90 days, until 2025-04-10")
+
+			params = &database.CreateThingParams{
+				Remove:      now,
+				ExpiryMode:  database.ExpiryModeAccess,
+				ExpireAfter: 91,
+			}
+			err = p.Create(params, now)
+			So(errors.Is(err, ErrRemoveTooLate), ShouldBeTrue)
+			So(err.Error(), ShouldContainSubstring, "can expire at most 90 days after they were last used")
+
+			params.ExpireAfter = 90
+			So(p.Create(params, now), ShouldBeNil)
+		})
+
+		Convey("Creating things can require a description", func() {
+			p := policies.For(database.ThingsTypeFile, "/lustre/scratch/a")
+			params := &database.CreateThingParams{Remove: now, Description: " "}
+
+			err := p.Create(params, now)
+			So(errors.Is(err, ErrNeedsDescription), ShouldBeTrue)
+			So(err.Error(), ShouldContainSubstring, "(things under /lustre/scratch/)")
+
+			params.Description = "my results"
+			So(p.Create(params, now), ShouldBeNil)
+		})
+
+		Convey("Extending things enforces the maximum extensions and removal date", func() {
+			p := policies.For(database.ThingsTypeDir, "/lustre/scratch/a")
+			remove := time.Date(2025, 3, 11, 0, 0, 0, 0, time.UTC)
+
+			So(p.Extend(remove, now, 0, now), ShouldBeNil)
+			So(p.Extend(remove, now, 1, now), ShouldBeNil)
+
+			err := p.Extend(remove, now, 2, now)
+			So(errors.Is(err, ErrTooManyExtensions), ShouldBeTrue)
+			So(err.Error(), ShouldContainSubstring, "can be extended at most 2 times")
+
+			err = p.Extend(remove.Add(day), now, 0, now)
+			So(errors.Is(err, ErrRemoveTooLate), ShouldBeTrue)
+
+			So(policies.For(database.ThingsTypeFile, "/nfs/a").Extend(remove.AddDate(10, 0, 0), now, 100, now), ShouldBeNil)
+		})
+
+		Convey("Things can only be extended to later dates in the future", func() {
+			p := policies.For(database.ThingsTypeFile, "/nfs/a")
+			current := time.Date(2025, 1, 20, 0, 0, 0, 0, time.UTC)
+
+			So(p.Extend(current.Add(day), current, 0, now), ShouldBeNil)
+
+			err := p.Extend(time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC), now, 0, now)
+			So(errors.Is(err, ErrRemoveNotFuture), ShouldBeTrue)
+			So(err.Error(), ShouldContainSubstring, "(not 2025-01-10)")
+
+			err = p.Extend(current, current, 0, now)
+			So(errors.Is(err, ErrRemoveNotLater), ShouldBeTrue)
+			So(err.Error(), ShouldContainSubstring, "(currently 2025-01-20)")
+
+			err = p.Extend(current.Add(-day), current, 0, now)
+			So(errors.Is(err, ErrRemoveNotLater), ShouldBeTrue)
+		})
+	})
+
+	Convey("Invalid policy config files can't be loaded", t, func() {
+		_, err := Load(filepath.Join(t.TempDir(), "missing.json"))
+		So(err, ShouldNotBeNil)
+
+		_, err = Load(writePolicies(t, "{"))
+		So(err.Error(), ShouldContainSubstring, "invalid policy config")
+
+		_, err = Load(writePolicies(t, `[{"type": "unknown"}]`))
+		So(errors.Is(err, database.ErrBadType), ShouldBeTrue)
+
+		_, err = Load(writePolicies(t, `[{"max_extensions": -1}]`))
+		So(errors.Is(err, ErrBadPolicy), ShouldBeTrue)
+
+		_, err = Load(writePolicies(t, `[{"default_days": 10, "max_days": 5}]`))
+		So(errors.Is(err, errDefaultAfterMaximum), ShouldBeTrue)
+	})
+}
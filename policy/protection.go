@@ -0,0 +1,223 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/wtsi-hgi/tt/database"
+)
+
+const ErrProtected = database.Error("That address is protected, so can't be registered for removal")
+
+// Protection defines the addresses that things can be registered at, and so
+// removed from: only those within the AllowRoots for their ThingsType (if any
+// are configured for that type), and never those matching any of the
+// DenyPatterns, nor those containing addresses that could match them.
+type Protection struct {
+	// AllowRoots are, per ThingsType, the addresses that things of that type
+	// must be within. The roots themselves are not allowed.
+	AllowRoots map[database.ThingsType][]string `json:"allow_roots"`
+
+	// DenyPatterns are path.Match() patterns (eg. "/home/*") of addresses that
+	// things of any type must not have, or be parents of (eg. "/home").
+	DenyPatterns []string `json:"deny_patterns"`
+}
+
+// LoadProtection reads a JSON file containing a Protection, and validates it.
+// The roots are converted to the canonical address form of their type.
+func LoadProtection(path string) (*Protection, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Protection{}
+
+	if err = json.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("invalid protection config %s: %w", path, err)
+	}
+
+	if err = p.Validate(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// Validate returns an error if the Protection has roots for ThingsTypes that
+// haven't been registered, or has malformed DenyPatterns. Roots are converted
+// to the canonical address form of their type.
+func (p *Protection) Validate() error {
+	for thingsType, roots := range p.AllowRoots {
+		if _, err := database.NewThingsType(string(thingsType)); err != nil {
+			return fmt.Errorf("allow_roots: %w", err)
+		}
+
+		for i, root := range roots {
+			roots[i] = thingsType.CanonicalAddress(root)
+		}
+	}
+
+	for _, pattern := range p.DenyPatterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("deny_patterns %q: %w", pattern, err)
+		}
+	}
+
+	return nil
+}
+
+// Check returns an error if the given address (which will be canonicalised)
+// isn't within one of the AllowRoots for the given ThingsType, or matches one
+// of the DenyPatterns, or is a parent of addresses that could match one. A nil
+// Protection allows everything.
+//
+// For dir and file things, the address with any symlinks resolved must also
+// pass these checks, so that symlinks can't be used to get around them.
+func (p *Protection) Check(thingsType database.ThingsType, address string) error {
+	if p == nil {
+		return nil
+	}
+
+	address = thingsType.CanonicalAddress(address)
+	roots, restricted := p.AllowRoots[thingsType]
+	addresses := []string{address}
+
+	if isFilesystem(thingsType) {
+		roots = withResolved(roots)
+
+		if resolved := resolve(address); resolved != address {
+			addresses = append(addresses, resolved)
+		}
+	}
+
+	for _, address := range addresses {
+		if restricted && !within(address, roots) {
+			return fmt.Errorf("%w: %s is not within any of the allowed %s roots (%s)",
+				ErrProtected, address, thingsType, strings.Join(p.AllowRoots[thingsType], ", "))
+		}
+
+		if err := p.checkDenied(address); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkDenied returns an error if the given address matches one of the
+// DenyPatterns, or is a parent of addresses that could.
+func (p *Protection) checkDenied(address string) error {
+	for _, pattern := range p.DenyPatterns {
+		if matched, err := path.Match(pattern, address); err == nil && matched {
+			return fmt.Errorf("%w: %s matches %s", ErrProtected, address, pattern)
+		}
+
+		if isParentOfMatches(address, pattern) {
+			return fmt.Errorf("%w: %s contains addresses matching %s", ErrProtected, address, pattern)
+		}
+	}
+
+	return nil
+}
+
+// isParentOfMatches returns true if the given address has fewer path elements
+// than the pattern, and each of them matches the pattern's element at the same
+// position, so that things within the address could match the pattern.
+func isParentOfMatches(address, pattern string) bool {
+	addressElements := strings.Split(strings.TrimSuffix(address, "/"), "/")
+	patternElements := strings.Split(pattern, "/")
+
+	if len(addressElements) >= len(patternElements) {
+		return false
+	}
+
+	for i, element := range addressElements {
+		if matched, err := path.Match(patternElements[i], element); err != nil || !matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isFilesystem returns true if things of the given type are at local
+// filesystem paths, which could contain symlinks.
+func isFilesystem(thingsType database.ThingsType) bool {
+	return thingsType == database.ThingsTypeDir || thingsType == database.ThingsTypeFile
+}
+
+// withResolved returns the given roots along with any that differ once their
+// symlinks are resolved.
+func withResolved(roots []string) []string {
+	all := slices.Clone(roots)
+
+	for _, root := range roots {
+		if resolved := resolve(root); resolved != root {
+			all = append(all, resolved)
+		}
+	}
+
+	return all
+}
+
+// resolve returns the given path with any symlinks in it resolved. If it
+// doesn't exist, its nearest existing parent is resolved instead, with the
+// rest of the path added back.
+func resolve(address string) string {
+	rest := ""
+
+	for dir := address; ; dir = filepath.Dir(dir) {
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			return filepath.Join(resolved, rest)
+		}
+
+		if dir == filepath.Dir(dir) {
+			return address
+		}
+
+		rest = filepath.Join(filepath.Base(dir), rest)
+	}
+}
+
+// within returns true if the given address is inside one of the given roots
+// (but isn't a root itself).
+func within(address string, roots []string) bool {
+	for _, root := range roots {
+		if address != root && strings.HasPrefix(address, strings.TrimSuffix(root, "/")+"/") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,153 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package policy
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/wtsi-hgi/tt/database"
+)
+
+const testProtection = `{
+	"allow_roots": {"dir": ["/lustre/scratch/", "/nfs/team"], "s3": ["s3://scratch/"]},
+	"deny_patterns": ["/lustre/scratch/projects/*", "/*"]
+}`
+
+func TestProtection(t *testing.T) {
+	Convey("Given a protection config file", t, func() {
+		p, err := LoadProtection(writePolicies(t, testProtection))
+		So(err, ShouldBeNil)
+		So(p.AllowRoots[database.ThingsTypeDir], ShouldResemble, []string{"/lustre/scratch", "/nfs/team"})
+
+		Convey("Only addresses within the allowed roots for their type are allowed", func() {
+			So(p.Check(database.ThingsTypeDir, "/lustre/scratch/a"), ShouldBeNil)
+			So(p.Check(database.ThingsTypeDir, "/nfs/team/a/b/../c"), ShouldBeNil)
+			So(p.Check(database.ThingsTypeS3, "s3://scratch/key"), ShouldBeNil)
+
+			for _, address := range []string{"/lustre/scratch", "/lustre/scratch/", "/lustre/scratch2/a",
+				"/nfs/team/a/../..", "/home/user/a"} {
+				err := p.Check(database.ThingsTypeDir, address)
+				So(errors.Is(err, ErrProtected), ShouldBeTrue)
+			}
+
+			err := p.Check(database.ThingsTypeDir, "/home/user/a")
+			So(err.Error(), ShouldEqual, ErrProtected.Error()+": /home/user/a is not within any of the "+
+				"allowed dir roots (/lustre/scratch, /nfs/team)")
+
+			So(errors.Is(p.Check(database.ThingsTypeS3, "s3://other/key"), ErrProtected), ShouldBeTrue)
+			So(p.Check(database.ThingsTypeFile, "/home/user/a"), ShouldBeNil)
+		})
+
+		Convey("Addresses matching the deny patterns are not allowed", func() {
+			err := p.Check(database.ThingsTypeDir, "/lustre/scratch/projects/big")
+			So(errors.Is(err, ErrProtected), ShouldBeTrue)
+			So(err.Error(), ShouldEndWith, "/lustre/scratch/projects/big matches /lustre/scratch/projects/*")
+
+			So(p.Check(database.ThingsTypeDir, "/lustre/scratch/projects/big/tmp"), ShouldBeNil)
+			So(errors.Is(p.Check(database.ThingsTypeFile, "/home/"), ErrProtected), ShouldBeTrue)
+			So(errors.Is(p.Check(database.ThingsTypeFile, "/"), ErrProtected), ShouldBeTrue)
+		})
+
+		Convey("Nor are addresses containing things that match the deny patterns", func() {
+			for _, address := range []string{"/lustre/scratch/projects", "/lustre/scratch/projects/"} {
+				err := p.Check(database.ThingsTypeDir, address)
+				So(errors.Is(err, ErrProtected), ShouldBeTrue)
+				So(err.Error(), ShouldEndWith, "/lustre/scratch/projects contains addresses matching "+
+					"/lustre/scratch/projects/*")
+			}
+
+			So(p.Check(database.ThingsTypeDir, "/lustre/scratch/other"), ShouldBeNil)
+		})
+	})
+
+	Convey("Given a protection with roots and deny patterns involving symlinks", t, func() {
+		dir := t.TempDir()
+		allowed := filepath.Join(dir, "allowed")
+		projects := filepath.Join(allowed, "projects")
+		outside := filepath.Join(dir, "outside")
+
+		So(os.MkdirAll(projects, 0o755), ShouldBeNil)
+		So(os.MkdirAll(outside, 0o755), ShouldBeNil)
+		So(os.Symlink(outside, filepath.Join(allowed, "out")), ShouldBeNil)
+		So(os.Symlink(projects, filepath.Join(allowed, "proj")), ShouldBeNil)
+		So(os.Symlink(allowed, filepath.Join(dir, "root")), ShouldBeNil)
+
+		resolvedDir, err := filepath.EvalSymlinks(dir)
+		So(err, ShouldBeNil)
+
+		p := &Protection{
+			AllowRoots: map[database.ThingsType][]string{
+				database.ThingsTypeDir: {filepath.Join(dir, "root")},
+			},
+			DenyPatterns: []string{filepath.Join(resolvedDir, "allowed", "projects", "*")},
+		}
+		So(p.Validate(), ShouldBeNil)
+
+		Convey("Addresses are allowed if they resolve to within a resolved root", func() {
+			So(p.Check(database.ThingsTypeDir, filepath.Join(dir, "root", "a")), ShouldBeNil)
+			So(p.Check(database.ThingsTypeDir, filepath.Join(allowed, "a", "b")), ShouldBeNil)
+		})
+
+		Convey("Symlinks can't be used to get outside the roots", func() {
+			for _, address := range []string{filepath.Join(allowed, "out"), filepath.Join(allowed, "out", "a")} {
+				err := p.Check(database.ThingsTypeDir, address)
+				So(errors.Is(err, ErrProtected), ShouldBeTrue)
+				So(err.Error(), ShouldContainSubstring, "is not within any of the allowed dir roots")
+			}
+		})
+
+		Convey("Symlinks can't be used to get around the deny patterns", func() {
+			for _, address := range []string{filepath.Join(allowed, "proj"), filepath.Join(allowed, "proj", "big")} {
+				So(errors.Is(p.Check(database.ThingsTypeDir, address), ErrProtected), ShouldBeTrue)
+			}
+
+			So(p.Check(database.ThingsTypeDir, filepath.Join(allowed, "proj", "big", "tmp")), ShouldBeNil)
+		})
+	})
+
+	Convey("A nil Protection allows everything", t, func() {
+		var p *Protection
+		So(p.Check(database.ThingsTypeDir, "/"), ShouldBeNil)
+	})
+
+	Convey("Invalid protection config files can't be loaded", t, func() {
+		_, err := LoadProtection(filepath.Join(t.TempDir(), "missing.json"))
+		So(err, ShouldNotBeNil)
+
+		_, err = LoadProtection(writePolicies(t, "["))
+		So(err.Error(), ShouldContainSubstring, "invalid protection config")
+
+		_, err = LoadProtection(writePolicies(t, `{"allow_roots": {"unknown": ["/a"]}}`))
+		So(errors.Is(err, database.ErrBadType), ShouldBeTrue)
+
+		_, err = LoadProtection(writePolicies(t, `{"deny_patterns": ["/a/["]}`))
+		So(err.Error(), ShouldContainSubstring, "syntax error in pattern")
+	})
+}
@@ -0,0 +1,509 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package policy
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wtsi-hgi/tt/database"
+)
+
+const ErrBadCalendar = database.Error("Invalid calendar")
+
+// errUnsupportedRecurrence is returned for recurring events that we can't
+// expand, which are skipped.
+var errUnsupportedRecurrence = errors.New("unsupported recurrence")
+
+const (
+	icalDate     = "20060102"
+	icalDateTime = "20060102T150405"
+	daysPerWeek  = 7
+
+	// calendarHorizon is how far ahead of now recurring events without a
+	// COUNT or UNTIL are expanded.
+	calendarHorizon = 5 * 365 * 24 * time.Hour
+)
+
+// Closure is a period when nothing should be removed, such as an institute
+// closure day or a maintenance window.
+type Closure struct {
+	Summary string
+	Start   time.Time
+	End     time.Time
+}
+
+// Schedule decides when things that are due for removal are actually acted
+// on: only once a Grace period after their removal date has passed, and never
+// at weekends (if SkipWeekends) or during any of the Closures. A nil Schedule
+// lets things be removed as soon as they're due.
+type Schedule struct {
+	Grace        time.Duration
+	SkipWeekends bool
+	Closures     []Closure
+}
+
+// Remove returns when a thing due for removal at the given time will actually
+// be removed: the first allowed time after its Grace period has passed.
+func (s *Schedule) Remove(due time.Time) time.Time {
+	if s == nil {
+		return due
+	}
+
+	return s.Next(due.Add(s.Grace))
+}
+
+// Scheduled returns when the given thing will actually be removed: the Remove()
+// time of its EffectiveRemove() date. This is the date that its subscribers are
+// told about, and that the reaper acts on.
+func (s *Schedule) Scheduled(thing *database.Thing) time.Time {
+	return s.Remove(thing.EffectiveRemove())
+}
+
+// Allowed returns true if things may be removed at the given time.
+func (s *Schedule) Allowed(t time.Time) bool {
+	return s.Next(t).Equal(t)
+}
+
+// Next returns the earliest time at or after the given one when things may be
+// removed.
+func (s *Schedule) Next(t time.Time) time.Time {
+	if s == nil {
+		return t
+	}
+
+	for {
+		next := s.skip(t)
+		if next.Equal(t) {
+			return t
+		}
+
+		t = next
+	}
+}
+
+// skip returns the end of the weekend or Closure that the given time is in, or
+// the time itself if it's in neither.
+func (s *Schedule) skip(t time.Time) time.Time {
+	if local := t.Local(); s.SkipWeekends && (local.Weekday() == time.Saturday || local.Weekday() == time.Sunday) {
+		days := (daysPerWeek + int(time.Monday) - int(local.Weekday())) % daysPerWeek
+		y, m, d := local.AddDate(0, 0, days).Date()
+
+		return time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+	}
+
+	for _, closure := range s.Closures {
+		if !t.Before(closure.Start) && t.Before(closure.End) {
+			return closure.End
+		}
+	}
+
+	return t
+}
+
+// LoadCalendar reads the events in an iCalendar (.ics) file as Closures. See
+// ParseCalendar.
+func LoadCalendar(path string, logger *log.Logger) ([]Closure, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	closures, err := ParseCalendar(f, logger)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return closures, nil
+}
+
+// ParseCalendar reads the VEVENTs in iCalendar data as Closures, from their
+// DTSTART to their DTEND, or for their DURATION. Without either, all-day events
+// last one day, and others are instantaneous. Dates and times without a time
+// zone are taken to be local.
+//
+// Events that recur with an RRULE of a FREQ (DAILY, WEEKLY, MONTHLY or YEARLY)
+// and optionally an INTERVAL, COUNT or UNTIL, are expanded in to a Closure for
+// each occurrence not excluded by an EXDATE. Those without a COUNT or UNTIL are
+// expanded up to 5 years from now. Other recurring events, such as those with
+// an RDATE or BYDAY, are skipped, with a warning logged to the given logger.
+func ParseCalendar(r io.Reader, logger *log.Logger) ([]Closure, error) {
+	lines, err := unfoldCalendar(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		closures []Closure
+		event    *calendarEvent
+	)
+
+	for _, line := range lines {
+		name, params, value := splitCalendarLine(line)
+
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			event = &calendarEvent{}
+		case event == nil:
+			continue
+		case name == "END" && value == "VEVENT":
+			occurrences, err := event.closures()
+			if errors.Is(err, errUnsupportedRecurrence) {
+				logger.Printf("skipping calendar event %q: %s", event.summary, err)
+			} else if err != nil {
+				return nil, err
+			}
+
+			closures = append(closures, occurrences...)
+			event = nil
+		default:
+			if err := event.set(name, params, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return closures, nil
+}
+
+// unfoldCalendar returns the lines of iCalendar data, joining lines that were
+// folded on to continuation lines starting with whitespace.
+func unfoldCalendar(r io.Reader) ([]string, error) {
+	var lines []string
+
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+
+			continue
+		}
+
+		lines = append(lines, line)
+	}
+
+	return lines, scanner.Err()
+}
+
+// splitCalendarLine splits an iCalendar content line like
+// "DTSTART;TZID=Europe/London:20250102T090000" in to its upper-cased name,
+// its parameters and its value.
+func splitCalendarLine(line string) (string, map[string]string, string) {
+	nameAndParams, value, _ := strings.Cut(line, ":")
+	parts := strings.Split(nameAndParams, ";")
+	params := make(map[string]string, len(parts)-1)
+
+	for _, param := range parts[1:] {
+		key, val, _ := strings.Cut(param, "=")
+		params[strings.ToUpper(key)] = strings.Trim(val, `"`)
+	}
+
+	return strings.ToUpper(parts[0]), params, value
+}
+
+// calendarEvent holds the properties of a VEVENT we care about.
+type calendarEvent struct {
+	summary       string
+	start, end    time.Time
+	allDay        bool
+	hasDuration   bool
+	durationDays  int
+	durationClock time.Duration
+	rule          string
+	exdates       []time.Time
+	unsupported   string
+}
+
+// set records the given property of the event.
+func (e *calendarEvent) set(name string, params map[string]string, value string) error {
+	var err error
+
+	switch name {
+	case "SUMMARY":
+		e.summary = strings.NewReplacer(`\,`, ",", `\;`, ";", `\n`, " ", `\N`, " ", `\\`, `\`).Replace(value)
+	case "DTSTART":
+		e.start, e.allDay, err = parseCalendarTime(params, value)
+	case "DTEND":
+		e.end, _, err = parseCalendarTime(params, value)
+	case "DURATION":
+		e.hasDuration = true
+		e.durationDays, e.durationClock, err = parseCalendarDuration(value)
+	case "RRULE":
+		e.rule = value
+	case "EXDATE":
+		for _, v := range strings.Split(value, ",") {
+			exdate, _, err := parseCalendarTime(params, v)
+			if err != nil {
+				return err
+			}
+
+			e.exdates = append(e.exdates, exdate)
+		}
+	case "RDATE":
+		e.unsupported = "RDATE"
+	}
+
+	return err
+}
+
+// closures returns the event as a Closure for each of its occurrences.
+func (e *calendarEvent) closures() ([]Closure, error) {
+	if e.start.IsZero() {
+		return nil, fmt.Errorf("%w: event %q has no DTSTART", ErrBadCalendar, e.summary)
+	}
+
+	switch {
+	case !e.end.IsZero():
+	case e.hasDuration:
+		e.end = e.start.AddDate(0, 0, e.durationDays).Add(e.durationClock)
+	case e.allDay:
+		e.end = e.start.AddDate(0, 0, 1)
+	default:
+		e.end = e.start
+	}
+
+	if e.end.Before(e.start) {
+		return nil, fmt.Errorf("%w: event %q ends before it starts", ErrBadCalendar, e.summary)
+	}
+
+	if e.unsupported != "" {
+		return nil, fmt.Errorf("%w: %s", errUnsupportedRecurrence, e.unsupported)
+	}
+
+	if e.rule == "" {
+		return []Closure{{Summary: e.summary, Start: e.start, End: e.end}}, nil
+	}
+
+	rule, err := parseCalendarRule(e.rule)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.recur(rule), nil
+}
+
+// recur returns a Closure for each occurrence of the event according to the
+// rule, except those excluded by its EXDATEs.
+func (e *calendarEvent) recur(rule *calendarRule) []Closure {
+	var closures []Closure
+
+	until := rule.until
+	if until.IsZero() {
+		until = time.Now().Add(calendarHorizon)
+	}
+
+	for i, n := 0, 0; rule.count == 0 || n < rule.count; i++ {
+		years, months, days := rule.years*i, rule.months*i, rule.days*i
+		start := e.start.AddDate(years, months, days)
+
+		if start.After(until) {
+			break
+		}
+
+		// monthly and yearly events skip months without their day, eg. the
+		// 31st, or the 29th of February.
+		if start.Day() != e.start.Day() && rule.days == 0 {
+			continue
+		}
+
+		n++
+
+		if slices.ContainsFunc(e.exdates, start.Equal) {
+			continue
+		}
+
+		closures = append(closures, Closure{Summary: e.summary, Start: start, End: e.end.AddDate(years, months, days)})
+	}
+
+	return closures
+}
+
+// calendarRule is a simple RRULE: every occurrence is the given number of
+// years, months and days after the previous one, until there have been count of
+// them (if not 0), or until the given time (if not zero).
+type calendarRule struct {
+	years, months, days int
+	count               int
+	until               time.Time
+}
+
+// parseCalendarRule parses an RRULE value like
+// "FREQ=WEEKLY;INTERVAL=2;COUNT=3". Returns an error that Is()
+// errUnsupportedRecurrence if it uses any parts we don't support.
+func parseCalendarRule(value string) (*calendarRule, error) {
+	rule := &calendarRule{}
+	interval := 1
+	freq := ""
+
+	for _, part := range strings.Split(value, ";") {
+		key, val, _ := strings.Cut(part, "=")
+
+		var err error
+
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			freq = strings.ToUpper(val)
+		case "INTERVAL":
+			interval, err = strconv.Atoi(val)
+		case "COUNT":
+			rule.count, err = strconv.Atoi(val)
+		case "UNTIL":
+			rule.until, _, err = parseCalendarTime(nil, val)
+		case "WKST":
+		default:
+			return nil, fmt.Errorf("%w: RRULE %s", errUnsupportedRecurrence, key)
+		}
+
+		if err != nil || interval < 1 || rule.count < 0 {
+			return nil, fmt.Errorf("%w: invalid RRULE %q", ErrBadCalendar, value)
+		}
+	}
+
+	switch freq {
+	case "DAILY":
+		rule.days = interval
+	case "WEEKLY":
+		rule.days = interval * daysPerWeek
+	case "MONTHLY":
+		rule.months = interval
+	case "YEARLY":
+		rule.years = interval
+	default:
+		return nil, fmt.Errorf("%w: RRULE FREQ %q", errUnsupportedRecurrence, freq)
+	}
+
+	return rule, nil
+}
+
+// parseCalendarDuration parses an iCalendar DURATION value like "P1DT2H30M",
+// returning its weeks and days as a number of days, and the rest as a
+// time.Duration.
+func parseCalendarDuration(value string) (int, time.Duration, error) {
+	rest, ok := strings.CutPrefix(strings.TrimPrefix(value, "+"), "P")
+	if !ok || rest == "" {
+		return 0, 0, fmt.Errorf("%w: invalid DURATION %q", ErrBadCalendar, value)
+	}
+
+	var (
+		days   int
+		clock  time.Duration
+		inTime bool
+		digits string
+	)
+
+	for _, r := range rest {
+		if r >= '0' && r <= '9' {
+			digits += string(r)
+
+			continue
+		}
+
+		if r == 'T' && !inTime && digits == "" {
+			inTime = true
+
+			continue
+		}
+
+		n, err := strconv.Atoi(digits)
+		if err != nil {
+			return 0, 0, fmt.Errorf("%w: invalid DURATION %q", ErrBadCalendar, value)
+		}
+
+		digits = ""
+
+		switch {
+		case !inTime && r == 'W':
+			days += n * daysPerWeek
+		case !inTime && r == 'D':
+			days += n
+		case inTime && r == 'H':
+			clock += time.Duration(n) * time.Hour
+		case inTime && r == 'M':
+			clock += time.Duration(n) * time.Minute
+		case inTime && r == 'S':
+			clock += time.Duration(n) * time.Second
+		default:
+			return 0, 0, fmt.Errorf("%w: invalid DURATION %q", ErrBadCalendar, value)
+		}
+	}
+
+	if digits != "" {
+		return 0, 0, fmt.Errorf("%w: invalid DURATION %q", ErrBadCalendar, value)
+	}
+
+	return days, clock, nil
+}
+
+// parseCalendarTime parses an iCalendar DATE or DATE-TIME value, returning
+// true if it was a DATE.
+func parseCalendarTime(params map[string]string, value string) (time.Time, bool, error) {
+	if params["VALUE"] == "DATE" || len(value) == len(icalDate) {
+		t, err := time.ParseInLocation(icalDate, value, time.Local)
+
+		return t, true, calendarTimeError(err)
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse(icalDateTime+"Z", value)
+
+		return t, false, calendarTimeError(err)
+	}
+
+	loc := time.Local
+
+	if tzid := params["TZID"]; tzid != "" {
+		var err error
+
+		if loc, err = time.LoadLocation(tzid); err != nil {
+			return time.Time{}, false, calendarTimeError(err)
+		}
+	}
+
+	t, err := time.ParseInLocation(icalDateTime, value, loc)
+
+	return t, false, calendarTimeError(err)
+}
+
+func calendarTimeError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %w", ErrBadCalendar, err)
+}
@@ -0,0 +1,172 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package policy
+
+import (
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	null "github.com/guregu/null/v5"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/wtsi-hgi/tt/database"
+)
+
+const testCalendar = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Institute closure\\, summer\r\n" +
+	"DTSTART;VALUE=DATE:20250609\r\n" +
+	"DTEND;VALUE=DATE:20250611\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Bank holiday\r\n" +
+	"DTSTART;VALUE=DATE:20250616\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Storage\r\n" +
+	"  maintenance\r\n" +
+	"DTSTART:20250617T080000Z\r\n" +
+	"DTEND:20250617T120000Z\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestSchedule(t *testing.T) {
+	day := func(d, h int) time.Time { return time.Date(2025, 6, d, h, 0, 0, 0, time.Local) }
+
+	Convey("You can parse closures from an iCalendar file", t, func() {
+		closures, err := LoadCalendar(writePolicies(t, testCalendar), log.New(io.Discard, "", 0))
+		So(err, ShouldBeNil)
+		So(len(closures), ShouldEqual, 3)
+
+		So(closures[0].Summary, ShouldEqual, "Institute closure, summer")
+		So(closures[0].Start, ShouldEqual, day(9, 0))
+		So(closures[0].End, ShouldEqual, day(11, 0))
+		So(closures[1].Start, ShouldEqual, day(16, 0))
+		So(closures[1].End, ShouldEqual, day(17, 0))
+		So(closures[2].Summary, ShouldEqual, "Storage maintenance")
+		So(closures[2].Start, ShouldEqual, time.Date(2025, 6, 17, 8, 0, 0, 0, time.UTC))
+		So(closures[2].End, ShouldEqual, time.Date(2025, 6, 17, 12, 0, 0, 0, time.UTC))
+
+		Convey("Which are used to schedule removals along with a grace period and weekends", func() {
+			s := &Schedule{Grace: 24 * time.Hour, SkipWeekends: true, Closures: closures}
+
+			So(s.Remove(day(3, 0)), ShouldEqual, day(4, 0))
+			So(s.Remove(day(6, 0)), ShouldEqual, day(11, 0))
+			So(s.Remove(day(15, 0)), ShouldEqual, day(17, 0))
+			So(s.Allowed(day(7, 12)), ShouldBeFalse)
+			So(s.Allowed(day(10, 12)), ShouldBeFalse)
+			So(s.Allowed(day(12, 12)), ShouldBeTrue)
+			So(s.Next(closures[2].Start), ShouldEqual, closures[2].End)
+
+			thing := &database.Thing{
+				Remove:      day(1, 0),
+				ExpiryMode:  database.ExpiryModeAccess,
+				ExpireAfter: 5,
+				Modified:    null.TimeFrom(day(1, 12)),
+			}
+			So(s.Scheduled(thing), ShouldEqual, day(11, 0))
+		})
+	})
+
+	Convey("A nil Schedule allows removal when things are due", t, func() {
+		var s *Schedule
+
+		So(s.Remove(day(7, 12)), ShouldEqual, day(7, 12))
+		So(s.Allowed(day(7, 12)), ShouldBeTrue)
+		So(s.Scheduled(&database.Thing{Remove: day(7, 0)}), ShouldEqual, day(7, 0))
+	})
+
+	Convey("Calendar events can last for a DURATION, or be instantaneous", t, func() {
+		closures, err := ParseCalendar(strings.NewReader("BEGIN:VEVENT\nDTSTART:20250610T090000Z\n"+
+			"DURATION:P1DT2H30M\nEND:VEVENT\nBEGIN:VEVENT\nDTSTART:20250609\nDURATION:P2W\nEND:VEVENT\n"+
+			"BEGIN:VEVENT\nDTSTART:20250611T090000Z\nEND:VEVENT\n"), log.New(io.Discard, "", 0))
+		So(err, ShouldBeNil)
+		So(len(closures), ShouldEqual, 3)
+
+		start := time.Date(2025, 6, 10, 9, 0, 0, 0, time.UTC)
+		So(closures[0].Start, ShouldEqual, start)
+		So(closures[0].End, ShouldEqual, start.Add(26*time.Hour+30*time.Minute))
+		So(closures[1].Start, ShouldEqual, day(9, 0))
+		So(closures[1].End, ShouldEqual, day(23, 0))
+		So(closures[2].Start, ShouldEqual, start.AddDate(0, 0, 1))
+		So(closures[2].End, ShouldEqual, closures[2].Start)
+	})
+
+	Convey("Simple recurring calendar events are expanded, and others are skipped with a warning", t, func() {
+		var logs strings.Builder
+
+		closures, err := ParseCalendar(strings.NewReader(
+			"BEGIN:VEVENT\nSUMMARY:weekly\nDTSTART:20250602T080000Z\nDTEND:20250602T100000Z\n"+
+				"RRULE:FREQ=WEEKLY;COUNT=3\nEXDATE:20250609T080000Z\nEND:VEVENT\n"+
+				"BEGIN:VEVENT\nSUMMARY:monthly\nDTSTART:20250131\nRRULE:FREQ=MONTHLY;INTERVAL=1;UNTIL=20250401\n"+
+				"END:VEVENT\n"+
+				"BEGIN:VEVENT\nSUMMARY:mondays\nDTSTART:20250602\nRRULE:FREQ=WEEKLY;BYDAY=MO\nEND:VEVENT\n"+
+				"BEGIN:VEVENT\nSUMMARY:extra\nDTSTART:20250602\nRDATE:20250603\nEND:VEVENT\n"+
+				"BEGIN:VEVENT\nSUMMARY:christmas\nDTSTART:20241225\nRRULE:FREQ=YEARLY\nEND:VEVENT\n"),
+			log.New(&logs, "", 0))
+		So(err, ShouldBeNil)
+
+		So(closures[0].Summary, ShouldEqual, "weekly")
+		So(closures[0].Start, ShouldEqual, time.Date(2025, 6, 2, 8, 0, 0, 0, time.UTC))
+		So(closures[0].End, ShouldEqual, time.Date(2025, 6, 2, 10, 0, 0, 0, time.UTC))
+		So(closures[1].Start, ShouldEqual, time.Date(2025, 6, 16, 8, 0, 0, 0, time.UTC))
+		So(closures[1].End, ShouldEqual, time.Date(2025, 6, 16, 10, 0, 0, 0, time.UTC))
+
+		So(closures[2].Summary, ShouldEqual, "monthly")
+		So(closures[2].Start, ShouldEqual, time.Date(2025, 1, 31, 0, 0, 0, 0, time.Local))
+		So(closures[3].Start, ShouldEqual, time.Date(2025, 3, 31, 0, 0, 0, 0, time.Local))
+		So(closures[3].End, ShouldEqual, time.Date(2025, 4, 1, 0, 0, 0, 0, time.Local))
+
+		christmases := closures[4:]
+		So(christmases[0].Summary, ShouldEqual, "christmas")
+		So(christmases[1].Start, ShouldEqual, time.Date(2025, 12, 25, 0, 0, 0, 0, time.Local))
+
+		last := christmases[len(christmases)-1].Start
+		So(last, ShouldHappenBetween, time.Now().AddDate(4, 0, -1), time.Now().AddDate(5, 0, 1))
+
+		So(logs.String(), ShouldContainSubstring, `skipping calendar event "mondays"`)
+		So(logs.String(), ShouldContainSubstring, `skipping calendar event "extra"`)
+	})
+
+	Convey("Invalid calendars can't be parsed", t, func() {
+		for _, ics := range []string{
+			"BEGIN:VEVENT\nSUMMARY:no start\nEND:VEVENT\n",
+			"BEGIN:VEVENT\nDTSTART:20250610\nDTEND:20250609\nEND:VEVENT\n",
+			"BEGIN:VEVENT\nDTSTART:2025-06-10\nEND:VEVENT\n",
+			"BEGIN:VEVENT\nDTSTART;TZID=Nowhere/Special:20250610T090000\nEND:VEVENT\n",
+			"BEGIN:VEVENT\nDTSTART:20250610\nDURATION:1D\nEND:VEVENT\n",
+			"BEGIN:VEVENT\nDTSTART:20250610\nDURATION:P1H\nEND:VEVENT\n",
+			"BEGIN:VEVENT\nDTSTART:20250610\nRRULE:FREQ=WEEKLY;COUNT=x\nEND:VEVENT\n",
+		} {
+			_, err := ParseCalendar(strings.NewReader(ics), log.New(io.Discard, "", 0))
+			So(errors.Is(err, ErrBadCalendar), ShouldBeTrue)
+		}
+	})
+}
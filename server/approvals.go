@@ -0,0 +1,317 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wtsi-hgi/tt/backend"
+	"github.com/wtsi-hgi/tt/database"
+	"github.com/wtsi-hgi/tt/policy"
+)
+
+// approvalsPage is the data for the approvals.html template.
+type approvalsPage struct {
+	Things []database.Thing
+}
+
+// pageApprovals is the html page at /approvals, listing the things awaiting
+// approval, with buttons for approvers to approve or reject them.
+func (s *Server) pageApprovals(c *gin.Context) {
+	result, err := s.db.GetThings(database.GetThingsParams{
+		FilterOnState: database.StatePending,
+	})
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+
+		return
+	}
+
+	c.HTML(http.StatusOK, "templates/approvals.html", approvalsPage{Things: result.Things})
+}
+
+// holdForApproval returns true if approvers are configured and the given error
+// from applying the retention policy is only that the requested removal date is
+// later than the policy allows, in which case the params are marked as needing
+// approval.
+func (s *Server) holdForApproval(params *database.CreateThingParams, err error) bool {
+	if s.approverGroup == "" || !errors.Is(err, policy.ErrRemoveTooLate) {
+		return false
+	}
+
+	params.Approval = database.ApprovalPending
+	params.ApprovalReason = err.Error()
+
+	return true
+}
+
+// measure probes the address of the thing being created if approvers and an
+// approval size are configured and its ThingsType has a backend.Prober, marking
+// the params as needing approval if what's there is bigger than the approval
+// size. Returns the result of probing, if it was probed.
+func (s *Server) measure(ctx context.Context, params *database.CreateThingParams) (*database.ProbeResult, error) {
+	if s.approverGroup == "" || s.approvalSize <= 0 || params.Approval == database.ApprovalPending {
+		return nil, nil
+	}
+
+	prober, ok := backend.ProberFor(params.Type)
+	if !ok {
+		return nil, nil
+	}
+
+	result, err := prober.Probe(ctx, &database.Thing{
+		Address: params.Type.CanonicalAddress(params.Address),
+		Type:    params.Type,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Exists && result.Size > s.approvalSize {
+		params.Approval = database.ApprovalPending
+		params.ApprovalReason = fmt.Sprintf("Its size of %s is more than the %s that can be registered "+
+			"without approval", formatBytes(result.Size), formatBytes(s.approvalSize))
+	}
+
+	return result, nil
+}
+
+// requestApproval records that the newly created thing is awaiting approval,
+// and tells the approvers (the listed members of the approver group that are
+// tt users) about it, logging any failure.
+func (s *Server) requestApproval(c *gin.Context, thing *database.Thing) {
+	if err := s.db.AddAuditEvent(database.AuditEvent{
+		ThingID: thing.ID,
+		Actor:   s.username(c),
+		Action:  database.AuditPending,
+		Detail:  thing.ApprovalReason,
+	}); err != nil {
+		s.Logger.Printf("recording that thing %d (%s) is awaiting approval failed: %s", thing.ID, thing.Address, err)
+	}
+
+	if s.notifier == nil {
+		return
+	}
+
+	names, err := s.groupMembers(s.approverGroup)
+	if err != nil {
+		s.Logger.Printf("looking up members of approver group %s failed: %s", s.approverGroup, err)
+
+		return
+	}
+
+	var approvers []database.User
+
+	for _, name := range names {
+		user, err := s.db.GetUserByName(name)
+		if err != nil {
+			s.Logger.Printf("looking up approver %s failed: %s", name, err)
+
+			continue
+		}
+
+		approvers = append(approvers, *user)
+	}
+
+	if len(approvers) == 0 {
+		return
+	}
+
+	err = s.notifier.Notify(approvers,
+		fmt.Sprintf("tt: %s is awaiting your approval", thing.Address),
+		fmt.Sprintf("%s has asked for the %s %s to be registered with tt until %s, because \"%s\". "+
+			"It needs approval before it can be registered: %s.\n\n"+
+			"Please approve or reject it on the approvals page of the tt web interface.\n",
+			thing.Creator, thing.Type, thing.Address, thing.Remove.Format(time.DateOnly), thing.Reason,
+			thing.ApprovalReason))
+	if err != nil {
+		s.Logger.Printf("notifying approvers about thing %d (%s) failed: %s", thing.ID, thing.Address, err)
+	}
+}
+
+// postApprove posts to /things/id/approve, approving the Thing with that id
+// that is awaiting approval, so that it counts as registered, and returns its
+// updated table row.
+//
+// The logged in user must be a member of the configured ApproverGroup, and
+// can't approve a Thing they asked to register themselves; otherwise responds
+// with http.StatusForbidden. Responds with http.StatusBadRequest if the Thing
+// isn't awaiting approval.
+//
+// If the ThingsType's backend is a backend.Registrar, the Thing is registered
+// with it now; if that fails, the Thing stays awaiting approval, and responds
+// with http.StatusBadGateway.
+//
+// The approval is recorded in the Thing's history, and its subscribers are
+// told.
+func (s *Server) postApprove(c *gin.Context) {
+	thing, approver, ok := s.pendingThingForApprover(c)
+	if !ok {
+		return
+	}
+
+	if thing.Creator == approver {
+		c.AbortWithError(http.StatusForbidden, ErrSelfApproval)
+
+		return
+	}
+
+	if registrar, ok := backend.RegistrarFor(thing.Type); ok {
+		if err := registrar.Register(c.Request.Context(), thing); err != nil {
+			c.AbortWithError(http.StatusBadGateway, err)
+
+			return
+		}
+	}
+
+	s.decide(c, thing, approver, database.ApprovalApproved, database.AuditApproved, "approved by "+approver,
+		fmt.Sprintf("tt: %s was approved", thing.Address),
+		fmt.Sprintf("The %s %s has been approved by %s, so is now registered with tt, and will be "+
+			"removed on %s.\n", thing.Type, thing.Address, approver, s.schedule.Scheduled(thing).Format(time.DateOnly)))
+}
+
+// postReject posts to /things/id/reject, optionally with a Reason, rejecting
+// the Thing with that id that is awaiting approval, so that it is never
+// registered (and is treated as removed), and returns its updated table row.
+//
+// Only members of the ApproverGroup can reject things, responding with
+// http.StatusForbidden for anyone else, though unlike with postApprove() they
+// can reject their own requests. Responds with http.StatusBadRequest if the
+// Thing isn't awaiting approval.
+//
+// The rejection is recorded in the Thing's history, and its subscribers are
+// told, along with the Reason.
+func (s *Server) postReject(c *gin.Context) {
+	thing, approver, ok := s.pendingThingForApprover(c)
+	if !ok {
+		return
+	}
+
+	detail := "rejected by " + approver
+	if reason := c.PostForm("Reason"); reason != "" {
+		detail += ": " + reason
+	}
+
+	s.decide(c, thing, approver, database.ApprovalRejected, database.AuditRejected, detail,
+		fmt.Sprintf("tt: %s was rejected", thing.Address),
+		fmt.Sprintf("The request to register the %s %s with tt was %s. It has not been registered, "+
+			"and will not be removed by tt.\n", thing.Type, thing.Address, detail))
+}
+
+// pendingThingForApprover returns the Thing given by the id parameter, and the
+// name of the approver making the request, aborting the request and returning
+// false if they aren't an approver, or the Thing isn't awaiting approval.
+func (s *Server) pendingThingForApprover(c *gin.Context) (*database.Thing, string, bool) {
+	approver := s.username(c)
+
+	if !s.isApprover(approver) {
+		c.AbortWithError(http.StatusForbidden, ErrNotApprover)
+
+		return nil, "", false
+	}
+
+	thing, ok := s.thingFromParam(c)
+	if !ok {
+		return nil, "", false
+	}
+
+	if thing.Approval != database.ApprovalPending || thing.Removed {
+		c.AbortWithError(http.StatusBadRequest, database.ErrNotPending)
+
+		return nil, "", false
+	}
+
+	return thing, approver, true
+}
+
+// isApprover returns true if the user with the given name is a member of the
+// approver group.
+func (s *Server) isApprover(username string) bool {
+	if s.approverGID == "" || username == "" {
+		return false
+	}
+
+	identity, err := backend.LookupIdentity(username)
+
+	return err == nil && identity.InGroup(s.approverGID)
+}
+
+// decide records the given approval decision about the thing, made by the
+// given approver, in the database and the thing's history, tells its
+// subscribers with the given message, and responds with its updated table row,
+// fetched again so that it shows the thing's new State.
+func (s *Server) decide(c *gin.Context, thing *database.Thing, approver string, state database.ApprovalState,
+	action database.AuditAction, detail, subject, body string) {
+	if err := s.db.SetApproval(thing.ID, state); err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+
+		return
+	}
+
+	if err := s.db.AddAuditEvent(database.AuditEvent{
+		ThingID: thing.ID,
+		Actor:   approver,
+		Action:  action,
+		Detail:  detail,
+	}); err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+
+		return
+	}
+
+	thing, err := s.db.GetThing(thing.ID)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+
+		return
+	}
+
+	s.notifySubscribers(thing, subject, body)
+
+	c.HTML(http.StatusOK, "templates/thing.html", thing)
+}
+
+// notifySubscribers sends the given message to the subscribers of the given
+// thing using our notifier, if we have one, logging any failure.
+func (s *Server) notifySubscribers(thing *database.Thing, subject, body string) {
+	if s.notifier == nil {
+		return
+	}
+
+	users, err := s.db.GetSubscribers(thing.ID)
+	if err == nil && len(users) > 0 {
+		err = s.notifier.Notify(users, subject, body)
+	}
+
+	if err != nil {
+		s.Logger.Printf("notifying subscribers of thing %d (%s) failed: %s", thing.ID, thing.Address, err)
+	}
+}
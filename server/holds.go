@@ -0,0 +1,158 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	null "github.com/guregu/null/v5"
+	"github.com/wtsi-hgi/tt/database"
+)
+
+// postHold posts a Reason, and optionally an Until date, to /things/id/hold,
+// placing a legal hold on the Thing with that id so that it isn't warned about
+// or removed until then (or until the hold is released, if there's no Until
+// date), and returns its updated table row. Any removal job it has that isn't
+// already running is deleted.
+//
+// Holds can only be placed by one of the configured admins; anyone else gets
+// http.StatusForbidden, and the hold is attributed to the admin who is logged
+// in. Responds with http.StatusBadRequest if the Thing has been removed, or
+// Until isn't in the future.
+//
+// The hold is recorded in the Thing's history.
+func (s *Server) postHold(c *gin.Context) {
+	thing, admin, ok := s.thingForAdmin(c)
+	if !ok {
+		return
+	}
+
+	var params database.HoldParams
+
+	if err := c.ShouldBind(&params); err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+
+		return
+	}
+
+	if thing.Removed {
+		c.AbortWithError(http.StatusBadRequest, database.ErrThingRemoved)
+
+		return
+	}
+
+	until := null.NewTime(params.Until, !params.Until.IsZero())
+	if until.Valid && !until.Time.After(time.Now()) {
+		c.AbortWithError(http.StatusBadRequest, database.ErrHoldEnded)
+
+		return
+	}
+
+	detail := "held by " + admin
+	if until.Valid {
+		detail += " until " + until.Time.Format(time.DateOnly)
+	}
+
+	s.changeHold(c, thing, admin, func() error {
+		return s.db.SetHold(thing.ID, params.Reason, until)
+	}, database.AuditHeld, detail+": "+params.Reason)
+}
+
+// postRelease posts to /things/id/release, releasing the legal hold on the
+// Thing with that id, and returns its updated table row. Non-admins get
+// http.StatusForbidden, so subscribers can't lift holds placed on their things.
+// Responds with http.StatusBadRequest if the Thing isn't on hold.
+//
+// The release is recorded in the Thing's history.
+func (s *Server) postRelease(c *gin.Context) {
+	thing, admin, ok := s.thingForAdmin(c)
+	if !ok {
+		return
+	}
+
+	if !thing.HoldReason.Valid {
+		c.AbortWithError(http.StatusBadRequest, database.ErrNotHeld)
+
+		return
+	}
+
+	s.changeHold(c, thing, admin, func() error {
+		return s.db.ReleaseHold(thing.ID)
+	}, database.AuditHoldReleased, fmt.Sprintf("released by %s (was held: %s)", admin, thing.HoldReason.String))
+}
+
+// thingForAdmin returns the Thing given by the id parameter, and the name of
+// the admin making the request, aborting the request and returning false if
+// they aren't an admin.
+func (s *Server) thingForAdmin(c *gin.Context) (*database.Thing, string, bool) {
+	admin := s.username(c)
+
+	if admin == "" || !slices.Contains(s.admins, admin) {
+		c.AbortWithError(http.StatusForbidden, ErrNotAdmin)
+
+		return nil, "", false
+	}
+
+	thing, ok := s.thingFromParam(c)
+
+	return thing, admin, ok
+}
+
+// changeHold calls change, records that in the thing's history as done by the
+// given admin with the given action and detail, and returns its updated table
+// row.
+func (s *Server) changeHold(c *gin.Context, thing *database.Thing, admin string, change func() error,
+	action database.AuditAction, detail string) {
+	if err := change(); err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+
+		return
+	}
+
+	if err := s.db.AddAuditEvent(database.AuditEvent{
+		ThingID: thing.ID,
+		Actor:   admin,
+		Action:  action,
+		Detail:  detail,
+	}); err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+
+		return
+	}
+
+	thing, err := s.db.GetThing(thing.ID)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+
+		return
+	}
+
+	c.HTML(http.StatusOK, "templates/thing.html", thing)
+}
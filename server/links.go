@@ -0,0 +1,301 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wtsi-hgi/tt/database"
+	"github.com/wtsi-hgi/tt/links"
+)
+
+// linkPage is what templates/link.html shows: either a Question asking whether
+// to take a link's action, with a button that posts to the link's Path; a
+// Message saying what a link did; or the Error that stopped it.
+type linkPage struct {
+	Question string
+	Path     string
+	Message  string
+	Error    error
+}
+
+// getLink gets /links/token, where token ends a one-click link from a warning
+// email, signed by the configured links.Signer. Nothing is changed; instead a
+// page is returned asking the link's user if they want its action to be taken
+// on its Thing, with a button that posts to the same URL (see postLink()). This
+// means that mail scanners and previews that fetch links don't act on them.
+//
+// If links aren't configured, responds with http.StatusNotFound. If the link
+// is invalid, or its Thing has been removed, responds with
+// http.StatusBadRequest. If it has expired, responds with http.StatusGone, and
+// if its user is no longer subscribed to the Thing, http.StatusForbidden. The
+// page explains the problem.
+func (s *Server) getLink(c *gin.Context) {
+	question, code, err := s.askLink(c)
+
+	c.HTML(code, "templates/link.html", linkPage{Question: question, Path: c.Request.URL.Path, Error: err})
+}
+
+// postLink posts to /links/token, where token ends a one-click link as for
+// getLink(). The link's action is taken on its Thing on behalf of its user,
+// without them needing to log in, and a page saying what was done is returned:
+//
+//   - links.Extend extends the Thing's removal date by links.ExtendDays days,
+//     as if by postExtend.
+//   - links.Unsubscribe stops the user being subscribed to the Thing.
+//   - links.Snooze snoozes warnings to the user about the Thing for
+//     database.DefaultSnoozeDays, as if by postSnooze.
+//   - links.ConfirmRemoval records the user's confirmation that the Thing can
+//     be removed on its scheduled removal date, so that they aren't warned
+//     about it again unless that date changes, and if it had changed since it
+//     was registered, confirms it should be removed anyway, as if by
+//     postConfirm.
+//
+// Each link can only be used once, and the action is recorded in the Thing's
+// history. A link is only used up if its action succeeds.
+//
+// Responds with the same status codes as getLink(), as well as
+// http.StatusBadRequest if the Thing can't be extended again, or snoozed
+// because it's within its second warning period, and http.StatusGone if the
+// link has already been used.
+func (s *Server) postLink(c *gin.Context) {
+	message, code, err := s.followLink(c)
+
+	c.HTML(code, "templates/link.html", linkPage{Message: message, Error: err})
+}
+
+// askLink does the work of getLink(), returning the question to ask and the
+// http status code to respond with, or the error that stops the link being
+// followed.
+func (s *Server) askLink(c *gin.Context) (string, int, error) {
+	link, thing, _, code, err := s.linkTarget(c)
+	if err != nil {
+		return "", code, err
+	}
+
+	switch link.Action {
+	case links.Extend:
+		return fmt.Sprintf("Extend the removal date of %s by %d days, to %s?", thing.Address, links.ExtendDays,
+			extendedByLink(thing).Format(time.DateOnly)), http.StatusOK, nil
+	case links.Unsubscribe:
+		return fmt.Sprintf("Stop being told about %s?", thing.Address), http.StatusOK, nil
+	case links.Snooze:
+		return fmt.Sprintf("Stop being warned about %s until %s, without extending it?", thing.Address,
+			s.snoozeUntil(thing, database.DefaultSnoozeDays).Format(time.DateOnly)), http.StatusOK, nil
+	default:
+		return fmt.Sprintf("Confirm that %s can be removed on %s, and stop being warned about it?", thing.Address,
+			s.schedule.Scheduled(thing).Format(time.DateOnly)), http.StatusOK, nil
+	}
+}
+
+// followLink does the work of postLink(), returning the message to show and
+// the http status code to respond with, or the error that stopped the link
+// being followed.
+func (s *Server) followLink(c *gin.Context) (string, int, error) {
+	link, thing, user, code, err := s.linkTarget(c)
+	if err != nil {
+		return "", code, err
+	}
+
+	var message string
+
+	err = s.db.UseLink(link.ID, link.Expires, func() error {
+		var err error
+
+		message, code, err = s.takeLinkAction(c, link.Action, thing, user)
+
+		return err
+	})
+
+	switch {
+	case errors.Is(err, database.ErrLinkUsed):
+		return "", http.StatusGone, err
+	case err != nil && code == http.StatusOK:
+		return "", http.StatusInternalServerError, err
+	}
+
+	return message, code, err
+}
+
+// linkTarget returns the valid link in the request, along with its thing and
+// user, or the error and http status code to respond with if it can't be
+// followed.
+func (s *Server) linkTarget(c *gin.Context) (*links.Link, *database.Thing, *database.User, int, error) {
+	link, code, err := s.verifyLink(c.Param("token"))
+	if err != nil {
+		return nil, nil, nil, code, err
+	}
+
+	thing, err := s.db.GetThing(link.ThingID)
+	if errors.Is(err, database.ErrNoThing) {
+		return nil, nil, nil, http.StatusNotFound, err
+	} else if err != nil {
+		return nil, nil, nil, http.StatusInternalServerError, err
+	}
+
+	if thing.Removed {
+		return nil, nil, nil, http.StatusBadRequest, database.ErrThingRemoved
+	}
+
+	user, code, err := s.linkUser(thing, link.UserID)
+	if err != nil {
+		return nil, nil, nil, code, err
+	}
+
+	return link, thing, user, http.StatusOK, nil
+}
+
+// takeLinkAction takes the given action on the thing on behalf of the user.
+func (s *Server) takeLinkAction(c *gin.Context, action links.Action, thing *database.Thing,
+	user *database.User) (string, int, error) {
+	switch action {
+	case links.Extend:
+		return s.extendByLink(c, thing, user)
+	case links.Unsubscribe:
+		return s.unsubscribeByLink(thing, user)
+	case links.Snooze:
+		return s.snoozeByLink(thing, user)
+	default:
+		return s.confirmByLink(c, thing, user)
+	}
+}
+
+// verifyLink returns the links.Link described by the given token, or the
+// error and http status code to respond with if it isn't valid.
+func (s *Server) verifyLink(token string) (*links.Link, int, error) {
+	if s.links == nil {
+		return nil, http.StatusNotFound, ErrNoLinks
+	}
+
+	link, err := s.links.Verify(token, time.Now())
+	if errors.Is(err, links.ErrLinkExpired) {
+		return nil, http.StatusGone, err
+	} else if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	return link, http.StatusOK, nil
+}
+
+// linkUser returns the subscriber of the thing with the given ID, or the error
+// and http status code to respond with if they're not subscribed.
+func (s *Server) linkUser(thing *database.Thing, userID uint32) (*database.User, int, error) {
+	subscribers, err := s.db.GetSubscribers(thing.ID)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	for _, user := range subscribers {
+		if user.ID == userID {
+			return &user, http.StatusOK, nil
+		}
+	}
+
+	return nil, http.StatusForbidden, ErrNotSubscriber
+}
+
+// extendByLink extends the thing's removal date by links.ExtendDays days on
+// behalf of the user.
+func (s *Server) extendByLink(c *gin.Context, thing *database.Thing, user *database.User) (string, int, error) {
+	remove := extendedByLink(thing)
+
+	if code, err := s.extend(c, thing, remove, user.Name); err != nil {
+		return "", code, err
+	}
+
+	return fmt.Sprintf("%s will now be removed on %s.", thing.Address, remove.Format(time.DateOnly)),
+		http.StatusOK, nil
+}
+
+// extendedByLink returns the removal date that following an extend link would
+// give the thing: links.ExtendDays after its current effective removal date.
+func extendedByLink(thing *database.Thing) time.Time {
+	return thing.EffectiveRemove().AddDate(0, 0, links.ExtendDays)
+}
+
+// unsubscribeByLink stops the user being subscribed to the thing.
+func (s *Server) unsubscribeByLink(thing *database.Thing, user *database.User) (string, int, error) {
+	if err := s.db.Unsubscribe(thing.ID, user.ID); err != nil {
+		return "", http.StatusInternalServerError, err
+	}
+
+	if err := s.db.AddAuditEvent(database.AuditEvent{
+		ThingID: thing.ID,
+		Actor:   user.Name,
+		Action:  database.AuditUnsubscribed,
+		Detail:  user.Name + " unsubscribed",
+	}); err != nil {
+		return "", http.StatusInternalServerError, err
+	}
+
+	return fmt.Sprintf("You will no longer be told about %s.", thing.Address), http.StatusOK, nil
+}
+
+// snoozeByLink stops the user being warned about the thing for
+// database.DefaultSnoozeDays days.
+func (s *Server) snoozeByLink(thing *database.Thing, user *database.User) (string, int, error) {
+	until, code, err := s.snooze(thing, user, database.DefaultSnoozeDays)
+	if err != nil {
+		return "", code, err
+	}
+
+	return fmt.Sprintf("You won't be warned about %s again until %s.", thing.Address, until.Format(time.DateOnly)),
+		http.StatusOK, nil
+}
+
+// confirmByLink records that the user has confirmed the thing can be removed on
+// its scheduled removal date, first taking a new Fingerprint of it if it had
+// changed since it was registered, so that it will be removed after all.
+func (s *Server) confirmByLink(c *gin.Context, thing *database.Thing, user *database.User) (string, int, error) {
+	if thing.Changed.Valid {
+		if err := s.setFingerprint(c.Request.Context(), thing); err != nil {
+			return "", http.StatusInternalServerError, err
+		}
+	}
+
+	remove := s.schedule.Scheduled(thing)
+	date := remove.Format(time.DateOnly)
+
+	if err := s.db.ConfirmRemoval(thing.ID, user.ID, remove); err != nil {
+		return "", http.StatusInternalServerError, err
+	}
+
+	if err := s.db.AddAuditEvent(database.AuditEvent{
+		ThingID: thing.ID,
+		Actor:   user.Name,
+		Action:  database.AuditConfirmed,
+		Detail:  "removal on " + date + " confirmed by " + user.Name,
+	}); err != nil {
+		return "", http.StatusInternalServerError, err
+	}
+
+	return fmt.Sprintf("Thanks for confirming that %s can be removed on %s. "+
+		"You won't be warned about it again, unless that date changes.", thing.Address, date), http.StatusOK, nil
+}
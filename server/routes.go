@@ -26,11 +26,18 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"slices"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	null "github.com/guregu/null/v5"
+	"github.com/wtsi-hgi/tt/backend"
 	"github.com/wtsi-hgi/tt/database"
+	"github.com/wtsi-hgi/tt/jobs"
 )
 
 const (
@@ -43,15 +50,43 @@ func (s *Server) pageRoot(c *gin.Context) {
 	c.HTML(http.StatusOK, "templates/root.html", nil)
 }
 
+// statusPage is the data for the status.html template.
+type statusPage struct {
+	Instance string
+	Leases   []database.Lease
+	Now      time.Time
+}
+
+// pageStatus is the html page at /status, showing which server instance holds
+// each lease, such as the leader that runs the scheduled jobs.
+func (s *Server) pageStatus(c *gin.Context) {
+	leases, err := s.db.GetLeases()
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+
+		return
+	}
+
+	c.HTML(http.StatusOK, "templates/status.html", statusPage{
+		Instance: s.instance,
+		Leases:   leases,
+		Now:      time.Now(),
+	})
+}
+
 // getThings returns table rows for each Thing in the database. User can supply
 // url query values to /things? to alter which and how these are returned:
 //
-// sort=[address|type|reason|remove] : sort by chosen field in Thing, default
-// remove
+// sort=[address|type|reason|remove|size|files|modified] : sort by chosen field
+// in Thing, default remove
 //
 // dir=[ASC|DESC] : sort direction, default ascending
 //
-// type=[dir|file|irods|openstack|s3] : filter to only show this type of thing
+// type=<name of a registered ThingsType> : filter to only show this type of
+// thing
+//
+// state=[pending|active|warned|expired|removing|failed|quarantined|removed] :
+// filter to only show things in this lifecycle state
 //
 // page=<int>&per_page=<int> : get a particular page of results, where each page
 // has per_page Things. Page defaults to 1, and per_page defaults to 50
@@ -77,6 +112,13 @@ func (s *Server) getThings(c *gin.Context) {
 		return
 	}
 
+	state, err := database.NewState(c.Query("state"))
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+
+		return
+	}
+
 	page, err := strconv.Atoi(c.Query("page"))
 	if err != nil || page < 1 {
 		page = defaultPage
@@ -89,6 +131,7 @@ func (s *Server) getThings(c *gin.Context) {
 
 	result, err := s.db.GetThings(database.GetThingsParams{
 		FilterOnType:   thingType,
+		FilterOnState:  state,
 		OrderBy:        orderBy,
 		OrderDirection: orderDirection,
 		Page:           page,
@@ -103,9 +146,490 @@ func (s *Server) getThings(c *gin.Context) {
 	c.HTML(http.StatusOK, "templates/things.html", result.Things)
 }
 
-// postThing posts all required fields of a Thing to /things, along with Creator
-// as the username of the person making this thing, and creates a new Thing
-// and Subscriber in the database.
+// getPreviousThings returns a summary of the removed Things that were
+// previously registered with the address and type given in the url query values
+// /things/previous?Address=<address>&Type=<type> (named after the fields used
+// when posting a new Thing). Returns nothing if there were none, or if Address
+// or Type are blank.
+func (s *Server) getPreviousThings(c *gin.Context) {
+	thingType, err := database.NewThingsType(c.Query("Type"))
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+
+		return
+	}
+
+	address := thingType.CanonicalAddress(c.Query("Address"))
+	if address == "" || thingType == database.ThingsTypeNil {
+		c.Status(http.StatusOK)
+
+		return
+	}
+
+	result, err := s.db.GetThings(database.GetThingsParams{
+		FilterOnType:    thingType,
+		FilterOnAddress: address,
+		FilterOnRemoved: null.BoolFrom(true),
+		OrderBy:         database.OrderByRemove,
+		OrderDirection:  database.OrderDesc,
+	})
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+
+		return
+	}
+
+	c.HTML(http.StatusOK, "templates/previous.html", result.Things)
+}
+
+// getThing returns the table row for the Thing with the id in the url
+// /things/id.
+func (s *Server) getThing(c *gin.Context) {
+	thing, ok := s.thingFromParam(c)
+	if !ok {
+		return
+	}
+
+	c.HTML(http.StatusOK, "templates/thing.html", thing)
+}
+
+// thingFromParam returns the Thing with the id in the url. If there isn't one,
+// or the id is invalid, aborts the request and returns false.
+func (s *Server) thingFromParam(c *gin.Context) (*database.Thing, bool) {
+	thingID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+
+		return nil, false
+	}
+
+	thing, err := s.db.GetThing(uint32(thingID))
+	if errors.Is(err, database.ErrNoThing) {
+		c.AbortWithError(http.StatusNotFound, err)
+
+		return nil, false
+	}
+
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+
+		return nil, false
+	}
+
+	return thing, true
+}
+
+// thingForManager returns the Thing with the id in the url, and the name of the
+// user making the request, aborting the request with http.StatusForbidden and
+// returning false unless they are subscribed to it, are an admin, or own its
+// address according to the backend.OwnerVerifier for its type.
+func (s *Server) thingForManager(c *gin.Context) (*database.Thing, string, bool) {
+	thing, ok := s.thingFromParam(c)
+	if !ok {
+		return nil, "", false
+	}
+
+	username := s.username(c)
+
+	manager, err := s.isManager(c.Request.Context(), thing, username)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+
+		return nil, "", false
+	}
+
+	if !manager {
+		c.AbortWithError(http.StatusForbidden, ErrNotManager)
+
+		return nil, "", false
+	}
+
+	return thing, username, true
+}
+
+// isManager returns true if the given user is an admin, is subscribed to the
+// thing, or owns its address.
+func (s *Server) isManager(ctx context.Context, thing *database.Thing, username string) (bool, error) {
+	if username == "" {
+		return false, nil
+	}
+
+	if slices.Contains(s.admins, username) {
+		return true, nil
+	}
+
+	subscribers, err := s.db.GetSubscribers(thing.ID)
+	if err != nil {
+		return false, err
+	}
+
+	if slices.ContainsFunc(subscribers, func(user database.User) bool { return user.Name == username }) {
+		return true, nil
+	}
+
+	verifier, ok := backend.OwnerVerifierFor(thing.Type)
+	if !ok {
+		return false, nil
+	}
+
+	identity, err := backend.LookupIdentity(username)
+	if err != nil {
+		return false, nil //nolint:nilerr
+	}
+
+	return verifier.VerifyOwner(ctx, thing, identity) == nil, nil
+}
+
+// postExtend posts a new Remove date to /things/id/extend, changing the
+// removal date of the Thing with that id, and returns its updated table row.
+// Only its subscribers, the owners of its address and admins can extend it;
+// others get http.StatusForbidden.
+//
+// Responds with http.StatusBadRequest if the new date isn't in the future and
+// later than the Thing's current removal date, or if the retention policy for
+// the Thing's type and address doesn't allow it to be extended again, or to
+// that date.
+//
+// If the ThingsType's backend is a backend.Registrar, the Thing is registered
+// again so that any metadata it stored about the removal date is kept in sync.
+// If that fails, the old removal date is restored and responds with
+// http.StatusBadGateway.
+//
+// The change is recorded in the Thing's history, and a new Fingerprint is taken
+// of things whose ThingsType's backend is a backend.Fingerprinter, since
+// extending them acknowledges their current contents.
+func (s *Server) postExtend(c *gin.Context) {
+	thing, username, ok := s.thingForManager(c)
+	if !ok {
+		return
+	}
+
+	var params database.ExtendParams
+
+	if err := c.ShouldBind(&params); err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+
+		return
+	}
+
+	if thing.Removed {
+		c.AbortWithError(http.StatusBadRequest, database.ErrThingRemoved)
+
+		return
+	}
+
+	if code, err := s.extend(c, thing, params.Remove, username); err != nil {
+		c.AbortWithError(code, err)
+
+		return
+	}
+
+	c.HTML(http.StatusOK, "templates/thing.html", thing)
+}
+
+// extend does the work of postExtend() for the given thing and new removal
+// date, recording the given actor in its history. If it fails, returns the
+// http status code to respond with.
+func (s *Server) extend(c *gin.Context, thing *database.Thing, remove time.Time, actor string) (int, error) {
+	extensions, err := s.countExtensions(thing)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	if err = s.policies.For(thing.Type, thing.Address).Extend(remove, thing.EffectiveRemove(), extensions, time.Now()); err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	oldRemove := thing.Remove
+
+	if err = s.db.ExtendRemoval(thing.ID, remove); errors.Is(err, database.ErrBadTransition) {
+		return http.StatusBadRequest, err
+	} else if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	thing.Remove = remove
+
+	if err = s.syncExtension(c, thing, oldRemove); err != nil {
+		return http.StatusBadGateway, err
+	}
+
+	if err = s.db.AddAuditEvent(database.AuditEvent{
+		ThingID: thing.ID,
+		Actor:   actor,
+		Action:  database.AuditExtended,
+		Detail: "removal date changed from " + oldRemove.Format(time.DateOnly) +
+			" to " + thing.Remove.Format(time.DateOnly),
+	}); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	s.fingerprint(c.Request.Context(), thing)
+
+	return http.StatusOK, nil
+}
+
+// postConfirm posts to /things/id/confirm, confirming that the Thing with that
+// id, which wasn't removed because it had changed since it was registered,
+// should be removed after all. A new Fingerprint is taken, so that it will be
+// removed the next time expired things are reaped, and its updated table row is
+// returned.
+//
+// Responds with http.StatusBadRequest if the Thing hasn't changed, or has been
+// removed, and http.StatusForbidden if the user isn't one of its subscribers,
+// an owner of its address or an admin. The confirmation is recorded in the
+// Thing's history.
+func (s *Server) postConfirm(c *gin.Context) {
+	thing, username, ok := s.thingForManager(c)
+	if !ok {
+		return
+	}
+
+	switch {
+	case thing.Removed:
+		c.AbortWithError(http.StatusBadRequest, database.ErrThingRemoved)
+
+		return
+	case !thing.Changed.Valid:
+		c.AbortWithError(http.StatusBadRequest, database.ErrNotChanged)
+
+		return
+	}
+
+	if err := s.setFingerprint(c.Request.Context(), thing); err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+
+		return
+	}
+
+	if err := s.db.AddAuditEvent(database.AuditEvent{
+		ThingID: thing.ID,
+		Actor:   username,
+		Action:  database.AuditConfirmed,
+		Detail:  "removal confirmed despite changes",
+	}); err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+
+		return
+	}
+
+	c.HTML(http.StatusOK, "templates/thing.html", thing)
+}
+
+// fingerprint calls setFingerprint(), logging any failure, since a thing
+// without an up to date Fingerprint is still usable.
+func (s *Server) fingerprint(ctx context.Context, thing *database.Thing) {
+	if err := s.setFingerprint(ctx, thing); err != nil {
+		s.Logger.Printf("fingerprinting thing %d (%s) failed: %s", thing.ID, thing.Address, err)
+	}
+}
+
+// setFingerprint takes a new Fingerprint of the thing using the
+// backend.Fingerprinter for its type, and records it, clearing any record of
+// the thing having changed. Does nothing for types without a Fingerprinter.
+func (s *Server) setFingerprint(ctx context.Context, thing *database.Thing) error {
+	fingerprinter, ok := backend.FingerprinterFor(thing.Type)
+	if !ok {
+		return nil
+	}
+
+	fingerprint, err := fingerprinter.Fingerprint(ctx, thing)
+	if err != nil {
+		return err
+	}
+
+	if err = s.db.SetFingerprint(thing.ID, fingerprint); err != nil {
+		return err
+	}
+
+	thing.Fingerprint = fingerprint
+	thing.Changed = null.Time{}
+
+	return nil
+}
+
+// postRestore posts to /things/id/restore, optionally with a new Remove date,
+// moving the data of the quarantined Thing with that id back to its address,
+// and returns its updated table row. Without a Remove date, its new removal
+// date is jobs.DefaultRestoreExtension from now.
+//
+// Responds with http.StatusBadRequest if the Thing isn't quarantined,
+// http.StatusConflict if something else now exists at its address, and
+// http.StatusForbidden if the user isn't one of its subscribers, an owner of
+// its address or an admin.
+//
+// The restoration is recorded in the Thing's history.
+func (s *Server) postRestore(c *gin.Context) {
+	thing, username, ok := s.thingForManager(c)
+	if !ok {
+		return
+	}
+
+	var params database.RestoreParams
+
+	if err := c.ShouldBind(&params); err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+
+		return
+	}
+
+	thing, err := jobs.Restore(c.Request.Context(), s.db, thing.ID, username, params.Remove)
+
+	switch {
+	case errors.Is(err, database.ErrNotQuarantined):
+		c.AbortWithError(http.StatusBadRequest, err)
+	case errors.Is(err, backend.ErrAddressInUse):
+		c.AbortWithError(http.StatusConflict, err)
+	case err != nil:
+		c.AbortWithError(http.StatusInternalServerError, err)
+	default:
+		c.HTML(http.StatusOK, "templates/thing.html", thing)
+	}
+}
+
+// postRetry posts to /things/id/retry, queuing the failed or cancelled
+// removal job of the Thing with that id to be tried again now, and returns its
+// updated table row. The retry is recorded in the Thing's history. Only its
+// subscribers, the owners of its address and admins may retry its removal.
+func (s *Server) postRetry(c *gin.Context) {
+	s.changeJob(c, s.db.RetryJob, database.AuditJobRetried)
+}
+
+// postCancel posts to /things/id/cancel, cancelling the removal job of the
+// Thing with that id, unless it's already running, and returns its updated
+// table row. The cancellation is recorded in the Thing's history. Only its
+// subscribers, the owners of its address and admins may cancel its removal.
+func (s *Server) postCancel(c *gin.Context) {
+	s.changeJob(c, s.db.CancelJob, database.AuditJobCancelled)
+}
+
+// changeJob calls change with the ID of the Thing with the id in the url, if
+// the user making the request manages it, records that in its history with the
+// given action, and returns its updated table row.
+func (s *Server) changeJob(c *gin.Context, change func(uint32) error, action database.AuditAction) {
+	thing, actor, ok := s.thingForManager(c)
+	if !ok {
+		return
+	}
+
+	err := change(thing.ID)
+
+	switch {
+	case errors.Is(err, database.ErrNoJob), errors.Is(err, database.ErrJobNotFailed):
+		c.AbortWithError(http.StatusBadRequest, err)
+
+		return
+	case errors.Is(err, database.ErrJobRunning):
+		c.AbortWithError(http.StatusConflict, err)
+
+		return
+	case err != nil:
+		c.AbortWithError(http.StatusInternalServerError, err)
+
+		return
+	}
+
+	if err = s.db.AddAuditEvent(database.AuditEvent{
+		ThingID: thing.ID,
+		Actor:   actor,
+		Action:  action,
+	}); err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+
+		return
+	}
+
+	if thing, err = s.db.GetThing(thing.ID); err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+
+		return
+	}
+
+	c.HTML(http.StatusOK, "templates/thing.html", thing)
+}
+
+// getJobs returns the removal jobs as JSON, optionally filtered to those in
+// the state given in the url query value /jobs?state=<state>.
+func (s *Server) getJobs(c *gin.Context) {
+	state, err := database.NewJobState(c.Query("state"))
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+
+		return
+	}
+
+	jobs, err := s.db.GetRemovalJobs(state)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+
+		return
+	}
+
+	if jobs == nil {
+		jobs = []database.RemovalJob{}
+	}
+
+	c.JSON(http.StatusOK, jobs)
+}
+
+// syncExtension registers the given thing again with the backend.Registrar for
+// its type, if any, so that it knows about the thing's new removal date. If
+// that fails, the thing's removal date is changed back to oldRemove.
+func (s *Server) syncExtension(c *gin.Context, thing *database.Thing, oldRemove time.Time) error {
+	registrar, ok := backend.RegistrarFor(thing.Type)
+	if !ok {
+		return nil
+	}
+
+	err := registrar.Register(c.Request.Context(), thing)
+	if err == nil {
+		return nil
+	}
+
+	return errors.Join(err, s.db.ExtendRemoval(thing.ID, oldRemove))
+}
+
+// username returns the name of the logged in user making the request, which is
+// never blank for the routes that require authentication.
+func (s *Server) username(c *gin.Context) string {
+	if u := s.GetUser(c); u != nil {
+		return u.Username
+	}
+
+	return ""
+}
+
+// postThing posts all required fields of a Thing to /things, and optionally
+// OnExpiry (delete, archive or notify) and ExpiryMode (fixed, or atime along
+// with ExpireAfter days), and creates a new Thing and Subscriber in the
+// database, with the logged in user as its Creator. Things can only be archived
+// if their ThingsType's backend is a backend.Archiver, and only expire after
+// they were last used if it's a backend.Prober.
+//
+// The retention policy for the Thing's type and address is applied, giving it
+// the policy's default removal date if it wasn't given one. If it breaks the
+// policy, responds with http.StatusBadRequest.
+//
+// If the address isn't allowed by the configured Protection, responds with
+// http.StatusForbidden.
+//
+// If the ThingsType's backend can verify ownership, the user must own or be
+// able to write to the address, unless they are a configured admin. If not,
+// responds with http.StatusForbidden.
+//
+// If approvers are configured, things with a removal date later than their
+// policy allows, or that are bigger than the configured approval size (for
+// ThingsTypes whose backend is a backend.Prober), are instead created awaiting
+// approval, and the approvers are told about them. They only count as
+// registered once approved.
+//
+// If a Thing with the same address and type already exists, responds with
+// http.StatusConflict, a Location header of the existing Thing's url, and html
+// linking to it.
+//
+// If the ThingsType's backend is a backend.Fingerprinter, a Fingerprint of what
+// exists at the address is recorded, so that it won't be removed if it changes
+// materially before its removal date.
 //
 // Afterwards, it broadcasts the new Thing to all listeners of /things/listen
 // using SSE.
@@ -118,20 +642,72 @@ func (s *Server) postThing(c *gin.Context) {
 		return
 	}
 
-	_, err := database.NewThingsType(string(postedThing.Type))
+	postedThing.Creator = s.username(c)
+
+	err := postedThing.Type.ValidateAddress(postedThing.Address)
 	if err != nil {
 		c.AbortWithError(http.StatusBadRequest, err)
 
 		return
 	}
 
+	if err = s.protection.Check(postedThing.Type, postedThing.Address); err != nil {
+		c.AbortWithError(http.StatusForbidden, err)
+
+		return
+	}
+
+	if err = s.validateOnExpiry(&postedThing); err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+
+		return
+	}
+
+	if err = s.validateExpiryMode(&postedThing); err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+
+		return
+	}
+
+	postedThing.Approval = database.ApprovalNotNeeded
+
+	if err = s.applyPolicy(&postedThing); err != nil && !s.holdForApproval(&postedThing, err) {
+		c.AbortWithError(http.StatusBadRequest, err)
+
+		return
+	}
+
+	if err = s.verifyOwner(c, postedThing); err != nil {
+		c.AbortWithError(http.StatusForbidden, err)
+
+		return
+	}
+
+	probed, err := s.measure(c.Request.Context(), &postedThing)
+	if err != nil {
+		c.AbortWithError(http.StatusBadGateway, err)
+
+		return
+	}
+
 	thing, err := s.db.CreateThing(postedThing)
 	if err != nil {
-		c.AbortWithError(http.StatusBadRequest, err)
+		s.abortCreateThing(c, err)
 
 		return
 	}
 
+	if thing.Approval == database.ApprovalPending {
+		s.requestApproval(c, thing)
+	} else if err = s.registerThing(c, thing); err != nil {
+		c.AbortWithError(http.StatusBadGateway, err)
+
+		return
+	}
+
+	s.recordProbe(thing, probed)
+	s.fingerprint(c.Request.Context(), thing)
+
 	err = s.broadcastNewThing(thing)
 	if err != nil {
 		c.AbortWithError(http.StatusInternalServerError, err)
@@ -142,22 +718,208 @@ func (s *Server) postThing(c *gin.Context) {
 	c.Status(http.StatusOK)
 }
 
-// deleteThing deletes the thing with the id in the url /things/id from the
-// database.
-func (s *Server) deleteThing(c *gin.Context) {
-	thingID, err := strconv.Atoi(c.Param("id"))
+// validateOnExpiry checks and normalises the OnExpiry action of the given
+// params, returning an error if it's invalid, or is ExpiryArchive for a
+// ThingsType that has no backend.Archiver.
+func (s *Server) validateOnExpiry(params *database.CreateThingParams) error {
+	action, err := database.NewExpiryAction(string(params.OnExpiry))
 	if err != nil {
-		c.AbortWithError(http.StatusBadRequest, err)
+		return err
+	}
 
-		return
+	params.OnExpiry = action
+
+	if action != database.ExpiryArchive {
+		return nil
+	}
+
+	if _, ok := backend.ArchiverFor(params.Type); !ok {
+		return ErrCantArchive
+	}
+
+	return nil
+}
+
+// validateExpiryMode checks and normalises the ExpiryMode of the given params,
+// returning an error if it's invalid, or is ExpiryModeAccess without a positive
+// ExpireAfter, or for a ThingsType that has no backend.Prober to find out when
+// things were last used. ExpiryModeAccess things without a Remove date are
+// given one ExpireAfter days from now, so that they aren't removed before then
+// even if they were last used long ago.
+func (s *Server) validateExpiryMode(params *database.CreateThingParams) error {
+	mode, err := database.NewExpiryMode(string(params.ExpiryMode))
+	if err != nil {
+		return err
+	}
+
+	params.ExpiryMode = mode
+
+	if mode != database.ExpiryModeAccess {
+		params.ExpireAfter = 0
+
+		return nil
+	}
+
+	if params.ExpireAfter < 1 {
+		return database.ErrBadExpireAfter
+	}
+
+	if _, ok := backend.ProberFor(params.Type); !ok {
+		return ErrCantAccess
+	}
+
+	if params.Remove.IsZero() {
+		y, m, d := time.Now().Date()
+		params.Remove = time.Date(y, m, d+params.ExpireAfter, 0, 0, 0, 0, time.UTC)
+	}
+
+	return nil
+}
+
+// applyPolicy applies the retention policy for the given params' type and
+// address, giving them a default removal date if they don't have one, and
+// returning an error if they break the policy.
+func (s *Server) applyPolicy(params *database.CreateThingParams) error {
+	address := params.Type.CanonicalAddress(params.Address)
+
+	return s.policies.For(params.Type, address).Create(params, time.Now())
+}
+
+// countExtensions returns how many times the given Thing has been extended,
+// according to its history.
+func (s *Server) countExtensions(thing *database.Thing) (int, error) {
+	events, err := s.db.GetAuditEvents(thing.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	extensions := 0
+
+	for _, event := range events {
+		if event.Action == database.AuditExtended {
+			extensions++
+		}
+	}
+
+	return extensions, nil
+}
+
+// verifyOwner returns nil if the user making the request is an admin, or if
+// there's no backend.OwnerVerifier for the thing's type, or if the verifier
+// says they own the thing's address. Otherwise returns an error.
+func (s *Server) verifyOwner(c *gin.Context, params database.CreateThingParams) error {
+	verifier, ok := backend.OwnerVerifierFor(params.Type)
+	if !ok {
+		return nil
+	}
+
+	if slices.Contains(s.admins, params.Creator) {
+		return nil
 	}
 
-	err = s.db.DeleteThing(uint32(thingID))
+	identity, err := backend.LookupIdentity(params.Creator)
 	if err != nil {
+		return err
+	}
+
+	return verifier.VerifyOwner(c.Request.Context(), &database.Thing{
+		Address: params.Type.CanonicalAddress(params.Address),
+		Type:    params.Type,
+	}, identity)
+}
+
+// recordProbe records the given result of probing the newly created thing, if
+// it was probed, logging any failure.
+func (s *Server) recordProbe(thing *database.Thing, result *database.ProbeResult) {
+	if result == nil {
+		return
+	}
+
+	if err := s.db.UpdateProbe(thing.ID, *result); err != nil {
+		s.Logger.Printf("recording probe of thing %d (%s) failed: %s", thing.ID, thing.Address, err)
+
+		return
+	}
+
+	thing.Exists = null.BoolFrom(result.Exists)
+
+	if result.Exists {
+		thing.Size = null.IntFrom(result.Size)
+		thing.Files = null.IntFrom(result.Files)
+	}
+}
+
+// registerThing tells the backend.Registrar for the thing's type, if any, about
+// the newly created thing. If that fails, the thing is deleted again so that
+// users can retry.
+func (s *Server) registerThing(c *gin.Context, thing *database.Thing) error {
+	registrar, ok := backend.RegistrarFor(thing.Type)
+	if !ok {
+		return nil
+	}
+
+	err := registrar.Register(c.Request.Context(), thing)
+	if err == nil {
+		return nil
+	}
+
+	return errors.Join(err, s.db.DeleteThing(thing.ID))
+}
+
+// abortCreateThing aborts the request with http.StatusConflict and a link to
+// the existing thing if err is a *database.DuplicateError, or with
+// http.StatusBadRequest otherwise.
+func (s *Server) abortCreateThing(c *gin.Context, err error) {
+	var dupErr *database.DuplicateError
+	if !errors.As(err, &dupErr) {
 		c.AbortWithError(http.StatusBadRequest, err)
 
 		return
 	}
 
+	c.Header("Location", thingURL(dupErr.ExistingID))
+	c.HTML(http.StatusConflict, "templates/duplicate.html", dupErr)
+	c.Abort()
+}
+
+// thingURL returns the url that getThing() is served from for the given thing
+// ID.
+func thingURL(id uint32) string {
+	return "/things/" + strconv.FormatUint(uint64(id), 10)
+}
+
+// deleteThing deletes the thing with the id in the url /things/id from the
+// database, recording who deleted it in its history, which is kept. Only its
+// subscribers, the owners of its address and admins may delete it, and nobody
+// may delete it while it's on hold: that gets http.StatusConflict.
+func (s *Server) deleteThing(c *gin.Context) {
+	thing, actor, ok := s.thingForManager(c)
+	if !ok {
+		return
+	}
+
+	if thing.Held(time.Now()) {
+		c.AbortWithError(http.StatusConflict, database.ErrThingHeld)
+
+		return
+	}
+
+	if err := s.db.AddAuditEvent(database.AuditEvent{
+		ThingID: thing.ID,
+		Actor:   actor,
+		Action:  database.AuditDeleted,
+		Detail:  "deleted by " + actor,
+	}); err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+
+		return
+	}
+
+	if err := s.db.DeleteThing(thing.ID); err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+
+		return
+	}
+
 	c.Status(http.StatusOK)
 }
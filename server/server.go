@@ -27,13 +27,23 @@ package server
 
 import (
 	"embed"
+	"fmt"
 	"html/template"
 	"io"
 	"io/fs"
+	"net/http"
+	"os/user"
 	"regexp"
+	"time"
 
+	"github.com/gin-gonic/gin"
 	gas "github.com/wtsi-hgi/go-authserver"
+	"github.com/wtsi-hgi/tt/backend"
 	"github.com/wtsi-hgi/tt/database"
+	"github.com/wtsi-hgi/tt/jobs"
+	"github.com/wtsi-hgi/tt/links"
+	"github.com/wtsi-hgi/tt/notify"
+	"github.com/wtsi-hgi/tt/policy"
 )
 
 //go:embed templates
@@ -42,6 +52,18 @@ var templatesFS embed.FS
 const (
 	ErrNoLogger   = gas.Error("a http logger must be configured")
 	ErrNoDatabase = gas.Error("a database must be supplied")
+
+	ErrCantArchive = database.Error("Things of that type can't be archived")
+	ErrCantAccess  = database.Error("Things of that type can't expire after they were last used, " +
+		"since they can't be probed")
+	ErrNotApprover   = database.Error("Only approvers can approve or reject things")
+	ErrSelfApproval  = database.Error("Approvers can't approve things they asked to register")
+	ErrNotAdmin      = database.Error("Only admins can place or release holds")
+	ErrNotSubscriber = database.Error("Only subscribers can snooze warnings about things")
+	ErrNotManager    = database.Error("Only subscribers, owners of its address and admins can change a thing")
+	ErrNoLinks       = database.Error("One-click links are not enabled on this server")
+	ErrNotLoggedIn   = database.Error("You must be logged in to do that")
+	ErrSnoozeTooLate = database.Error("Warnings can't be snoozed once a thing is within its second warning period")
 )
 
 // Config configures the server.
@@ -52,6 +74,61 @@ type Config struct {
 	// Database is used to query MySQL for users, things and subscribers. This
 	// is required.
 	Database database.Queries
+
+	// Admins are the names of users who may create things at any address,
+	// regardless of ownership, and place legal holds on things. Optional.
+	Admins []string
+
+	// Instance identifies this server on the status page, which shows which
+	// of several servers sharing the Database is the leader that runs the
+	// scheduled jobs. Optional.
+	Instance string
+
+	// Policies are the retention rules enforced when things are created and
+	// extended. Optional; without any, things just need a removal date.
+	Policies policy.Policies
+
+	// Protection limits the addresses things can be registered at. Optional.
+	Protection *policy.Protection
+
+	// ApproverGroup is the name of the Unix group whose members approve or
+	// reject things that need approval before being registered: those with a
+	// removal date later than their retention policy allows, or (if
+	// ApprovalSize is set) that are bigger than ApprovalSize bytes. Without it,
+	// such things can't be created. Optional.
+	ApproverGroup string
+	ApprovalSize  int64
+
+	// Schedule decides when things due for removal are actually removed, so
+	// that their effective removal date can be shown. Optional.
+	Schedule *policy.Schedule
+
+	// SecondWarning is how long before their removal date subscribers are sent
+	// their second warning about things. Snoozes end by then, so that nobody
+	// snoozes through it. Optional; defaults to jobs.DefaultSecondWarning.
+	SecondWarning time.Duration
+
+	// Notifier is used to tell approvers about things awaiting approval, and
+	// subscribers about their approval or rejection. Optional.
+	Notifier notify.Notifier
+
+	// Links verifies the signed one-click links in warning emails, which let
+	// subscribers act on things without logging in. Optional; without it,
+	// those links don't work.
+	Links *links.Signer
+
+	// CertFile and KeyFile are used to sign and verify the JWTs of logged in
+	// users. Everything that changes things, such as creating, extending or
+	// holding them, can only be done by logged in users, as whom it is done.
+	// Optional; without them, nobody can log in, so nothing can be changed
+	// (other than via Links).
+	CertFile string
+	KeyFile  string
+
+	// Auth checks the passwords of users logging in with one, returning their
+	// UID. Optional; without it, users can only log in via OIDC, if the
+	// server's AddOIDCRoutes() has been called.
+	Auth gas.AuthCallback
 }
 
 // CheckValid returns nil if all required options have been supplied, or an
@@ -72,8 +149,20 @@ func (c Config) CheckValid() error {
 // package's database, and a website that displays the information nicely.
 type Server struct {
 	gas.Server
-	db           database.Queries
-	rootTemplate *template.Template
+	db            database.Queries
+	admins        []string
+	instance      string
+	policies      policy.Policies
+	protection    *policy.Protection
+	approverGroup string
+	approverGID   string
+	groupMembers  func(group string) ([]string, error)
+	approvalSize  int64
+	notifier      notify.Notifier
+	schedule      *policy.Schedule
+	secondWarning time.Duration
+	links         *links.Signer
+	rootTemplate  *template.Template
 }
 
 // New creates a Server which serves the tt website.
@@ -86,12 +175,34 @@ func New(conf Config) (*Server, error) {
 	}
 
 	s := &Server{
-		Server: *gas.New(conf.HTTPLogger),
-		db:     conf.Database,
+		Server:       *gas.New(conf.HTTPLogger),
+		db:           conf.Database,
+		admins:       conf.Admins,
+		instance:     conf.Instance,
+		policies:     conf.Policies,
+		protection:   conf.Protection,
+		approvalSize: conf.ApprovalSize,
+		groupMembers: backend.GroupMembers,
+		notifier:     conf.Notifier,
+		schedule:     conf.Schedule,
+		links:        conf.Links,
+	}
+
+	s.secondWarning = conf.SecondWarning
+	if s.secondWarning == 0 {
+		s.secondWarning = jobs.DefaultSecondWarning
+	}
+
+	if err := s.setApproverGroup(conf.ApproverGroup); err != nil {
+		return nil, err
 	}
 
 	s.Router().Use(gas.IncludeAbortErrorsInBody)
 
+	if err := s.enableAuth(conf); err != nil {
+		return nil, err
+	}
+
 	err := s.addEndPoints()
 	if err != nil {
 		return nil, err
@@ -100,10 +211,55 @@ func New(conf Config) (*Server, error) {
 	return s, nil
 }
 
+// setApproverGroup looks up the Unix group with the given name, if not blank,
+// so that its members can approve things.
+func (s *Server) setApproverGroup(name string) error {
+	if name == "" {
+		return nil
+	}
+
+	group, err := user.LookupGroup(name)
+	if err != nil {
+		return err
+	}
+
+	s.approverGroup = group.Name
+	s.approverGID = group.Gid
+
+	return nil
+}
+
+// enableAuth enables JWT logins if the given config has a CertFile.
+func (s *Server) enableAuth(conf Config) error {
+	if conf.CertFile == "" {
+		return nil
+	}
+
+	auth := conf.Auth
+	if auth == nil {
+		auth = func(string, string) (bool, string) { return false, "" }
+	}
+
+	return s.EnableAuth(conf.CertFile, conf.KeyFile, auth)
+}
+
+// authenticate returns middleware that aborts with http.StatusUnauthorized
+// unless the request has the JWT of a logged in user, whose details are then
+// available via GetUser().
+func (s *Server) authenticate() gin.HandlerFunc {
+	if group := s.AuthRouter(); group != nil {
+		return group.Handlers[len(group.Handlers)-1]
+	}
+
+	return func(c *gin.Context) {
+		c.AbortWithError(http.StatusUnauthorized, ErrNotLoggedIn)
+	}
+}
+
 func (s *Server) addEndPoints() error {
 	s.rootTemplate = template.New("")
 
-	s.rootTemplate.Funcs(template.FuncMap{"args": func(args ...any) []any { return args }, "add": func(a, b int) int { return a + b }, "sub": func(a, b int) int { return a - b }, "rangenum": func(n int) []struct{} { return make([]struct{}, n) }})
+	s.rootTemplate.Funcs(templateFuncs(s.schedule))
 
 	err := s.loadAllTemplates("templates/.*")
 	if err != nil {
@@ -113,14 +269,58 @@ func (s *Server) addEndPoints() error {
 	s.Router().SetHTMLTemplate(s.rootTemplate)
 
 	s.Router().GET("/", s.pageRoot)
+	s.Router().GET("/status", s.pageStatus)
+	s.Router().GET("/approvals", s.pageApprovals)
 	s.Router().GET("/things", s.getThings)
 	s.Router().GET("/things/listen", s.SSESender(sseThingsEventName))
-	s.Router().POST("/things", s.postThing)
-	s.Router().DELETE("/things/:id", s.deleteThing)
+	s.Router().GET("/things/previous", s.getPreviousThings)
+	s.Router().GET("/things/:id", s.getThing)
+	s.Router().GET("/jobs", s.getJobs)
+	s.Router().GET(links.Path+":token", s.getLink)
+	s.Router().POST(links.Path+":token", s.postLink)
+
+	authed := s.Router().Group("/", s.authenticate())
+	authed.POST("/things", s.postThing)
+	authed.POST("/things/:id/extend", s.postExtend)
+	authed.POST("/things/:id/restore", s.postRestore)
+	authed.POST("/things/:id/confirm", s.postConfirm)
+	authed.POST("/things/:id/retry", s.postRetry)
+	authed.POST("/things/:id/cancel", s.postCancel)
+	authed.POST("/things/:id/approve", s.postApprove)
+	authed.POST("/things/:id/reject", s.postReject)
+	authed.POST("/things/:id/hold", s.postHold)
+	authed.POST("/things/:id/release", s.postRelease)
+	authed.POST("/things/:id/snooze", s.postSnooze)
+	authed.DELETE("/things/:id", s.deleteThing)
 
 	return nil
 }
 
+// templateFuncs returns the functions our templates can use. "scheduled"
+// gives the time a thing will actually be removed, according to the given
+// schedule (which can be nil).
+func templateFuncs(schedule *policy.Schedule) template.FuncMap {
+	return template.FuncMap{"args": func(args ...any) []any { return args }, "add": func(a, b int) int { return a + b }, "sub": func(a, b int) int { return a - b }, "rangenum": func(n int) []struct{} { return make([]struct{}, n) }, "bytes": formatBytes, "thingsTypes": database.ThingsTypes, "now": time.Now, "scheduled": func(thing database.Thing) time.Time { return schedule.Scheduled(&thing) }}
+}
+
+// formatBytes returns the given number of bytes in a human readable form, eg.
+// "1.5 KiB".
+func formatBytes(n int64) string {
+	const unit = 1024
+
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
 func (s *Server) loadAllTemplates(pattern string) error {
 	return fs.WalkDir(templatesFS, ".", func(path string, d fs.DirEntry, walkErr error) error {
 		if walkErr != nil {
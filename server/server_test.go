@@ -27,27 +27,54 @@ package server
 
 import (
 	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
 	"html/template"
 	"io"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"os/user"
+	"path/filepath"
 	"slices"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
+	null "github.com/guregu/null/v5"
 	. "github.com/smartystreets/goconvey/convey"
 	gas "github.com/wtsi-hgi/go-authserver"
+	"github.com/wtsi-hgi/tt/backend"
+	"github.com/wtsi-hgi/tt/backend/fs"
+	_ "github.com/wtsi-hgi/tt/backend/openstack"
+	_ "github.com/wtsi-hgi/tt/backend/s3"
 	"github.com/wtsi-hgi/tt/database"
 	"github.com/wtsi-hgi/tt/internal"
+	"github.com/wtsi-hgi/tt/links"
+	"github.com/wtsi-hgi/tt/policy"
 )
 
 type mockDB struct {
 	users    []database.User
 	things   []database.Thing
 	subs     []database.Subscriber
+	audit    []database.AuditEvent
 	thingID  uint32
 	lastPage int
+	leases   []database.Lease
+	snoozes  []database.Snooze
+	confirms []database.Confirmation
+	unsubs   []database.Subscriber
+	used     map[string]bool
 }
 
 func newMockDB() *mockDB {
@@ -59,6 +86,7 @@ func newMockDB() *mockDB {
 		users:    users,
 		things:   things,
 		subs:     subs,
+		used:     make(map[string]bool),
 		lastPage: 1,
 	}
 }
@@ -67,21 +95,78 @@ func (m *mockDB) CreateUser(name, email string) (*database.User, error) {
 	return nil, nil
 }
 
+func (m *mockDB) GetUserByName(name string) (*database.User, error) {
+	for _, user := range m.users {
+		if user.Name == name {
+			return &user, nil
+		}
+	}
+
+	return nil, errors.New("no such user")
+}
+
 func (m *mockDB) CreateThing(args database.CreateThingParams) (*database.Thing, error) {
+	for _, thing := range m.things {
+		if thing.Address == args.Address && thing.Type == args.Type && !thing.Removed {
+			return nil, &database.DuplicateError{ExistingID: thing.ID}
+		}
+	}
+
 	id := m.thingID
 	m.thingID++
 
 	thing := database.Thing{
-		ID:      id,
-		Address: args.Address,
+		ID:          id,
+		Address:     args.Address,
+		Type:        args.Type,
+		Remove:      args.Remove,
+		Creator:     args.Creator,
+		OnExpiry:    args.OnExpiry,
+		ExpiryMode:  args.ExpiryMode,
+		ExpireAfter: args.ExpireAfter,
+
+		Approval:       args.Approval,
+		ApprovalReason: args.ApprovalReason,
 	}
 
+	thing.State = thing.ComputeState()
+
 	m.things = append(m.things, thing)
 
+	if !slices.ContainsFunc(m.users, func(user database.User) bool { return user.Name == args.Creator }) {
+		m.users = append(m.users, database.User{ID: uint32(len(m.users)) + 1, Name: args.Creator})
+	}
+
 	return &thing, nil
 }
 
+func (m *mockDB) GetThing(id uint32) (*database.Thing, error) {
+	for _, thing := range m.things {
+		if thing.ID == id {
+			return &thing, nil
+		}
+	}
+
+	return nil, database.ErrNoThing
+}
+
 func (m *mockDB) GetThings(params database.GetThingsParams) (*database.GetThingsResult, error) {
+	if params.FilterOnAddress != "" {
+		return &database.GetThingsResult{Things: filterThingsOnAddress(m.things, params)}, nil
+	}
+
+	if params.FilterOnState != "" {
+		var things []database.Thing
+
+		for _, thing := range m.things {
+			if thing.ComputeState() == params.FilterOnState {
+				things = append(things, thing)
+			}
+		}
+
+		return &database.GetThingsResult{Things: things}, nil
+	}
+
 	return &database.GetThingsResult{
 		Things:   sortAndFilterThings(m.things, params),
 		LastPage: m.lastPage,
@@ -128,191 +213,2319 @@ func sortAndFilterThings(origThings []database.Thing, params database.GetThingsP
 	return things
 }
 
-func (m *mockDB) DeleteUser(id uint32) error {
+func filterThingsOnAddress(origThings []database.Thing, params database.GetThingsParams) []database.Thing {
+	var things []database.Thing
+
+	for _, thing := range origThings {
+		if thing.Address != params.FilterOnAddress || thing.Type != params.FilterOnType {
+			continue
+		}
+
+		if params.FilterOnRemoved.Valid && thing.Removed != params.FilterOnRemoved.Bool {
+			continue
+		}
+
+		things = append(things, thing)
+	}
+
+	return things
+}
+
+func (m *mockDB) MarkRemoved(id uint32) error {
+	for i, thing := range m.things {
+		if thing.ID == id {
+			m.things[i].Removed = true
+		}
+	}
+
 	return nil
 }
 
-func (m *mockDB) DeleteThing(id uint32) error {
+func (m *mockDB) ExtendRemoval(id uint32, remove time.Time) error {
 	for i, thing := range m.things {
 		if thing.ID == id {
-			m.things = append(m.things[:i], m.things[i+1:]...)
+			m.things[i].Remove = remove
+			m.things[i].Warned1 = null.Time{}
+			m.things[i].Warned2 = null.Time{}
+			m.things[i].Changed = null.Time{}
 		}
 	}
 
 	return nil
 }
 
-func (m *mockDB) Close() error {
+func (m *mockDB) SetWarned(id uint32, warned1, warned2 null.Time) error {
+	for i, thing := range m.things {
+		if thing.ID == id {
+			m.things[i].Warned1 = warned1
+			m.things[i].Warned2 = warned2
+		}
+	}
+
 	return nil
 }
 
-func TestServer(t *testing.T) {
-	Convey("Given a valid Config", t, func() {
-		logWriter := gas.NewStringLogger()
-		mdb := newMockDB()
+func (m *mockDB) SetFingerprint(id uint32, fingerprint *database.Fingerprint) error {
+	for i, thing := range m.things {
+		if thing.ID == id {
+			m.things[i].Fingerprint = fingerprint
+			m.things[i].Changed = null.Time{}
+		}
+	}
 
-		conf := Config{
-			HTTPLogger: logWriter,
-			Database:   mdb,
+	return nil
+}
+
+func (m *mockDB) SetApproval(id uint32, state database.ApprovalState) error {
+	for i, thing := range m.things {
+		if thing.ID == id {
+			m.things[i].Approval = state
+			m.things[i].Removed = thing.Removed || state == database.ApprovalRejected
+			m.things[i].State = m.things[i].ComputeState()
 		}
-		So(conf.CheckValid(), ShouldBeNil)
+	}
 
-		s, err := New(conf)
-		So(err, ShouldBeNil)
+	return nil
+}
 
-		SkipConvey("You can start and stop the server", func() {
-			errCh := make(chan error, 1)
+func (m *mockDB) SetHold(id uint32, reason string, until null.Time) error {
+	for i, thing := range m.things {
+		if thing.ID == id {
+			m.things[i].HoldReason = null.StringFrom(reason)
+			m.things[i].HoldUntil = until
+		}
+	}
 
-			go func() {
-				errCh <- s.Start(":0", "", "")
-			}()
+	return nil
+}
 
-			<-time.After(1 * time.Second)
+func (m *mockDB) ReleaseHold(id uint32) error {
+	for i, thing := range m.things {
+		if thing.ID == id {
+			m.things[i].HoldReason = null.String{}
+			m.things[i].HoldUntil = null.Time{}
+		}
+	}
 
-			s.Stop()
-			err = <-errCh
-			So(err, ShouldBeNil)
-		})
+	return nil
+}
 
-		Convey("You can use the root endpoint", func() {
-			actual := testEndpoint(s, "GET", "/", nil)
+func (m *mockDB) SetSnooze(thingID, userID uint32, until time.Time) error {
+	for i, snooze := range m.snoozes {
+		if snooze.ThingID == thingID && snooze.User.ID == userID {
+			m.snoozes[i].Until = until
 
-			expected, err := templatesFS.ReadFile("templates/root.html")
-			So(err, ShouldBeNil)
+			return nil
+		}
+	}
 
-			So(actual, ShouldEqual, string(expected))
-		})
+	for _, user := range m.users {
+		if user.ID == userID {
+			m.snoozes = append(m.snoozes, database.Snooze{ThingID: thingID, User: user, Until: until})
+		}
+	}
 
-		Convey("You can GET the things endpoint", func() {
-			actual := testEndpoint(s, "GET", "/things", nil)
-			So(actual, ShouldEqual, "")
+	return nil
+}
 
-			mdb.users, mdb.things, mdb.subs = internal.GetExampleData()
-			expected := executeThingsTemplate(mdb.things)
+func (m *mockDB) GetSnoozes(thingID uint32) ([]database.Snooze, error) {
+	return nil, nil
+}
 
-			actual = testEndpoint(s, "GET", "/things", nil)
-			So(actual, ShouldEqual, expected)
-			So(strings.Count(actual, "</tr"), ShouldEqual, 10)
-			So(strings.Count(actual, "<td>"), ShouldEqual, 60)
+func (m *mockDB) GetEndedSnoozes(before time.Time) ([]database.Snooze, error) {
+	return nil, nil
+}
 
-			things := sortAndFilterThings(mdb.things, database.GetThingsParams{
-				OrderDirection: database.OrderDesc,
-			})
-			expected = executeThingsTemplate(things)
-			actual = testEndpoint(s, "GET", "/things?dir=DESC", nil)
-			So(actual, ShouldEqual, expected)
+func (m *mockDB) MarkSnoozeMissed(thingID, userID uint32) error {
+	return nil
+}
 
-			code := testEndpointCode(s, "GET", "/things?dir=BAD", nil)
-			So(code, ShouldEqual, http.StatusBadRequest)
+func (m *mockDB) DeleteSnooze(thingID, userID uint32) error {
+	return nil
+}
 
-			things = sortAndFilterThings(mdb.things, database.GetThingsParams{
-				OrderBy: database.OrderByAddress,
-			})
-			expected = executeThingsTemplate(things)
-			actual = testEndpoint(s, "GET", "/things?sort=address", nil)
-			So(actual, ShouldEqual, expected)
+func (m *mockDB) ConfirmRemoval(thingID, userID uint32, remove time.Time) error {
+	m.confirms = slices.DeleteFunc(m.confirms, func(c database.Confirmation) bool {
+		return c.ThingID == thingID && c.User.ID == userID
+	})
 
-			code = testEndpointCode(s, "GET", "/things?sort=bad", nil)
-			So(code, ShouldEqual, http.StatusBadRequest)
+	for _, user := range m.users {
+		if user.ID == userID {
+			m.confirms = append(m.confirms, database.Confirmation{ThingID: thingID, User: user, Remove: remove})
+		}
+	}
 
-			things = sortAndFilterThings(mdb.things, database.GetThingsParams{
-				OrderBy:        database.OrderByAddress,
-				OrderDirection: database.OrderDesc,
-			})
-			expected = executeThingsTemplate(things)
-			actual = testEndpoint(s, "GET", "/things?sort=address&dir=DESC", nil)
-			So(actual, ShouldEqual, expected)
+	return nil
+}
 
-			things = sortAndFilterThings(mdb.things, database.GetThingsParams{
-				FilterOnType: database.ThingsTypeS3,
-			})
-			expected = executeThingsTemplate(things)
-			actual = testEndpoint(s, "GET", "/things?type=s3", nil)
-			So(actual, ShouldEqual, expected)
-			So(strings.Count(actual, "</tr>"), ShouldEqual, 2)
+func (m *mockDB) GetConfirmations(thingID uint32) ([]database.Confirmation, error) {
+	return m.confirms, nil
+}
 
-			code = testEndpointCode(s, "GET", "/things?type=bad", nil)
-			So(code, ShouldEqual, http.StatusBadRequest)
+func (m *mockDB) UseLink(id string, expires time.Time, use func() error) error {
+	if m.used[id] {
+		return database.ErrLinkUsed
+	}
 
-			perPage := 3
-			things = sortAndFilterThings(mdb.things, database.GetThingsParams{
-				Page:          1,
-				ThingsPerPage: perPage,
-			})
-			expected = executeThingsTemplate(things)
-			actual = testEndpoint(s, "GET", "/things?page=1&per_page=3", nil)
-			So(actual, ShouldEqual, expected)
-			So(strings.Count(actual, "</tr>"), ShouldEqual, perPage)
-			So(actual, ShouldContainSubstring, "<td>j</td>")
-			So(actual, ShouldContainSubstring, "<td>c</td>")
-			So(actual, ShouldContainSubstring, "<td>e</td>")
+	if err := use(); err != nil {
+		return err
+	}
 
-			things = sortAndFilterThings(mdb.things, database.GetThingsParams{
-				Page:          2,
-				ThingsPerPage: perPage,
-			})
-			expected = executeThingsTemplate(things)
-			actual = testEndpoint(s, "GET", "/things?page=2&per_page=3", nil)
-			So(actual, ShouldEqual, expected)
-			So(strings.Count(actual, "</tr>"), ShouldEqual, perPage)
-			So(actual, ShouldContainSubstring, "<td>i</td>")
-			So(actual, ShouldContainSubstring, "<td>a</td>")
-			So(actual, ShouldContainSubstring, "<td>f</td>")
-		})
+	m.used[id] = true
 
-		Convey("You can POST to the things endpoint and listen for SSE updates", func() {
-			actual := testEndpoint(s, "POST", "/things", nil)
-			So(actual, ShouldEqual, "")
+	return nil
+}
 
-			So(len(mdb.things), ShouldEqual, 1)
-			So(mdb.things[0].Address, ShouldEqual, "test1")
-		})
-	})
+func (m *mockDB) MarkChanged(id uint32) error {
+	for i, thing := range m.things {
+		if thing.ID == id {
+			m.things[i].Changed = null.TimeFrom(time.Now())
+		}
+	}
+
+	return nil
 }
 
-func testEndpoint(s *Server, method, target string, inputBody io.Reader) string {
-	recorder := recordRequest(s, method, target, inputBody)
-	So(recorder.Code, ShouldEqual, http.StatusOK)
-	So(recorder.Header().Get("Content-Type"), ShouldEqual, "text/html; charset=utf-8")
+func (m *mockDB) MarkQuarantined(id uint32, location string, until time.Time) error {
+	for i, thing := range m.things {
+		if thing.ID == id {
+			m.things[i].Quarantine = null.StringFrom(location)
+			m.things[i].QuarantineUntil = null.TimeFrom(until)
+		}
+	}
 
-	return recorder.Body.String()
+	return nil
 }
 
-func recordRequest(s *Server, method, target string, inputBody io.Reader) *httptest.ResponseRecorder {
-	recorder := httptest.NewRecorder()
-	req := httptest.NewRequest(method, target, inputBody)
-	s.Router().ServeHTTP(recorder, req)
+func (m *mockDB) MarkArchived(id uint32, path string) error {
+	for i, thing := range m.things {
+		if thing.ID == id {
+			m.things[i].Archive = null.StringFrom(path)
+		}
+	}
 
-	return recorder
+	return nil
 }
 
-func testEndpointCode(s *Server, method, target string, inputBody io.Reader) int {
-	recorder := recordRequest(s, method, target, inputBody)
-	return recorder.Code
+func (m *mockDB) MarkExpired(id uint32) error {
+	for i, thing := range m.things {
+		if thing.ID == id {
+			m.things[i].Expired = null.TimeFrom(time.Now())
+		}
+	}
+
+	return nil
 }
 
-func executeThingsTemplate(things []database.Thing) string {
-	data, err := templatesFS.ReadFile("templates/things.html")
-	So(err, ShouldBeNil)
-	templ := template.New("")
-	templChild := templ.New("templates/things.html")
-	templChild, err = templChild.Parse(string(data))
-	So(err, ShouldBeNil)
+func (m *mockDB) MarkRestored(id uint32, remove time.Time) error {
+	for i, thing := range m.things {
+		if thing.ID == id {
+			m.things[i].Quarantine = null.String{}
+			m.things[i].QuarantineUntil = null.Time{}
+			m.things[i].Remove = remove
+		}
+	}
 
-	data, err = templatesFS.ReadFile("templates/thing.html")
-	So(err, ShouldBeNil)
-	templChild = templChild.New("templates/thing.html")
-	_, err = templChild.Parse(string(data))
-	So(err, ShouldBeNil)
+	return nil
+}
 
-	var expectedB bytes.Buffer
-	err = templ.ExecuteTemplate(&expectedB, "templates/things.html", things)
-	So(err, ShouldBeNil)
+func (m *mockDB) EnqueueJob(thingID uint32, action database.JobAction) error {
+	return m.setJob(thingID, func(thing *database.Thing) error {
+		if thing.Job == "" {
+			thing.Job = database.JobQueued
+		}
 
-	expected := expectedB.String()
-	So(expected, ShouldStartWith, "\n<tr")
-	So(expected, ShouldEndWith, "</tr>\n")
-	So(expected, ShouldContainSubstring, "<td>")
+		return nil
+	})
+}
 
-	return expected
+// setJob calls change on the thing with the given ID.
+func (m *mockDB) setJob(thingID uint32, change func(*database.Thing) error) error {
+	for i, thing := range m.things {
+		if thing.ID == thingID {
+			return change(&m.things[i])
+		}
+	}
+
+	return database.ErrNoThing
+}
+
+func (m *mockDB) GetRemovalJobs(state database.JobState) ([]database.RemovalJob, error) {
+	var jobs []database.RemovalJob
+
+	for _, thing := range m.things {
+		if thing.Job != "" && (state == "" || thing.Job == state) {
+			jobs = append(jobs, database.RemovalJob{
+				ThingID:   thing.ID,
+				Type:      thing.Type,
+				Action:    database.JobRemove,
+				State:     thing.Job,
+				LastError: thing.JobError,
+				Progress:  thing.JobProgress,
+			})
+		}
+	}
+
+	return jobs, nil
+}
+
+func (m *mockDB) StartJob(thingID uint32, _ string) error {
+	return m.setJob(thingID, func(thing *database.Thing) error {
+		thing.Job = database.JobRunning
+
+		return nil
+	})
+}
+
+func (m *mockDB) UpdateJobProgress(thingID uint32, progress database.RemovalProgress) error {
+	return m.setJob(thingID, func(thing *database.Thing) error {
+		thing.JobProgress = progress
+
+		return nil
+	})
+}
+
+func (m *mockDB) FinishJob(thingID uint32) error {
+	return m.setJob(thingID, func(thing *database.Thing) error {
+		thing.Job = ""
+		thing.JobError = null.String{}
+
+		return nil
+	})
+}
+
+func (m *mockDB) FailJob(thingID uint32, lastError string, state database.JobState, _ time.Time) error {
+	return m.setJob(thingID, func(thing *database.Thing) error {
+		thing.Job = state
+		thing.JobError = null.StringFrom(lastError)
+
+		return nil
+	})
+}
+
+func (m *mockDB) RetryJob(thingID uint32) error {
+	return m.setJob(thingID, func(thing *database.Thing) error {
+		switch thing.Job {
+		case "":
+			return database.ErrNoJob
+		case database.JobFailed, database.JobCancelled:
+			thing.Job = database.JobQueued
+
+			return nil
+		}
+
+		return database.ErrJobNotFailed
+	})
+}
+
+func (m *mockDB) CancelJob(thingID uint32) error {
+	return m.setJob(thingID, func(thing *database.Thing) error {
+		switch thing.Job {
+		case "":
+			return database.ErrNoJob
+		case database.JobRunning:
+			return database.ErrJobRunning
+		}
+
+		thing.Job = database.JobCancelled
+
+		return nil
+	})
+}
+
+func (m *mockDB) ResetRunningJobs() error {
+	for i, thing := range m.things {
+		if thing.Job == database.JobRunning {
+			m.things[i].Job = database.JobQueued
+		}
+	}
+
+	return nil
+}
+
+func (m *mockDB) AcquireLease(name, holder string, ttl time.Duration) (bool, error) {
+	return false, nil
+}
+
+func (m *mockDB) ReleaseLease(name, holder string) error {
+	return nil
+}
+
+func (m *mockDB) GetLeases() ([]database.Lease, error) {
+	return m.leases, nil
+}
+
+func (m *mockDB) UpdateProbe(id uint32, result database.ProbeResult) error {
+	for i, thing := range m.things {
+		if thing.ID == id {
+			m.things[i].Exists = null.BoolFrom(result.Exists)
+			m.things[i].Size = null.IntFrom(result.Size)
+			m.things[i].Files = null.IntFrom(result.Files)
+			m.things[i].Modified = null.TimeFrom(result.Modified)
+			m.things[i].Accessed = null.NewTime(result.Accessed, !result.Accessed.IsZero())
+		}
+	}
+
+	return nil
+}
+
+func (m *mockDB) GetSubscribers(thingID uint32) ([]database.User, error) {
+	thing, err := m.GetThing(thingID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, user := range m.users {
+		unsubscribed := slices.Contains(m.unsubs, database.Subscriber{UserID: user.ID, ThingID: thingID})
+
+		if user.Name == thing.Creator && !unsubscribed {
+			return []database.User{user}, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (m *mockDB) Unsubscribe(thingID, userID uint32) error {
+	m.unsubs = append(m.unsubs, database.Subscriber{UserID: userID, ThingID: thingID})
+
+	return nil
+}
+
+func (m *mockDB) AddAuditEvent(event database.AuditEvent) error {
+	m.audit = append(m.audit, event)
+
+	return nil
+}
+
+func (m *mockDB) GetAuditEvents(thingID uint32) ([]database.AuditEvent, error) {
+	var events []database.AuditEvent
+
+	for _, event := range m.audit {
+		if event.ThingID == thingID {
+			events = append(events, event)
+		}
+	}
+
+	return events, nil
+}
+
+func (m *mockDB) DeleteUser(id uint32) error {
+	return nil
+}
+
+func (m *mockDB) DeleteThing(id uint32) error {
+	for i, thing := range m.things {
+		if thing.ID == id {
+			m.things = append(m.things[:i], m.things[i+1:]...)
+		}
+	}
+
+	return nil
+}
+
+func (m *mockDB) Close() error {
+	return nil
+}
+
+func TestServer(t *testing.T) {
+	Convey("Given a valid Config", t, func() {
+		logWriter := gas.NewStringLogger()
+		mdb := newMockDB()
+
+		conf := Config{
+			HTTPLogger: logWriter,
+			Database:   mdb,
+		}
+		So(conf.CheckValid(), ShouldBeNil)
+
+		s, err := New(withAuth(conf))
+		So(err, ShouldBeNil)
+
+		SkipConvey("You can start and stop the server", func() {
+			errCh := make(chan error, 1)
+
+			go func() {
+				errCh <- s.Start(":0", "", "")
+			}()
+
+			<-time.After(1 * time.Second)
+
+			s.Stop()
+			err = <-errCh
+			So(err, ShouldBeNil)
+		})
+
+		Convey("You can use the root endpoint", func() {
+			actual := testEndpoint(s, "GET", "/", nil)
+
+			data, err := templatesFS.ReadFile("templates/root.html")
+			So(err, ShouldBeNil)
+
+			templ, err := template.New("").Funcs(templateFuncs(nil)).Parse(string(data))
+			So(err, ShouldBeNil)
+
+			data, err = templatesFS.ReadFile("templates/login.html")
+			So(err, ShouldBeNil)
+
+			_, err = templ.New("templates/login.html").Parse(string(data))
+			So(err, ShouldBeNil)
+
+			var expected bytes.Buffer
+
+			err = templ.Execute(&expected, nil)
+			So(err, ShouldBeNil)
+
+			So(actual, ShouldEqual, expected.String())
+
+			for _, thingsType := range database.ThingsTypes() {
+				So(actual, ShouldContainSubstring,
+					`<option value="`+string(thingsType)+`">`+thingsType.Label()+`</option>`)
+			}
+		})
+
+		Convey("You can GET the things endpoint", func() {
+			actual := testEndpoint(s, "GET", "/things", nil)
+			So(actual, ShouldEqual, "")
+
+			mdb.users, mdb.things, mdb.subs = internal.GetExampleData()
+			expected := executeThingsTemplate(mdb.things)
+
+			actual = testEndpoint(s, "GET", "/things", nil)
+			So(actual, ShouldEqual, expected)
+			So(strings.Count(actual, "</tr"), ShouldEqual, 10)
+			So(strings.Count(actual, "<td>"), ShouldEqual, 90)
+
+			things := sortAndFilterThings(mdb.things, database.GetThingsParams{
+				OrderDirection: database.OrderDesc,
+			})
+			expected = executeThingsTemplate(things)
+			actual = testEndpoint(s, "GET", "/things?dir=DESC", nil)
+			So(actual, ShouldEqual, expected)
+
+			code := testEndpointCode(s, "GET", "/things?dir=BAD", nil)
+			So(code, ShouldEqual, http.StatusBadRequest)
+
+			things = sortAndFilterThings(mdb.things, database.GetThingsParams{
+				OrderBy: database.OrderByAddress,
+			})
+			expected = executeThingsTemplate(things)
+			actual = testEndpoint(s, "GET", "/things?sort=address", nil)
+			So(actual, ShouldEqual, expected)
+
+			code = testEndpointCode(s, "GET", "/things?sort=bad", nil)
+			So(code, ShouldEqual, http.StatusBadRequest)
+
+			things = sortAndFilterThings(mdb.things, database.GetThingsParams{
+				OrderBy:        database.OrderByAddress,
+				OrderDirection: database.OrderDesc,
+			})
+			expected = executeThingsTemplate(things)
+			actual = testEndpoint(s, "GET", "/things?sort=address&dir=DESC", nil)
+			So(actual, ShouldEqual, expected)
+
+			things = sortAndFilterThings(mdb.things, database.GetThingsParams{
+				FilterOnType: database.ThingsTypeS3,
+			})
+			expected = executeThingsTemplate(things)
+			actual = testEndpoint(s, "GET", "/things?type=s3", nil)
+			So(actual, ShouldEqual, expected)
+			So(strings.Count(actual, "</tr>"), ShouldEqual, 2)
+
+			code = testEndpointCode(s, "GET", "/things?type=bad", nil)
+			So(code, ShouldEqual, http.StatusBadRequest)
+
+			mdb.things[0].Removed = true
+			mdb.things[0].State = database.StateRemoved
+			actual = testEndpoint(s, "GET", "/things?state=removed", nil)
+			So(strings.Count(actual, "</tr>"), ShouldEqual, 1)
+			So(actual, ShouldContainSubstring, "<td>j</td>")
+			So(actual, ShouldContainSubstring, `<span class="uk-label"
+			title="lifecycle state">removed</span>`)
+
+			actual = testEndpoint(s, "GET", "/things?state=active", nil)
+			So(strings.Count(actual, "</tr>"), ShouldEqual, 9)
+			So(strings.Count(actual, "<td>j</td>"), ShouldEqual, 1)
+			So(actual, ShouldContainSubstring, `<span class="uk-label uk-label-success"
+			title="lifecycle state">active</span>`)
+
+			code = testEndpointCode(s, "GET", "/things?state=bad", nil)
+			So(code, ShouldEqual, http.StatusBadRequest)
+
+			perPage := 3
+			things = sortAndFilterThings(mdb.things, database.GetThingsParams{
+				Page:          1,
+				ThingsPerPage: perPage,
+			})
+			expected = executeThingsTemplate(things)
+			actual = testEndpoint(s, "GET", "/things?page=1&per_page=3", nil)
+			So(actual, ShouldEqual, expected)
+			So(strings.Count(actual, "</tr>"), ShouldEqual, perPage)
+			So(actual, ShouldContainSubstring, "<td>j</td>")
+			So(actual, ShouldContainSubstring, "<td>c</td>")
+			So(actual, ShouldContainSubstring, "<td>e</td>")
+
+			things = sortAndFilterThings(mdb.things, database.GetThingsParams{
+				Page:          2,
+				ThingsPerPage: perPage,
+			})
+			expected = executeThingsTemplate(things)
+			actual = testEndpoint(s, "GET", "/things?page=2&per_page=3", nil)
+			So(actual, ShouldEqual, expected)
+			So(strings.Count(actual, "</tr>"), ShouldEqual, perPage)
+			So(actual, ShouldContainSubstring, "<td>i</td>")
+			So(actual, ShouldContainSubstring, "<td>a</td>")
+			So(actual, ShouldContainSubstring, "<td>f</td>")
+		})
+
+		Convey("You can't change anything unless you're logged in", func() {
+			for _, route := range []string{
+				"POST /things", "POST /things/0/extend", "POST /things/0/restore", "POST /things/0/confirm",
+				"POST /things/0/retry", "POST /things/0/cancel", "POST /things/0/approve", "POST /things/0/reject",
+				"POST /things/0/hold", "POST /things/0/release", "POST /things/0/snooze", "DELETE /things/0",
+			} {
+				method, target, _ := strings.Cut(route, " ")
+
+				So(testEndpointCode(s, method, target, nil), ShouldEqual, http.StatusUnauthorized)
+			}
+
+			form := "Address=/test1&Type=dir&Reason=r&Remove=2025-01-02"
+			So(testEndpointCode(s, "POST", "/things", strings.NewReader(form)), ShouldEqual, http.StatusUnauthorized)
+			So(testEndpointCode(s, "POST", gas.EndPointJWT, strings.NewReader("username=user1&password=bad")),
+				ShouldEqual, http.StatusUnauthorized)
+			So(mdb.things, ShouldBeEmpty)
+
+			Convey("and nobody can log in without auth being configured", func() {
+				s, err = New(conf)
+				So(err, ShouldBeNil)
+
+				So(testEndpointCode(s, "POST", gas.EndPointJWT, strings.NewReader("username=user1&password="+testPassword)),
+					ShouldEqual, http.StatusNotFound)
+
+				recorder := recordRequest(s, "POST", "/things", strings.NewReader(form))
+				So(recorder.Code, ShouldEqual, http.StatusUnauthorized)
+				So(recorder.Body.String(), ShouldContainSubstring, ErrNotLoggedIn.Error())
+			})
+		})
+
+		Convey("Things are created by the logged in user, not any posted Creator", func() {
+			form := "Address=/test1&Type=dir&Reason=r&Remove=2025-01-02&Creator=admin"
+
+			So(testEndpointCodeAs(s, "user1", "POST", "/things", strings.NewReader(form)), ShouldEqual, http.StatusOK)
+			So(mdb.things[0].Creator, ShouldEqual, "user1")
+		})
+
+		Convey("You can't POST things with invalid types or addresses", func() {
+			for _, form := range []string{
+				"Address=/test1&Type=bad&Reason=r&Remove=2025-01-02",
+				"Address=test1&Type=dir&Reason=r&Remove=2025-01-02",
+				"Address=+&Type=irods&Reason=r&Remove=2025-01-02",
+				"Address=network/1&Type=openstack&Reason=r&Remove=2025-01-02",
+			} {
+				recorder := recordRequestAs(s, "user1", "POST", "/things", strings.NewReader(form))
+				So(recorder.Code, ShouldEqual, http.StatusBadRequest)
+			}
+
+			recorder := recordRequestAs(s, "user1", "POST", "/things",
+				strings.NewReader("Address=test1&Type=dir&Reason=r&Remove=2025-01-02"))
+			So(recorder.Body.String(), ShouldContainSubstring, string(database.ErrNotAbsolute))
+			So(mdb.things, ShouldBeEmpty)
+		})
+
+		Convey("You can POST to the things endpoint and listen for SSE updates", func() {
+			form := "Address=/test1&Type=dir&Reason=r&Remove=2025-01-02"
+
+			code := testEndpointCodeAs(s, "user1", "POST", "/things", strings.NewReader(form))
+			So(code, ShouldEqual, http.StatusOK)
+
+			So(len(mdb.things), ShouldEqual, 1)
+			So(mdb.things[0].Address, ShouldEqual, "/test1")
+
+			Convey("Then you can GET it by its ID", func() {
+				actual := testEndpoint(s, "GET", "/things/0", nil)
+				So(actual, ShouldContainSubstring, "<td>/test1</td>")
+
+				code := testEndpointCode(s, "GET", "/things/1", nil)
+				So(code, ShouldEqual, http.StatusNotFound)
+
+				code = testEndpointCode(s, "GET", "/things/bad", nil)
+				So(code, ShouldEqual, http.StatusBadRequest)
+			})
+
+			Convey("Then once probed, its size, files and modification date are shown", func() {
+				modified := time.Date(2025, 2, 3, 4, 5, 6, 0, time.UTC)
+				err := mdb.UpdateProbe(0, database.ProbeResult{Exists: true, Size: 1536, Files: 3, Modified: modified})
+				So(err, ShouldBeNil)
+
+				actual := testEndpoint(s, "GET", "/things/0", nil)
+				So(actual, ShouldContainSubstring, "<td>1.5 KiB</td>")
+				So(actual, ShouldContainSubstring, "<td>3</td>")
+				So(actual, ShouldContainSubstring, "<td>2025-02-03</td>")
+				So(actual, ShouldNotContainSubstring, "missing")
+
+				err = mdb.UpdateProbe(0, database.ProbeResult{})
+				So(err, ShouldBeNil)
+
+				actual = testEndpoint(s, "GET", "/things/0", nil)
+				So(actual, ShouldContainSubstring, "missing")
+			})
+
+			Convey("Then POSTing the same address and type again is a conflict", func() {
+				recorder := recordRequestAs(s, "user1", "POST", "/things", strings.NewReader(form))
+				So(recorder.Code, ShouldEqual, http.StatusConflict)
+				So(recorder.Header().Get("Location"), ShouldEqual, "/things/0")
+				So(recorder.Body.String(), ShouldContainSubstring, `href="/things/0"`)
+				So(len(mdb.things), ShouldEqual, 1)
+
+				form = strings.Replace(form, "Type=dir", "Type=file", 1)
+				code := testEndpointCodeAs(s, "user1", "POST", "/things", strings.NewReader(form))
+				So(code, ShouldEqual, http.StatusOK)
+				So(len(mdb.things), ShouldEqual, 2)
+			})
+
+			Convey("Then once it is removed you can register the address again, and see the previous one", func() {
+				actual := testEndpoint(s, "GET", "/things/previous?Address=/test1&Type=dir", nil)
+				So(actual, ShouldNotContainSubstring, "previously registered")
+
+				err := mdb.MarkRemoved(0)
+				So(err, ShouldBeNil)
+
+				actual = testEndpoint(s, "GET", "/things/previous?Address=/test1/&Type=dir", nil)
+				So(actual, ShouldContainSubstring, "previously registered")
+
+				code := testEndpointCodeAs(s, "user1", "POST", "/things", strings.NewReader(form))
+				So(code, ShouldEqual, http.StatusOK)
+				So(len(mdb.things), ShouldEqual, 2)
+
+				actual = testEndpoint(s, "GET", "/things/previous?Address=/test1&Type=file", nil)
+				So(actual, ShouldNotContainSubstring, "previously registered")
+
+				code = testEndpointCode(s, "GET", "/things/previous?Address=/test1&Type=bad", nil)
+				So(code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+	})
+}
+
+// mockVerifier is a backend.OwnerVerifier that says only the owner owns
+// anything.
+type mockVerifier struct {
+	owner string
+}
+
+func (m *mockVerifier) Exists(context.Context, *database.Thing) (bool, error) {
+	return true, nil
+}
+
+func (m *mockVerifier) VerifyOwner(_ context.Context, _ *database.Thing, identity *backend.Identity) error {
+	if identity.Username == m.owner {
+		return nil
+	}
+
+	return backend.ErrNotOwner
+}
+
+func TestServerOwnership(t *testing.T) {
+	Convey("Given a Config with backends that verify ownership, and admins", t, func() {
+		u, err := user.Current()
+		So(err, ShouldBeNil)
+
+		mdb := newMockDB()
+
+		useBackends(t, map[database.ThingsType]backend.Backend{database.ThingsTypeDir: &mockVerifier{owner: u.Username}})
+
+		s, err := New(withAuth(Config{
+			HTTPLogger: gas.NewStringLogger(),
+			Database:   mdb,
+			Admins:     []string{"admin"},
+		}))
+		So(err, ShouldBeNil)
+
+		post := func(thingsType, creator string) *httptest.ResponseRecorder {
+			form := "Address=/a&Type=" + thingsType + "&Reason=r&Remove=2025-01-02"
+
+			return recordRequestAs(s, creator, "POST", "/things", strings.NewReader(form))
+		}
+
+		Convey("Owners can create things", func() {
+			So(post("dir", u.Username).Code, ShouldEqual, http.StatusOK)
+			So(len(mdb.things), ShouldEqual, 1)
+		})
+
+		Convey("Admins can create things they don't own", func() {
+			So(post("dir", "admin").Code, ShouldEqual, http.StatusOK)
+			So(len(mdb.things), ShouldEqual, 1)
+		})
+
+		Convey("Other users can't create things they don't own, even claiming to be an admin", func() {
+			recorder := post("dir", "tt-no-such-user")
+			So(recorder.Code, ShouldEqual, http.StatusForbidden)
+			So(recorder.Body.String(), ShouldContainSubstring, "unknown user")
+
+			form := "Address=/a&Type=dir&Reason=r&Remove=2025-01-02&Creator=admin"
+			recorder = recordRequestAs(s, "tt-no-such-user", "POST", "/things", strings.NewReader(form))
+			So(recorder.Code, ShouldEqual, http.StatusForbidden)
+			So(len(mdb.things), ShouldEqual, 0)
+		})
+
+		Convey("Only subscribers, owners and admins can extend, confirm, restore, retry or cancel things", func() {
+			So(post("dir", "admin").Code, ShouldEqual, http.StatusOK)
+			So(mdb.MarkChanged(0), ShouldBeNil)
+
+			for _, action := range []string{"extend", "confirm", "restore", "retry", "cancel"} {
+				recorder := recordRequestAs(s, "tt-no-such-user", "POST", "/things/0/"+action,
+					strings.NewReader("Remove=2036-03-04"))
+				So(recorder.Code, ShouldEqual, http.StatusForbidden)
+				So(recorder.Body.String(), ShouldContainSubstring, ErrNotManager.Error())
+			}
+
+			So(len(mdb.audit), ShouldEqual, 0)
+
+			for i, username := range []string{"admin", u.Username} {
+				So(testEndpointCodeAs(s, username, "POST", "/things/0/extend",
+					strings.NewReader(fmt.Sprintf("Remove=2036-03-0%d", i+4))), ShouldEqual, http.StatusOK)
+			}
+
+			So(len(mdb.audit), ShouldEqual, 2)
+			So(mdb.audit[1].Actor, ShouldEqual, u.Username)
+		})
+
+		Convey("Ownership isn't checked for types without a verifier", func() {
+			So(post("irods", "tt-no-such-user").Code, ShouldEqual, http.StatusOK)
+			So(len(mdb.things), ShouldEqual, 1)
+		})
+	})
+}
+
+func TestServerSchedule(t *testing.T) {
+	Convey("Given a Config with a removal schedule", t, func() {
+		mdb := newMockDB()
+
+		s, err := New(withAuth(Config{
+			HTTPLogger: gas.NewStringLogger(),
+			Database:   mdb,
+			Schedule:   &policy.Schedule{Grace: 48 * time.Hour},
+		}))
+		So(err, ShouldBeNil)
+
+		Convey("Things show their effective removal date", func() {
+			form := "Address=/a&Type=irods&Reason=r&Remove=2025-01-02"
+			So(recordRequestAs(s, "user", "POST", "/things", strings.NewReader(form)).Code, ShouldEqual, http.StatusOK)
+
+			actual := testEndpoint(s, "GET", "/things/0", nil)
+			So(actual, ShouldContainSubstring, "2025-01-02")
+			So(actual, ShouldContainSubstring, "effective 2025-01-04")
+		})
+	})
+
+	Convey("Without a removal schedule, things don't show an effective removal date", t, func() {
+		mdb := newMockDB()
+
+		s, err := New(withAuth(Config{HTTPLogger: gas.NewStringLogger(), Database: mdb}))
+		So(err, ShouldBeNil)
+
+		form := "Address=/a&Type=irods&Reason=r&Remove=2025-01-02"
+		So(recordRequestAs(s, "user", "POST", "/things", strings.NewReader(form)).Code, ShouldEqual, http.StatusOK)
+		So(testEndpoint(s, "GET", "/things/0", nil), ShouldNotContainSubstring, "effective")
+	})
+}
+
+func TestServerHolds(t *testing.T) {
+	Convey("Given a Config with admins, and a registered thing", t, func() {
+		mdb := newMockDB()
+
+		s, err := New(withAuth(Config{
+			HTTPLogger: gas.NewStringLogger(),
+			Database:   mdb,
+			Admins:     []string{"admin"},
+		}))
+		So(err, ShouldBeNil)
+
+		form := "Address=/a&Type=irods&Reason=r&Remove=2025-01-02"
+		So(recordRequestAs(s, "user", "POST", "/things", strings.NewReader(form)).Code, ShouldEqual, http.StatusOK)
+
+		post := func(path, username, form string) *httptest.ResponseRecorder {
+			return recordRequestAs(s, username, "POST", "/things/0/"+path, strings.NewReader(form))
+		}
+
+		until := time.Now().AddDate(0, 1, 0).Format(time.DateOnly)
+
+		Convey("Admins can place a hold with a reason and optional end date, which is shown and audited", func() {
+			recorder := post("hold", "admin", "Reason=investigation&Until="+until)
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Body.String(), ShouldContainSubstring, "on hold until "+until)
+			So(recorder.Body.String(), ShouldContainSubstring, "Release hold")
+			So(mdb.things[0].HoldReason.String, ShouldEqual, "investigation")
+			So(mdb.things[0].HoldUntil.Time.Format(time.DateOnly), ShouldEqual, until)
+			So(mdb.things[0].Held(time.Now()), ShouldBeTrue)
+
+			So(len(mdb.audit), ShouldEqual, 1)
+			So(mdb.audit[0].Action, ShouldEqual, database.AuditHeld)
+			So(mdb.audit[0].Actor, ShouldEqual, "admin")
+			So(mdb.audit[0].Detail, ShouldEqual, "held by admin until "+until+": investigation")
+
+			Convey("and release it", func() {
+				recorder = post("release", "admin", "")
+				So(recorder.Code, ShouldEqual, http.StatusOK)
+				So(recorder.Body.String(), ShouldNotContainSubstring, "on hold")
+				So(mdb.things[0].HoldReason.Valid, ShouldBeFalse)
+
+				So(len(mdb.audit), ShouldEqual, 2)
+				So(mdb.audit[1].Action, ShouldEqual, database.AuditHoldReleased)
+				So(mdb.audit[1].Actor, ShouldEqual, "admin")
+				So(mdb.audit[1].Detail, ShouldEqual, "released by admin (was held: investigation)")
+
+				So(post("release", "admin", "").Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+
+		Convey("Holds without an end date last until released", func() {
+			So(post("hold", "admin", "Reason=paper").Code, ShouldEqual, http.StatusOK)
+			So(mdb.things[0].HoldUntil.Valid, ShouldBeFalse)
+			So(mdb.things[0].Held(time.Now().AddDate(10, 0, 0)), ShouldBeTrue)
+		})
+
+		Convey("Only admins can place or release holds, even if non-admins claim to be one", func() {
+			So(post("hold", "user", "Reason=investigation").Code, ShouldEqual, http.StatusForbidden)
+			So(post("hold", "user", "Reason=investigation&Creator=admin").Code, ShouldEqual, http.StatusForbidden)
+			So(post("release", "user", "Creator=admin").Code, ShouldEqual, http.StatusForbidden)
+			So(mdb.things[0].HoldReason.Valid, ShouldBeFalse)
+		})
+
+		Convey("Held things can't be deleted, and only managers can delete them, keeping their history", func() {
+			recorder := post("hold", "admin", "Reason=investigation")
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Body.String(), ShouldNotContainSubstring, "hx-delete")
+
+			for _, username := range []string{"admin", "user"} {
+				recorder = recordRequestAs(s, username, "DELETE", "/things/0", nil)
+				So(recorder.Code, ShouldEqual, http.StatusConflict)
+				So(recorder.Body.String(), ShouldContainSubstring, database.ErrThingHeld.Error())
+			}
+
+			So(len(mdb.things), ShouldEqual, 1)
+
+			recorder = post("release", "admin", "")
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Body.String(), ShouldContainSubstring, "hx-delete")
+
+			So(testEndpointCodeAs(s, "other", "DELETE", "/things/0", nil), ShouldEqual, http.StatusForbidden)
+			So(len(mdb.things), ShouldEqual, 1)
+
+			So(testEndpointCodeAs(s, "user", "DELETE", "/things/0", nil), ShouldEqual, http.StatusOK)
+			So(mdb.things, ShouldBeEmpty)
+
+			So(len(mdb.audit), ShouldEqual, 3)
+			So(mdb.audit[0].Action, ShouldEqual, database.AuditHeld)
+			So(mdb.audit[2].Action, ShouldEqual, database.AuditDeleted)
+			So(mdb.audit[2].Actor, ShouldEqual, "user")
+		})
+
+		Convey("Holds need a reason, and must end in the future", func() {
+			So(post("hold", "admin", "").Code, ShouldEqual, http.StatusBadRequest)
+			So(post("hold", "admin", "Reason=r&Until=2020-01-01").Code, ShouldEqual, http.StatusBadRequest)
+			So(mdb.things[0].HoldReason.Valid, ShouldBeFalse)
+		})
+	})
+}
+
+// mockRegistrar is a backend.Registrar that records the things it registers,
+// or fails with err.
+type mockRegistrar struct {
+	registered []uint32
+	err        error
+}
+
+func (m *mockRegistrar) Exists(context.Context, *database.Thing) (bool, error) {
+	return true, nil
+}
+
+func (m *mockRegistrar) Register(_ context.Context, thing *database.Thing) error {
+	if m.err != nil {
+		return m.err
+	}
+
+	m.registered = append(m.registered, thing.ID)
+
+	return nil
+}
+
+func TestServerRegistration(t *testing.T) {
+	Convey("Given a Config with a backend that registers things", t, func() {
+		mdb := newMockDB()
+		mr := &mockRegistrar{}
+
+		useBackends(t, map[database.ThingsType]backend.Backend{database.ThingsTypeS3: mr})
+
+		s, err := New(withAuth(Config{
+			HTTPLogger: gas.NewStringLogger(),
+			Database:   mdb,
+		}))
+		So(err, ShouldBeNil)
+
+		post := func(thingsType string) *httptest.ResponseRecorder {
+			address := "bucket/a"
+			if thingsType != string(database.ThingsTypeS3) {
+				address = "/" + address
+			}
+
+			form := "Address=" + address + "&Type=" + thingsType + "&Reason=r&Remove=2025-01-02"
+
+			return recordRequestAs(s, "c", "POST", "/things", strings.NewReader(form))
+		}
+
+		Convey("Created things are registered with the backend", func() {
+			So(post("s3").Code, ShouldEqual, http.StatusOK)
+			So(len(mdb.things), ShouldEqual, 1)
+			So(mr.registered, ShouldResemble, []uint32{mdb.things[0].ID})
+		})
+
+		Convey("Things of other types are not registered", func() {
+			So(post("irods").Code, ShouldEqual, http.StatusOK)
+			So(len(mdb.things), ShouldEqual, 1)
+			So(mr.registered, ShouldBeEmpty)
+		})
+
+		Convey("Extending things changes their removal date and registers them again", func() {
+			So(post("s3").Code, ShouldEqual, http.StatusOK)
+			id := mdb.things[0].ID
+			target := thingURL(id) + "/extend"
+
+			recorder := recordRequestAs(s, "c", "POST", target, strings.NewReader("Remove=2036-03-04"))
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(mdb.things[0].Remove.Format(time.DateOnly), ShouldEqual, "2036-03-04")
+			So(mr.registered, ShouldResemble, []uint32{id, id})
+			So(recorder.Body.String(), ShouldContainSubstring, "2036-03-04")
+
+			So(len(mdb.audit), ShouldEqual, 1)
+			So(mdb.audit[0].Action, ShouldEqual, database.AuditExtended)
+			So(mdb.audit[0].Detail, ShouldContainSubstring, "2025-01-02 to 2036-03-04")
+
+			So(testEndpointCodeAs(s, "c", "POST", target, strings.NewReader("Remove=bad")), ShouldEqual, http.StatusBadRequest)
+
+			recorder = recordRequestAs(s, "c", "POST", target, strings.NewReader("Remove=2025-01-03"))
+			So(recorder.Code, ShouldEqual, http.StatusBadRequest)
+			So(recorder.Body.String(), ShouldContainSubstring, policy.ErrRemoveNotFuture.Error())
+
+			recorder = recordRequestAs(s, "c", "POST", target, strings.NewReader("Remove=2036-03-03"))
+			So(recorder.Code, ShouldEqual, http.StatusBadRequest)
+			So(recorder.Body.String(), ShouldContainSubstring, policy.ErrRemoveNotLater.Error())
+			So(recorder.Body.String(), ShouldContainSubstring, "(currently 2036-03-04)")
+			So(mdb.things[0].Remove.Format(time.DateOnly), ShouldEqual, "2036-03-04")
+			So(len(mdb.audit), ShouldEqual, 1)
+			So(testEndpointCodeAs(s, "c", "POST", "/things/99/extend", strings.NewReader("Remove=2036-03-04")),
+				ShouldEqual, http.StatusNotFound)
+
+			mr.err = errors.New("tagging failed")
+
+			recorder = recordRequestAs(s, "c", "POST", target, strings.NewReader("Remove=2037-03-04"))
+			So(recorder.Code, ShouldEqual, http.StatusBadGateway)
+			So(mdb.things[0].Remove.Format(time.DateOnly), ShouldEqual, "2036-03-04")
+			So(len(mdb.audit), ShouldEqual, 1)
+
+			mdb.things[0].Removed = true
+
+			So(testEndpointCodeAs(s, "c", "POST", target, strings.NewReader("Remove=2037-03-04")), ShouldEqual, http.StatusBadRequest)
+		})
+
+		Convey("Things that fail to register are not kept", func() {
+			mr.err = errors.New("tagging failed")
+
+			recorder := post("s3")
+			So(recorder.Code, ShouldEqual, http.StatusBadGateway)
+			So(recorder.Body.String(), ShouldContainSubstring, "tagging failed")
+			So(len(mdb.things), ShouldEqual, 0)
+		})
+	})
+}
+
+// mockQuarantiner is a backend.Quarantiner that records the things it
+// restores, or fails with err.
+type mockQuarantiner struct {
+	restored []uint32
+	err      error
+}
+
+func (m *mockQuarantiner) Exists(context.Context, *database.Thing) (bool, error) {
+	return false, nil
+}
+
+func (m *mockQuarantiner) Quarantine(context.Context, *database.Thing) (string, error) {
+	return "", nil
+}
+
+func (m *mockQuarantiner) Restore(_ context.Context, thing *database.Thing) error {
+	if m.err != nil {
+		return m.err
+	}
+
+	m.restored = append(m.restored, thing.ID)
+
+	return nil
+}
+
+func (m *mockQuarantiner) Purge(context.Context, *database.Thing) error {
+	return nil
+}
+
+func TestServerQuarantine(t *testing.T) {
+	Convey("Given a Config with a backend that quarantines things, and a quarantined thing", t, func() {
+		mdb := newMockDB()
+		mq := &mockQuarantiner{}
+
+		useBackends(t, map[database.ThingsType]backend.Backend{database.ThingsTypeDir: mq})
+
+		s, err := New(withAuth(Config{
+			HTTPLogger: gas.NewStringLogger(),
+			Database:   mdb,
+		}))
+		So(err, ShouldBeNil)
+
+		form := "Address=/a&Type=dir&Reason=r&Remove=2025-01-02"
+		So(testEndpointCodeAs(s, "c", "POST", "/things", strings.NewReader(form)), ShouldEqual, http.StatusOK)
+
+		id := mdb.things[0].ID
+		target := thingURL(id) + "/restore"
+
+		Convey("Things that aren't quarantined can't be restored", func() {
+			So(testEndpointCodeAs(s, "c", "POST", target, nil), ShouldEqual, http.StatusBadRequest)
+			So(testEndpoint(s, "GET", thingURL(id), nil), ShouldNotContainSubstring, "Restore")
+		})
+
+		Convey("Quarantined things are shown with a restore button", func() {
+			err := mdb.MarkQuarantined(id, "/quarantine/a", time.Date(2025, 1, 16, 0, 0, 0, 0, time.UTC))
+			So(err, ShouldBeNil)
+
+			actual := testEndpoint(s, "GET", thingURL(id), nil)
+			So(actual, ShouldContainSubstring, "quarantined until 2025-01-16")
+			So(actual, ShouldContainSubstring, `hx-post="`+target+`"`)
+
+			Convey("and can be restored, with an optional new removal date", func() {
+				recorder := recordRequestAs(s, "c", "POST", target, strings.NewReader("Remove=2036-03-04"))
+				So(recorder.Code, ShouldEqual, http.StatusOK)
+				So(recorder.Body.String(), ShouldNotContainSubstring, "quarantined until")
+				So(recorder.Body.String(), ShouldContainSubstring, "2036-03-04")
+				So(mq.restored, ShouldResemble, []uint32{id})
+				So(mdb.things[0].Quarantined(), ShouldBeFalse)
+
+				So(len(mdb.audit), ShouldEqual, 1)
+				So(mdb.audit[0].Action, ShouldEqual, database.AuditRestored)
+			})
+
+			Convey("but not if something else is now at their address", func() {
+				mq.err = backend.ErrAddressInUse
+
+				So(testEndpointCodeAs(s, "c", "POST", target, nil), ShouldEqual, http.StatusConflict)
+				So(mdb.things[0].Quarantined(), ShouldBeTrue)
+			})
+
+			Convey("and failures are reported", func() {
+				mq.err = errors.New("rename failed")
+
+				So(testEndpointCodeAs(s, "c", "POST", target, nil), ShouldEqual, http.StatusInternalServerError)
+				So(testEndpointCodeAs(s, "c", "POST", target, strings.NewReader("Remove=bad")), ShouldEqual,
+					http.StatusBadRequest)
+			})
+		})
+	})
+}
+
+// mockFingerprinter is a backend.Fingerprinter that returns fingerprint, or
+// fails with err.
+type mockFingerprinter struct {
+	fingerprint *database.Fingerprint
+	err         error
+}
+
+func (m *mockFingerprinter) Exists(context.Context, *database.Thing) (bool, error) {
+	return true, nil
+}
+
+func (m *mockFingerprinter) Fingerprint(context.Context, *database.Thing) (*database.Fingerprint, error) {
+	return m.fingerprint, m.err
+}
+
+func TestServerFingerprint(t *testing.T) {
+	Convey("Given a Config with a backend that fingerprints things", t, func() {
+		mdb := newMockDB()
+		mf := &mockFingerprinter{fingerprint: &database.Fingerprint{Size: 1, Files: 1}}
+		logger := gas.NewStringLogger()
+
+		useBackends(t, map[database.ThingsType]backend.Backend{database.ThingsTypeDir: mf})
+
+		s, err := New(withAuth(Config{
+			HTTPLogger: logger,
+			Database:   mdb,
+		}))
+		So(err, ShouldBeNil)
+
+		post := func(address string) int {
+			form := "Address=" + address + "&Type=dir&Reason=r&Remove=2025-01-02"
+
+			return testEndpointCodeAs(s, "c", "POST", "/things", strings.NewReader(form))
+		}
+
+		Convey("Created things are fingerprinted", func() {
+			So(post("/a"), ShouldEqual, http.StatusOK)
+			So(mdb.things[0].Fingerprint, ShouldResemble, mf.fingerprint)
+
+			Convey("unless that fails, which is only logged", func() {
+				mf.err = errors.New("permission denied")
+
+				So(post("/b"), ShouldEqual, http.StatusOK)
+				So(mdb.things[1].Fingerprint, ShouldBeNil)
+				So(logger.String(), ShouldContainSubstring, "fingerprinting thing 1 (/b) failed: permission denied")
+			})
+		})
+
+		Convey("Things that changed can have their removal confirmed", func() {
+			So(post("/a"), ShouldEqual, http.StatusOK)
+
+			id := mdb.things[0].ID
+			target := thingURL(id) + "/confirm"
+
+			So(testEndpointCodeAs(s, "c", "POST", target, nil), ShouldEqual, http.StatusBadRequest)
+			So(testEndpoint(s, "GET", thingURL(id), nil), ShouldNotContainSubstring, "Confirm removal")
+
+			So(mdb.MarkChanged(id), ShouldBeNil)
+
+			actual := testEndpoint(s, "GET", thingURL(id), nil)
+			So(actual, ShouldContainSubstring, ">\n\t\t\tchanged</span>")
+			So(actual, ShouldContainSubstring, `hx-post="`+target+`"`)
+
+			mf.fingerprint = &database.Fingerprint{Size: 2, Files: 2}
+
+			actual = testEndpointAs(s, "c", "POST", target, nil)
+			So(actual, ShouldNotContainSubstring, "Confirm removal")
+			So(mdb.things[0].Changed.Valid, ShouldBeFalse)
+			So(mdb.things[0].Fingerprint, ShouldResemble, mf.fingerprint)
+			So(len(mdb.audit), ShouldEqual, 1)
+			So(mdb.audit[0].Action, ShouldEqual, database.AuditConfirmed)
+
+			So(mdb.MarkChanged(id), ShouldBeNil)
+			mf.err = errors.New("permission denied")
+			So(testEndpointCodeAs(s, "c", "POST", target, nil), ShouldEqual, http.StatusInternalServerError)
+			So(mdb.things[0].Changed.Valid, ShouldBeTrue)
+
+			mdb.things[0].Removed = true
+			So(testEndpointCodeAs(s, "c", "POST", target, nil), ShouldEqual, http.StatusBadRequest)
+		})
+
+		Convey("Extending things takes a new fingerprint", func() {
+			So(post("/a"), ShouldEqual, http.StatusOK)
+			So(mdb.MarkChanged(mdb.things[0].ID), ShouldBeNil)
+
+			mf.fingerprint = &database.Fingerprint{Size: 3, Files: 3}
+
+			So(testEndpointCodeAs(s, "c", "POST", thingURL(mdb.things[0].ID)+"/extend", strings.NewReader("Remove=2036-03-04")),
+				ShouldEqual, http.StatusOK)
+			So(mdb.things[0].Changed.Valid, ShouldBeFalse)
+			So(mdb.things[0].Fingerprint, ShouldResemble, mf.fingerprint)
+		})
+	})
+}
+
+// mockArchiver is a backend.Archiver that doesn't archive anything.
+type mockArchiver struct{}
+
+func (mockArchiver) Exists(context.Context, *database.Thing) (bool, error) {
+	return true, nil
+}
+
+func (mockArchiver) Archive(context.Context, *database.Thing) (string, error) {
+	return "", nil
+}
+
+func TestServerOnExpiry(t *testing.T) {
+	Convey("Given a Config with a backend that can archive things", t, func() {
+		mdb := newMockDB()
+
+		useBackends(t, map[database.ThingsType]backend.Backend{database.ThingsTypeDir: mockArchiver{}})
+
+		s, err := New(withAuth(Config{
+			HTTPLogger: gas.NewStringLogger(),
+			Database:   mdb,
+		}))
+		So(err, ShouldBeNil)
+
+		post := func(thingsType, onExpiry string) *httptest.ResponseRecorder {
+			form := "Address=/a&Type=" + thingsType + "&Reason=r&Remove=2025-01-02&OnExpiry=" + onExpiry
+
+			return recordRequestAs(s, "c", "POST", "/things", strings.NewReader(form))
+		}
+
+		Convey("Things default to being deleted on expiry", func() {
+			So(post("dir", "").Code, ShouldEqual, http.StatusOK)
+			So(mdb.things[0].OnExpiry, ShouldEqual, database.ExpiryDelete)
+		})
+
+		Convey("Things can be archived on expiry if their type can be archived", func() {
+			So(post("dir", "archive").Code, ShouldEqual, http.StatusOK)
+			So(mdb.things[0].OnExpiry, ShouldEqual, database.ExpiryArchive)
+			So(testEndpoint(s, "GET", thingURL(mdb.things[0].ID), nil), ShouldContainSubstring, "will be archived")
+
+			err := mdb.MarkArchived(mdb.things[0].ID, "/archive/0-a.tar.gz")
+			So(err, ShouldBeNil)
+			So(testEndpoint(s, "GET", thingURL(mdb.things[0].ID), nil), ShouldContainSubstring,
+				"archived to /archive/0-a.tar.gz")
+
+			recorder := post("file", "archive")
+			So(recorder.Code, ShouldEqual, http.StatusBadRequest)
+			So(recorder.Body.String(), ShouldContainSubstring, string(ErrCantArchive))
+			So(len(mdb.things), ShouldEqual, 1)
+		})
+
+		Convey("Things can't be archived on expiry if their backend has nowhere to archive to", func() {
+			useBackends(t, map[database.ThingsType]backend.Backend{database.ThingsTypeDir: fs.New(1)})
+
+			recorder := post("dir", "archive")
+			So(recorder.Code, ShouldEqual, http.StatusBadRequest)
+			So(recorder.Body.String(), ShouldContainSubstring, string(ErrCantArchive))
+			So(len(mdb.things), ShouldEqual, 0)
+		})
+
+		Convey("Things can be only notified about on expiry", func() {
+			So(post("file", "notify").Code, ShouldEqual, http.StatusOK)
+			So(testEndpoint(s, "GET", thingURL(mdb.things[0].ID), nil), ShouldContainSubstring, "will only notify")
+		})
+
+		Convey("Invalid on expiry actions are rejected", func() {
+			So(post("dir", "explode").Code, ShouldEqual, http.StatusBadRequest)
+			So(mdb.things, ShouldBeEmpty)
+		})
+	})
+}
+
+// mockProber is a backend.Prober that finds nothing.
+type mockProber struct{}
+
+func (mockProber) Exists(context.Context, *database.Thing) (bool, error) {
+	return false, nil
+}
+
+func (mockProber) Probe(context.Context, *database.Thing) (*database.ProbeResult, error) {
+	return &database.ProbeResult{}, nil
+}
+
+func TestServerExpiryMode(t *testing.T) {
+	Convey("Given a Config with a backend that can probe things", t, func() {
+		mdb := newMockDB()
+
+		useBackends(t, map[database.ThingsType]backend.Backend{database.ThingsTypeDir: mockProber{}})
+
+		s, err := New(withAuth(Config{
+			HTTPLogger: gas.NewStringLogger(),
+			Database:   mdb,
+		}))
+		So(err, ShouldBeNil)
+
+		post := func(thingsType, remove, mode, after string) *httptest.ResponseRecorder {
+			form := "Address=/a&Type=" + thingsType + "&Reason=r&Remove=" + remove +
+				"&ExpiryMode=" + mode + "&ExpireAfter=" + after
+
+			return recordRequestAs(s, "c", "POST", "/things", strings.NewReader(form))
+		}
+
+		Convey("Things default to a fixed removal date", func() {
+			So(post("file", "2025-01-02", "", "30").Code, ShouldEqual, http.StatusOK)
+			So(mdb.things[0].ExpiryMode, ShouldEqual, database.ExpiryModeFixed)
+			So(mdb.things[0].ExpireAfter, ShouldEqual, 0)
+			So(testEndpoint(s, "GET", thingURL(mdb.things[0].ID), nil), ShouldNotContainSubstring, "after last use")
+		})
+
+		Convey("Things of types that can be probed can expire after they were last used", func() {
+			So(post("dir", "2025-01-02", "atime", "30").Code, ShouldEqual, http.StatusOK)
+			So(mdb.things[0].ExpiryMode, ShouldEqual, database.ExpiryModeAccess)
+			So(mdb.things[0].ExpireAfter, ShouldEqual, 30)
+
+			id := mdb.things[0].ID
+			actual := testEndpoint(s, "GET", thingURL(id), nil)
+			So(actual, ShouldContainSubstring, "2025-01-02")
+			So(actual, ShouldContainSubstring, "30 days after last use")
+
+			err := mdb.UpdateProbe(id, database.ProbeResult{
+				Exists:   true,
+				Modified: time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC),
+				Accessed: time.Date(2025, 2, 1, 9, 0, 0, 0, time.UTC),
+			})
+			So(err, ShouldBeNil)
+
+			actual = testEndpoint(s, "GET", thingURL(id), nil)
+			So(actual, ShouldContainSubstring, "2025-03-03")
+			So(actual, ShouldContainSubstring, "last used 2025-02-01, not before 2025-01-02")
+		})
+
+		Convey("Things that expire after they were last used have a default earliest removal date", func() {
+			So(post("dir", "", "atime", "7").Code, ShouldEqual, http.StatusOK)
+
+			y, m, d := time.Now().Date()
+			So(mdb.things[0].Remove.Format(time.DateOnly), ShouldEqual,
+				time.Date(y, m, d+7, 0, 0, 0, 0, time.UTC).Format(time.DateOnly))
+		})
+
+		Convey("Invalid expiry modes are rejected", func() {
+			So(post("dir", "2025-01-02", "sometime", "30").Code, ShouldEqual, http.StatusBadRequest)
+			So(post("dir", "2025-01-02", "atime", "").Code, ShouldEqual, http.StatusBadRequest)
+			So(post("dir", "2025-01-02", "atime", "0").Code, ShouldEqual, http.StatusBadRequest)
+
+			recorder := post("file", "2025-01-02", "atime", "30")
+			So(recorder.Code, ShouldEqual, http.StatusBadRequest)
+			So(recorder.Body.String(), ShouldContainSubstring, string(ErrCantAccess))
+			So(mdb.things, ShouldBeEmpty)
+		})
+	})
+}
+
+func TestServerPolicies(t *testing.T) {
+	Convey("Given a Config with retention policies", t, func() {
+		mdb := newMockDB()
+		maxExtensions := 1
+
+		s, err := New(withAuth(Config{
+			HTTPLogger: gas.NewStringLogger(),
+			Database:   mdb,
+			Policies: policy.Policies{
+				{Prefix: "/scratch/", DefaultDays: 10, MaxDays: 30, MaxExtensions: &maxExtensions},
+				{Type: database.ThingsTypeDir, Prefix: "/scratch/", RequireDescription: true},
+			},
+		}))
+		So(err, ShouldBeNil)
+
+		y, m, d := time.Now().Date()
+		daysFromNow := func(days int) string {
+			return time.Date(y, m, d+days, 0, 0, 0, 0, time.UTC).Format(time.DateOnly)
+		}
+
+		post := func(thingsType, address, remove, description string) *httptest.ResponseRecorder {
+			form := "Address=" + address + "&Type=" + thingsType + "&Reason=r&Remove=" + remove +
+				"&Description=" + description
+
+			return recordRequestAs(s, "c", "POST", "/things", strings.NewReader(form))
+		}
+
+		Convey("Things without a removal date get the policy default", func() {
+			So(post("file", "/scratch/a", "", "").Code, ShouldEqual, http.StatusOK)
+			So(mdb.things[0].Remove.Format(time.DateOnly), ShouldEqual, daysFromNow(10))
+
+			recorder := post("file", "/home/a", "", "")
+			So(recorder.Code, ShouldEqual, http.StatusBadRequest)
+			So(recorder.Body.String(), ShouldContainSubstring, policy.ErrNoRemove.Error())
+		})
+
+		Convey("Things can't be kept for longer than the policy allows", func() {
+			recorder := post("file", "/scratch/a", daysFromNow(31), "")
+			So(recorder.Code, ShouldEqual, http.StatusBadRequest)
+			So(recorder.Body.String(), ShouldContainSubstring,
+				"things under /scratch/ can be kept for at most 30 days, until "+daysFromNow(30))
+			So(mdb.things, ShouldBeEmpty)
+
+			So(post("file", "/scratch/a", daysFromNow(30), "").Code, ShouldEqual, http.StatusOK)
+			So(post("file", "/home/a", daysFromNow(3000), "").Code, ShouldEqual, http.StatusOK)
+		})
+
+		Convey("Policies can require a description", func() {
+			recorder := post("dir", "/scratch/a", daysFromNow(1), "")
+			So(recorder.Code, ShouldEqual, http.StatusBadRequest)
+			So(recorder.Body.String(), ShouldContainSubstring, policy.ErrNeedsDescription.Error())
+
+			So(post("dir", "/scratch/a", daysFromNow(1), "results").Code, ShouldEqual, http.StatusOK)
+		})
+
+		Convey("Extensions are limited by the policy", func() {
+			So(post("file", "/scratch/a", daysFromNow(1), "").Code, ShouldEqual, http.StatusOK)
+			target := thingURL(mdb.things[0].ID) + "/extend"
+
+			recorder := recordRequestAs(s, "c", "POST", target, strings.NewReader("Remove="+daysFromNow(31)))
+			So(recorder.Code, ShouldEqual, http.StatusBadRequest)
+			So(recorder.Body.String(), ShouldContainSubstring, policy.ErrRemoveTooLate.Error())
+
+			So(testEndpointCodeAs(s, "c", "POST", target, strings.NewReader("Remove="+daysFromNow(20))), ShouldEqual, http.StatusOK)
+
+			recorder = recordRequestAs(s, "c", "POST", target, strings.NewReader("Remove="+daysFromNow(25)))
+			So(recorder.Code, ShouldEqual, http.StatusBadRequest)
+			So(recorder.Body.String(), ShouldContainSubstring, "can be extended at most 1 times")
+			So(mdb.things[0].Remove.Format(time.DateOnly), ShouldEqual, daysFromNow(20))
+		})
+	})
+}
+
+func TestServerProtection(t *testing.T) {
+	Convey("Given a Config with protected addresses", t, func() {
+		mdb := newMockDB()
+
+		s, err := New(withAuth(Config{
+			HTTPLogger: gas.NewStringLogger(),
+			Database:   mdb,
+			Admins:     []string{"admin"},
+			Protection: &policy.Protection{
+				AllowRoots:   map[database.ThingsType][]string{database.ThingsTypeDir: {"/scratch"}},
+				DenyPatterns: []string{"/scratch/projects/*"},
+			},
+		}))
+		So(err, ShouldBeNil)
+
+		post := func(thingsType, address string) *httptest.ResponseRecorder {
+			form := "Address=" + address + "&Type=" + thingsType + "&Reason=r&Remove=2025-01-02"
+
+			return recordRequestAs(s, "admin", "POST", "/things", strings.NewReader(form))
+		}
+
+		Convey("Things can't be registered at protected addresses, even by admins", func() {
+			for _, address := range []string{"/", "/home", "/scratch", "/scratch/projects/a"} {
+				recorder := post("dir", address)
+				So(recorder.Code, ShouldEqual, http.StatusForbidden)
+				So(recorder.Body.String(), ShouldContainSubstring, policy.ErrProtected.Error())
+			}
+
+			So(mdb.things, ShouldBeEmpty)
+
+			So(post("dir", "/scratch/projects/a/tmp").Code, ShouldEqual, http.StatusOK)
+			So(post("file", "/home/a").Code, ShouldEqual, http.StatusOK)
+			So(len(mdb.things), ShouldEqual, 2)
+		})
+	})
+}
+
+type mockSizeProber struct {
+	size int64
+}
+
+func (m *mockSizeProber) Exists(context.Context, *database.Thing) (bool, error) {
+	return true, nil
+}
+
+func (m *mockSizeProber) Probe(context.Context, *database.Thing) (*database.ProbeResult, error) {
+	return &database.ProbeResult{Exists: true, Size: m.size, Files: 1}, nil
+}
+
+type mockNotification struct {
+	users   []database.User
+	subject string
+	body    string
+}
+
+type mockNotifier struct {
+	notifications []mockNotification
+}
+
+func (m *mockNotifier) Notify(users []database.User, subject, body string) error {
+	m.notifications = append(m.notifications, mockNotification{users: users, subject: subject, body: body})
+
+	return nil
+}
+
+func TestServerApprovals(t *testing.T) {
+	Convey("Given a Config with approvers, a retention policy and an approval size", t, func() {
+		u, err := user.Current()
+		So(err, ShouldBeNil)
+
+		group, err := user.LookupGroupId(u.Gid)
+		So(err, ShouldBeNil)
+
+		mdb := newMockDB()
+		creator := database.User{ID: 1, Name: "c", Email: "c@example.com"}
+		approver := database.User{ID: 2, Name: u.Username, Email: "boss@example.com"}
+		mdb.users = []database.User{creator, approver}
+
+		mr := &mockRegistrar{}
+		mp := &mockSizeProber{size: 1000}
+		mn := &mockNotifier{}
+
+		useBackends(t, map[database.ThingsType]backend.Backend{database.ThingsTypeS3: mr, database.ThingsTypeDir: mp})
+
+		s, err := New(withAuth(Config{
+			HTTPLogger:    gas.NewStringLogger(),
+			Database:      mdb,
+			Policies:      policy.Policies{{MaxDays: 30}},
+			ApproverGroup: group.Name,
+			ApprovalSize:  1024,
+			Notifier:      mn,
+		}))
+		So(err, ShouldBeNil)
+
+		s.groupMembers = func(name string) ([]string, error) {
+			So(name, ShouldEqual, group.Name)
+
+			return []string{approver.Name, "missing"}, nil
+		}
+
+		y, m, d := time.Now().Date()
+		tooLate := time.Date(y, m, d+31, 0, 0, 0, 0, time.UTC).Format(time.DateOnly)
+		allowed := time.Date(y, m, d+30, 0, 0, 0, 0, time.UTC).Format(time.DateOnly)
+
+		post := func(thingsType, remove string) *httptest.ResponseRecorder {
+			address := "/a"
+			if thingsType == "s3" {
+				address = "b/a"
+			}
+
+			form := "Address=" + address + "&Type=" + thingsType + "&Reason=r&Remove=" + remove +
+				"&Approval=approved"
+
+			return recordRequestAs(s, "c", "POST", "/things", strings.NewReader(form))
+		}
+
+		Convey("Things within policy that aren't too big are registered straight away", func() {
+			So(post("dir", allowed).Code, ShouldEqual, http.StatusOK)
+			So(mdb.things[0].Approval, ShouldEqual, database.ApprovalNotNeeded)
+			So(mdb.things[0].Size.Int64, ShouldEqual, 1000)
+			So(mn.notifications, ShouldBeEmpty)
+		})
+
+		Convey("Things that are too big await approval, and approvers are told", func() {
+			mp.size = 2048
+
+			So(post("dir", allowed).Code, ShouldEqual, http.StatusOK)
+			So(mdb.things[0].Approval, ShouldEqual, database.ApprovalPending)
+			So(mdb.things[0].ApprovalReason, ShouldEqual,
+				"Its size of 2.0 KiB is more than the 1.0 KiB that can be registered without approval")
+			So(mdb.things[0].Size.Int64, ShouldEqual, 2048)
+
+			So(len(mdb.audit), ShouldEqual, 1)
+			So(mdb.audit[0].Action, ShouldEqual, database.AuditPending)
+
+			So(len(mn.notifications), ShouldEqual, 1)
+			So(mn.notifications[0].users, ShouldResemble, []database.User{approver})
+			So(mn.notifications[0].subject, ShouldEqual, "tt: /a is awaiting your approval")
+			So(mn.notifications[0].body, ShouldContainSubstring, "2.0 KiB")
+
+			So(testEndpoint(s, "GET", thingURL(mdb.things[0].ID), nil), ShouldContainSubstring, "awaiting approval")
+		})
+
+		Convey("Things with a removal date later than policy allows await approval", func() {
+			So(post("s3", tooLate).Code, ShouldEqual, http.StatusOK)
+			So(mdb.things[0].Approval, ShouldEqual, database.ApprovalPending)
+			So(mdb.things[0].ApprovalReason, ShouldContainSubstring, "can be kept for at most 30 days")
+			So(mr.registered, ShouldBeEmpty)
+
+			id := mdb.things[0].ID
+
+			page := testEndpoint(s, "GET", "/approvals", nil)
+			So(page, ShouldContainSubstring, "/things/"+strconv.Itoa(int(id))+"/approve")
+			So(page, ShouldContainSubstring, "can be kept for at most 30 days")
+
+			Convey("which only approvers can approve, registering them", func() {
+				target := thingURL(id) + "/approve"
+
+				recorder := recordRequestAs(s, "c", "POST", target, nil)
+				So(recorder.Code, ShouldEqual, http.StatusForbidden)
+				So(recorder.Body.String(), ShouldContainSubstring, ErrNotApprover.Error())
+
+				recorder = recordRequestAs(s, "c", "POST", target, strings.NewReader("Approver="+approver.Name))
+				So(recorder.Code, ShouldEqual, http.StatusForbidden)
+
+				recorder = recordRequestAs(s, approver.Name, "POST", target, nil)
+				So(recorder.Code, ShouldEqual, http.StatusOK)
+				So(recorder.Body.String(), ShouldNotContainSubstring, "awaiting approval")
+				So(recorder.Body.String(), ShouldContainSubstring, `title="lifecycle state">active<`)
+				So(mdb.things[0].Approval, ShouldEqual, database.ApprovalApproved)
+				So(mr.registered, ShouldResemble, []uint32{id})
+
+				So(mdb.audit[1].Action, ShouldEqual, database.AuditApproved)
+				So(mdb.audit[1].Actor, ShouldEqual, approver.Name)
+
+				So(len(mn.notifications), ShouldEqual, 2)
+				So(mn.notifications[1].users, ShouldResemble, []database.User{creator})
+				So(mn.notifications[1].subject, ShouldEqual, "tt: b/a was approved")
+
+				So(testEndpoint(s, "GET", "/approvals", nil), ShouldContainSubstring, "Nothing is awaiting approval")
+
+				recorder = recordRequestAs(s, approver.Name, "POST", target, nil)
+				So(recorder.Code, ShouldEqual, http.StatusBadRequest)
+				So(recorder.Body.String(), ShouldContainSubstring, database.ErrNotPending.Error())
+			})
+
+			Convey("or approvers can reject, so they're never registered", func() {
+				recorder := recordRequestAs(s, approver.Name, "POST", thingURL(id)+"/reject",
+					strings.NewReader("Reason=too+long"))
+				So(recorder.Code, ShouldEqual, http.StatusOK)
+				So(recorder.Body.String(), ShouldContainSubstring, "rejected")
+				So(recorder.Body.String(), ShouldContainSubstring, `title="lifecycle state">removed<`)
+				So(mdb.things[0].Approval, ShouldEqual, database.ApprovalRejected)
+				So(mdb.things[0].Removed, ShouldBeTrue)
+				So(mr.registered, ShouldBeEmpty)
+
+				So(mdb.audit[1].Action, ShouldEqual, database.AuditRejected)
+				So(mdb.audit[1].Detail, ShouldEqual, "rejected by "+approver.Name+": too long")
+				So(mn.notifications[1].body, ShouldContainSubstring, "was rejected by "+approver.Name+": too long")
+
+				So(post("s3", allowed).Code, ShouldEqual, http.StatusOK)
+				So(mdb.things[1].Approval, ShouldEqual, database.ApprovalNotNeeded)
+			})
+
+			Convey("but approvers can only reject, not approve, things they asked for", func() {
+				form := "Address=b/c&Type=s3&Reason=r&Remove=" + tooLate
+				So(testEndpointCodeAs(s, approver.Name, "POST", "/things", strings.NewReader(form)),
+					ShouldEqual, http.StatusOK)
+				own := thingURL(mdb.things[1].ID)
+
+				recorder := recordRequestAs(s, approver.Name, "POST", own+"/approve", nil)
+				So(recorder.Code, ShouldEqual, http.StatusForbidden)
+				So(recorder.Body.String(), ShouldContainSubstring, "approve things they asked to register")
+				So(mdb.things[1].Approval, ShouldEqual, database.ApprovalPending)
+				So(mr.registered, ShouldBeEmpty)
+
+				So(testEndpointCodeAs(s, approver.Name, "POST", own+"/reject", nil), ShouldEqual, http.StatusOK)
+				So(mdb.things[1].Approval, ShouldEqual, database.ApprovalRejected)
+			})
+
+			Convey("but if registering them on approval fails, they stay pending", func() {
+				mr.err = errors.New("tagging failed")
+
+				So(testEndpointCodeAs(s, approver.Name, "POST", thingURL(id)+"/approve", nil),
+					ShouldEqual, http.StatusBadGateway)
+				So(mdb.things[0].Approval, ShouldEqual, database.ApprovalPending)
+			})
+		})
+
+		Convey("Without approvers, things breaking policy are rejected and big things registered", func() {
+			s.approverGroup = ""
+			mp.size = 2048
+
+			So(post("s3", tooLate).Code, ShouldEqual, http.StatusBadRequest)
+			So(post("dir", allowed).Code, ShouldEqual, http.StatusOK)
+			So(mdb.things[0].Approval, ShouldEqual, database.ApprovalNotNeeded)
+			So(mdb.things[0].Size.Valid, ShouldBeFalse)
+		})
+	})
+}
+
+func TestServerApproverGroup(t *testing.T) {
+	Convey("Servers can't be created with an approver group that doesn't exist", t, func() {
+		_, err := New(Config{
+			HTTPLogger:    gas.NewStringLogger(),
+			Database:      newMockDB(),
+			ApproverGroup: "tt-no-such-group",
+		})
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestFormatBytes(t *testing.T) {
+	Convey("You can format bytes in a human readable way", t, func() {
+		So(formatBytes(0), ShouldEqual, "0 B")
+		So(formatBytes(1023), ShouldEqual, "1023 B")
+		So(formatBytes(1024), ShouldEqual, "1.0 KiB")
+		So(formatBytes(3*1024*1024+512*1024), ShouldEqual, "3.5 MiB")
+		So(formatBytes(5*1024*1024*1024*1024), ShouldEqual, "5.0 TiB")
+	})
+}
+
+func testEndpoint(s *Server, method, target string, inputBody io.Reader) string {
+	recorder := recordRequest(s, method, target, inputBody)
+	So(recorder.Code, ShouldEqual, http.StatusOK)
+	So(recorder.Header().Get("Content-Type"), ShouldEqual, "text/html; charset=utf-8")
+
+	return recorder.Body.String()
+}
+
+func recordRequest(s *Server, method, target string, inputBody io.Reader) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	s.Router().ServeHTTP(recorder, newRequest(method, target, inputBody))
+
+	return recorder
+}
+
+func newRequest(method, target string, inputBody io.Reader) *http.Request {
+	req := httptest.NewRequest(method, target, inputBody)
+
+	if inputBody != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	return req
+}
+
+func testEndpointCode(s *Server, method, target string, inputBody io.Reader) int {
+	recorder := recordRequest(s, method, target, inputBody)
+	return recorder.Code
+}
+
+// testEndpointAs is like testEndpoint, but logged in as the given user.
+func testEndpointAs(s *Server, username, method, target string, inputBody io.Reader) string {
+	recorder := recordRequestAs(s, username, method, target, inputBody)
+	So(recorder.Code, ShouldEqual, http.StatusOK)
+	So(recorder.Header().Get("Content-Type"), ShouldEqual, "text/html; charset=utf-8")
+
+	return recorder.Body.String()
+}
+
+// recordRequestAs is like recordRequest, but logs in to the given server, which
+// must have been created with a withAuth() Config, as the given user first, and
+// makes the request with their JWT.
+func recordRequestAs(s *Server, username, method, target string,
+	inputBody io.Reader) *httptest.ResponseRecorder {
+	login := recordRequest(s, "POST", gas.EndPointJWT,
+		strings.NewReader("username="+username+"&password="+testPassword))
+	So(login.Code, ShouldEqual, http.StatusOK)
+
+	var token string
+
+	So(json.Unmarshal(login.Body.Bytes(), &token), ShouldBeNil)
+
+	req := newRequest(method, target, inputBody)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	recorder := httptest.NewRecorder()
+	s.Router().ServeHTTP(recorder, req)
+
+	return recorder
+}
+
+// testEndpointCodeAs is like testEndpointCode, but logged in as the given user.
+func testEndpointCodeAs(s *Server, username, method, target string, inputBody io.Reader) int {
+	return recordRequestAs(s, username, method, target, inputBody).Code
+}
+
+const testPassword = "pass"
+
+// testCertFile and testKeyFile are created by TestMain() for withAuth().
+var testCertFile, testKeyFile string
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "tt-server-test")
+	if err != nil {
+		panic(err)
+	}
+
+	testCertFile, testKeyFile, err = writeTestCert(dir)
+	if err != nil {
+		panic(err)
+	}
+
+	code := m.Run()
+
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// writeTestCert writes a self-signed certificate and its key to the given
+// directory, returning their paths.
+func writeTestCert(dir string) (string, string, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return "", "", err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return "", "", err
+	}
+
+	certPath := filepath.Join(dir, "cert")
+	keyPath := filepath.Join(dir, "key")
+
+	err = os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	if err != nil {
+		return "", "", err
+	}
+
+	return certPath, keyPath, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{
+		Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key),
+	}), 0600)
+}
+
+// useBackends sets the registered Backend of every ThingsType to the one in the
+// given map (nil if it isn't in it), restoring the previous ones once the test
+// finishes.
+func useBackends(t *testing.T, backends map[database.ThingsType]backend.Backend) {
+	t.Helper()
+
+	for _, thingsType := range database.ThingsTypes() {
+		previous, err := database.SetBackend(thingsType, backends[thingsType])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		t.Cleanup(func() {
+			database.SetBackend(thingsType, previous) //nolint:errcheck
+		})
+	}
+}
+
+// withAuth returns the given Config with auth enabled, such that any user can
+// log in with testPassword.
+func withAuth(conf Config) Config {
+	conf.CertFile = testCertFile
+	conf.KeyFile = testKeyFile
+	conf.Auth = func(username, password string) (bool, string) {
+		uid, _ := gas.UserNameToUID(username)
+
+		return password == testPassword, uid
+	}
+
+	return conf
+}
+
+func executeThingsTemplate(things []database.Thing) string {
+	data, err := templatesFS.ReadFile("templates/things.html")
+	So(err, ShouldBeNil)
+	templ := template.New("").Funcs(templateFuncs(nil))
+	templChild := templ.New("templates/things.html")
+	templChild, err = templChild.Parse(string(data))
+	So(err, ShouldBeNil)
+
+	data, err = templatesFS.ReadFile("templates/thing.html")
+	So(err, ShouldBeNil)
+	templChild = templChild.New("templates/thing.html")
+	templChild, err = templChild.Parse(string(data))
+	So(err, ShouldBeNil)
+
+	data, err = templatesFS.ReadFile("templates/progress.html")
+	So(err, ShouldBeNil)
+	templChild = templChild.New("templates/progress.html")
+	_, err = templChild.Parse(string(data))
+	So(err, ShouldBeNil)
+
+	var expectedB bytes.Buffer
+	err = templ.ExecuteTemplate(&expectedB, "templates/things.html", things)
+	So(err, ShouldBeNil)
+
+	expected := expectedB.String()
+	So(expected, ShouldStartWith, "\n<tr")
+	So(expected, ShouldEndWith, "</tr>\n")
+	So(expected, ShouldContainSubstring, "<td>")
+
+	return expected
+}
+
+func TestServerRemovalJobs(t *testing.T) {
+	Convey("Given a Config and a thing with a removal job", t, func() {
+		mdb := newMockDB()
+
+		s, err := New(withAuth(Config{
+			HTTPLogger: gas.NewStringLogger(),
+			Database:   mdb,
+		}))
+		So(err, ShouldBeNil)
+
+		form := "Address=/a&Type=dir&Reason=r&Remove=2025-01-02"
+		So(testEndpointCodeAs(s, "c", "POST", "/things", strings.NewReader(form)), ShouldEqual, http.StatusOK)
+
+		id := mdb.things[0].ID
+		So(mdb.EnqueueJob(id, database.JobRemove), ShouldBeNil)
+
+		Convey("Queued jobs are shown, and can be cancelled but not retried", func() {
+			actual := testEndpoint(s, "GET", thingURL(id), nil)
+			So(actual, ShouldContainSubstring, "removal queued")
+			So(actual, ShouldContainSubstring, `hx-post="`+thingURL(id)+`/cancel"`)
+			So(actual, ShouldNotContainSubstring, "Retry")
+
+			So(testEndpointCodeAs(s, "c", "POST", thingURL(id)+"/retry", nil), ShouldEqual, http.StatusBadRequest)
+
+			recorder := recordRequestAs(s, "c", "POST", thingURL(id)+"/cancel", nil)
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Body.String(), ShouldContainSubstring, "removal cancelled")
+			So(recorder.Body.String(), ShouldContainSubstring, `hx-post="`+thingURL(id)+`/retry"`)
+			So(mdb.things[0].Job, ShouldEqual, database.JobCancelled)
+			So(len(mdb.audit), ShouldEqual, 1)
+			So(mdb.audit[0].Action, ShouldEqual, database.AuditJobCancelled)
+		})
+
+		Convey("Running jobs can't be cancelled", func() {
+			So(mdb.StartJob(id, "a"), ShouldBeNil)
+			So(testEndpointCodeAs(s, "c", "POST", thingURL(id)+"/cancel", nil), ShouldEqual, http.StatusConflict)
+		})
+
+		Convey("Failed jobs are shown with their last error, and can be retried", func() {
+			So(mdb.FailJob(id, "remove failed", database.JobFailed, time.Now()), ShouldBeNil)
+
+			actual := testEndpoint(s, "GET", thingURL(id), nil)
+			So(actual, ShouldContainSubstring, "removal failed")
+			So(actual, ShouldContainSubstring, "remove failed")
+
+			recorder := recordRequestAs(s, "c", "POST", thingURL(id)+"/retry", nil)
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Body.String(), ShouldContainSubstring, "removal queued")
+			So(mdb.audit[0].Action, ShouldEqual, database.AuditJobRetried)
+		})
+
+		Convey("Jobs can be listed as JSON, optionally filtered by state", func() {
+			var jobs []database.RemovalJob
+
+			recorder := recordRequest(s, "GET", "/jobs", nil)
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Header().Get("Content-Type"), ShouldEqual, "application/json; charset=utf-8")
+
+			err := json.Unmarshal(recorder.Body.Bytes(), &jobs)
+			So(err, ShouldBeNil)
+			So(len(jobs), ShouldEqual, 1)
+			So(jobs[0].ThingID, ShouldEqual, id)
+			So(jobs[0].State, ShouldEqual, database.JobQueued)
+
+			So(recordRequest(s, "GET", "/jobs?state=failed", nil).Body.String(), ShouldEqual, "[]")
+			So(testEndpointCode(s, "GET", "/jobs?state=bad", nil), ShouldEqual, http.StatusBadRequest)
+		})
+
+		Convey("Running jobs show their progress, which is broadcast when it changes", func() {
+			So(mdb.StartJob(id, "a"), ShouldBeNil)
+
+			mdb.things[0].Files = null.IntFrom(10)
+			progress := database.RemovalProgress{Files: 5, Bytes: 2048, Errors: 1}
+			So(mdb.UpdateJobProgress(id, progress), ShouldBeNil)
+
+			actual := testEndpoint(s, "GET", thingURL(id), nil)
+			So(actual, ShouldContainSubstring, `<progress class="uk-progress" value="50" max="100">`)
+			So(actual, ShouldContainSubstring, "5 files (2.0 KiB) removed")
+			So(actual, ShouldContainSubstring, "1 errors")
+			So(actual, ShouldNotContainSubstring, "hx-swap-oob")
+
+			last := s.broadcastProgress(nil)
+			So(last, ShouldResemble, map[uint32]database.RemovalProgress{id: progress})
+
+			var rendered bytes.Buffer
+
+			err := s.rootTemplate.ExecuteTemplate(&rendered, "templates/progress.html", []any{&mdb.things[0], true})
+			So(err, ShouldBeNil)
+			So(rendered.String(), ShouldContainSubstring, `<div id="progress-`+strconv.Itoa(int(id))+`" hx-swap-oob="true">`)
+
+			jobs := []database.RemovalJob{}
+			recorder := recordRequest(s, "GET", "/jobs?state=running", nil)
+			So(json.Unmarshal(recorder.Body.Bytes(), &jobs), ShouldBeNil)
+			So(len(jobs), ShouldEqual, 1)
+			So(jobs[0].Progress, ShouldResemble, progress)
+
+			So(mdb.FinishJob(id), ShouldBeNil)
+			So(mdb.MarkRemoved(id), ShouldBeNil)
+
+			So(s.broadcastProgress(last), ShouldBeEmpty)
+			So(testEndpoint(s, "GET", thingURL(id), nil), ShouldNotContainSubstring, "<progress")
+		})
+
+		Convey("Things without jobs can't have them retried or cancelled", func() {
+			So(mdb.FinishJob(id), ShouldBeNil)
+			So(testEndpointCodeAs(s, "c", "POST", thingURL(id)+"/cancel", nil), ShouldEqual, http.StatusBadRequest)
+			So(testEndpointCodeAs(s, "c", "POST", thingURL(id)+"/retry", nil), ShouldEqual, http.StatusBadRequest)
+		})
+	})
+}
+
+func TestServerStatus(t *testing.T) {
+	Convey("Given a Config with an instance name", t, func() {
+		mdb := newMockDB()
+
+		s, err := New(withAuth(Config{
+			HTTPLogger: gas.NewStringLogger(),
+			Database:   mdb,
+			Instance:   "host:1",
+		}))
+		So(err, ShouldBeNil)
+
+		Convey("The status page says when there's no leader", func() {
+			actual := testEndpoint(s, "GET", "/status", nil)
+			So(actual, ShouldContainSubstring, "This is host:1.")
+			So(actual, ShouldContainSubstring, "No server is currently running the scheduled jobs.")
+		})
+
+		Convey("The status page shows the leader", func() {
+			now := time.Now()
+
+			mdb.leases = []database.Lease{{
+				Name:     database.SchedulerLease,
+				Holder:   "host:1",
+				Acquired: now.Add(-time.Hour),
+				Expires:  now.Add(time.Minute),
+			}}
+
+			actual := testEndpoint(s, "GET", "/status", nil)
+			So(actual, ShouldContainSubstring, "host:1")
+			So(actual, ShouldContainSubstring, "this server")
+			So(actual, ShouldNotContainSubstring, "expired")
+
+			mdb.leases[0].Holder = "host:2"
+			mdb.leases[0].Expires = now.Add(-time.Minute)
+
+			actual = testEndpoint(s, "GET", "/status", nil)
+			So(actual, ShouldContainSubstring, "host:2")
+			So(actual, ShouldContainSubstring, "expired")
+			So(actual, ShouldNotContainSubstring, "this server")
+		})
+	})
+}
+
+func TestServerSnooze(t *testing.T) {
+	Convey("Given a registered thing that its creator has been warned about", t, func() {
+		mdb := newMockDB()
+		mdb.users = []database.User{{ID: 1, Name: "user"}, {ID: 2, Name: "other"}}
+
+		secondWarning := 24 * time.Hour
+
+		s, err := New(withAuth(Config{
+			HTTPLogger:    gas.NewStringLogger(),
+			Database:      mdb,
+			SecondWarning: secondWarning,
+		}))
+		So(err, ShouldBeNil)
+
+		remove := time.Now().AddDate(0, 0, 60).Format(time.DateOnly)
+		form := "Address=/a&Type=irods&Reason=r&Remove=" + remove
+		So(recordRequestAs(s, "user", "POST", "/things", strings.NewReader(form)).Code, ShouldEqual, http.StatusOK)
+
+		So(mdb.SetWarned(0, null.TimeFrom(time.Now()), null.Time{}), ShouldBeNil)
+
+		snooze := func(username, form string) *httptest.ResponseRecorder {
+			return recordRequestAs(s, username, "POST", "/things/0/snooze", strings.NewReader(form))
+		}
+
+		Convey("its subscriber can snooze warnings for a week, which is audited but doesn't change the thing", func() {
+			recorder := snooze("user", "")
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Body.String(), ShouldContainSubstring, "Snooze")
+
+			until := time.Now().AddDate(0, 0, database.DefaultSnoozeDays)
+
+			So(len(mdb.snoozes), ShouldEqual, 1)
+			So(mdb.snoozes[0].ThingID, ShouldEqual, 0)
+			So(mdb.snoozes[0].User.Name, ShouldEqual, "user")
+			So(mdb.snoozes[0].Until, ShouldHappenWithin, time.Minute, until)
+			So(mdb.things[0].Remove.Format(time.DateOnly), ShouldEqual, remove)
+			So(mdb.things[0].Warned1.Valid, ShouldBeTrue)
+
+			So(len(mdb.audit), ShouldEqual, 1)
+			So(mdb.audit[0].Action, ShouldEqual, database.AuditSnoozed)
+			So(mdb.audit[0].Detail, ShouldEqual, "warnings snoozed by user until "+until.Format(time.DateOnly))
+
+			Convey("or for a given number of days, replacing their snooze", func() {
+				So(snooze("user", "Days=30").Code, ShouldEqual, http.StatusOK)
+				So(len(mdb.snoozes), ShouldEqual, 1)
+				So(mdb.snoozes[0].Until, ShouldHappenWithin, time.Minute, time.Now().AddDate(0, 0, 30))
+			})
+		})
+
+		Convey("snoozes end by the start of the second warning period", func() {
+			So(snooze("user", "Days=100").Code, ShouldEqual, http.StatusOK)
+			So(len(mdb.snoozes), ShouldEqual, 1)
+			So(mdb.snoozes[0].Until, ShouldEqual, mdb.things[0].Remove.Add(-secondWarning))
+
+			mdb.things[0].Remove = time.Now().Add(secondWarning)
+			recorder := snooze("user", "")
+			So(recorder.Code, ShouldEqual, http.StatusBadRequest)
+			So(recorder.Body.String(), ShouldContainSubstring, ErrSnoozeTooLate.Error())
+		})
+
+		Convey("non-subscribers can't snooze warnings, even if they say they're a subscriber", func() {
+			So(snooze("other", "").Code, ShouldEqual, http.StatusForbidden)
+			So(snooze("other", "Creator=user").Code, ShouldEqual, http.StatusForbidden)
+			So(recordRequest(s, "POST", "/things/0/snooze", nil).Code, ShouldEqual, http.StatusUnauthorized)
+			So(mdb.snoozes, ShouldBeEmpty)
+		})
+
+		Convey("snoozes can't be negative, or be of removed things", func() {
+			So(snooze("user", "Days=-1").Code, ShouldEqual, http.StatusBadRequest)
+
+			So(mdb.MarkRemoved(0), ShouldBeNil)
+			So(snooze("user", "").Code, ShouldEqual, http.StatusBadRequest)
+			So(mdb.snoozes, ShouldBeEmpty)
+		})
+
+		Convey("the Snooze button is only shown once warned", func() {
+			So(mdb.SetWarned(0, null.Time{}, null.Time{}), ShouldBeNil)
+			So(testEndpoint(s, "GET", "/things/0", nil), ShouldNotContainSubstring, "/snooze")
+		})
+	})
+}
+
+func TestServerLinks(t *testing.T) {
+	Convey("Given a server with a links Signer, and a registered thing", t, func() {
+		mdb := newMockDB()
+		mdb.users = []database.User{{ID: 1, Name: "user"}, {ID: 2, Name: "other"}}
+
+		signer, err := links.NewSigner([]byte(strings.Repeat("k", links.MinKeyLength)), "https://tt")
+		So(err, ShouldBeNil)
+
+		s, err := New(withAuth(Config{
+			HTTPLogger: gas.NewStringLogger(),
+			Database:   mdb,
+			Links:      signer,
+		}))
+		So(err, ShouldBeNil)
+
+		remove := time.Now().AddDate(0, 0, 10)
+		form := "Address=/a&Type=irods&Reason=r&Remove=" + remove.Format(time.DateOnly)
+		So(recordRequestAs(s, "user", "POST", "/things", strings.NewReader(form)).Code, ShouldEqual, http.StatusOK)
+
+		expires := time.Now().Add(time.Hour)
+
+		follow := func(action links.Action, userID uint32, expires time.Time) *httptest.ResponseRecorder {
+			url, errs := signer.URL(action, 0, userID, expires)
+			So(errs, ShouldBeNil)
+
+			return recordRequest(s, "POST", strings.TrimPrefix(url, "https://tt"), nil)
+		}
+
+		Convey("getting links just asks if you want to take their action", func() {
+			url, err := signer.URL(links.Extend, 0, 1, expires)
+			So(err, ShouldBeNil)
+
+			path := strings.TrimPrefix(url, "https://tt")
+			extended := mdb.things[0].Remove.AddDate(0, 0, links.ExtendDays).Format(time.DateOnly)
+
+			recorder := recordRequest(s, "GET", path, nil)
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Body.String(), ShouldContainSubstring, "Extend the removal date of /a by 30 days, to "+extended+"?")
+			So(recorder.Body.String(), ShouldContainSubstring, `<form method="post" action="`+path+`">`)
+			So(recordRequest(s, "GET", path, nil).Code, ShouldEqual, http.StatusOK)
+			So(mdb.things[0].Remove.Format(time.DateOnly), ShouldEqual, remove.Format(time.DateOnly))
+			So(mdb.audit, ShouldBeEmpty)
+			So(mdb.used, ShouldBeEmpty)
+
+			url, err = signer.URL(links.Unsubscribe, 0, 1, expires)
+			So(err, ShouldBeNil)
+			So(testEndpoint(s, "GET", strings.TrimPrefix(url, "https://tt"), nil), ShouldContainSubstring,
+				"Stop being told about /a?")
+
+			url, err = signer.URL(links.ConfirmRemoval, 0, 1, expires)
+			So(err, ShouldBeNil)
+			So(testEndpoint(s, "GET", strings.TrimPrefix(url, "https://tt"), nil), ShouldContainSubstring,
+				"Confirm that /a can be removed on "+remove.Format(time.DateOnly))
+
+			subscribers, err := mdb.GetSubscribers(0)
+			So(err, ShouldBeNil)
+			So(len(subscribers), ShouldEqual, 1)
+			So(mdb.snoozes, ShouldBeEmpty)
+
+			So(testEndpointCode(s, "GET", links.Path+"extend.0.1.9999999999.id.sig", nil), ShouldEqual,
+				http.StatusBadRequest)
+		})
+
+		Convey("extend links extend the removal date by 30 days, once", func() {
+			url, err := signer.URL(links.Extend, 0, 1, expires)
+			So(err, ShouldBeNil)
+
+			path := strings.TrimPrefix(url, "https://tt")
+			extended := mdb.things[0].Remove.AddDate(0, 0, links.ExtendDays).Format(time.DateOnly)
+
+			recorder := recordRequest(s, "POST", path, nil)
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Body.String(), ShouldContainSubstring, "/a will now be removed on "+extended)
+			So(mdb.things[0].Remove.Format(time.DateOnly), ShouldEqual, extended)
+
+			So(len(mdb.audit), ShouldEqual, 1)
+			So(mdb.audit[0].Action, ShouldEqual, database.AuditExtended)
+			So(mdb.audit[0].Actor, ShouldEqual, "user")
+
+			recorder = recordRequest(s, "POST", path, nil)
+			So(recorder.Code, ShouldEqual, http.StatusGone)
+			So(recorder.Body.String(), ShouldContainSubstring, database.ErrLinkUsed.Error())
+			So(mdb.things[0].Remove.Format(time.DateOnly), ShouldEqual, extended)
+		})
+
+		Convey("links aren't used up if their action fails", func() {
+			s.policies = policy.Policies{{MaxDays: 20}}
+
+			So(follow(links.Extend, 1, expires).Code, ShouldEqual, http.StatusBadRequest)
+			So(mdb.used, ShouldBeEmpty)
+			So(mdb.audit, ShouldBeEmpty)
+
+			s.policies = nil
+
+			So(follow(links.Extend, 1, expires).Code, ShouldEqual, http.StatusOK)
+			So(len(mdb.used), ShouldEqual, 1)
+			So(len(mdb.audit), ShouldEqual, 1)
+		})
+
+		Convey("unsubscribe links unsubscribe their user", func() {
+			recorder := follow(links.Unsubscribe, 1, expires)
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Body.String(), ShouldContainSubstring, "You will no longer be told about /a")
+
+			subscribers, err := mdb.GetSubscribers(0)
+			So(err, ShouldBeNil)
+			So(subscribers, ShouldBeEmpty)
+
+			So(mdb.audit[0].Action, ShouldEqual, database.AuditUnsubscribed)
+			So(mdb.audit[0].Detail, ShouldEqual, "user unsubscribed")
+
+			So(follow(links.Extend, 1, expires).Code, ShouldEqual, http.StatusForbidden)
+		})
+
+		Convey("snooze links snooze warnings to their user, ending by the second warning period", func() {
+			url, err := signer.URL(links.Snooze, 0, 1, expires)
+			So(err, ShouldBeNil)
+			So(testEndpoint(s, "GET", strings.TrimPrefix(url, "https://tt"), nil), ShouldContainSubstring,
+				"Stop being warned about /a until")
+
+			recorder := follow(links.Snooze, 1, expires)
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Body.String(), ShouldContainSubstring, "be warned about /a again until")
+
+			So(len(mdb.snoozes), ShouldEqual, 1)
+			So(mdb.snoozes[0].User.ID, ShouldEqual, 1)
+			So(mdb.snoozes[0].Until, ShouldEqual, mdb.things[0].Remove.Add(-3*24*time.Hour))
+			So(mdb.audit[0].Action, ShouldEqual, database.AuditSnoozed)
+			So(mdb.audit[0].Actor, ShouldEqual, "user")
+
+			mdb.things[0].Remove = time.Now().Add(24 * time.Hour)
+
+			recorder = follow(links.Snooze, 1, expires)
+			So(recorder.Code, ShouldEqual, http.StatusBadRequest)
+			So(recorder.Body.String(), ShouldContainSubstring, "within its second warning period")
+			So(len(mdb.used), ShouldEqual, 1)
+		})
+
+		Convey("confirm removal links record their user's confirmation of the scheduled removal date", func() {
+			recorder := follow(links.ConfirmRemoval, 1, expires)
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Body.String(), ShouldContainSubstring, "can be removed on "+remove.Format(time.DateOnly))
+
+			So(mdb.snoozes, ShouldBeEmpty)
+			So(len(mdb.confirms), ShouldEqual, 1)
+			So(mdb.confirms[0].ThingID, ShouldEqual, 0)
+			So(mdb.confirms[0].User.ID, ShouldEqual, 1)
+			So(mdb.confirms[0].Covers(mdb.things[0].Remove), ShouldBeTrue)
+
+			So(mdb.audit[0].Action, ShouldEqual, database.AuditConfirmed)
+			So(mdb.audit[0].Detail, ShouldEqual, "removal on "+remove.Format(time.DateOnly)+" confirmed by user")
+
+			Convey("which is after any grace period", func() {
+				s.schedule = &policy.Schedule{Grace: 48 * time.Hour}
+				scheduled := mdb.things[0].Remove.Add(48 * time.Hour)
+
+				url, err := signer.URL(links.ConfirmRemoval, 0, 1, expires.Add(time.Second))
+				So(err, ShouldBeNil)
+
+				recorder = recordRequest(s, "POST", strings.TrimPrefix(url, "https://tt"), nil)
+				So(recorder.Code, ShouldEqual, http.StatusOK)
+				So(recorder.Body.String(), ShouldContainSubstring, "can be removed on "+scheduled.Format(time.DateOnly))
+				So(len(mdb.confirms), ShouldEqual, 1)
+				So(mdb.confirms[0].Remove, ShouldEqual, scheduled)
+			})
+		})
+
+		Convey("expired, forged, other users' and removed things' links don't work", func() {
+			recorder := follow(links.Extend, 1, time.Now().Add(-time.Second))
+			So(recorder.Code, ShouldEqual, http.StatusGone)
+			So(recorder.Body.String(), ShouldContainSubstring, links.ErrLinkExpired.Error())
+
+			So(testEndpointCode(s, "GET", links.Path+"extend.0.1.9999999999.id.sig", nil), ShouldEqual,
+				http.StatusBadRequest)
+			So(follow(links.Extend, 2, expires).Code, ShouldEqual, http.StatusForbidden)
+
+			So(mdb.MarkRemoved(0), ShouldBeNil)
+			So(follow(links.Extend, 1, expires).Code, ShouldEqual, http.StatusBadRequest)
+
+			So(mdb.audit, ShouldBeEmpty)
+		})
+
+		Convey("links don't work without a Signer", func() {
+			url, err := signer.URL(links.Extend, 0, 1, expires)
+			So(err, ShouldBeNil)
+
+			s, err = New(withAuth(Config{HTTPLogger: gas.NewStringLogger(), Database: mdb}))
+			So(err, ShouldBeNil)
+
+			recorder := recordRequest(s, "GET", strings.TrimPrefix(url, "https://tt"), nil)
+			So(recorder.Code, ShouldEqual, http.StatusNotFound)
+			So(recorder.Body.String(), ShouldContainSubstring, ErrNoLinks.Error())
+			So(recordRequest(s, "POST", strings.TrimPrefix(url, "https://tt"), nil).Code, ShouldEqual,
+				http.StatusNotFound)
+		})
+	})
 }
@@ -0,0 +1,148 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package server
+
+import (
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wtsi-hgi/tt/database"
+)
+
+// postSnooze posts to /things/id/snooze, optionally with a number of Days
+// (default database.DefaultSnoozeDays), stopping the logged in user being
+// warned about the upcoming removal of the Thing with that id until then,
+// without changing its removal date, and returns its table row. If a warning is
+// held back, they'll be reminded of it when the snooze ends.
+//
+// Snoozes end by the start of the second warning period before the Thing's
+// scheduled removal, however many Days are asked for, so that nobody sleeps
+// through its removal.
+//
+// Only subscribers of the Thing may snooze warnings about it; others get
+// http.StatusForbidden. Responds with http.StatusBadRequest if the Thing has
+// been removed, or is already within its second warning period.
+//
+// The snooze is recorded in the Thing's history.
+func (s *Server) postSnooze(c *gin.Context) {
+	thing, ok := s.thingFromParam(c)
+	if !ok {
+		return
+	}
+
+	params := database.SnoozeParams{Days: database.DefaultSnoozeDays}
+
+	if err := c.ShouldBind(&params); err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+
+		return
+	}
+
+	if thing.Removed {
+		c.AbortWithError(http.StatusBadRequest, database.ErrThingRemoved)
+
+		return
+	}
+
+	user, ok := s.subscriberFromRequest(c, thing)
+	if !ok {
+		return
+	}
+
+	if _, code, err := s.snooze(thing, user, params.Days); err != nil {
+		c.AbortWithError(code, err)
+
+		return
+	}
+
+	c.HTML(http.StatusOK, "templates/thing.html", thing)
+}
+
+// snoozeUntil returns when a snooze of warnings about the thing for the given
+// number of days from now would end: no later than the start of the second
+// warning period before its scheduled removal.
+func (s *Server) snoozeUntil(thing *database.Thing, days int) time.Time {
+	until := time.Now().AddDate(0, 0, days)
+
+	if latest := s.schedule.Scheduled(thing).Add(-s.secondWarning); until.After(latest) {
+		return latest
+	}
+
+	return until
+}
+
+// snooze stops the user being warned about the thing for the given number of
+// days (see snoozeUntil()), recording that in its history as done by them, and
+// returns when the snooze ends. Otherwise returns the error and the http status
+// code to respond with, which is http.StatusBadRequest with ErrSnoozeTooLate if
+// the thing is already within its second warning period.
+func (s *Server) snooze(thing *database.Thing, user *database.User, days int) (time.Time, int, error) {
+	until := s.snoozeUntil(thing, days)
+
+	if !until.After(time.Now()) {
+		return time.Time{}, http.StatusBadRequest, ErrSnoozeTooLate
+	}
+
+	if err := s.db.SetSnooze(thing.ID, user.ID, until); err != nil {
+		return time.Time{}, http.StatusInternalServerError, err
+	}
+
+	if err := s.db.AddAuditEvent(database.AuditEvent{
+		ThingID: thing.ID,
+		Actor:   user.Name,
+		Action:  database.AuditSnoozed,
+		Detail:  "warnings snoozed by " + user.Name + " until " + until.Format(time.DateOnly),
+	}); err != nil {
+		return time.Time{}, http.StatusInternalServerError, err
+	}
+
+	return until, http.StatusOK, nil
+}
+
+// subscriberFromRequest returns the logged in user making the request,
+// aborting the request and returning false if they aren't subscribed to the
+// thing.
+func (s *Server) subscriberFromRequest(c *gin.Context, thing *database.Thing) (*database.User, bool) {
+	username := s.username(c)
+
+	subscribers, err := s.db.GetSubscribers(thing.ID)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+
+		return nil, false
+	}
+
+	i := slices.IndexFunc(subscribers, func(user database.User) bool { return user.Name == username })
+	if username == "" || i == -1 {
+		c.AbortWithError(http.StatusForbidden, ErrNotSubscriber)
+
+		return nil, false
+	}
+
+	return &subscribers[i], true
+}
@@ -29,11 +29,17 @@ package server
 
 import (
 	"bytes"
+	"context"
+	"time"
 
 	"github.com/wtsi-hgi/tt/database"
 )
 
-const sseThingsEventName = "thingsSSE"
+const (
+	sseThingsEventName = "thingsSSE"
+
+	DefaultProgressInterval = 2 * time.Second
+)
 
 // broadcastNewThing returns an error if there's an issue rendering the given
 // thing via the thing.html template. Otherwise, in a goroutine, sends the Thing
@@ -50,3 +56,79 @@ func (s *Server) broadcastNewThing(thing *database.Thing) error {
 	s.Logger.Printf("broadcastNewThing called, got err %s sending %s", err, renderedOutput.String())
 	return err
 }
+
+// WatchProgress checks the progress of running removal jobs every interval,
+// until ctx is done, sending the progress bars of those that have changed to
+// all SSE listeners, along with the final state of those that have stopped
+// running. The bars are sent as out-of-band swaps, so that htmx puts them in
+// the right rows.
+//
+// Because progress is read from the database, this shows the progress of jobs
+// run by any server sharing it.
+func (s *Server) WatchProgress(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := make(map[uint32]database.RemovalProgress)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			last = s.broadcastProgress(last)
+		}
+	}
+}
+
+// broadcastProgress broadcasts the progress of each running removal job that
+// isn't in last or has a different progress there, and of each job in last
+// that is no longer running. Returns the progress of the running jobs.
+func (s *Server) broadcastProgress(last map[uint32]database.RemovalProgress) map[uint32]database.RemovalProgress {
+	jobs, err := s.db.GetRemovalJobs(database.JobRunning)
+	if err != nil {
+		s.Logger.Printf("getting running removal jobs failed: %s", err)
+
+		return last
+	}
+
+	current := make(map[uint32]database.RemovalProgress, len(jobs))
+
+	for _, job := range jobs {
+		current[job.ThingID] = job.Progress
+
+		if progress, ok := last[job.ThingID]; !ok || progress != job.Progress {
+			s.broadcastThingProgress(job.ThingID)
+		}
+	}
+
+	for id := range last {
+		if _, ok := current[id]; !ok {
+			s.broadcastThingProgress(id)
+		}
+	}
+
+	return current
+}
+
+// broadcastThingProgress sends the progress bar of the Thing with the given ID
+// to all SSE listeners.
+func (s *Server) broadcastThingProgress(id uint32) {
+	thing, err := s.db.GetThing(id)
+	if err != nil {
+		s.Logger.Printf("getting thing %d failed: %s", id, err)
+
+		return
+	}
+
+	var renderedOutput bytes.Buffer
+
+	err = s.rootTemplate.ExecuteTemplate(&renderedOutput, "templates/progress.html", []any{thing, true})
+	if err == nil {
+		err = s.SSEBroadcast(sseThingsEventName, renderedOutput.String())
+	}
+
+	if err != nil {
+		s.Logger.Printf("broadcasting progress of thing %d failed: %s", id, err)
+	}
+}